@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/xaaha/hulak/pkg/utils"
 )
@@ -17,7 +18,9 @@ import (
 // Cache structure to store both results and handle warnings
 type valueCache struct {
 	result any
-	exists bool
+	// until is when a result from a ResponseSource stops being good, e.g.
+	// when its token expires. Zero keeps the result for the whole run.
+	until time.Time
 }
 
 // Global cache map with thread-safe access
@@ -27,42 +30,88 @@ var (
 
 	// Add file operation mutex
 	fileOpsMutex sync.Map
+
+	// lookupMutex holds one mutex per getValueOf file name, so a file is
+	// looked up, and its token renewed, once at a time.
+	lookupMutex sync.Map
 )
 
+// ResponseSource can supply the response getValueOf reads for a request
+// file in place of its _response.json, e.g. a cached OAuth token that is
+// refreshed once it expires. until is when the response stops being good,
+// zero if never. ok is false to read the file as usual.
+type ResponseSource func(requestFile string) (response any, until time.Time, ok bool)
+
+// WithResponseSource returns a copy of secretsMap that hands source to
+// getValueOf in the templates rendered with it. A nil source returns
+// secretsMap as is.
+func WithResponseSource(secretsMap map[string]any, source ResponseSource) map[string]any {
+	if source == nil {
+		return secretsMap
+	}
+	withSource := utils.CopyEnvMap(secretsMap)
+	withSource[utils.ResponseSourceKey] = source
+	return withSource
+}
+
+// GetValueOfWith returns the getValueOf of templates rendered with
+// secretsMap: GetValueOf, asking the ResponseSource in secretsMap, if any,
+// before reading a _response.json.
+func GetValueOfWith(secretsMap map[string]any) func(key, fileName string) any {
+	source, _ := secretsMap[utils.ResponseSourceKey].(ResponseSource)
+	return func(key, fileName string) any {
+		return getValueOf(source, key, fileName)
+	}
+}
+
 // GetValueOf gets the value of key from a json file with caching
 func GetValueOf(key, fileName string) any {
+	return getValueOf(nil, key, fileName)
+}
+
+func getValueOf(source ResponseSource, key, fileName string) any {
 	// Create cache key combining file and key
 	cacheKey := fmt.Sprintf("%s:%s", fileName, key)
 
 	// Check cache first
-	valuesCacheMutex.RLock()
-	if cache, exists := valuesCache[cacheKey]; exists {
-		valuesCacheMutex.RUnlock()
-		return cache.result
+	if result, ok := cachedValue(cacheKey); ok {
+		return result
 	}
-	valuesCacheMutex.RUnlock()
 
-	// If not in cache, acquire write lock and process
-	valuesCacheMutex.Lock()
-	defer valuesCacheMutex.Unlock()
+	// Look the file up once at a time. A token renewal, which may wait for
+	// a browser sign-in, then runs once while lookups of the same file wait
+	// for its result; lookups of other files and cache hits go on.
+	mutex, _ := lookupMutex.LoadOrStore(fileName, &sync.Mutex{})
+	mutex.(*sync.Mutex).Lock()
+	defer mutex.(*sync.Mutex).Unlock()
 
 	// Double-check pattern in case another goroutine cached while we waited
-	if cache, exists := valuesCache[cacheKey]; exists {
-		return cache.result
+	if result, ok := cachedValue(cacheKey); ok {
+		return result
 	}
 
 	// Process the file and get result
-	result := processValueOf(key, fileName)
+	result, until := processValueOf(source, key, fileName)
 
 	// Cache the result
-	valuesCache[cacheKey] = valueCache{
-		result: result,
-		exists: true,
-	}
+	valuesCacheMutex.Lock()
+	valuesCache[cacheKey] = valueCache{result: result, until: until}
+	valuesCacheMutex.Unlock()
 
 	return result
 }
 
+// cachedValue returns the cached result of cacheKey unless it has expired.
+func cachedValue(cacheKey string) (any, bool) {
+	valuesCacheMutex.RLock()
+	defer valuesCacheMutex.RUnlock()
+	cache, exists := valuesCache[cacheKey]
+	if !exists || (!cache.until.IsZero() && !time.Now().Before(cache.until)) {
+		return nil, false
+	}
+	return cache.result, true
+}
+
 // BasicAuth takes a username and password, joins them with a colon,
 // base64-encodes the result, and returns the full header value "Basic <encoded>".
 // Both arguments are treated as plain strings — use .env template vars for secrets.
@@ -99,8 +148,9 @@ func getFileMutex(filePath string) *sync.Mutex {
 // the resolved JSON file, or "" if anything goes wrong. Errors are printed to
 // stderr; we can't return them because this is invoked as a template function
 // whose signature is fixed at func(...) any. Stdout stays clean so any
-// downstream `$(...)` capture still gets clean program output.
-func processValueOf(key, fileName string) any {
+// downstream `$(...)` capture still gets clean program output. until is
+// when a response from source stops being good.
+func processValueOf(source ResponseSource, key, fileName string) (result any, until time.Time) {
 	// Validate inputs
 	if key == "" || fileName == "" {
 		if key == "" {
@@ -115,22 +165,25 @@ func processValueOf(key, fileName string) any {
 				),
 			)
 		}
-		return ""
+		return "", time.Time{}
 	}
 
 	jsonResFilePath, err := resolveJSONFilePath(fileName)
 	if err != nil {
 		utils.PrintErrorStderr(err.Error())
-		return ""
+		return "", time.Time{}
 	}
 
-	content, err := readJSONFile(jsonResFilePath)
-	if err != nil {
-		utils.PrintErrorStderr(err.Error())
-		return ""
+	content, until, ok := responseFromSource(source, fileName, jsonResFilePath)
+	if !ok {
+		content, err = readJSONFile(jsonResFilePath)
+		if err != nil {
+			utils.PrintErrorStderr(err.Error())
+			return "", time.Time{}
+		}
 	}
 
-	result, err := extractValueByKey(key, content)
+	result, err = ExtractValueByKey(key, content)
 	if err != nil {
 		utils.PrintErrorStderr(fmt.Sprintf(
 			"looking up value '%s': make sure '%s' exists and has key '%s'",
@@ -142,10 +195,10 @@ func processValueOf(key, fileName string) any {
 			),
 			key,
 		))
-		return ""
+		return "", time.Time{}
 	}
 
-	return result
+	return result, until
 }
 
 // resolveJSONFilePath determines the correct JSON file path based on the input fileName
@@ -207,6 +260,22 @@ func resolveJSONFilePath(fileName string) (string, error) {
 	return filepath.Join(dirPath, jsonBaseName), nil
 }
 
+// responseFromSource asks source for the response of the request file
+// behind jsonResFilePath. A fileName naming a .json file directly has no
+// request file.
+func responseFromSource(source ResponseSource, fileName, jsonResFilePath string) (any, time.Time, bool) {
+	if source == nil || strings.HasSuffix(fileName, utils.JSON) {
+		return nil, time.Time{}, false
+	}
+	stem := strings.TrimSuffix(jsonResFilePath, utils.ResponseFileName)
+	for _, ext := range []string{utils.YAML, utils.YML} {
+		if utils.FileExists(stem + ext) {
+			return source(stem + ext)
+		}
+	}
+	return nil, time.Time{}, false
+}
+
 // readJSONFile reads and parses a JSON file with proper locking
 func readJSONFile(filePath string) (any, error) {
 	// Get file-specific mutex
@@ -243,8 +312,11 @@ func readJSONFile(filePath string) (any, error) {
 	return content, nil
 }
 
-// extractValueByKey extracts a value from JSON content using the provided key
-func extractValueByKey(key string, content any) (any, error) {
+// ExtractValueByKey extracts a value from decoded JSON content using the
+// dot/bracket key syntax getValueOf understands ("user.name", "items[0].id",
+// "{key.with.dots}"). An array root must be addressed with "[index]...".
+// Exported so response assertions resolve paths exactly like getValueOf.
+func ExtractValueByKey(key string, content any) (any, error) {
 	var result any
 	var err error
 
@@ -2,10 +2,14 @@ package actions
 
 import (
 	"encoding/base64"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/xaaha/hulak/pkg/utils"
 )
@@ -156,7 +160,7 @@ func Test_processValueOf(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := processValueOf(tt.key, tt.fileName)
+			got, _ := processValueOf(nil, tt.key, tt.fileName)
 
 			// Compare results
 			if got != tt.want {
@@ -166,6 +170,87 @@ func Test_processValueOf(t *testing.T) {
 	}
 }
 
+func Test_processValueOf_ResponseSource(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestFile := filepath.Join(tmpDir, "login.hk.yaml")
+	responseFile := filepath.Join(tmpDir, "login.hk"+utils.ResponseFileName)
+	if err := os.WriteFile(requestFile, []byte("kind: Auth\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(responseFile, []byte(`{"access_token": "saved"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		fileName string
+		fresh    bool
+		want     any
+	}{
+		{name: "source supplies the response", fileName: requestFile, fresh: true, want: "fresh"},
+		{name: "source declines", fileName: requestFile, want: "saved"},
+		{name: "json file read directly", fileName: responseFile, fresh: true, want: "saved"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var asked string
+			source := func(path string) (any, time.Time, bool) {
+				asked = path
+				return map[string]any{"access_token": "fresh"}, time.Time{}, tt.fresh
+			}
+			if got, _ := processValueOf(source, "access_token", tt.fileName); got != tt.want {
+				t.Errorf("processValueOf() = %v, want %v", got, tt.want)
+			}
+			if asked != "" && asked != requestFile {
+				t.Errorf("ResponseSource asked for %q, want %q", asked, requestFile)
+			}
+		})
+	}
+}
+
+// TestGetValueOfWith_Expiry verifies a response from the run's source is
+// cached only until it stops being good, and that concurrent lookups of a
+// file ask the source once.
+func TestGetValueOfWith_Expiry(t *testing.T) {
+	tmpDir := t.TempDir()
+	requestFile := filepath.Join(tmpDir, "renew.hk.yaml")
+	if err := os.WriteFile(requestFile, []byte("kind: Auth\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int64
+	until := time.Now().Add(time.Hour)
+	var source ResponseSource = func(string) (any, time.Time, bool) {
+		n := calls.Add(1)
+		time.Sleep(10 * time.Millisecond) // a slow renewal
+		return map[string]any{"access_token": fmt.Sprintf("tok-%d", n)}, until, true
+	}
+	getValueOf := GetValueOfWith(WithResponseSource(nil, source))
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			if got := getValueOf("access_token", requestFile); got != "tok-1" {
+				t.Errorf("getValueOf() = %v, want tok-1", got)
+			}
+		})
+	}
+	wg.Wait()
+	if calls.Load() != 1 {
+		t.Fatalf("source called %d times, want once", calls.Load())
+	}
+
+	// Expire the cached token: the next lookup asks the source again.
+	valuesCacheMutex.Lock()
+	entry := valuesCache[requestFile+":access_token"]
+	entry.until = time.Now().Add(-time.Second)
+	valuesCache[requestFile+":access_token"] = entry
+	valuesCacheMutex.Unlock()
+	if got := getValueOf("access_token", requestFile); got != "tok-2" {
+		t.Errorf("getValueOf() after expiry = %v, want tok-2", got)
+	}
+}
+
 func TestBasicAuth(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -5,9 +5,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/xaaha/hulak/pkg/httpclient"
@@ -19,6 +20,25 @@ import (
 // Uses httpclient.New() for shared redirect policy and TLS defaults.
 var DefaultClient httpclient.HTTPClient = httpclient.New()
 
+// optionClients holds a client per set of options. yamlparser shares one
+// TLS config and one proxy between files with the same settings, so they
+// share connections too; a run shares one cookie jar.
+var optionClients sync.Map
+
+// clientFor returns the client for a request with the options opts. The
+// DefaultClient is swapped for one built with opts; any other client, e.g.
+// a test mock, is used as is.
+func clientFor(client httpclient.HTTPClient, opts httpclient.Options) httpclient.HTTPClient {
+	if opts == (httpclient.Options{}) || client != DefaultClient {
+		return client
+	}
+	if c, ok := optionClients.Load(opts); ok {
+		return c.(httpclient.HTTPClient)
+	}
+	c, _ := optionClients.LoadOrStore(opts, httpclient.NewWithOptions(opts))
+	return c.(httpclient.HTTPClient)
+}
+
 // StandardCall calls the api and returns the json body string
 // Uses the DefaultClient for HTTP calls
 func StandardCall(
@@ -36,36 +56,69 @@ func StandardCallWithClient(
 	apiInfo yamlparser.APIInfo,
 	debug bool,
 	client httpclient.HTTPClient,
+) (CustomResponse, error) {
+	return sendRequest(ctx, apiInfo, debug, client, readOptions{})
+}
+
+// readOptions say how sendRequest reads a response body beyond buffering
+// it whole, which is what the zero value does.
+type readOptions struct {
+	// events reads event streams (SSE, chunked NDJSON) as they arrive.
+	events *eventReader
+	// stream writes large bodies to disk as they arrive; see streamTarget.
+	stream *streamTarget
+}
+
+// sendRequest is StandardCallWithClient with control over how the body is
+// read. An event stream is read event by event into a transcript; a body
+// that qualifies for streaming (see streamTarget.receive) is written to
+// disk as it arrives instead of being read into memory.
+func sendRequest(
+	ctx context.Context,
+	apiInfo yamlparser.APIInfo,
+	debug bool,
+	client httpclient.HTTPClient,
+	read readOptions,
 ) (CustomResponse, error) {
 	if apiInfo.Headers == nil {
 		apiInfo.Headers = map[string]string{}
 	}
+	client = clientFor(client, httpclient.Options{TLS: apiInfo.TLS, Proxy: apiInfo.Proxy, Jar: apiInfo.Jar})
 	method := apiInfo.Method
 	urlStr := apiInfo.URL
-	bodyReader := apiInfo.Body
 
-	var bodyBytes []byte
-	var err error
-	if bodyReader != nil {
-		bodyBytes, err = io.ReadAll(bodyReader)
+	// The body is only buffered for --debug, which echoes it back, and for
+	// signing, which hashes it. Otherwise it streams, so uploading a large
+	// file doesn't load it into memory.
+	var reqBody []byte
+	newBodyReader := apiInfo.Body
+	if debug || newBodyReader == nil || apiInfo.Signing != nil {
+		bodyBytes, err := readBody(newBodyReader)
 		if err != nil {
 			return CustomResponse{}, err
 		}
-	} else {
-		bodyBytes = []byte{}
+		reqBody = bodyBytes
+		newBodyReader = bytes.NewReader(bodyBytes)
+	}
+	headers, err := apiInfo.Bearer.Apply(apiInfo.Headers)
+	if err != nil {
+		closeBody(newBodyReader)
+		return CustomResponse{}, err
 	}
-
-	newBodyReader := bytes.NewReader(bodyBytes)
-	headers := apiInfo.Headers
 	preparedURL := PrepareURL(urlStr, apiInfo.URLParams)
 
-	reqBodyForDebug := make([]byte, len(bodyBytes))
-	copy(reqBodyForDebug, bodyBytes)
-
+	ctx, redirects := httpclient.WithRedirects(ctx, apiInfo.Redirects)
+	ctx, trace := traceRequest(ctx)
 	req, err := http.NewRequestWithContext(ctx, method, preparedURL, newBodyReader)
 	if err != nil {
+		closeBody(newBodyReader)
 		return CustomResponse{}, fmt.Errorf("error occurred on '%s': %w", method, err)
 	}
+	// File uploads know their size; without it the request goes out chunked,
+	// which some upload endpoints reject.
+	if sized, ok := newBodyReader.(interface{ Size() int64 }); ok {
+		req.ContentLength = sized.Size()
+	}
 
 	if len(headers) > 0 {
 		for key, val := range headers {
@@ -75,62 +128,167 @@ func StandardCallWithClient(
 
 	start := time.Now()
 
-	response, err := client.Do(req)
+	response, req, err := doRequest(client, req, reqBody, apiInfo.Signing)
 	if err != nil {
 		return CustomResponse{}, err
 	}
 	end := time.Now()
 
 	duration := end.Sub(start)
+	response.Body = trace.body(response.Body)
 
-	return processResponse(req, response, duration, debug, reqBodyForDebug)
+	// An event stream that ends in an error still has its response.
+	resp, err := readResponse(req, response, duration, debug, reqBody, read)
+	resp.Redirects = redirects.Hops()
+	resp.Timings = trace.result()
+	return resp, err
+}
+
+// readResponse reads the response to req as read says: as an event
+// stream, streamed to disk, or buffered.
+func readResponse(
+	req *http.Request,
+	response *http.Response,
+	duration time.Duration,
+	debug bool,
+	reqBody []byte,
+	read readOptions,
+) (CustomResponse, error) {
+	if read.events != nil && read.events.handles(response) {
+		return readEvents(req, response, duration, debug, reqBody, read.events)
+	}
+	if read.stream != nil {
+		streamed, err := read.stream.receive(response)
+		if err != nil {
+			return CustomResponse{}, err
+		}
+		if streamed != nil {
+			return streamedResponse(response, duration, streamed), nil
+		}
+	}
+	return processResponse(req, response, duration, debug, reqBody)
 }
 
 // SendAndSaveAPIRequest builds the API request from the file at opts.Path,
 // then either executes it or (when opts.DryRun) prints the built request.
 //
-// Returns the HTTP status string (e.g. "200 OK") so the runner can render
-// a per-file outcome line. On pre-flight failures (config parse, request
-// build) the status is empty. On transport failures (network down, DNS) the
-// status is also empty — the err carries the detail.
+// The result carries the HTTP status string (e.g. "200 OK") so the runner can
+// render a per-file outcome line. On pre-flight failures (config parse,
+// request build) the status is empty. On transport failures (network down,
+// DNS) the status is also empty — the err carries the detail.
+//
+// When the file has an assert section, a failed check is returned as the
+// error alongside the response bytes and status, so the caller can still
+// print the response while marking the request as failed. A capture that
+// can't be resolved fails the request the same way.
+//
+// A retry section (or opts.Retries) resends the request on retryable
+// failures; result.Attempts reports how many tries were made. A poll
+// section re-sends it until the response meets the poll condition, and
+// Attempts then counts every request sent.
+//
+// A GraphQL subscription runs over graphql-transport-ws instead of an HTTP
+// request, and its results are read like an event stream; see
+// subscribeEvents.
 //
 // When opts.DryRun is true, the request is built and printed to stdout but
 // never sent. No response file is written. opts.Show controls whether
 // sensitive headers are revealed in the printed output.
-func SendAndSaveAPIRequest(ctx context.Context, opts RequestOptions) ([]byte, string, error) {
+func SendAndSaveAPIRequest(ctx context.Context, opts RequestOptions) (RequestResult, error) {
 	apiConfig, _, err := yamlparser.FinalStructForAPI(opts.Path, opts.Secrets)
 	if err != nil {
-		return nil, "", err
+		return RequestResult{}, err
 	}
 
 	apiInfo, err := apiConfig.PrepareStruct()
 	if err != nil {
-		return nil, "", err
+		return RequestResult{}, err
+	}
+	if apiConfig.UsesJar(opts.Cookies) {
+		apiInfo.Jar = opts.Jar
 	}
 
 	if opts.DryRun {
 		if err := PrintDryRun(&apiInfo, opts.Show); err != nil {
-			return nil, "", err
+			return RequestResult{}, err
 		}
-		return nil, "", nil
+		return RequestResult{}, nil
 	}
 
-	resp, err := StandardCall(ctx, apiInfo, opts.Debug)
+	// A file's retry section wins over --retries, same as its timeout wins
+	// over --timeout.
+	retry := apiConfig.Retry
+	if retry == nil && opts.Retries > 0 {
+		retry = &yamlparser.Retry{Attempts: opts.Retries + 1}
+	}
+	policy, err := retry.Policy()
 	if err != nil {
-		return nil, "", err
+		return RequestResult{}, err
 	}
 
-	status := ""
+	read := readOptions{
+		events: &eventReader{config: apiConfig.Events, live: opts.EventOut},
+		stream: newStreamTarget(&opts, &apiConfig),
+	}
+	send := func(ctx context.Context) (CustomResponse, int, error) {
+		return callWithRetry(ctx, apiInfo, opts.Debug, DefaultClient, &policy, read)
+	}
+	if apiConfig.Poll != nil && apiInfo.Body != nil {
+		// Every poll sends the same body, so read it once up front.
+		body, err := readBody(apiInfo.Body)
+		if err != nil {
+			return RequestResult{}, err
+		}
+		send = func(ctx context.Context) (CustomResponse, int, error) {
+			info := apiInfo
+			info.Body = bytes.NewReader(body)
+			return callWithRetry(ctx, info, opts.Debug, DefaultClient, &policy, read)
+		}
+	}
+	if apiConfig.Body != nil && apiConfig.Body.Graphql.IsSubscription() {
+		// A subscription runs once over a WebSocket; retry and streaming
+		// to disk don't apply, and the file can't also poll.
+		send = func(ctx context.Context) (CustomResponse, int, error) {
+			resp, err := subscribeEvents(ctx, apiInfo, opts.Debug, read.events)
+			return resp, 1, err
+		}
+	}
+	resp, attempts, err := pollUntil(ctx, apiConfig.Poll, opts.Debug, send)
+	if err != nil && resp.Response == nil {
+		return RequestResult{Attempts: attempts}, err
+	}
+
+	result := RequestResult{Attempts: attempts, Timings: resp.Timings}
 	if resp.Response != nil {
-		status = resp.Response.Status
+		result.Status = resp.Response.Status
+	}
+
+	// A poll that timed out still has a last response; it is saved like an
+	// assertion failure so the user can see the state it stopped at.
+	pollErr := err
+
+	// Assertion and capture failures don't stop the save, so a failing
+	// check still leaves the response on disk for the user to inspect.
+	assertErr := checkAssertions(apiConfig.Assert, &resp)
+	captured, captureErr := extractCaptures(apiConfig.Capture, &resp)
+	result.Captured = captured
+
+	if resp.streamed != nil {
+		// Already on disk (and echoed); only the summary is left to print.
+		result.Summary = streamSummary(&resp)
+		return result, errors.Join(pollErr, assertErr, captureErr)
 	}
 
 	if opts.NoSave {
-		return SerializeResp(&resp), status, nil
+		result.Body = SerializeResp(&resp)
+		result.Summary = responseSummary(&resp, "")
+		return result, errors.Join(pollErr, assertErr, captureErr)
 	}
 
-	respBytes, saveErr := SerializeAndSaveResp(&resp, opts.Path, opts.OutPath)
-	return respBytes, status, saveErr
+	respBytes, summary, saveErr := serializeAndSaveRow(&resp, opts.Path, opts.OutPath, opts.Row)
+	result.Body = respBytes
+	result.Summary = summary
+	return result, errors.Join(pollErr, saveErr, assertErr, captureErr)
 }
 
 // PrintAndSaveFinalResp prints the CustomResponse to stdout and saves it to
@@ -138,8 +296,11 @@ func SendAndSaveAPIRequest(ctx context.Context, opts RequestOptions) ([]byte, st
 // the caller can fail the task. A successful HTTP request with a missing
 // response file should not look like a success to the user.
 func PrintAndSaveFinalResp(resp *CustomResponse, path string) error {
-	respBytes, saveErr := SerializeAndSaveResp(resp, path, "")
-	if respBytes != nil {
+	respBytes, summary, saveErr := serializeAndSaveRow(resp, path, "", 0)
+	switch {
+	case summary != "":
+		utils.PrintInfoStderr(summary)
+	case respBytes != nil:
 		PrintRespBytes(respBytes)
 	}
 	return saveErr
@@ -169,13 +330,62 @@ func SerializeResp(resp *CustomResponse) []byte {
 //
 // Default: raw response body (JSON pretty-printed, others byte-perfect).
 // --debug: full CustomResponse (request, response, http_info, duration).
+//
+// Binary bodies (images, PDFs, archives, ...) are saved byte-for-byte with
+// an extension from their type.
 func SerializeAndSaveResp(resp *CustomResponse, path, outPath string) ([]byte, error) {
+	body, _, err := serializeAndSaveRow(resp, path, outPath, 0)
+	return body, err
+}
+
+// serializeAndSaveRow is SerializeAndSaveResp for one row of a data-driven
+// run; the saved file name carries the row number. For a binary body it
+// also returns the summary to print in place of the bytes. Outside
+// --debug, the redirects are saved beside the body; see saveRedirects.
+func serializeAndSaveRow(resp *CustomResponse, path, outPath string, row int) ([]byte, string, error) {
+	body, summary, err := saveBody(resp, path, outPath, row)
+	if resp.isDebug() {
+		return body, summary, err
+	}
+	return body, summary, errors.Join(err, saveRedirects(resp, path, outPath, row))
+}
+
+// saveBody saves the serialized response for serializeAndSaveRow.
+func saveBody(resp *CustomResponse, path, outPath string, row int) ([]byte, string, error) {
 	body := SerializeResp(resp)
 	if len(body) == 0 {
 		// 204 No Content and friends: nothing to print or save.
-		return body, nil
+		return body, "", nil
+	}
+	if resp.isBinary() {
+		saved, err := writeBinaryRes(resp, path, outPath, row)
+		return body, responseSummary(resp, saved), err
+	}
+	if resp.isDebug() && isBinaryBody(resp.contentType, resp.rawBody) {
+		// The debug JSON holds a placeholder for the body, so the bytes are
+		// saved beside it, and the JSON keeps .json even for, say, a PDF.
+		_, binErr := writeBinaryRes(resp, path, outPath, row)
+		_, err := writeFile(path, ".json", body, outPath, row)
+		return body, "", errors.Join(err, binErr)
+	}
+	saved, err := evalAndWriteRes(string(body), resp.contentType, path, outPath, row)
+	return body, responseSummary(resp, saved), err
+}
+
+// responseSummary is the line printed in place of the body for responses
+// that aren't printed whole: an event stream (already printed event by
+// event) or a binary body. Empty for everything else, and in debug mode,
+// which prints the full response. savedPath is "" when nothing was saved.
+func responseSummary(resp *CustomResponse, savedPath string) string {
+	switch {
+	case resp.isDebug():
+		return ""
+	case resp.events != nil:
+		return eventsSummary(resp.events, savedPath)
+	case resp.isBinary():
+		return binarySummary(resp, savedPath)
 	}
-	return body, evalAndWriteRes(string(body), resp.contentType, path, outPath)
+	return ""
 }
 
 // defaultBodyForOutput returns the bytes used for default-mode save and
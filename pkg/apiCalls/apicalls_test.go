@@ -3,14 +3,27 @@ package apicalls
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"io"
+	"log"
+	"math/big"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/xaaha/hulak/pkg/httpclient"
 	"github.com/xaaha/hulak/pkg/yamlparser"
 )
 
@@ -162,6 +175,56 @@ func TestStandardCallWithClient_PostWithBody(t *testing.T) {
 	}
 }
 
+// TestStandardCallWithClient_BinaryUpload verifies a binary body is sent
+// byte-for-byte with a Content-Length rather than chunked, and with the
+// content type detected from the file.
+func TestStandardCallWithClient_BinaryUpload(t *testing.T) {
+	content := []byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff, 0xfe, 0x0a}
+	path := filepath.Join(t.TempDir(), "report.pdf")
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		gotBody   []byte
+		gotLength int64
+		gotChunks []string
+		gotCT     string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotLength = r.ContentLength
+		gotChunks = r.TransferEncoding
+		gotCT = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	file := yamlparser.APICallFile{
+		Method: "PUT",
+		URL:    yamlparser.URL(server.URL),
+		Body:   &yamlparser.Body{Binary: path},
+	}
+	apiInfo, err := file.PrepareStruct()
+	if err != nil {
+		t.Fatalf("PrepareStruct: %v", err)
+	}
+
+	if _, err := StandardCallWithClient(context.Background(), apiInfo, false, server.Client()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(gotBody, content) {
+		t.Errorf("body = %v, want %v", gotBody, content)
+	}
+	if gotLength != int64(len(content)) || len(gotChunks) != 0 {
+		t.Errorf("Content-Length = %d, Transfer-Encoding = %v; want %d and none",
+			gotLength, gotChunks, len(content))
+	}
+	if gotCT != "application/pdf" {
+		t.Errorf("Content-Type = %q, want application/pdf", gotCT)
+	}
+}
+
 func TestStandardCallWithClient_NetworkError(t *testing.T) {
 	mockClient := &MockHTTPClient{
 		DoFunc: func(_ *http.Request) (*http.Response, error) {
@@ -795,7 +858,7 @@ func TestSendAndSaveAPIRequest_NoSave(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			respBytes, status, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+			result, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
 				Secrets: map[string]any{},
 				Path:    path,
 				NoSave:  tc.noSave,
@@ -803,11 +866,11 @@ func TestSendAndSaveAPIRequest_NoSave(t *testing.T) {
 			if err != nil {
 				t.Fatalf("SendAndSaveAPIRequest: %v", err)
 			}
-			if status != "200 OK" {
-				t.Errorf("status = %q, want 200 OK", status)
+			if result.Status != "200 OK" {
+				t.Errorf("status = %q, want 200 OK", result.Status)
 			}
-			if !strings.Contains(string(respBytes), `"ok": true`) {
-				t.Errorf("response bytes should contain the (pretty-printed) body, got:\n%s", respBytes)
+			if !strings.Contains(string(result.Body), `"ok": true`) {
+				t.Errorf("response bytes should contain the (pretty-printed) body, got:\n%s", result.Body)
 			}
 
 			matches, err := filepath.Glob(filepath.Join(dir, "*_response.*"))
@@ -837,7 +900,7 @@ func TestSendAndSaveAPIRequest_OutPath(t *testing.T) {
 	}
 
 	outPath := filepath.Join(dir, "custom", "out.json")
-	respBytes, status, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+	result, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
 		Secrets: map[string]any{},
 		Path:    path,
 		OutPath: outPath,
@@ -845,11 +908,11 @@ func TestSendAndSaveAPIRequest_OutPath(t *testing.T) {
 	if err != nil {
 		t.Fatalf("SendAndSaveAPIRequest: %v", err)
 	}
-	if status != "200 OK" {
-		t.Errorf("status = %q, want 200 OK", status)
+	if result.Status != "200 OK" {
+		t.Errorf("status = %q, want 200 OK", result.Status)
 	}
-	if !strings.Contains(string(respBytes), `"ok": true`) {
-		t.Errorf("response bytes should contain the (pretty-printed) body, got:\n%s", respBytes)
+	if !strings.Contains(string(result.Body), `"ok": true`) {
+		t.Errorf("response bytes should contain the (pretty-printed) body, got:\n%s", result.Body)
 	}
 
 	// The response must land at exactly outPath (nested dir created for us).
@@ -866,3 +929,253 @@ func TestSendAndSaveAPIRequest_OutPath(t *testing.T) {
 		t.Errorf("default response file should not be written when -o is set, found: %v", defaults)
 	}
 }
+
+func TestSendAndSaveAPIRequest_AuthUse(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	login := filepath.Join(dir, "login.hk.yaml")
+	path := filepath.Join(dir, "req.hk.yaml")
+	files := map[string]string{
+		login: "kind: Auth\n",
+		filepath.Join(dir, "login.hk_response.json"): `{"access_token": "tok-1"}`,
+		path: "---\nkind: API\nmethod: GET\nurl: " + server.URL + "\nauth:\n  use: " + login + "\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A dry run prints the placeholder, not the token.
+	file, _, err := yamlparser.FinalStructForAPI(path, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := file.PrepareStruct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FormatDryRun(&info, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Bearer <access_token from "+login+">") || strings.Contains(out, "tok-1") {
+		t.Errorf("dry run should show the placeholder, got:\n%s", out)
+	}
+
+	if _, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+		Secrets: map[string]any{},
+		Path:    path,
+		NoSave:  true,
+	}); err != nil {
+		t.Fatalf("SendAndSaveAPIRequest: %v", err)
+	}
+	if gotAuth != "Bearer tok-1" {
+		t.Errorf("Authorization = %q, want the token read as the request was sent", gotAuth)
+	}
+}
+
+// mtlsServer starts a server that requires a client certificate signed by
+// its CA. Its certificate is only valid for hulak.test. It returns the
+// server, a pool with its CA, and a client certificate.
+func mtlsServer(t *testing.T) (*httptest.Server, *x509.CertPool, tls.Certificate) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hulak test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	issue := func(serial int64, tmpl *x509.Certificate) tls.Certificate {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		tmpl.SerialNumber = big.NewInt(serial)
+		tmpl.NotBefore, tmpl.NotAfter = ca.NotBefore, ca.NotAfter
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	}
+	serverCert := issue(2, &x509.Certificate{
+		DNSNames:    []string{"hulak.test"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	clientCert := issue(3, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "hulak client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"client":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // rejected handshakes are the point
+	server.TLS = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, pool, clientCert
+}
+
+func TestStandardCall_TLS(t *testing.T) {
+	server, pool, clientCert := mtlsServer(t)
+
+	tests := []struct {
+		name    string
+		tls     *tls.Config
+		wantErr string
+	}{
+		{
+			name: "client certificate, CA bundle, and server name",
+			tls:  &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{clientCert}, RootCAs: pool, ServerName: "hulak.test"},
+		},
+		{
+			name:    "no client certificate",
+			tls:     &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool, ServerName: "hulak.test"},
+			wantErr: "certificate required",
+		},
+		{
+			name:    "default settings don't trust the server",
+			wantErr: "certificate",
+		},
+		{
+			name:    "certificate not valid for the URL's host",
+			tls:     &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{clientCert}, RootCAs: pool},
+			wantErr: "127.0.0.1",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			info := yamlparser.APIInfo{Method: http.MethodGet, URL: server.URL, TLS: tc.tls}
+			resp, err := StandardCall(context.Background(), info, false)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("StandardCall() = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(resp.rawBody), "hulak client") {
+				t.Errorf("body = %s, want the server to see the client certificate", resp.rawBody)
+			}
+		})
+	}
+}
+
+func TestStandardCall_Proxy(t *testing.T) {
+	var gotURL string
+	proxy := NewMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		_, _ = w.Write([]byte(`{"via":"proxy"}`))
+	})
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	info := yamlparser.APIInfo{
+		Method:    http.MethodGet,
+		URL:       "http://api.example.test/orders",
+		URLParams: map[string]string{"page": "2"},
+		Proxy:     &httpclient.Proxy{URL: proxyURL},
+	}
+	resp, err := StandardCall(context.Background(), info, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotURL != "http://api.example.test/orders?page=2" || string(resp.rawBody) != `{"via":"proxy"}` {
+		t.Errorf("proxy got %q and answered %s", gotURL, resp.rawBody)
+	}
+}
+
+func TestClientFor(t *testing.T) {
+	withTLS := httpclient.Options{TLS: &tls.Config{MinVersion: tls.VersionTLS13}}
+	withProxy := httpclient.Options{TLS: withTLS.TLS, Proxy: &httpclient.Proxy{NoProxy: []string{"*"}}}
+	mock := &MockHTTPClient{}
+	if got := clientFor(DefaultClient, httpclient.Options{}); got != DefaultClient {
+		t.Error("no options should keep the DefaultClient")
+	}
+	if got := clientFor(mock, withTLS); got != mock {
+		t.Error("an injected client should be used as is")
+	}
+	a, b := clientFor(DefaultClient, withTLS), clientFor(DefaultClient, withTLS)
+	if a == DefaultClient || a != b {
+		t.Error("the same options should get one client of their own")
+	}
+	if clientFor(DefaultClient, withProxy) == a {
+		t.Error("different options should get different clients")
+	}
+	jar := httpclient.NewJar()
+	if c, ok := clientFor(DefaultClient, httpclient.Options{Jar: jar}).(*httpclient.Client); !ok || c.HTTP.Jar != jar {
+		t.Error("a jar should get a client that uses it")
+	}
+}
+
+func TestStandardCall_Redirects(t *testing.T) {
+	server := NewMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.Redirect(w, r, "/callback?code=abc", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	defer server.Close()
+
+	t.Run("debug output shows the chain", func(t *testing.T) {
+		info := yamlparser.APIInfo{Method: http.MethodGet, URL: server.URL + "/login"}
+		resp, err := StandardCall(context.Background(), info, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []httpclient.RedirectHop{{
+			URL:      server.URL + "/login",
+			Status:   "302 Found",
+			Location: server.URL + "/callback?code=abc",
+		}}
+		if !reflect.DeepEqual(resp.Redirects, want) {
+			t.Errorf("Redirects = %+v, want %+v", resp.Redirects, want)
+		}
+		if out := string(SerializeResp(&resp)); !strings.Contains(out, `"redirects"`) {
+			t.Errorf("debug output has no redirects:\n%s", out)
+		}
+	})
+
+	t.Run("none stops at the redirect", func(t *testing.T) {
+		info := yamlparser.APIInfo{
+			Method:    http.MethodGet,
+			URL:       server.URL + "/login",
+			Redirects: &httpclient.RedirectPolicy{},
+		}
+		resp, err := StandardCall(context.Background(), info, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Response.StatusCode != http.StatusFound || resp.header.Get("Location") != "/callback?code=abc" {
+			t.Errorf("got %s, Location %q", resp.Response.Status, resp.header.Get("Location"))
+		}
+		if len(resp.Redirects) != 0 {
+			t.Errorf("Redirects = %+v, want none followed", resp.Redirects)
+		}
+	})
+}
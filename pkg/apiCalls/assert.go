@@ -0,0 +1,177 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/actions"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// checkAssertions evaluates the request file's assert section against resp.
+// Returns nil when every configured check holds (or there is no section).
+//
+// On failure the error's first line is a short headline ("2 assertions
+// failed") and each failed check follows on its own line. The runner's
+// splitErrorForOutcome prints the headline on the outcome row and the rest
+// as an indented detail block, so every failure is visible without making
+// the row unreadable.
+func checkAssertions(a *yamlparser.Assert, resp *CustomResponse) error {
+	if a == nil || resp == nil {
+		return nil
+	}
+
+	var failures []string
+	failures = append(failures, checkStatus(a.Status, resp)...)
+	failures = append(failures, checkHeaders(a.Headers, resp)...)
+	failures = append(failures, checkJSON(a.JSON, resp.jsonBody())...)
+	failures = append(failures, checkBody(a.Body, resp.rawBody)...)
+	failures = append(failures, checkDuration(a, resp.elapsed)...)
+
+	if len(failures) == 0 {
+		return nil
+	}
+	headline := "1 assertion failed"
+	if len(failures) > 1 {
+		headline = fmt.Sprintf("%d assertions failed", len(failures))
+	}
+	return errors.New(headline + "\n" + strings.Join(failures, "\n"))
+}
+
+// checkStatus fails when the response code is not one of the wanted codes.
+func checkStatus(want yamlparser.StatusCodes, resp *CustomResponse) []string {
+	if len(want) == 0 {
+		return nil
+	}
+	got := 0
+	if resp.Response != nil {
+		got = resp.Response.StatusCode
+	}
+	if slices.Contains(want, got) {
+		return nil
+	}
+	wantStr := make([]string, len(want))
+	for i, code := range want {
+		wantStr[i] = strconv.Itoa(code)
+	}
+	return []string{fmt.Sprintf("status: got %d, want %s", got, strings.Join(wantStr, " or "))}
+}
+
+// checkHeaders fails for each header that is missing or whose value does
+// not contain the expected text. Names are matched case-insensitively and
+// reported in sorted order so the output is stable across runs.
+func checkHeaders(want map[string]string, resp *CustomResponse) []string {
+	var failures []string
+	for _, name := range slices.Sorted(maps.Keys(want)) {
+		values := resp.header.Values(name)
+		if len(values) == 0 {
+			failures = append(failures, fmt.Sprintf("header %s: missing", name))
+			continue
+		}
+		got := strings.Join(values, ", ")
+		if !strings.Contains(got, want[name]) {
+			failures = append(failures, fmt.Sprintf(
+				"header %s: got %q, want it to contain %q", name, got, want[name],
+			))
+		}
+	}
+	return failures
+}
+
+// checkJSON resolves each path against the decoded body with the same
+// lookup getValueOf uses, then applies the check's exists/equals/matches.
+func checkJSON(checks []yamlparser.JSONAssertion, rawBody []byte) []string {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	var content any
+	if err := json.Unmarshal(rawBody, &content); err != nil {
+		return []string{"json: response body is not valid JSON"}
+	}
+
+	var failures []string
+	for _, check := range checks {
+		value, err := actions.ExtractValueByKey(check.Path, content)
+		found := err == nil
+
+		if check.Exists != nil && *check.Exists != found {
+			if found {
+				failures = append(failures, fmt.Sprintf("json %s: expected to be absent", check.Path))
+			} else {
+				failures = append(failures, fmt.Sprintf("json %s: not found", check.Path))
+			}
+			continue
+		}
+		if !found {
+			if check.Equals != nil || check.Matches != "" {
+				failures = append(failures, fmt.Sprintf("json %s: not found", check.Path))
+			}
+			continue
+		}
+
+		got := stringifyValue(value)
+		if check.Equals != nil {
+			if want := stringifyValue(check.Equals); got != want {
+				failures = append(failures, fmt.Sprintf(
+					"json %s: got %s, want %s", check.Path, got, want,
+				))
+			}
+		}
+		if check.Matches != "" {
+			re, err := regexp.Compile(check.Matches)
+			if err != nil || !re.MatchString(got) {
+				failures = append(failures, fmt.Sprintf(
+					"json %s: %s does not match %q", check.Path, got, check.Matches,
+				))
+			}
+		}
+	}
+	return failures
+}
+
+// checkBody fails for each regex the raw body does not match.
+func checkBody(patterns []string, rawBody []byte) []string {
+	var failures []string
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil || !re.Match(rawBody) {
+			failures = append(failures, fmt.Sprintf("body: does not match %q", pattern))
+		}
+	}
+	return failures
+}
+
+// checkDuration fails when the request took longer than max_duration.
+func checkDuration(a *yamlparser.Assert, elapsed time.Duration) []string {
+	maxDuration, err := a.ParsedMaxDuration()
+	if err != nil || maxDuration == 0 || elapsed <= maxDuration {
+		return nil
+	}
+	return []string{fmt.Sprintf(
+		"duration: took %s, max %s", elapsed.Round(time.Millisecond), maxDuration,
+	)}
+}
+
+// stringifyValue renders a JSON or YAML value for comparison and messages.
+// Strings compare by content, maps and slices as compact JSON, and scalars
+// via %v so a YAML 42 (uint64) equals a JSON 42 (int).
+func stringifyValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case map[string]any, []any:
+		if b, err := json.Marshal(val); err == nil {
+			return string(b)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
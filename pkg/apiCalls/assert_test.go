@@ -0,0 +1,154 @@
+package apicalls
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestCheckAssertions(t *testing.T) {
+	yes, no := true, false
+	resp := &CustomResponse{
+		Response: &ResponseInfo{StatusCode: http.StatusOK, Status: "200 OK"},
+		header:   http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		rawBody:  []byte(`{"ok":true,"data":{"id":42,"name":"hulak"},"items":[{"sku":"a-1"}]}`),
+		elapsed:  120 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name   string
+		assert *yamlparser.Assert
+		// wantFailures lists substrings expected in the error detail. Empty
+		// means the assertions must pass.
+		wantFailures []string
+	}{
+		{"nil assert passes", nil, nil},
+		{"matching status", &yamlparser.Assert{Status: yamlparser.StatusCodes{200, 201}}, nil},
+		{
+			"wrong status",
+			&yamlparser.Assert{Status: yamlparser.StatusCodes{201, 202}},
+			[]string{"status: got 200, want 201 or 202"},
+		},
+		{
+			"header contains, case-insensitive name",
+			&yamlparser.Assert{Headers: map[string]string{"content-type": "application/json"}},
+			nil,
+		},
+		{
+			"header mismatch and missing",
+			&yamlparser.Assert{Headers: map[string]string{"content-type": "xml", "x-trace": ""}},
+			[]string{"header content-type: got", "header x-trace: missing"},
+		},
+		{
+			"json equals across number types",
+			&yamlparser.Assert{JSON: []yamlparser.JSONAssertion{
+				{Path: "data.id", Equals: uint64(42)},
+				{Path: "data.name", Equals: "hulak"},
+				{Path: "items[0].sku", Matches: `^a-\d$`},
+				{Path: "ok", Exists: &yes},
+				{Path: "missing", Exists: &no},
+			}},
+			nil,
+		},
+		{
+			"json failures",
+			&yamlparser.Assert{JSON: []yamlparser.JSONAssertion{
+				{Path: "data.id", Equals: 7},
+				{Path: "data.nope", Equals: 1},
+				{Path: "ok", Exists: &no},
+			}},
+			[]string{"json data.id: got 42, want 7", "json data.nope: not found", "json ok: expected to be absent"},
+		},
+		{"body regex", &yamlparser.Assert{Body: []string{`"ok":\s*true`}}, nil},
+		{
+			"body regex mismatch",
+			&yamlparser.Assert{Body: []string{`"ok":\s*false`}},
+			[]string{"body: does not match"},
+		},
+		{"under max duration", &yamlparser.Assert{MaxDuration: "1s"}, nil},
+		{
+			"over max duration",
+			&yamlparser.Assert{MaxDuration: "100ms"},
+			[]string{"duration: took 120ms, max 100ms"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkAssertions(tc.assert, resp)
+			if len(tc.wantFailures) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected assertion failure: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected assertion failure, got nil")
+			}
+			for _, want := range tc.wantFailures {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q missing %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAssertions_HeadlineCountsFailures(t *testing.T) {
+	resp := &CustomResponse{
+		Response: &ResponseInfo{StatusCode: http.StatusInternalServerError},
+		rawBody:  []byte("oops"),
+	}
+	err := checkAssertions(&yamlparser.Assert{
+		Status: yamlparser.StatusCodes{200},
+		JSON:   []yamlparser.JSONAssertion{{Path: "id", Equals: 1}},
+	}, resp)
+	if err == nil {
+		t.Fatal("expected failure")
+	}
+	headline, _, _ := strings.Cut(err.Error(), "\n")
+	if headline != "2 assertions failed" {
+		t.Errorf("headline = %q, want %q", headline, "2 assertions failed")
+	}
+}
+
+// TestSendAndSaveAPIRequest_AssertFailure verifies a failed assertion is
+// returned as the error while the response is still saved and returned.
+func TestSendAndSaveAPIRequest_AssertFailure(t *testing.T) {
+	server := NewMockServer(http.StatusInternalServerError, `{"ok":false}`)
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "req.hk.yaml")
+	doc := "method: GET\nurl: " + server.URL + "\nassert:\n  status: 200\n"
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+		Secrets: map[string]any{},
+		Path:    path,
+	})
+	if err == nil || !strings.Contains(err.Error(), "status: got 500, want 200") {
+		t.Fatalf("err = %v, want status assertion failure", err)
+	}
+	if result.Status != "500 Internal Server Error" {
+		t.Errorf("status = %q", result.Status)
+	}
+	if !strings.Contains(string(result.Body), `"ok": false`) {
+		t.Errorf("response bytes should still be returned, got %s", result.Body)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*_response.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 {
+		t.Errorf("response file should still be saved, found %v", matches)
+	}
+}
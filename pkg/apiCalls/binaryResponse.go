@@ -0,0 +1,182 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// binaryPlaceholder stands in for a binary body in --debug output, where
+// the raw bytes would corrupt the JSON document and the terminal.
+func binaryPlaceholder(contentType string, size int) string {
+	return fmt.Sprintf("<binary body: %s, %s>", mediaTypeOrUnknown(contentType), utils.FormatBytes(int64(size)))
+}
+
+// isBinary reports whether the response body should be saved and reported
+// as binary rather than printed. Debug responses are a JSON document and
+// always print.
+func (r *CustomResponse) isBinary() bool {
+	return !r.isDebug() && isBinaryBody(r.contentType, r.rawBody)
+}
+
+// isBinaryBody decides whether a response body is binary. Text media types
+// (and anything with a charset) are never binary; well-known binary families
+// (images, audio, archives, PDFs, protobuf, ...) always are. Everything
+// else, including a missing or generic Content-Type, is binary when the
+// bytes aren't valid UTF-8 or contain a NUL.
+func isBinaryBody(contentType string, body []byte) bool {
+	if len(body) == 0 {
+		return false
+	}
+	media, params, err := mime.ParseMediaType(contentType)
+	if err == nil && !isGenericMediaType(media) {
+		if _, hasCharset := params["charset"]; hasCharset || isTextMediaType(media) {
+			return false
+		}
+		if isBinaryMediaType(media) {
+			return true
+		}
+	}
+	return !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0
+}
+
+// isTextMediaType matches media types whose body is readable text.
+func isTextMediaType(media string) bool {
+	if strings.HasPrefix(media, "text/") ||
+		strings.HasSuffix(media, "+json") || strings.HasSuffix(media, "+xml") {
+		return true
+	}
+	switch media {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-javascript", "application/ecmascript",
+		"application/x-www-form-urlencoded", "application/graphql",
+		"application/yaml", "application/x-yaml", "application/x-ndjson",
+		"application/json-seq", "application/sql":
+		return true
+	}
+	return false
+}
+
+// isBinaryMediaType matches media types that are binary whatever the bytes
+// look like. Small protobuf messages, for example, are often valid UTF-8.
+func isBinaryMediaType(media string) bool {
+	for _, prefix := range []string{
+		"image/", "audio/", "video/", "font/",
+		"application/vnd.openxmlformats-", "application/vnd.ms-",
+		"application/vnd.oasis.opendocument.",
+	} {
+		if strings.HasPrefix(media, prefix) {
+			return true
+		}
+	}
+	_, known := binaryExtensions[media]
+	return known
+}
+
+// binaryExtensions maps binary media types to the extension their saved
+// response gets.
+var binaryExtensions = map[string]string{
+	"application/pdf":                 ".pdf",
+	"application/zip":                 ".zip",
+	"application/x-zip-compressed":    ".zip",
+	"application/gzip":                ".gz",
+	"application/x-gzip":              ".gz",
+	"application/x-tar":               ".tar",
+	"application/x-bzip2":             ".bz2",
+	"application/x-xz":                ".xz",
+	"application/x-7z-compressed":     ".7z",
+	"application/java-archive":        ".jar",
+	"application/wasm":                ".wasm",
+	"application/protobuf":            ".pb",
+	"application/x-protobuf":          ".pb",
+	"application/vnd.google.protobuf": ".pb",
+	"application/grpc":                ".pb",
+	"application/msgpack":             ".msgpack",
+	"application/x-msgpack":           ".msgpack",
+	"application/vnd.msgpack":         ".msgpack",
+	"application/cbor":                ".cbor",
+	"application/x-sqlite3":           ".sqlite",
+	"application/vnd.ms-excel":        ".xls",
+	"application/msword":              ".doc",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+	"image/png":                ".png",
+	"image/jpeg":               ".jpg",
+	"image/gif":                ".gif",
+	"image/webp":               ".webp",
+	"image/bmp":                ".bmp",
+	"image/tiff":               ".tiff",
+	"image/avif":               ".avif",
+	"image/x-icon":             ".ico",
+	"image/vnd.microsoft.icon": ".ico",
+	"audio/mpeg":               ".mp3",
+	"audio/wav":                ".wav",
+	"audio/wave":               ".wav",
+	"audio/ogg":                ".ogg",
+	"audio/aac":                ".aac",
+	"audio/flac":               ".flac",
+	"video/mp4":                ".mp4",
+	"video/webm":               ".webm",
+	"video/ogg":                ".ogv",
+	"video/quicktime":          ".mov",
+	"font/woff":                ".woff",
+	"font/woff2":               ".woff2",
+	"font/ttf":                 ".ttf",
+	"font/otf":                 ".otf",
+}
+
+// safeExtension matches an extension that is fine to put on a file name
+// taken from a server header.
+var safeExtension = regexp.MustCompile(`^\.[A-Za-z0-9]{1,10}$`)
+
+// binaryExtension picks the saved file's extension for a binary response:
+// the extension of the Content-Disposition filename when the server names
+// one, then the Content-Type, then the type sniffed from the bytes, and
+// ".bin" when nothing matches.
+func binaryExtension(contentType, disposition string, body []byte) string {
+	if _, params, err := mime.ParseMediaType(disposition); err == nil {
+		ext := strings.ToLower(filepath.Ext(filepath.Base(params["filename"])))
+		if safeExtension.MatchString(ext) {
+			return ext
+		}
+	}
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil || isGenericMediaType(media) {
+		media, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+	if ext, ok := binaryExtensions[media]; ok {
+		return ext
+	}
+	return ".bin"
+}
+
+// binarySummary is the line printed in place of a binary body, e.g.
+// "binary response (image/png, 48.2 KB) saved to avatar_response.png".
+// savedPath is "" when nothing was written.
+func binarySummary(resp *CustomResponse, savedPath string) string {
+	summary := fmt.Sprintf(
+		"binary response (%s, %s)",
+		mediaTypeOrUnknown(resp.contentType), utils.FormatBytes(int64(len(resp.rawBody))),
+	)
+	if savedPath == "" {
+		return summary + " not printed"
+	}
+	return summary + " saved to " + savedPath
+}
+
+// mediaTypeOrUnknown strips parameters from a Content-Type for display.
+func mediaTypeOrUnknown(contentType string) string {
+	if media, _, err := mime.ParseMediaType(contentType); err == nil {
+		return media
+	}
+	return "unknown type"
+}
@@ -0,0 +1,147 @@
+package apicalls
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngBytes is a PNG signature followed by bytes that aren't valid UTF-8.
+var pngBytes = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff\xfe")
+
+func TestIsBinaryBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        bool
+	}{
+		{"json", "application/json", []byte(`{"a":1}`), false},
+		{"vendored json", "application/vnd.api+json", []byte(`{}`), false},
+		{"text with charset", "text/plain; charset=utf-8", []byte("hi"), false},
+		{"image", "image/png", pngBytes, true},
+		{"svg is text", "image/svg+xml", []byte("<svg/>"), false},
+		{"pdf", "application/pdf", []byte("%PDF-1.7"), true},
+		{"protobuf that looks like text", "application/x-protobuf", []byte("\x08\x96\x01"), true},
+		{"octet-stream with text", "application/octet-stream", []byte("plain"), false},
+		{"octet-stream with bytes", "application/octet-stream", []byte{0xff, 0x00, 0x10}, true},
+		{"no content type, invalid utf-8", "", []byte{0xc3, 0x28}, true},
+		{"unknown type, text body", "application/x-custom", []byte("ok"), false},
+		{"empty body", "image/png", nil, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isBinaryBody(tc.contentType, tc.body); got != tc.want {
+				t.Errorf("isBinaryBody(%q) = %v, want %v", tc.contentType, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBinaryExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		disposition string
+		body        []byte
+		want        string
+	}{
+		{"from content type", "image/jpeg", "", nil, ".jpg"},
+		{"parameters ignored", "application/pdf; qs=0.001", "", nil, ".pdf"},
+		{"office document", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "", nil, ".xlsx"},
+		{"disposition wins", "application/octet-stream", `attachment; filename="report.XLSX"`, nil, ".xlsx"},
+		{"unsafe disposition ignored", "application/zip", `attachment; filename="a.t/../x y"`, nil, ".zip"},
+		{"sniffed when generic", "application/octet-stream", "", pngBytes, ".png"},
+		{"sniffed when missing", "", "", []byte("PK\x03\x04rest"), ".zip"},
+		{"unknown falls back to bin", "application/x-custom", "", []byte{0x00}, ".bin"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := binaryExtension(tc.contentType, tc.disposition, tc.body); got != tc.want {
+				t.Errorf("binaryExtension = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSerializeAndSaveRow_Binary verifies a binary body lands on disk
+// byte-for-byte with the right extension, and a summary replaces it in
+// output.
+func TestSerializeAndSaveRow_Binary(t *testing.T) {
+	dir := t.TempDir()
+	resp := &CustomResponse{
+		Response:    &ResponseInfo{StatusCode: 200, Status: "200 OK"},
+		contentType: "image/png",
+		rawBody:     pngBytes,
+		header:      http.Header{"Content-Type": {"image/png"}},
+	}
+
+	body, summary, err := serializeAndSaveRow(resp, filepath.Join(dir, "avatar.hk.yaml"), "", 2)
+	if err != nil {
+		t.Fatalf("serializeAndSaveRow: %v", err)
+	}
+	if !bytes.Equal(body, pngBytes) {
+		t.Errorf("returned body = %q, want the raw bytes", body)
+	}
+
+	saved := filepath.Join(dir, "avatar.hk_response_2.png")
+	onDisk, err := os.ReadFile(saved)
+	if err != nil {
+		t.Fatalf("reading %s: %v", saved, err)
+	}
+	if !bytes.Equal(onDisk, pngBytes) {
+		t.Errorf("on disk = %q, want %q", onDisk, pngBytes)
+	}
+	if want := "binary response (image/png, 18 B) saved to " + saved; summary != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+}
+
+// TestProcessResponse_BinaryDebug verifies --debug output replaces a binary
+// body with a placeholder instead of embedding the bytes, and that the
+// bytes are still saved beside the debug JSON.
+func TestProcessResponse_BinaryDebug(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.test/file", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &http.Response{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/pdf"}},
+		Body:       io.NopCloser(strings.NewReader("%PDF-1.7\xff\xfe")),
+	}
+
+	got, err := processResponse(req, resp, 0, true, nil)
+	if err != nil {
+		t.Fatalf("processResponse: %v", err)
+	}
+	body, _ := got.Response.Body.(string)
+	if body != "<binary body: application/pdf, 10 B>" {
+		t.Errorf("debug body = %q, want a binary placeholder", body)
+	}
+	if got.isBinary() {
+		t.Error("debug responses should print, not be treated as binary")
+	}
+
+	dir := t.TempDir()
+	printed, summary, err := serializeAndSaveRow(&got, filepath.Join(dir, "report.hk.yaml"), "", 0)
+	if err != nil {
+		t.Fatalf("serializeAndSaveRow: %v", err)
+	}
+	if summary != "" || !bytes.Contains(printed, []byte("binary body: application/pdf")) {
+		t.Errorf("output = %q, summary = %q; want the debug JSON", printed, summary)
+	}
+	raw, err := os.ReadFile(filepath.Join(dir, "report.hk_response.pdf"))
+	if err != nil || string(raw) != "%PDF-1.7\xff\xfe" {
+		t.Errorf("raw file = %q, %v; want the bytes beside the debug JSON", raw, err)
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, "report.hk_response.json"))
+	if err != nil || !bytes.Equal(saved, printed) {
+		t.Errorf("debug file = %q, %v; want the debug JSON", saved, err)
+	}
+}
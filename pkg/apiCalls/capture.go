@@ -0,0 +1,70 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xaaha/hulak/pkg/actions"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// extractCaptures resolves the file's capture list against resp and returns
+// name → value. JSON values keep their decoded type (numbers stay numbers)
+// so a captured id can still feed a typed GraphQL variable; header and regex
+// captures are strings.
+//
+// A capture that can't be resolved is an error: later files that reference
+// it would otherwise run with a missing value and fail far from the cause.
+func extractCaptures(captures yamlparser.Captures, resp *CustomResponse) (map[string]any, error) {
+	if len(captures) == 0 || resp == nil {
+		return nil, nil
+	}
+
+	// Decode lazily — header/regex-only capture lists work on any body.
+	var content any
+	decoded := false
+
+	values := make(map[string]any, len(captures))
+	for _, c := range captures {
+		switch {
+		case c.Path != "":
+			if !decoded {
+				if err := json.Unmarshal(resp.jsonBody(), &content); err != nil {
+					return nil, fmt.Errorf("capture %q: response body is not valid JSON", c.Name)
+				}
+				decoded = true
+			}
+			value, err := actions.ExtractValueByKey(c.Path, content)
+			if err != nil {
+				return nil, fmt.Errorf("capture %q: path %s not found in response", c.Name, c.Path)
+			}
+			values[c.Name] = value
+
+		case c.Header != "":
+			headerValues := resp.header.Values(c.Header)
+			if len(headerValues) == 0 {
+				return nil, fmt.Errorf("capture %q: header %s not found in response", c.Name, c.Header)
+			}
+			values[c.Name] = strings.Join(headerValues, ", ")
+
+		case c.Regex != "":
+			re, err := regexp.Compile(c.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("capture %q: invalid regex: %w", c.Name, err)
+			}
+			match := re.FindSubmatch(resp.rawBody)
+			if match == nil {
+				return nil, fmt.Errorf("capture %q: regex %q did not match the response", c.Name, c.Regex)
+			}
+			if len(match) > 1 {
+				values[c.Name] = string(match[1])
+			} else {
+				values[c.Name] = string(match[0])
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,77 @@
+package apicalls
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestExtractCaptures(t *testing.T) {
+	resp := &CustomResponse{
+		Response: &ResponseInfo{StatusCode: http.StatusOK},
+		header:   http.Header{"Etag": {`"v1"`}, "X-Tag": {"a", "b"}},
+		rawBody:  []byte(`{"data":{"id":42,"token":"abc"},"items":[{"sku":"a-1"}]}`),
+	}
+
+	tests := []struct {
+		name     string
+		captures yamlparser.Captures
+		want     map[string]any
+		wantErr  string
+	}{
+		{"no captures", nil, nil, ""},
+		{
+			"json path keeps type",
+			yamlparser.Captures{{Name: "id", Path: "data.id"}, {Name: "sku", Path: "items[0].sku"}},
+			map[string]any{"id": 42, "sku": "a-1"},
+			"",
+		},
+		{
+			"header, case insensitive and joined",
+			yamlparser.Captures{{Name: "etag", Header: "etag"}, {Name: "tags", Header: "x-tag"}},
+			map[string]any{"etag": `"v1"`, "tags": "a, b"},
+			"",
+		},
+		{
+			"regex group and whole match",
+			yamlparser.Captures{
+				{Name: "token", Regex: `"token":"(\w+)"`},
+				{Name: "sku", Regex: `a-\d`},
+			},
+			map[string]any{"token": "abc", "sku": "a-1"},
+			"",
+		},
+		{"missing path", yamlparser.Captures{{Name: "x", Path: "data.nope"}}, nil, `capture "x": path data.nope not found`},
+		{"missing header", yamlparser.Captures{{Name: "x", Header: "X-Nope"}}, nil, `capture "x": header X-Nope not found`},
+		{"regex no match", yamlparser.Captures{{Name: "x", Regex: `nope`}}, nil, "did not match"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := extractCaptures(tc.captures, resp)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("extractCaptures() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractCaptures_NonJSONBody(t *testing.T) {
+	resp := &CustomResponse{rawBody: []byte("plain text")}
+	_, err := extractCaptures(yamlparser.Captures{{Name: "id", Path: "id"}}, resp)
+	if err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Fatalf("err = %v, want not valid JSON", err)
+	}
+}
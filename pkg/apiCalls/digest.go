@@ -0,0 +1,208 @@
+package apicalls
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // G501: MD5 is what Digest servers ask for
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// HTTP Digest access authentication, RFC 7616.
+
+// digestChallenge is one Digest challenge from a WWW-Authenticate header.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+	userhash  bool
+}
+
+// doDigest sends req, and when the server answers 401 with a Digest
+// challenge, sends it again with the response to it. Any other answer,
+// including a 401 without a Digest challenge, is returned as is.
+func doDigest(
+	client httpclient.HTTPClient,
+	req *http.Request,
+	body []byte,
+	auth *yamlparser.RequestAuth,
+) (*http.Response, *http.Request, error) {
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, req, err
+	}
+	challenge, ok := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if !ok {
+		return resp, req, nil
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	retry := req.Clone(req.Context())
+	retry.Body = io.NopCloser(bytes.NewReader(body))
+	authorization, err := challenge.authorize(
+		auth.Username, auth.Password, req.Method, req.URL.RequestURI(), body, randomHex(16),
+	)
+	if err != nil {
+		return nil, retry, err
+	}
+	retry.Header.Set("Authorization", authorization)
+	resp, err = client.Do(retry)
+	return resp, retry, err
+}
+
+// parseDigestChallenge returns the first Digest challenge with an
+// algorithm hulak supports: MD5, SHA-256, or their -sess variants.
+func parseDigestChallenge(headers []string) (digestChallenge, bool) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		c := digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+		for q := range strings.SplitSeq(params["qop"], ",") {
+			if q = strings.TrimSpace(q); q != "" {
+				c.qop = append(c.qop, strings.ToLower(q))
+			}
+		}
+		if c.nonce != "" && digestHash(c.algorithm) != nil {
+			return c, true
+		}
+	}
+	return digestChallenge{}, false
+}
+
+// parseAuthParams reads the comma-separated name=value pairs after an
+// auth scheme. Values may be quoted, with backslash escapes, and quoted
+// values may contain commas.
+func parseAuthParams(s string) map[string]string {
+	params := map[string]string{}
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " ")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[name] = value.String()
+	}
+	return params
+}
+
+// digestHash returns the hash function of a Digest algorithm, or nil for
+// one hulak doesn't support.
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(algorithm), "-sess")) {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+// authorize returns the Authorization header answering the challenge for
+// a request to uri. qop auth is preferred over auth-int; a challenge
+// without qop gets the RFC 2069 response.
+func (c *digestChallenge) authorize(
+	username, password, method, uri string,
+	body []byte,
+	cnonce string,
+) (string, error) {
+	newHash := digestHash(c.algorithm)
+	if newHash == nil {
+		return "", fmt.Errorf("unsupported digest algorithm %q", c.algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	var qop string
+	switch {
+	case len(c.qop) == 0:
+	case slices.Contains(c.qop, "auth"):
+		qop = "auth"
+	case slices.Contains(c.qop, "auth-int"):
+		qop = "auth-int"
+	default:
+		return "", fmt.Errorf("unsupported digest qop %q", strings.Join(c.qop, ","))
+	}
+	const nc = "00000001"
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	if qop == "auth-int" {
+		ha2 = h(method + ":" + uri + ":" + h(string(body)))
+	}
+	response := h(ha1 + ":" + c.nonce + ":" + ha2)
+	if qop != "" {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, qop, ha2}, ":"))
+	}
+
+	if c.userhash {
+		username = h(username + ":" + c.realm)
+	}
+	fields := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + c.algorithm,
+		fmt.Sprintf("response=%q", response),
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if c.userhash {
+		fields = append(fields, "userhash=true")
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
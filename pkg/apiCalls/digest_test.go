@@ -0,0 +1,181 @@
+package apicalls
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // G501: the test server checks MD5 digests
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestParseDigestChallenge(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    digestChallenge
+		wantOK  bool
+	}{
+		{
+			name:    "quoted params with a comma in qop",
+			headers: []string{`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`},
+			want: digestChallenge{
+				realm:     "http-auth@example.org",
+				nonce:     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+				opaque:    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+				algorithm: "SHA-256",
+				qop:       []string{"auth", "auth-int"},
+			},
+			wantOK: true,
+		},
+		{
+			name:    "basic challenge is skipped and algorithm defaults to MD5",
+			headers: []string{`Basic realm="x"`, `digest realm="r", nonce="n"`},
+			want:    digestChallenge{realm: "r", nonce: "n", algorithm: "MD5"},
+			wantOK:  true,
+		},
+		{
+			name:    "escaped quote in realm",
+			headers: []string{`Digest realm="say \"hi\"", nonce=abc, userhash=true`},
+			want:    digestChallenge{realm: `say "hi"`, nonce: "abc", algorithm: "MD5", userhash: true},
+			wantOK:  true,
+		},
+		{
+			name:    "unsupported algorithm",
+			headers: []string{`Digest realm="r", nonce="n", algorithm=SHA-512-256`},
+		},
+		{
+			name:    "no digest challenge",
+			headers: []string{`Bearer realm="api"`},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseDigestChallenge(tc.headers)
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantOK)
+			}
+			if tc.wantOK && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("challenge = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDigestChallenge_Authorize(t *testing.T) {
+	tests := []struct {
+		name         string
+		challenge    digestChallenge
+		username     string
+		password     string
+		cnonce       string
+		wantResponse string
+	}{
+		{
+			name:         "RFC 2617 MD5",
+			challenge:    digestChallenge{realm: "testrealm@host.com", nonce: "dcd98b7102dd2f0e8b11d0f600bfb0c093", algorithm: "MD5", qop: []string{"auth", "auth-int"}},
+			username:     "Mufasa",
+			password:     "Circle Of Life",
+			cnonce:       "0a4f113b",
+			wantResponse: "6629fae49393a05397450978507c4ef1",
+		},
+		{
+			name:         "RFC 7616 SHA-256",
+			challenge:    digestChallenge{realm: "http-auth@example.org", nonce: "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", algorithm: "SHA-256", qop: []string{"auth"}},
+			username:     "Mufasa",
+			password:     "Circle of Life",
+			cnonce:       "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ",
+			wantResponse: "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.challenge.authorize(tc.username, tc.password, http.MethodGet, "/dir/index.html", nil, tc.cnonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{
+				`response="` + tc.wantResponse + `"`,
+				`username="Mufasa"`,
+				`uri="/dir/index.html"`,
+				"qop=auth,",
+				"nc=00000001",
+				`cnonce="` + tc.cnonce + `"`,
+			} {
+				if !strings.Contains(got, want) {
+					t.Errorf("Authorization = %s\nmissing %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+// digestServer answers 401 with an MD5 challenge until a request carries
+// the right response for user:pass.
+func digestServer(t *testing.T, calls *atomic.Int32) string {
+	t.Helper()
+	const realm, nonce = "hulak", "abc123"
+	server := NewMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		c, ok := parseDigestChallenge([]string{r.Header.Get("Authorization")})
+		if ok {
+			params := parseAuthParams(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+			md := func(s string) string {
+				sum := md5.Sum([]byte(s)) //nolint:gosec // G401: Digest MD5
+				return hex.EncodeToString(sum[:])
+			}
+			ha1 := md("user:" + realm + ":pass")
+			ha2 := md(r.Method + ":" + params["uri"])
+			want := md(strings.Join([]string{ha1, c.nonce, params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+			if params["response"] == want && params["uri"] == r.URL.RequestURI() {
+				_, _ = w.Write([]byte(`{"ok":true}`))
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Digest realm="`+realm+`", qop="auth", nonce="`+nonce+`"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestSendRequest_Digest(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		wantStatus int
+	}{
+		{"answers the challenge", "pass", http.StatusOK},
+		{"wrong password gets the second 401", "nope", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			url := digestServer(t, &calls)
+			info := yamlparser.APIInfo{
+				Method:    http.MethodPost,
+				URL:       url + "/things",
+				URLParams: map[string]string{"q": "1"},
+				Body:      strings.NewReader(`{"a":1}`),
+				Signing:   &yamlparser.RequestAuth{Type: "digest", Username: "user", Password: tc.password},
+			}
+			resp, err := StandardCall(context.Background(), info, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Response.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d", resp.Response.StatusCode, tc.wantStatus)
+			}
+			if calls.Load() != 2 {
+				t.Errorf("server got %d requests, want 2", calls.Load())
+			}
+			if !strings.HasPrefix(resp.Request.Headers["Authorization"], "Digest ") {
+				t.Errorf("debug request headers = %v, want the digest Authorization", resp.Request.Headers)
+			}
+		})
+	}
+}
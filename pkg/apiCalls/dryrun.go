@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/xaaha/hulak/pkg/utils"
 	"github.com/xaaha/hulak/pkg/yamlparser"
@@ -94,6 +95,11 @@ func FormatDryRun(apiInfo *yamlparser.APIInfo, show bool) (string, error) {
 			return b.String(), nil
 		}
 	}
+	if !utf8.Valid(body) {
+		// A binary upload would garble the terminal; show its size instead.
+		fmt.Fprintf(&b, "<binary body: %d bytes>\n", len(body))
+		return b.String(), nil
+	}
 	b.WriteString(string(body))
 	b.WriteByte('\n')
 	return b.String(), nil
@@ -203,11 +209,20 @@ func formatFormFields(values url.Values) string {
 	return b.String()
 }
 
-// readBody consumes an io.Reader and returns its bytes. Returns an empty
-// slice when r is nil.
+// readBody consumes an io.Reader, closing it if it is an upload body, and
+// returns its bytes. Returns an empty slice when r is nil.
 func readBody(r io.Reader) ([]byte, error) {
 	if r == nil {
 		return nil, nil
 	}
+	defer closeBody(r)
 	return io.ReadAll(r)
 }
+
+// closeBody closes r when it holds open files (an upload body), so a body
+// that is read here rather than by the HTTP client doesn't leak them.
+func closeBody(r io.Reader) {
+	if c, ok := r.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
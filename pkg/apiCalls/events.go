@@ -0,0 +1,303 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+const (
+	// eventStreamType is the Content-Type of Server-Sent Events.
+	eventStreamType = "text/event-stream"
+	// transcriptType is the type an events transcript is saved as, so it
+	// gets the .ndjson extension.
+	transcriptType = "application/x-ndjson"
+)
+
+// Reasons an event stream stopped being read.
+const (
+	stopMaxEvents = "max_events reached"
+	stopUntil     = "until matched"
+	stopDuration  = "duration elapsed"
+	stopEOF       = "stream ended"
+)
+
+// eventReader reads event stream responses: Server-Sent Events always, and
+// with an events section any chunked response of newline-delimited records.
+type eventReader struct {
+	config *yamlparser.Events
+	// live receives each event as it arrives. Nil prints nothing.
+	live io.Writer
+}
+
+// streamEvent is one line of an events transcript.
+type streamEvent struct {
+	// Event is the SSE event type; empty for the default "message" type
+	// and for line-delimited streams.
+	Event string `json:"event,omitempty"`
+	ID    string `json:"id,omitempty"`
+	// Data is the event's data: embedded as is when it is JSON, otherwise
+	// as a string.
+	Data json.RawMessage `json:"data"`
+	// ElapsedMS is when the event arrived, in milliseconds after the
+	// response headers.
+	ElapsedMS int64 `json:"elapsed_ms"`
+}
+
+// eventsResult describes how an event stream was read.
+type eventsResult struct {
+	count   int
+	stop    string
+	elapsed time.Duration
+}
+
+// handles reports whether resp is an event stream r should read: SSE, or,
+// when the file has an events section, a body of unknown length.
+func (r *eventReader) handles(resp *http.Response) bool {
+	media, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return media == eventStreamType || (r.config != nil && resp.ContentLength < 0)
+}
+
+// read consumes the event stream in resp until a limit from the events
+// section is reached or the stream ends, printing events to r.live as they
+// arrive. It returns the NDJSON transcript, one streamEvent per line. The
+// body is closed when read returns. On a read error the transcript so far
+// is returned with the error.
+func (r *eventReader) read(resp *http.Response) ([]byte, eventsResult, error) {
+	defer func() { _ = resp.Body.Close() }()
+	media, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return r.collect(func(emit emitFunc) error {
+		if media == eventStreamType {
+			return readSSE(resp.Body, emit)
+		}
+		return readLines(resp.Body, emit)
+	}, func() { _ = resp.Body.Close() })
+}
+
+// emitFunc hands one event to the reader; it returns false once a limit
+// is reached and the source should stop.
+type emitFunc func(ev streamEvent, data string) bool
+
+// collect runs source, recording every event it emits in the transcript
+// until a limit from the events section is reached or source returns.
+// When the duration limit passes, stop is called to unblock source; the
+// error source then returns is not a failure.
+func (r *eventReader) collect(source func(emit emitFunc) error, stop func()) ([]byte, eventsResult, error) {
+	until, err := r.config.UntilRegexp()
+	if err != nil {
+		return nil, eventsResult{}, err
+	}
+	maxEvents := 0
+	if r.config != nil {
+		maxEvents = r.config.MaxEvents
+	}
+
+	start := time.Now()
+	var timedOut atomic.Bool
+	if d, _ := r.config.ParsedDuration(); d > 0 {
+		timer := time.AfterFunc(d, func() {
+			timedOut.Store(true)
+			stop()
+		})
+		defer timer.Stop()
+	}
+
+	var (
+		transcript bytes.Buffer
+		result     eventsResult
+	)
+	emit := func(ev streamEvent, data string) bool {
+		ev.Data = eventData(data)
+		ev.ElapsedMS = time.Since(start).Milliseconds()
+		line, _ := json.Marshal(ev) // strings and valid JSON always marshal
+		transcript.Write(line)
+		transcript.WriteByte('\n')
+		result.count++
+		r.print(ev.Event, data)
+
+		switch {
+		case until != nil && (until.MatchString(data) || (ev.Event != "" && until.MatchString(ev.Event))):
+			result.stop = stopUntil
+		case maxEvents > 0 && result.count >= maxEvents:
+			result.stop = stopMaxEvents
+		}
+		return result.stop == ""
+	}
+
+	err = source(emit)
+	result.elapsed = time.Since(start)
+	switch {
+	case result.stop != "":
+	case timedOut.Load():
+		result.stop, err = stopDuration, nil
+	case err == nil:
+		result.stop = stopEOF
+	default:
+		err = fmt.Errorf("reading event stream after %d events: %w", result.count, err)
+	}
+	return transcript.Bytes(), result, err
+}
+
+// print writes one event to r.live: its data, prefixed with the type for
+// named SSE events.
+func (r *eventReader) print(event, data string) {
+	if r.live == nil {
+		return
+	}
+	if event != "" {
+		data = event + ": " + data
+	}
+	_, _ = io.WriteString(r.live, data+"\n")
+}
+
+// eventData keeps JSON data as JSON, compacted so its transcript line
+// stays one line, and quotes anything else.
+func eventData(data string) json.RawMessage {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(data)); err == nil {
+		return compact.Bytes()
+	}
+	quoted, _ := json.Marshal(data)
+	return quoted
+}
+
+// readSSE parses a text/event-stream body per the HTML Living Standard:
+// "field: value" lines, a blank line dispatching the event, ":" comments.
+// emit returns false to stop reading. An event cut off by the end of the
+// stream (no closing blank line) is dropped, as browsers do.
+func readSSE(body io.Reader, emit emitFunc) error {
+	var (
+		ev      streamEvent
+		data    strings.Builder
+		hasData bool
+	)
+	return scanLines(body, func(line string) bool {
+		if line == "" {
+			if !hasData {
+				ev = streamEvent{ID: ev.ID}
+				return true
+			}
+			keepGoing := emit(ev, strings.TrimSuffix(data.String(), "\n"))
+			id := ev.ID // the last event ID carries over to later events
+			ev, hasData = streamEvent{ID: id}, false
+			data.Reset()
+			return keepGoing
+		}
+		if strings.HasPrefix(line, ":") {
+			return true
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+			if value == "message" {
+				ev.Event = ""
+			}
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				ev.ID = value
+			}
+		}
+		return true
+	})
+}
+
+// readLines treats every non-blank line of body as one event, for chunked
+// NDJSON and similar streams.
+func readLines(body io.Reader, emit emitFunc) error {
+	return scanLines(body, func(line string) bool {
+		if strings.TrimSpace(line) == "" {
+			return true
+		}
+		return emit(streamEvent{}, line)
+	})
+}
+
+// scanLines calls fn with each line of body, without its line ending,
+// until fn returns false or the body ends. Lines have no length limit.
+func scanLines(body io.Reader, fn func(line string) bool) error {
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" || err == nil {
+			if !fn(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")) {
+				return nil
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// eventsSummary is the line printed after an event stream, e.g.
+// "12 events in 3.2s (until matched), transcript saved to chat_response.ndjson".
+// savedPath is "" when nothing was written.
+func eventsSummary(result *eventsResult, savedPath string) string {
+	summary := fmt.Sprintf(
+		"%d events in %s (%s)", result.count, result.elapsed.Round(100*time.Millisecond), result.stop,
+	)
+	if savedPath == "" {
+		return summary
+	}
+	return summary + ", transcript saved to " + savedPath
+}
+
+// jsonBody is the body JSON assertions and captures read: rawBody, or for
+// an event stream its transcript as a JSON array, so `[0].data.id` picks a
+// field of the first event.
+func (r *CustomResponse) jsonBody() []byte {
+	if r.events == nil {
+		return r.rawBody
+	}
+	lines := bytes.Split(bytes.TrimSuffix(r.rawBody, []byte("\n")), []byte("\n"))
+	if len(r.rawBody) == 0 {
+		lines = nil
+	}
+	return append(append([]byte("["), bytes.Join(lines, []byte(","))...), ']')
+}
+
+// readEvents reads an event stream response into a CustomResponse whose
+// body is the NDJSON transcript. Status and headers are the stream's own,
+// so status and header assertions work as usual; body assertions and
+// captures see the transcript. A read error is returned along with the
+// events read before it.
+func readEvents(
+	req *http.Request,
+	resp *http.Response,
+	duration time.Duration,
+	debug bool,
+	reqBody []byte,
+	events *eventReader,
+) (CustomResponse, error) {
+	transcript, result, readErr := events.read(resp)
+	resp.Body = io.NopCloser(bytes.NewReader(transcript))
+	out, err := processResponse(req, resp, duration, debug, reqBody)
+	if err != nil {
+		return out, err
+	}
+	if !debug {
+		out.contentType = transcriptType
+	}
+	out.events = &result
+	return out, readErr
+}
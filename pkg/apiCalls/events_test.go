@@ -0,0 +1,230 @@
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := ": keep-alive comment\r\n" +
+		"data: {\"token\":\"Hel\"}\r\n\r\n" +
+		"event: message\nid: 7\ndata:lo\n\n" +
+		"event: usage\ndata: line one\ndata: line two\n\n" +
+		"id: 8\n\n" + // no data: not dispatched, but the id sticks
+		"data: after\n\n" +
+		"data: cut off without a blank line"
+
+	type got struct{ event, id, data string }
+	var events []got
+	err := readSSE(strings.NewReader(stream), func(ev streamEvent, data string) bool {
+		events = append(events, got{ev.Event, ev.ID, data})
+		return true
+	})
+	if err != nil {
+		t.Fatalf("readSSE: %v", err)
+	}
+
+	want := []got{
+		{"", "", `{"token":"Hel"}`},
+		{"", "7", "lo"},
+		{"usage", "7", "line one\nline two"},
+		{"", "8", "after"},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events =\n%+v\nwant\n%+v", events, want)
+	}
+}
+
+func TestEventReaderRead(t *testing.T) {
+	sse := "data: {\"n\":1}\n\ndata: {\"n\":2}\n\nevent: done\ndata: [DONE]\n\ndata: {\"n\":3}\n\n"
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		config      *yamlparser.Events
+		wantCount   int
+		wantStop    string
+		wantLive    string
+	}{
+		{
+			name:        "read to the end",
+			contentType: "text/event-stream; charset=utf-8",
+			body:        sse,
+			wantCount:   4,
+			wantStop:    stopEOF,
+			wantLive:    "{\"n\":1}\n{\"n\":2}\ndone: [DONE]\n{\"n\":3}\n",
+		},
+		{
+			name:        "max events",
+			contentType: "text/event-stream",
+			body:        sse,
+			config:      &yamlparser.Events{MaxEvents: 2},
+			wantCount:   2,
+			wantStop:    stopMaxEvents,
+			wantLive:    "{\"n\":1}\n{\"n\":2}\n",
+		},
+		{
+			name:        "until matches the data",
+			contentType: "text/event-stream",
+			body:        sse,
+			config:      &yamlparser.Events{Until: `^\[DONE\]$`},
+			wantCount:   3,
+			wantStop:    stopUntil,
+		},
+		{
+			name:        "until matches the event type",
+			contentType: "text/event-stream",
+			body:        sse,
+			config:      &yamlparser.Events{Until: `^done$`},
+			wantCount:   3,
+			wantStop:    stopUntil,
+		},
+		{
+			name:        "newline-delimited records",
+			contentType: "application/x-ndjson",
+			body:        "{\"n\":1}\n\n{\"n\":2}",
+			config:      &yamlparser.Events{},
+			wantCount:   2,
+			wantStop:    stopEOF,
+			wantLive:    "{\"n\":1}\n{\"n\":2}\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var live bytes.Buffer
+			reader := &eventReader{config: tc.config, live: &live}
+			resp := &http.Response{
+				Header:        http.Header{"Content-Type": {tc.contentType}},
+				ContentLength: -1,
+				Body:          io.NopCloser(strings.NewReader(tc.body)),
+			}
+			if !reader.handles(resp) {
+				t.Fatal("handles() = false, want true")
+			}
+
+			transcript, result, err := reader.read(resp)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if result.count != tc.wantCount || result.stop != tc.wantStop {
+				t.Errorf("count %d, stop %q; want %d, %q", result.count, result.stop, tc.wantCount, tc.wantStop)
+			}
+			if tc.wantLive != "" && live.String() != tc.wantLive {
+				t.Errorf("live = %q, want %q", live.String(), tc.wantLive)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(string(transcript), "\n"), "\n")
+			if len(lines) != tc.wantCount {
+				t.Fatalf("transcript has %d lines, want %d:\n%s", len(lines), tc.wantCount, transcript)
+			}
+			var first streamEvent
+			if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+				t.Fatalf("transcript line is not JSON: %v", err)
+			}
+			if string(first.Data) != `{"n":1}` {
+				t.Errorf("first data = %s, want the JSON embedded as is", first.Data)
+			}
+		})
+	}
+}
+
+func TestEventReaderHandles(t *testing.T) {
+	chunked := &http.Response{Header: http.Header{"Content-Type": {"application/json"}}, ContentLength: -1}
+	if (&eventReader{}).handles(chunked) {
+		t.Error("a chunked response without an events section should be read as usual")
+	}
+	if !(&eventReader{config: &yamlparser.Events{}}).handles(chunked) {
+		t.Error("a chunked response with an events section should be read as events")
+	}
+	sized := &http.Response{Header: http.Header{"Content-Type": {"application/json"}}, ContentLength: 10}
+	if (&eventReader{config: &yamlparser.Events{}}).handles(sized) {
+		t.Error("a response with a length is not a stream")
+	}
+}
+
+// TestSendRequest_EventsDuration verifies the duration limit ends a stream
+// the server keeps open, keeping the events received so far.
+func TestSendRequest_EventsDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: hello\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	var live bytes.Buffer
+	read := readOptions{events: &eventReader{
+		config: &yamlparser.Events{Duration: "200ms"},
+		live:   &live,
+	}}
+	start := time.Now()
+	resp, err := sendRequest(
+		context.Background(),
+		yamlparser.APIInfo{Method: http.MethodGet, URL: server.URL},
+		false, http.DefaultClient, read,
+	)
+	if err != nil {
+		t.Fatalf("sendRequest: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("took %s; the duration limit did not stop the stream", elapsed)
+	}
+	if resp.events == nil || resp.events.count != 1 || resp.events.stop != stopDuration {
+		t.Fatalf("events = %+v, want 1 event stopped by duration", resp.events)
+	}
+	if live.String() != "hello\n" {
+		t.Errorf("live = %q, want the event data", live.String())
+	}
+	if resp.contentType != transcriptType || resp.header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("contentType %q, header %q", resp.contentType, resp.header.Get("Content-Type"))
+	}
+
+	dir := t.TempDir()
+	_, summary, err := serializeAndSaveRow(&resp, filepath.Join(dir, "chat.hk.yaml"), "", 0)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	wantPath := filepath.Join(dir, "chat.hk_response.ndjson")
+	if !strings.HasPrefix(summary, "1 events in ") || !strings.HasSuffix(summary, "(duration elapsed), transcript saved to "+wantPath) {
+		t.Errorf("summary = %q", summary)
+	}
+}
+
+func TestJSONBody_Events(t *testing.T) {
+	tests := []struct {
+		name string
+		resp CustomResponse
+		want string
+	}{
+		{"plain body", CustomResponse{rawBody: []byte(`{"a":1}`)}, `{"a":1}`},
+		{"no events", CustomResponse{events: &eventsResult{}}, `[]`},
+		{
+			"transcript",
+			CustomResponse{
+				rawBody: []byte("{\"data\":1,\"elapsed_ms\":0}\n{\"data\":\"x\",\"elapsed_ms\":5}\n"),
+				events:  &eventsResult{count: 2},
+			},
+			`[{"data":1,"elapsed_ms":0},{"data":"x","elapsed_ms":5}]`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(tc.resp.jsonBody()); got != tc.want {
+				t.Errorf("jsonBody() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
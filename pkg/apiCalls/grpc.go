@@ -0,0 +1,520 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// The server reflection method, in the current and the older package. Both
+// speak the same messages.
+const (
+	reflectionV1      = "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo"
+	reflectionV1Alpha = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+)
+
+// SendGRPC calls the unary or server-streaming method in the gRPC file at
+// opts.Path and saves the response next to the file as JSON, like an HTTP
+// response. A server-streaming response is read like an event stream:
+// messages print to opts.EventOut as they arrive, the file's events
+// section limits how many are read, and they are saved as an NDJSON
+// transcript.
+//
+// result.Status is the gRPC status code, e.g. "OK" or "NotFound". A
+// non-OK status fails the request with its message. With opts.Debug, the
+// saved response also carries the method, status, and response metadata.
+//
+// When opts.DryRun is true, the call is printed to stdout and nothing is
+// sent.
+func SendGRPC(ctx context.Context, opts RequestOptions) (RequestResult, error) {
+	file, err := yamlparser.FinalStructForGRPC(opts.Path, opts.Secrets)
+	if err != nil {
+		return RequestResult{}, err
+	}
+
+	if opts.DryRun {
+		out, err := FormatGRPCDryRun(&file, opts.Show)
+		if err != nil {
+			return RequestResult{}, err
+		}
+		fmt.Print(out)
+		return RequestResult{}, nil
+	}
+
+	if file.Headers, err = file.Bearer().Apply(file.Headers); err != nil {
+		return RequestResult{}, err
+	}
+	conn, err := dialGRPC(&file)
+	if err != nil {
+		return RequestResult{}, err
+	}
+	defer func() { _ = conn.Close() }()
+	ctx = metadata.NewOutgoingContext(ctx, metadata.New(file.Headers))
+
+	method, err := resolveMethod(ctx, conn, &file, opts.Path)
+	if err != nil {
+		return RequestResult{Attempts: 1}, err
+	}
+	if method.IsStreamingClient() {
+		return RequestResult{}, fmt.Errorf(
+			"%s is a client-streaming method; only unary and server-streaming methods can be called",
+			method.FullName(),
+		)
+	}
+	msgJSON, _ := file.MessageJSON() // checked by FinalStructForGRPC
+	req := dynamicpb.NewMessage(method.Input())
+	if err := protojson.Unmarshal(msgJSON, req); err != nil {
+		return RequestResult{}, fmt.Errorf("message doesn't match %s: %w", method.Input().FullName(), err)
+	}
+
+	call := grpcCall{conn: conn, method: method, path: grpcMethodPath(method), debug: opts.Debug}
+	if method.IsStreamingServer() {
+		return call.stream(ctx, req, &opts, &eventReader{config: file.Events, live: opts.EventOut})
+	}
+	return call.unary(ctx, req, &opts)
+}
+
+// dialGRPC creates a client for the file's server, with TLS unless the
+// file asks for plaintext. It connects on the first call, through the
+// file's proxy like an HTTP request.
+func dialGRPC(file *yamlparser.GRPCFile) (*grpc.ClientConn, error) {
+	addr, plaintext, err := file.Target()
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := file.ClientTLS()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	creds := credentials.NewTLS(tlsConfig)
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+	proxy := file.ClientProxy()
+	dial := func(ctx context.Context, addr string) (net.Conn, error) {
+		return proxy.DialContext(ctx, addr, !plaintext)
+	}
+	// passthrough hands the dialer the host, not an address resolved here,
+	// so no_proxy rules match it and the proxy resolves it.
+	conn, err := grpc.NewClient("passthrough:///"+addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithContextDialer(dial),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("grpc client for %s: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// descriptorFinder looks up a descriptor by its full name, in compiled
+// .proto files or in the files the server reflected.
+type descriptorFinder interface {
+	FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error)
+}
+
+// resolveMethod finds the descriptor of the file's method, in its Protos
+// when set and otherwise through server reflection.
+func resolveMethod(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	file *yamlparser.GRPCFile,
+	path string,
+) (protoreflect.MethodDescriptor, error) {
+	service, name, err := file.ServiceMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		finder descriptorFinder
+		source string
+	)
+	if len(file.Protos) > 0 {
+		finder, err = compileProtos(ctx, path, file.Protos)
+		source = "protos"
+	} else {
+		finder, err = reflectFiles(ctx, conn, service)
+		source = "server reflection"
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := finder.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found in %s", service, source)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	method := sd.Methods().ByName(protoreflect.Name(name))
+	if method == nil {
+		return nil, fmt.Errorf("service %s has no method %s", service, name)
+	}
+	return method, nil
+}
+
+// compileProtos parses the .proto files at paths, resolved like getFile
+// relative to the request file at requestPath. Imports resolve from each
+// file's directory, then the project root; the well-known types are
+// built in.
+func compileProtos(ctx context.Context, requestPath string, paths []string) (descriptorFinder, error) {
+	var importPaths, names []string
+	seen := map[string]bool{}
+	addImportPath := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			importPaths = append(importPaths, dir)
+		}
+	}
+	for _, p := range paths {
+		abs, err := utils.ResolveRequestFile(requestPath, p)
+		if err != nil {
+			return nil, fmt.Errorf("protos entry %q: %w", p, err)
+		}
+		addImportPath(filepath.Dir(abs))
+		names = append(names, filepath.Base(abs))
+	}
+	if root, ok := utils.FindProjectRoot(); ok {
+		addImportPath(root)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	files, err := compiler.Compile(ctx, names...)
+	if err != nil {
+		return nil, fmt.Errorf("compiling protos: %w", err)
+	}
+	return files.AsResolver(), nil
+}
+
+// reflectFiles asks the server's reflection service for the file that
+// defines symbol and every file it imports, trying v1 first and then
+// v1alpha for older servers.
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, symbol string) (*protoregistry.Files, error) {
+	files, err := reflectFilesVia(ctx, conn, reflectionV1, symbol)
+	if status.Code(err) == codes.Unimplemented {
+		files, err = reflectFilesVia(ctx, conn, reflectionV1Alpha, symbol)
+	}
+	switch status.Code(err) {
+	case codes.OK:
+		return files, nil
+	case codes.Unimplemented:
+		return nil, errors.New("the server doesn't support reflection; list the method's .proto files in protos")
+	case codes.NotFound:
+		return nil, fmt.Errorf("service %s not found in server reflection", symbol)
+	default:
+		return nil, fmt.Errorf("server reflection: %w", grpcError(err))
+	}
+}
+
+// reflectFilesVia runs the reflection conversation over the given method.
+// Imports the server doesn't return are taken from the well-known types
+// compiled into hulak.
+func reflectFilesVia(
+	ctx context.Context,
+	conn *grpc.ClientConn,
+	method, symbol string,
+) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true, ServerStreams: true}, method)
+	if err != nil {
+		return nil, err
+	}
+
+	fds := map[string]*descriptorpb.FileDescriptorProto{}
+	ask := func(req *rpb.ServerReflectionRequest) error {
+		if err := stream.SendMsg(req); err != nil {
+			return err
+		}
+		resp := new(rpb.ServerReflectionResponse)
+		if err := stream.RecvMsg(resp); err != nil {
+			return err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := new(descriptorpb.FileDescriptorProto)
+			if err := proto.Unmarshal(raw, fd); err != nil {
+				return fmt.Errorf("decoding reflected file: %w", err)
+			}
+			fds[fd.GetName()] = fd
+		}
+		return nil
+	}
+
+	err = ask(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for missing := missingImport(fds); missing != ""; missing = missingImport(fds) {
+		if builtin, err := protoregistry.GlobalFiles.FindFileByPath(missing); err == nil {
+			fds[missing] = protodesc.ToFileDescriptorProto(builtin)
+			continue
+		}
+		err := ask(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: missing},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if fds[missing] == nil {
+			return nil, fmt.Errorf("server reflection didn't return %s", missing)
+		}
+	}
+	_ = stream.CloseSend()
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range fds {
+		set.File = append(set.File, fd)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("reflected files: %w", err)
+	}
+	return files, nil
+}
+
+// missingImport returns an import of fds that isn't in fds yet, or "".
+func missingImport(fds map[string]*descriptorpb.FileDescriptorProto) string {
+	for _, fd := range fds {
+		for _, dep := range fd.GetDependency() {
+			if fds[dep] == nil {
+				return dep
+			}
+		}
+	}
+	return ""
+}
+
+// grpcMethodPath is the method's wire name, "/package.Service/Method".
+func grpcMethodPath(method protoreflect.MethodDescriptor) string {
+	return fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+}
+
+// grpcError turns a status error into "NotFound: user 7 not found".
+func grpcError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("%s: %s", st.Code(), st.Message())
+}
+
+// grpcCall is one call of a resolved method.
+type grpcCall struct {
+	conn   *grpc.ClientConn
+	method protoreflect.MethodDescriptor
+	path   string
+	debug  bool
+}
+
+// grpcDebugResponse is what a call saves with --debug.
+type grpcDebugResponse struct {
+	Method   string            `json:"method"`
+	Status   string            `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Trailers map[string]string `json:"trailers,omitempty"`
+	Response json.RawMessage   `json:"response,omitempty"`
+	Duration string            `json:"duration"`
+}
+
+// unary sends req and saves the response message as JSON.
+func (c *grpcCall) unary(ctx context.Context, req proto.Message, opts *RequestOptions) (RequestResult, error) {
+	var header, trailer metadata.MD
+	resp := dynamicpb.NewMessage(c.method.Output())
+	start := time.Now()
+	callErr := c.conn.Invoke(ctx, c.path, req, resp, grpc.Header(&header), grpc.Trailer(&trailer))
+	elapsed := time.Since(start)
+
+	code := status.Code(callErr)
+	result := RequestResult{Status: code.String(), Attempts: 1}
+	var body json.RawMessage
+	if callErr == nil {
+		raw, err := protojson.Marshal(resp)
+		if err != nil {
+			return result, fmt.Errorf("encoding response: %w", err)
+		}
+		body = raw
+	}
+	if c.debug {
+		body, _ = json.Marshal(grpcDebugResponse{
+			Method:   c.path,
+			Status:   code.String(),
+			Headers:  flattenMetadata(header),
+			Trailers: flattenMetadata(trailer),
+			Response: body,
+			Duration: elapsed.String(),
+		})
+	}
+	if callErr != nil && !c.debug {
+		return result, grpcError(callErr)
+	}
+
+	result.Body = defaultBodyForOutput(body)
+	if !opts.NoSave {
+		if _, err := writeFile(opts.Path, ".json", result.Body, opts.OutPath, opts.Row); err != nil {
+			return result, errors.Join(grpcErrorOrNil(callErr), err)
+		}
+	}
+	return result, grpcErrorOrNil(callErr)
+}
+
+// stream sends req and reads the response messages as events until the
+// server ends the stream or a limit from the events section is reached.
+// The messages are saved as an NDJSON transcript, one per line.
+func (c *grpcCall) stream(
+	ctx context.Context,
+	req proto.Message,
+	opts *RequestOptions,
+	events *eventReader,
+) (RequestResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	result := RequestResult{Attempts: 1}
+
+	stream, err := c.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, c.path)
+	if err != nil {
+		result.Status = status.Code(err).String()
+		return result, grpcError(err)
+	}
+	if err := stream.SendMsg(req); err != nil && !errors.Is(err, io.EOF) {
+		result.Status = status.Code(err).String()
+		return result, grpcError(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return result, grpcError(err)
+	}
+
+	var callErr error
+	transcript, read, err := events.collect(func(emit emitFunc) error {
+		for {
+			resp := dynamicpb.NewMessage(c.method.Output())
+			if err := stream.RecvMsg(resp); err != nil {
+				if errors.Is(err, io.EOF) {
+					return nil
+				}
+				callErr = err
+				return nil
+			}
+			data, err := protojson.Marshal(resp)
+			if err != nil {
+				return fmt.Errorf("encoding response: %w", err)
+			}
+			if !emit(streamEvent{}, string(data)) {
+				return nil
+			}
+		}
+	}, cancel)
+	if read.stop == stopDuration {
+		callErr = nil // the cancel that ended the wait
+	}
+
+	result.Status = status.Code(callErr).String()
+	result.Body = transcript
+	runErr := errors.Join(err, grpcErrorOrNil(callErr))
+	if opts.NoSave {
+		result.Summary = eventsSummary(&read, "")
+		return result, runErr
+	}
+	saved, saveErr := writeFile(opts.Path, ".ndjson", transcript, opts.OutPath, opts.Row)
+	result.Summary = eventsSummary(&read, saved)
+	return result, errors.Join(runErr, saveErr)
+}
+
+// grpcErrorOrNil is grpcError for a possibly nil err.
+func grpcErrorOrNil(err error) error {
+	if err == nil {
+		return nil
+	}
+	return grpcError(err)
+}
+
+// flattenMetadata joins repeated metadata values with ", ", like HTTP
+// headers.
+func flattenMetadata(md metadata.MD) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(md))
+	for k, vals := range md {
+		out[k] = strings.Join(vals, ", ")
+	}
+	return out
+}
+
+// FormatGRPCDryRun renders the call in a gRPC file without connecting.
+// Sensitive headers are masked unless show is true.
+func FormatGRPCDryRun(file *yamlparser.GRPCFile, show bool) (string, error) {
+	addr, plaintext, err := file.Target()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	transport := "tls"
+	if plaintext {
+		transport = "plaintext"
+	}
+	fmt.Fprintf(&b, "GRPC %s %s (%s)\n", strings.TrimPrefix(file.Method, "/"), addr, transport)
+
+	headers := utils.RedactHeaders(file.Headers, show)
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s: %s\n", k, headers[k])
+	}
+	if len(file.Protos) > 0 {
+		fmt.Fprintf(&b, "protos: %s\n", strings.Join(file.Protos, ", "))
+	} else {
+		b.WriteString("descriptors: server reflection\n")
+	}
+
+	msg, err := file.MessageJSON()
+	if err != nil {
+		return "", err
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, msg, "", "  "); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(&b, "\n%s\n", pretty.String())
+	return b.String(), nil
+}
@@ -0,0 +1,304 @@
+package apicalls
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// healthProto declares grpc.health.v1 like the server's compiled-in copy.
+const healthProto = `syntax = "proto3";
+package grpc.health.v1;
+
+message HealthCheckRequest { string service = 1; }
+
+message HealthCheckResponse {
+  enum ServingStatus {
+    UNKNOWN = 0;
+    SERVING = 1;
+    NOT_SERVING = 2;
+    SERVICE_UNKNOWN = 3;
+  }
+  ServingStatus status = 1;
+}
+
+service Health {
+  rpc Check(HealthCheckRequest) returns (HealthCheckResponse);
+  rpc Watch(HealthCheckRequest) returns (stream HealthCheckResponse);
+}
+`
+
+// newHealthServer serves the standard health service on a local port,
+// with server reflection when reflect is true. The authorization metadata
+// of the last call is stored in gotAuth.
+func newHealthServer(t *testing.T, reflect bool, gotAuth *string) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(ctx context.Context) {
+		if md, ok := metadata.FromIncomingContext(ctx); ok && gotAuth != nil && len(md["authorization"]) > 0 {
+			*gotAuth = md["authorization"][0]
+		}
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+			record(ctx)
+			return h(ctx, req)
+		}),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(server, hs)
+	if reflect {
+		reflection.Register(server)
+	}
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return lis.Addr().String()
+}
+
+// writeGRPCFile writes a gRPC request file into dir and returns its path.
+func writeGRPCFile(t *testing.T, dir, name, doc string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSendGRPC_Unary(t *testing.T) {
+	var gotAuth string
+	addr := newHealthServer(t, true, &gotAuth)
+	path := writeGRPCFile(t, t.TempDir(), "check.hk.yaml", `kind: gRPC
+url: `+addr+`
+method: grpc.health.v1.Health/Check
+plaintext: true
+headers:
+  Authorization: Bearer t0k
+message:
+  service: users
+`)
+
+	result, err := SendGRPC(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path})
+	if err != nil {
+		t.Fatalf("SendGRPC: %v", err)
+	}
+	if result.Status != "OK" || result.Attempts != 1 || gotAuth != "Bearer t0k" {
+		t.Errorf("status %q, attempts %d, authorization %q", result.Status, result.Attempts, gotAuth)
+	}
+	want := "{\n  \"status\": \"NOT_SERVING\"\n}"
+	if string(result.Body) != want {
+		t.Errorf("body = %s, want %s", result.Body, want)
+	}
+	saved, err := os.ReadFile(filepath.Join(filepath.Dir(path), "check.hk_response.json"))
+	if err != nil || string(saved) != want {
+		t.Errorf("saved = %s, %v", saved, err)
+	}
+}
+
+func TestSendGRPC_Proxy(t *testing.T) {
+	addr := newHealthServer(t, true, nil)
+	proxyURL, asked := newConnectProxy(t, addr)
+	path := writeGRPCFile(t, t.TempDir(), "check.hk.yaml", `kind: gRPC
+url: grpc.example.test:50051
+method: grpc.health.v1.Health/Check
+plaintext: true
+proxy: `+proxyURL+`
+message:
+  service: users
+`)
+
+	result, err := SendGRPC(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path, NoSave: true})
+	if err != nil {
+		t.Fatalf("SendGRPC: %v", err)
+	}
+	if result.Status != "OK" {
+		t.Errorf("status = %q, want OK", result.Status)
+	}
+	if got := asked(); len(got) == 0 || got[0] != "grpc.example.test:50051" {
+		t.Errorf("proxy asked for %v, want the file's host", got)
+	}
+}
+
+func TestSendGRPC_Errors(t *testing.T) {
+	tests := []struct {
+		name       string
+		reflect    bool
+		method     string
+		message    string
+		wantStatus string
+		wantErr    string
+	}{
+		{
+			name:       "status error",
+			reflect:    true,
+			method:     "grpc.health.v1.Health/Check",
+			message:    "{service: billing}",
+			wantStatus: "NotFound",
+			wantErr:    "NotFound: unknown service",
+		},
+		{
+			name:    "unknown field",
+			reflect: true,
+			method:  "grpc.health.v1.Health/Check",
+			message: "{name: users}",
+			wantErr: "message doesn't match grpc.health.v1.HealthCheckRequest",
+		},
+		{
+			name:    "unknown method",
+			reflect: true,
+			method:  "grpc.health.v1.Health/List",
+			message: "{}",
+			wantErr: "service grpc.health.v1.Health has no method List",
+		},
+		{
+			name:    "unknown service",
+			reflect: true,
+			method:  "users.v1.Users/Get",
+			message: "{}",
+			wantErr: "service users.v1.Users not found in server reflection",
+		},
+		{
+			name:    "no reflection",
+			method:  "grpc.health.v1.Health/Check",
+			message: "{}",
+			wantErr: "doesn't support reflection",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := newHealthServer(t, tc.reflect, nil)
+			path := writeGRPCFile(t, t.TempDir(), "call.hk.yaml",
+				"kind: gRPC\nurl: "+addr+"\nplaintext: true\nmethod: "+tc.method+"\nmessage: "+tc.message+"\n")
+
+			result, err := SendGRPC(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+			if result.Status != tc.wantStatus {
+				t.Errorf("status = %q, want %q", result.Status, tc.wantStatus)
+			}
+		})
+	}
+}
+
+// TestSendGRPC_Protos verifies descriptors come from .proto files when the
+// server has no reflection, resolved like getFile.
+func TestSendGRPC_Protos(t *testing.T) {
+	project := t.TempDir()
+	for _, dir := range []string{"env", "protos", "requests"} {
+		if err := os.Mkdir(filepath.Join(project, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{"protos/health.proto", "requests/check.proto"} {
+		if err := os.WriteFile(filepath.Join(project, p), []byte(healthProto), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(project)
+
+	for _, protos := range []string{"protos/health.proto", "'*.proto'"} {
+		t.Run(protos, func(t *testing.T) {
+			addr := newHealthServer(t, false, nil)
+			path := writeGRPCFile(t, filepath.Join(project, "requests"), "check.hk.yaml",
+				"kind: gRPC\nurl: "+addr+"\nplaintext: true\nmethod: grpc.health.v1.Health/Check\nprotos:\n  - "+protos+"\n")
+
+			result, err := SendGRPC(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path, NoSave: true})
+			if err != nil {
+				t.Fatalf("SendGRPC: %v", err)
+			}
+			if !strings.Contains(string(result.Body), `"status": "SERVING"`) {
+				t.Errorf("body = %s", result.Body)
+			}
+		})
+	}
+}
+
+// TestSendGRPC_ServerStreaming verifies a server-streaming response is
+// read like an event stream, until a limit from the events section.
+func TestSendGRPC_ServerStreaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   string
+		wantStop string
+	}{
+		{"max_events", "events:\n  max_events: 1\n", stopMaxEvents},
+		{"duration", "events:\n  duration: 300ms\n", stopDuration},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := newHealthServer(t, true, nil)
+			path := writeGRPCFile(t, t.TempDir(), "watch.hk.yaml",
+				"kind: gRPC\nurl: "+addr+"\nplaintext: true\nmethod: grpc.health.v1.Health/Watch\n"+tc.events)
+
+			var live strings.Builder
+			result, err := SendGRPC(context.Background(), RequestOptions{
+				Secrets:  map[string]any{},
+				Path:     path,
+				EventOut: &live,
+			})
+			if err != nil {
+				t.Fatalf("SendGRPC: %v", err)
+			}
+			saved := filepath.Join(filepath.Dir(path), "watch.hk_response.ndjson")
+			if result.Status != "OK" ||
+				!strings.HasPrefix(result.Summary, "1 events in ") ||
+				!strings.HasSuffix(result.Summary, "("+tc.wantStop+"), transcript saved to "+saved) {
+				t.Errorf("status %q, summary %q", result.Status, result.Summary)
+			}
+			if live.String() != "{\"status\":\"SERVING\"}\n" {
+				t.Errorf("live output = %q", live.String())
+			}
+			content, err := os.ReadFile(saved)
+			if err != nil || !strings.HasPrefix(string(content), `{"data":{"status":"SERVING"},"elapsed_ms":`) {
+				t.Errorf("transcript = %s, %v", content, err)
+			}
+		})
+	}
+}
+
+func TestFormatGRPCDryRun(t *testing.T) {
+	path := writeGRPCFile(t, t.TempDir(), "check.hk.yaml", `kind: gRPC
+url: https://health.example.com
+method: /grpc.health.v1.Health/Check
+headers:
+  Authorization: Bearer secret
+message:
+  service: users
+`)
+	file, err := yamlparser.FinalStructForGRPC(path, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FormatGRPCDryRun(&file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"GRPC grpc.health.v1.Health/Check health.example.com:443 (tls)\n",
+		"descriptors: server reflection\n",
+		"\n{\n  \"service\": \"users\"\n}\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("dry run printed the Authorization header:\n%s", out)
+	}
+}
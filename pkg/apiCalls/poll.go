@@ -0,0 +1,107 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// sendFunc sends the request once (with retries) and reports how many
+// attempts it took. pollUntil calls it once per poll.
+type sendFunc func(ctx context.Context) (CustomResponse, int, error)
+
+// pollUntil re-sends the request every poll interval until the response
+// meets poll.Until, then returns that response. Returns the total number of
+// requests sent across all polls, retries included.
+//
+// The condition is evaluated with checkAssertions, so JSON paths resolve
+// exactly like getValueOf. A transport error (after retries) stops polling
+// and is returned as is. When the poll timeout or ctx ends first, the last
+// response is returned with an error naming the checks that never held.
+// A nil poll sends the request once.
+func pollUntil(
+	ctx context.Context,
+	poll *yamlparser.Poll,
+	debug bool,
+	send sendFunc,
+) (CustomResponse, int, error) {
+	if poll == nil {
+		return send(ctx)
+	}
+
+	interval, err := poll.ParsedInterval()
+	if err != nil {
+		return CustomResponse{}, 0, err
+	}
+	if timeout, err := poll.ParsedTimeout(); err != nil {
+		return CustomResponse{}, 0, err
+	} else if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var (
+		start    = time.Now()
+		total    = 0
+		polls    = 0
+		last     CustomResponse
+		lastMiss error
+	)
+	for {
+		resp, attempts, err := send(ctx)
+		total += attempts
+		if err != nil {
+			if polls > 0 && ctx.Err() != nil {
+				return last, total, pollTimeoutError(polls, time.Since(start), lastMiss)
+			}
+			return resp, total, err
+		}
+		polls++
+
+		miss := checkAssertions(poll.Until, &resp)
+		if miss == nil {
+			return resp, total, nil
+		}
+		last, lastMiss = resp, miss
+
+		if debug {
+			headline, _, _ := strings.Cut(miss.Error(), "\n")
+			utils.PrintWarningStderr(fmt.Sprintf(
+				"poll %d: condition not met (%s); next in %s", polls, headline, interval,
+			))
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return last, total, pollTimeoutError(polls, time.Since(start), lastMiss)
+		case <-timer.C:
+		}
+	}
+}
+
+// pollTimeoutError reports a poll that gave up. The unmet checks from the
+// last response go on the detail lines so the outcome shows why.
+func pollTimeoutError(polls int, elapsed time.Duration, miss error) error {
+	noun := "requests"
+	if polls == 1 {
+		noun = "request"
+	}
+	msg := fmt.Sprintf(
+		"poll: condition not met after %d %s in %s", polls, noun, elapsed.Round(time.Millisecond),
+	)
+	if miss != nil {
+		if _, detail, ok := strings.Cut(miss.Error(), "\n"); ok {
+			msg += "\n" + detail
+		}
+	}
+	return errors.New(msg)
+}
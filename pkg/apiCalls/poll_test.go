@@ -0,0 +1,181 @@
+package apicalls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// jobServer answers {"status":"PENDING"} until the doneAfter-th request,
+// then {"status":"DONE"}. doneAfter <= 0 never finishes.
+func jobServer(t *testing.T, doneAfter int32) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	server := NewMockServerWithHandler(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if doneAfter > 0 && n >= doneAfter {
+			_, _ = fmt.Fprintf(w, `{"status":"DONE","n":%d}`, n)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = fmt.Fprintf(w, `{"status":"PENDING","n":%d}`, n)
+	})
+	t.Cleanup(server.Close)
+	return server.URL, &calls
+}
+
+func untilDone(interval, timeout string) *yamlparser.Poll {
+	return &yamlparser.Poll{
+		Interval: interval,
+		Timeout:  timeout,
+		Until: &yamlparser.Assert{
+			JSON: []yamlparser.JSONAssertion{{Path: "status", Equals: "DONE"}},
+		},
+	}
+}
+
+func sendOnce(url string) sendFunc {
+	return func(ctx context.Context) (CustomResponse, int, error) {
+		apiInfo := yamlparser.APIInfo{Method: "GET", URL: url}
+		resp, err := StandardCallWithClient(ctx, apiInfo, false, http.DefaultClient)
+		return resp, 1, err
+	}
+}
+
+func TestPollUntil(t *testing.T) {
+	t.Run("nil poll sends once", func(t *testing.T) {
+		url, calls := jobServer(t, 0)
+		_, total, err := pollUntil(context.Background(), nil, false, sendOnce(url))
+		if err != nil || total != 1 || calls.Load() != 1 {
+			t.Errorf("total = %d, calls = %d, err = %v; want one request", total, calls.Load(), err)
+		}
+	})
+
+	t.Run("stops when the condition holds", func(t *testing.T) {
+		url, calls := jobServer(t, 3)
+		resp, total, err := pollUntil(context.Background(), untilDone("5ms", ""), false, sendOnce(url))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if total != 3 || calls.Load() != 3 {
+			t.Errorf("total = %d, calls = %d, want 3", total, calls.Load())
+		}
+		if !strings.Contains(string(resp.rawBody), `"DONE"`) {
+			t.Errorf("returned response should be the final one, got %s", resp.rawBody)
+		}
+	})
+
+	t.Run("times out with the unmet check", func(t *testing.T) {
+		url, _ := jobServer(t, 0)
+		resp, _, err := pollUntil(context.Background(), untilDone("10ms", "50ms"), false, sendOnce(url))
+		if err == nil {
+			t.Fatal("expected a poll timeout error")
+		}
+		headline, detail, _ := strings.Cut(err.Error(), "\n")
+		if !strings.HasPrefix(headline, "poll: condition not met after") {
+			t.Errorf("headline = %q", headline)
+		}
+		if !strings.Contains(detail, "json status: got PENDING, want DONE") {
+			t.Errorf("detail = %q, want the unmet check", detail)
+		}
+		if resp.Response == nil || resp.Response.StatusCode != http.StatusAccepted {
+			t.Error("the last response should be returned with the timeout error")
+		}
+	})
+
+	t.Run("transport error stops polling", func(t *testing.T) {
+		boom := errors.New("boom")
+		calls := 0
+		send := func(context.Context) (CustomResponse, int, error) {
+			calls++
+			return CustomResponse{}, 1, boom
+		}
+		_, _, err := pollUntil(context.Background(), untilDone("1ms", ""), false, send)
+		if !errors.Is(err, boom) || calls != 1 {
+			t.Errorf("err = %v after %d calls, want boom after 1", err, calls)
+		}
+	})
+}
+
+// TestSendAndSaveAPIRequest_Poll verifies a poll section waits for the
+// condition and that the final response is what gets saved.
+func TestSendAndSaveAPIRequest_Poll(t *testing.T) {
+	url, _ := jobServer(t, 2)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "job.hk.yaml")
+	doc := "method: GET\nurl: " + url + "\npoll:\n  interval: 5ms\n  until:\n    status: 200\n" +
+		"    json:\n      - path: status\n        equals: DONE\n"
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	result, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+		Secrets: map[string]any{},
+		Path:    path,
+	})
+	if err != nil {
+		t.Fatalf("SendAndSaveAPIRequest: %v", err)
+	}
+	if result.Status != "200 OK" || result.Attempts != 2 {
+		t.Errorf("status = %q, attempts = %d; want 200 OK after 2", result.Status, result.Attempts)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*_response.*"))
+	if err != nil || len(matches) != 1 {
+		t.Fatalf("response files = %v, %v; want one", matches, err)
+	}
+	saved, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), `"DONE"`) {
+		t.Errorf("saved response = %s, want the final one", saved)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("poll took far longer than its interval")
+	}
+}
+
+// TestSendAndSaveAPIRequest_PollResendsBody verifies every poll sends the
+// full request body, not just the first.
+func TestSendAndSaveAPIRequest_PollResendsBody(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "job.hk.yaml")
+	doc := "method: POST\nurl: " + server.URL + "\nbody:\n  raw: ping\n" +
+		"poll:\n  interval: 5ms\n  until:\n    status: 200\n"
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+		Secrets: map[string]any{},
+		Path:    path,
+	}); err != nil {
+		t.Fatalf("SendAndSaveAPIRequest: %v", err)
+	}
+	if want := []string{"ping", "ping", "ping"}; !slices.Equal(bodies, want) {
+		t.Errorf("bodies = %q, want %q", bodies, want)
+	}
+}
@@ -48,19 +48,19 @@ func processResponse(
 		return CustomResponse{}, fmt.Errorf("reading response body: %w", err)
 	}
 
-	// Formatting the duration to two decimal points
-	durationFormatted := fmt.Sprintf(
-		"%.2fms",
-		float64(duration.Milliseconds())+float64(duration.Microseconds()%1000)/1000.0,
-	)
+	durationFormatted := formatDuration(duration)
+
+	contentType := resp.Header.Get("Content-Type")
 
 	var responseBody any
 	if err := json.Unmarshal(respBody, &responseBody); err != nil {
 		responseBody = string(respBody)
+		// Raw bytes would garble the --debug JSON; rawBody still holds them.
+		if debug && isBinaryBody(contentType, respBody) {
+			responseBody = binaryPlaceholder(contentType, len(respBody))
+		}
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-
 	if !debug {
 		// Return minimal set of data. rawBody is what the file writer
 		// and stdout printer actually emit in default mode; the wrapped
@@ -76,6 +76,8 @@ func processResponse(
 			Duration:    durationFormatted,
 			contentType: contentType,
 			rawBody:     respBody,
+			header:      resp.Header,
+			elapsed:     duration,
 		}, nil
 	}
 
@@ -132,6 +134,8 @@ func processResponse(
 		Duration:    durationFormatted,
 		contentType: contentType,
 		rawBody:     respBody,
+		header:      resp.Header,
+		elapsed:     duration,
 	}, nil
 }
 
@@ -201,3 +205,12 @@ func ListDirPaths(dir, dirseq string) (DirPath, error) {
 
 	return result, nil
 }
+
+// formatDuration renders a request duration in milliseconds to two decimal
+// points, e.g. "12.34ms".
+func formatDuration(duration time.Duration) string {
+	return fmt.Sprintf(
+		"%.2fms",
+		float64(duration.Milliseconds())+float64(duration.Microseconds()%1000)/1000.0,
+	)
+}
@@ -0,0 +1,185 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// callWithRetry sends the request through sendRequest, resending
+// it while the policy says the failure is retryable and attempts remain.
+// Returns the last response or error and the number of attempts made.
+//
+// When retries are allowed, the body is buffered once so every attempt
+// sends the same bytes. Waits honor ctx, so the file's timeout bounds all
+// attempts together; when ctx ends mid-wait the last result is returned as
+// is. The policy's AttemptTimeout bounds each attempt on its own.
+func callWithRetry(
+	ctx context.Context,
+	apiInfo yamlparser.APIInfo,
+	debug bool,
+	client httpclient.HTTPClient,
+	policy *yamlparser.RetryPolicy,
+	read readOptions,
+) (CustomResponse, int, error) {
+	// Only a request that may be resent needs its body in memory; a single
+	// attempt streams it.
+	var body []byte
+	if apiInfo.Body != nil && policy.Attempts > 1 {
+		var err error
+		if body, err = readBody(apiInfo.Body); err != nil {
+			return CustomResponse{}, 0, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		if body != nil {
+			apiInfo.Body = bytes.NewReader(body)
+		}
+		resp, err := sendAttempt(ctx, apiInfo, debug, client, policy, read)
+
+		reason := retryReason(ctx, policy, apiInfo.Method, &resp, err)
+		if attempt >= policy.Attempts || reason == "" {
+			return resp, attempt, err
+		}
+
+		wait := retryWait(policy, attempt, &resp)
+		if debug {
+			utils.PrintWarningStderr(fmt.Sprintf(
+				"attempt %d/%d: %s; retrying in %s", attempt, policy.Attempts, reason, wait,
+			))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, attempt, err
+		case <-timer.C:
+		}
+	}
+}
+
+// sendAttempt is one attempt of callWithRetry, ended after the policy's
+// AttemptTimeout when it has one.
+func sendAttempt(
+	ctx context.Context,
+	apiInfo yamlparser.APIInfo,
+	debug bool,
+	client httpclient.HTTPClient,
+	policy *yamlparser.RetryPolicy,
+	read readOptions,
+) (CustomResponse, error) {
+	if policy.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		defer cancel()
+	}
+	return sendRequest(ctx, apiInfo, debug, client, read)
+}
+
+// retryReason returns why the result should be retried, or "" when it
+// should not: a success, a status the policy doesn't list, an error class
+// the policy doesn't list, or the file's own deadline having passed. An
+// attempt that ran out its AttemptTimeout is a timeout.
+func retryReason(
+	ctx context.Context,
+	policy *yamlparser.RetryPolicy,
+	method string,
+	resp *CustomResponse,
+	err error,
+) string {
+	if ctx.Err() != nil {
+		return ""
+	}
+	if err == nil {
+		if resp.Response != nil && policy.RetriesStatus(resp.Response.StatusCode) {
+			return resp.Response.Status
+		}
+		return ""
+	}
+
+	var netErr net.Error
+	if (errors.As(err, &netErr) && netErr.Timeout()) || errors.Is(err, context.DeadlineExceeded) {
+		if policy.OnTimeout {
+			return "timeout"
+		}
+		return ""
+	}
+	if policy.OnConnection && isConnectionError(err, method) {
+		return "connection error"
+	}
+	return ""
+}
+
+// isConnectionError reports whether err means the server never got the
+// request: DNS failures, and connections that were refused or failed to
+// open. A connection reset or closed once the request was written may
+// come after the server acted on it, so it only counts for idempotent
+// methods, which are safe to send twice.
+func isConnectionError(err error, method string) bool {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	if errors.As(err, &dnsErr) ||
+		errors.As(err, &opErr) && opErr.Op == "dial" ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	return isIdempotent(method) &&
+		(errors.As(err, &opErr) ||
+			errors.Is(err, syscall.ECONNRESET) ||
+			errors.Is(err, io.EOF) ||
+			errors.Is(err, io.ErrUnexpectedEOF))
+}
+
+// isIdempotent reports whether sending a request with method twice has
+// the same effect as sending it once (RFC 9110, section 9.2.2).
+func isIdempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryWait returns how long to wait before retry number n. A Retry-After
+// header given in seconds raises the wait; MaxDelay caps it either way.
+func retryWait(policy *yamlparser.RetryPolicy, n int, resp *CustomResponse) time.Duration {
+	wait := policy.Backoff(n)
+	if policy.Jitter && wait > 0 {
+		half := wait / 2
+		wait = half + rand.N(wait-half+1) //nolint:gosec // G404: jitter needs no crypto randomness
+	}
+	if after := retryAfter(resp); after > wait {
+		wait = min(after, policy.MaxDelay)
+	}
+	return wait
+}
+
+// retryAfter reads a delay-seconds Retry-After header. HTTP-date values and
+// missing headers yield 0.
+func retryAfter(resp *CustomResponse) time.Duration {
+	if resp == nil || resp.header == nil {
+		return 0
+	}
+	secs, err := strconv.Atoi(resp.header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,259 @@
+package apicalls
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// flakyServer fails the first `failures` requests with status, then
+// answers 200. It records every request body it sees.
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32, *[]string) {
+	t.Helper()
+	var calls atomic.Int32
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls, &bodies
+}
+
+func fastPolicy(attempts int) *yamlparser.RetryPolicy {
+	return &yamlparser.RetryPolicy{
+		Attempts:     attempts,
+		Delay:        time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Status:       yamlparser.DefaultRetryStatus,
+		OnTimeout:    true,
+		OnConnection: true,
+	}
+}
+
+func TestCallWithRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		status       int
+		attempts     int
+		wantAttempts int
+		wantStatus   int
+	}{
+		{"succeeds after retries", 2, http.StatusBadGateway, 3, 3, http.StatusOK},
+		{"gives up when attempts run out", 5, http.StatusServiceUnavailable, 3, 3, http.StatusServiceUnavailable},
+		{"non-retryable status is returned at once", 5, http.StatusInternalServerError, 3, 1, http.StatusInternalServerError},
+		{"single attempt policy never retries", 1, http.StatusBadGateway, 1, 1, http.StatusBadGateway},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls, bodies := flakyServer(t, tc.failures, tc.status)
+			apiInfo := yamlparser.APIInfo{
+				Method: "POST",
+				URL:    server.URL,
+				Body:   strings.NewReader(`{"n":1}`),
+			}
+
+			resp, attempts, err := callWithRetry(
+				context.Background(), apiInfo, false, http.DefaultClient, fastPolicy(tc.attempts), readOptions{},
+			)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if attempts != tc.wantAttempts || int(calls.Load()) != tc.wantAttempts {
+				t.Errorf("attempts = %d, server calls = %d, want %d", attempts, calls.Load(), tc.wantAttempts)
+			}
+			if resp.Response.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d", resp.Response.StatusCode, tc.wantStatus)
+			}
+			for i, b := range *bodies {
+				if b != `{"n":1}` {
+					t.Errorf("attempt %d sent body %q, want the original body", i+1, b)
+				}
+			}
+		})
+	}
+}
+
+func TestCallWithRetry_ConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close() // nothing listens here any more
+
+	apiInfo := yamlparser.APIInfo{Method: "GET", URL: url}
+
+	_, attempts, err := callWithRetry(context.Background(), apiInfo, false, http.DefaultClient, fastPolicy(3), readOptions{})
+	if err == nil || attempts != 3 {
+		t.Errorf("attempts = %d, err = %v; want 3 attempts and an error", attempts, err)
+	}
+
+	noTransport := fastPolicy(3)
+	noTransport.OnConnection = false
+	_, attempts, _ = callWithRetry(context.Background(), apiInfo, false, http.DefaultClient, noTransport, readOptions{})
+	if attempts != 1 {
+		t.Errorf("with connection retries off, attempts = %d, want 1", attempts)
+	}
+}
+
+func TestCallWithRetry_AttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			<-r.Context().Done() // hang until the client gives up
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	t.Cleanup(server.Close)
+
+	policy := fastPolicy(3)
+	policy.AttemptTimeout = 50 * time.Millisecond
+	resp, attempts, err := callWithRetry(
+		context.Background(), yamlparser.APIInfo{Method: "GET", URL: server.URL}, false, http.DefaultClient, policy, readOptions{},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 2 || resp.Response.StatusCode != http.StatusOK {
+		t.Errorf("attempts = %d, status = %v; want the hung attempt retried", attempts, resp.Response)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	read := &net.OpError{Op: "read", Err: syscall.ECONNRESET}
+	tests := []struct {
+		name   string
+		err    error
+		method string
+		want   bool
+	}{
+		{"dial error on a POST", dial, "POST", true},
+		{"DNS failure on a PATCH", &net.DNSError{Err: "no such host"}, "PATCH", true},
+		{"reset after writing a GET", read, "GET", true},
+		{"reset after writing a POST", read, "POST", false},
+		{"closed before the response to a PUT", io.ErrUnexpectedEOF, "PUT", true},
+		{"closed before the response to a PATCH", io.EOF, "PATCH", false},
+		{"other error", errors.New("bad request"), "GET", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isConnectionError(tc.err, tc.method); got != tc.want {
+				t.Errorf("isConnectionError(%v, %s) = %v, want %v", tc.err, tc.method, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCallWithRetry_StopsWhenContextEnds(t *testing.T) {
+	server, calls, _ := flakyServer(t, 100, http.StatusBadGateway)
+	policy := fastPolicy(100)
+	policy.Delay = time.Hour
+	policy.MaxDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	resp, attempts, err := callWithRetry(
+		ctx, yamlparser.APIInfo{Method: "GET", URL: server.URL}, false, http.DefaultClient, policy, readOptions{},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 1 || calls.Load() != 1 {
+		t.Errorf("attempts = %d, calls = %d, want 1", attempts, calls.Load())
+	}
+	if resp.Response.StatusCode != http.StatusBadGateway {
+		t.Errorf("last response should be returned, got %d", resp.Response.StatusCode)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("wait did not end with the context")
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	policy := &yamlparser.RetryPolicy{Delay: 100 * time.Millisecond, MaxDelay: 3 * time.Second}
+
+	if got := retryWait(policy, 1, &CustomResponse{}); got != 100*time.Millisecond {
+		t.Errorf("plain wait = %v, want 100ms", got)
+	}
+
+	withHeader := &CustomResponse{header: http.Header{"Retry-After": {"2"}}}
+	if got := retryWait(policy, 1, withHeader); got != 2*time.Second {
+		t.Errorf("Retry-After wait = %v, want 2s", got)
+	}
+
+	tooLong := &CustomResponse{header: http.Header{"Retry-After": {"60"}}}
+	if got := retryWait(policy, 1, tooLong); got != 3*time.Second {
+		t.Errorf("Retry-After should be capped at max_delay, got %v", got)
+	}
+
+	jittered := *policy
+	jittered.Jitter = true
+	for range 20 {
+		got := retryWait(&jittered, 1, &CustomResponse{})
+		if got < 50*time.Millisecond || got > 100*time.Millisecond {
+			t.Fatalf("jittered wait = %v, want within [50ms, 100ms]", got)
+		}
+	}
+}
+
+// TestSendAndSaveAPIRequest_Retries verifies the --retries option applies
+// the default policy and reports attempts, and that a file's retry section
+// wins over it.
+func TestSendAndSaveAPIRequest_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		section      string
+		retries      int
+		wantAttempts int
+		wantStatus   string
+	}{
+		{"no retry configured", "", 0, 1, "502 Bad Gateway"},
+		{"retries option", "", 2, 2, "200 OK"},
+		{"file section wins", "retry:\n  attempts: 1\n", 2, 1, "502 Bad Gateway"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, _, _ := flakyServer(t, 1, http.StatusBadGateway)
+			path := filepath.Join(t.TempDir(), "req.hk.yaml")
+			doc := "method: GET\nurl: " + server.URL + "\n" + tc.section
+			if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+				Secrets: map[string]any{},
+				Path:    path,
+				NoSave:  true,
+				Retries: tc.retries,
+			})
+			if err != nil {
+				t.Fatalf("SendAndSaveAPIRequest: %v", err)
+			}
+			if result.Attempts != tc.wantAttempts || result.Status != tc.wantStatus {
+				t.Errorf("attempts = %d, status = %q; want %d, %q",
+					result.Attempts, result.Status, tc.wantAttempts, tc.wantStatus)
+			}
+		})
+	}
+}
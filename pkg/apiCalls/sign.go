@@ -0,0 +1,137 @@
+package apicalls
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// doRequest sends req with client, signing it first when signing is set.
+// body is the request body in memory, which a signature hashes and digest
+// sends again. It returns the request that went out last, whose headers
+// --debug shows.
+func doRequest(
+	client httpclient.HTTPClient,
+	req *http.Request,
+	body []byte,
+	signing *yamlparser.RequestAuth,
+) (*http.Response, *http.Request, error) {
+	now := time.Now()
+	switch {
+	case signing == nil:
+	case strings.EqualFold(signing.Type, yamlparser.AuthSigV4):
+		signSigV4(req, body, signing, now)
+	case strings.EqualFold(signing.Type, yamlparser.AuthHMAC):
+		if err := signHMAC(req, body, signing, now); err != nil {
+			return nil, req, err
+		}
+	case strings.EqualFold(signing.Type, yamlparser.AuthDigest):
+		return doDigest(client, req, body, signing)
+	}
+	resp, err := client.Do(req)
+	return resp, req, err
+}
+
+// hmacPlaceholder matches a placeholder in an hmac string_to_sign: a name
+// such as {method}, or {header.Name} for a request header.
+var hmacPlaceholder = regexp.MustCompile(`\{(header\.[^{}]+|[a-z0-9_]+)\}`)
+
+// signHMAC fills in the auth section's string_to_sign for req and sends
+// its HMAC-SHA256 under the key in the signature header. The placeholders
+// are:
+//
+//	{method}       GET, POST, ...
+//	{url}          the full URL, with the query
+//	{host}         host[:port]
+//	{path}         the escaped path, "/" when empty
+//	{query}        the raw query, without "?"
+//	{body}         the body as sent
+//	{body_sha256}  hex SHA-256 of the body
+//	{timestamp}    Unix seconds
+//	{date}         the time as an HTTP date
+//	{nonce}        32 random hex characters
+//	{header.Name}  the value of the request header Name
+//
+// The timestamp and nonce headers, when set, are added first, so the
+// server gets the values the signature covers.
+func signHMAC(req *http.Request, body []byte, auth *yamlparser.RequestAuth, now time.Time) error {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	nonce := randomHex(16)
+	if auth.TimestampHeader != "" {
+		req.Header.Set(auth.TimestampHeader, timestamp)
+	}
+	if auth.NonceHeader != "" {
+		req.Header.Set(auth.NonceHeader, nonce)
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	values := map[string]string{
+		"method":      req.Method,
+		"url":         req.URL.String(),
+		"host":        req.URL.Host,
+		"path":        path,
+		"query":       req.URL.RawQuery,
+		"body":        string(body),
+		"body_sha256": sha256Hex(body),
+		"timestamp":   timestamp,
+		"date":        now.UTC().Format(http.TimeFormat),
+		"nonce":       nonce,
+	}
+	var unknown string
+	message := hmacPlaceholder.ReplaceAllStringFunc(auth.StringToSign, func(m string) string {
+		name := m[1 : len(m)-1]
+		if header, ok := strings.CutPrefix(name, "header."); ok {
+			return req.Header.Get(header)
+		}
+		v, ok := values[name]
+		if !ok && unknown == "" {
+			unknown = m
+		}
+		return v
+	})
+	if unknown != "" {
+		return fmt.Errorf("unknown placeholder %s in string_to_sign", unknown)
+	}
+
+	mac := hmacSHA256([]byte(auth.Key), message)
+	signature := hex.EncodeToString(mac)
+	if strings.EqualFold(auth.Encoding, yamlparser.SignatureBase64) {
+		signature = base64.StdEncoding.EncodeToString(mac)
+	}
+	req.Header.Set(auth.SignatureName(), auth.Prefix+signature)
+	return nil
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of message under key.
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// sha256Hex returns the lowercase hex SHA-256 of b.
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex returns n random bytes as hex.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b) // never fails, per crypto/rand
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package apicalls
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestSignHMAC(t *testing.T) {
+	now := time.Unix(1440938160, 0)
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		auth       yamlparser.RequestAuth
+		wantHeader string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:   "hex signature over method, path, timestamp, and body hash",
+			method: http.MethodPost,
+			url:    "https://api.example.com/orders",
+			body:   `{"a":1}`,
+			auth: yamlparser.RequestAuth{
+				Type:            "hmac",
+				Key:             "secret",
+				StringToSign:    "{method}\n{path}\n{timestamp}\n{body_sha256}",
+				TimestampHeader: "X-Timestamp",
+			},
+			wantHeader: "X-Signature",
+			want:       "c602410dd7504ac091f6b5adfbb952202f49f9637d90320510df3b1f557a557c",
+		},
+		{
+			name:   "base64 with a prefix in a named header",
+			method: http.MethodGet,
+			url:    "https://api.example.com/orders?x=1",
+			auth: yamlparser.RequestAuth{
+				Type:         "hmac",
+				Key:          "secret",
+				StringToSign: "{method} {path}?{query}",
+				Encoding:     "base64",
+				Name:         "Authorization",
+				Prefix:       "HMAC ",
+			},
+			wantHeader: "Authorization",
+			want:       "HMAC IBkOH6UPrxQzqf2SjLiNuJZllcOl73MDbswYTsrIxBk=",
+		},
+		{
+			name:    "unknown placeholder",
+			method:  http.MethodGet,
+			url:     "https://api.example.com/",
+			auth:    yamlparser.RequestAuth{Type: "hmac", Key: "k", StringToSign: "{method}{verb}"},
+			wantErr: "unknown placeholder {verb}",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = signHMAC(req, []byte(tc.body), &tc.auth, now)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("signHMAC() = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get(tc.wantHeader); got != tc.want {
+				t.Errorf("%s = %q, want %q", tc.wantHeader, got, tc.want)
+			}
+			if tc.auth.TimestampHeader != "" && req.Header.Get(tc.auth.TimestampHeader) != "1440938160" {
+				t.Errorf("%s = %q", tc.auth.TimestampHeader, req.Header.Get(tc.auth.TimestampHeader))
+			}
+		})
+	}
+}
+
+func TestSignHMAC_HeaderPlaceholderAndNonce(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/", nil)
+	req.Header.Set("X-Client", "hulak")
+	auth := yamlparser.RequestAuth{Type: "hmac", Key: "k", StringToSign: "{header.X-Client}:{nonce}", NonceHeader: "X-Nonce"}
+	if err := signHMAC(req, nil, &auth, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	nonce := req.Header.Get("X-Nonce")
+	if len(nonce) != 32 {
+		t.Fatalf("X-Nonce = %q, want 32 hex characters", nonce)
+	}
+	want := hex.EncodeToString(hmacSHA256([]byte("k"), "hulak:"+nonce))
+	if got := req.Header.Get("X-Signature"); got != want {
+		t.Errorf("X-Signature = %q, want %q", got, want)
+	}
+}
+
+func TestSendRequest_SignsEachAttempt(t *testing.T) {
+	var signatures []string
+	var bodies []string
+	server := NewMockServerWithHandler(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+		if len(signatures) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+	defer server.Close()
+
+	info := yamlparser.APIInfo{
+		Method:  http.MethodPost,
+		URL:     server.URL,
+		Body:    strings.NewReader(`{"a":1}`),
+		Signing: &yamlparser.RequestAuth{Type: "hmac", Key: "k", StringToSign: "{nonce}{body}"},
+	}
+	resp, attempts, err := callWithRetry(context.Background(), info, false, DefaultClient, fastPolicy(2), readOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || resp.Response.StatusCode != http.StatusOK {
+		t.Fatalf("attempts = %d, status = %d", attempts, resp.Response.StatusCode)
+	}
+	if signatures[0] == "" || signatures[0] == signatures[1] {
+		t.Errorf("signatures = %q, want a fresh one per attempt", signatures)
+	}
+	if bodies[0] != `{"a":1}` || bodies[1] != `{"a":1}` {
+		t.Errorf("bodies = %q", bodies)
+	}
+}
@@ -0,0 +1,114 @@
+package apicalls
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestSignSigV4(t *testing.T) {
+	// The AWS SigV4 test suite's credentials and time.
+	base := yamlparser.RequestAuth{
+		Type:      "sigv4",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:    "us-east-1",
+		Service:   "service",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	const scope = "AKIDEXAMPLE/20150830/us-east-1/service/aws4_request"
+
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		contentType   string
+		body          string
+		auth          func(a *yamlparser.RequestAuth)
+		wantSigned    string
+		wantSignature string
+	}{
+		{
+			name:          "get-vanilla",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/",
+			wantSigned:    "host;x-amz-date",
+			wantSignature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "get-vanilla-query-order-key-case",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			wantSigned:    "host;x-amz-date",
+			wantSignature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "post-x-www-form-urlencoded",
+			method:        http.MethodPost,
+			url:           "https://example.amazonaws.com/",
+			contentType:   "application/x-www-form-urlencoded",
+			body:          "Param1=value1",
+			wantSigned:    "content-type;host;x-amz-date",
+			wantSignature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+		{
+			name:          "session token is signed",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/",
+			auth:          func(a *yamlparser.RequestAuth) { a.SessionToken = "tok" },
+			wantSigned:    "host;x-amz-date;x-amz-security-token",
+			wantSignature: "e1c7f7828f6bce134454add32630355bcbb8010d654b605d4e23ec7c63cd5ecd",
+		},
+		{
+			name:          "path and query are encoded",
+			method:        http.MethodGet,
+			url:           "https://example.amazonaws.com/my%20docs/a%20b?a+b=c%2Bd",
+			auth:          func(a *yamlparser.RequestAuth) { a.Service = "execute-api" },
+			wantSigned:    "host;x-amz-date",
+			wantSignature: "c8d57b40c2f9434ac7dc4deb9e7a5cb18adffd47f4f28e61c5ea511736bcd5a4",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			auth := base
+			if tc.auth != nil {
+				tc.auth(&auth)
+			}
+			req, err := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			signSigV4(req, []byte(tc.body), &auth, now)
+
+			want := "AWS4-HMAC-SHA256 Credential=" + strings.Replace(scope, "service", auth.Service, 1) +
+				", SignedHeaders=" + tc.wantSigned + ", Signature=" + tc.wantSignature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q", got)
+			}
+		})
+	}
+}
+
+func TestSignSigV4_S3PayloadHash(t *testing.T) {
+	auth := yamlparser.RequestAuth{Type: "sigv4", AccessKey: "a", SecretKey: "s", Region: "eu-west-1", Service: "s3"}
+	req, _ := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/key", nil)
+	signSigV4(req, []byte("hi"), &auth, time.Now())
+
+	const hiSHA256 = "8f434346648f6b96df89dda901c5176b10a6d83961dd3c1ac88b59b2dc327aa4"
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != hiSHA256 {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, hiSHA256)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date,") {
+		t.Errorf("Authorization = %q, want the payload hash signed", req.Header.Get("Authorization"))
+	}
+}
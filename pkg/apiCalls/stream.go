@@ -0,0 +1,229 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// StreamThreshold is the response size above which the body is streamed to
+// disk even without --stream. Smaller bodies are buffered so they can be
+// pretty-printed, asserted on, and captured from.
+const StreamThreshold = 16 << 20 // 16 MiB
+
+// sniffLen is how much of a streamed body is read up front to pick its
+// file extension, the same window http.DetectContentType looks at.
+const sniffLen = 512
+
+// streamTarget says where a streamed response body goes. It mirrors the
+// save options of the request it belongs to.
+type streamTarget struct {
+	force   bool // --stream: stream whatever the size
+	path    string
+	outPath string
+	row     int
+	// echo receives a text body as it arrives; binary bodies are never
+	// echoed. Nil saves without echoing.
+	echo io.Writer
+	// progress is called after every chunk with the bytes received so far
+	// and the expected total (-1 when the server didn't say).
+	progress func(received, total int64)
+}
+
+// streamedBody records a response body that went to disk instead of memory.
+type streamedBody struct {
+	path string
+	size int64
+}
+
+// newStreamTarget returns where the response to this request streams to, or
+// nil when it must be buffered: --debug (the body is embedded in the
+// output), NoSave (the caller wants the body in hand), and files whose
+// poll, assert, or capture sections read the body.
+func newStreamTarget(opts *RequestOptions, config *yamlparser.APICallFile) *streamTarget {
+	if opts.Debug || opts.NoSave {
+		return nil
+	}
+	if config.Poll != nil || config.Assert.ReadsBody() || config.Capture.ReadsBody() {
+		if opts.Stream {
+			utils.PrintWarningStderr(fmt.Sprintf(
+				"%s: not streaming; its poll, assert, or capture section reads the body",
+				opts.Path,
+			))
+		}
+		return nil
+	}
+	return &streamTarget{
+		force:    opts.Stream,
+		path:     opts.Path,
+		outPath:  opts.OutPath,
+		row:      opts.Row,
+		echo:     opts.StreamEcho,
+		progress: opts.Progress,
+	}
+}
+
+// receive streams resp's body to the response file when streaming is
+// forced or the body is larger than StreamThreshold, and reports what was
+// written. A body of unknown length is read up to the threshold first; when
+// it ends before that, receive returns nil with resp.Body rewound so
+// processResponse handles it as usual.
+func (s *streamTarget) receive(resp *http.Response) (*streamedBody, error) {
+	threshold := int64(StreamThreshold)
+	if !s.force && resp.ContentLength >= 0 && resp.ContentLength <= threshold {
+		return nil, nil
+	}
+
+	peek := int64(sniffLen)
+	unknownLength := resp.ContentLength < 0
+	if !s.force && unknownLength {
+		peek = threshold + 1
+	}
+	head, err := io.ReadAll(io.LimitReader(resp.Body, peek))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+	if !s.force && unknownLength && int64(len(head)) <= threshold {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{bytes.NewReader(head), resp.Body}
+		return nil, nil
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			utils.PrintWarningStderr("closing response body: " + closeErr.Error())
+		}
+	}()
+
+	contentType := resp.Header.Get("Content-Type")
+	binary := isBinaryBody(contentType, trimPartialRune(head[:min(len(head), sniffLen)]))
+	ext := streamExtension(contentType, resp.Header.Get("Content-Disposition"), head, binary)
+	fullPath, err := responseFilePath(s.path, ext, s.outPath, s.row)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:gosec // G304: the path is the request's own response file
+	f, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("saving response %s: %w", fullPath, err)
+	}
+
+	w := &streamWriter{file: f, total: resp.ContentLength, progress: s.progress}
+	if !binary {
+		w.echo = s.echo
+	}
+	n, copyErr := io.Copy(w, io.MultiReader(bytes.NewReader(head), resp.Body))
+	if err := errors.Join(copyErr, f.Close()); err != nil {
+		return nil, fmt.Errorf(
+			"streaming response to %s (%s received): %w", fullPath, utils.FormatBytes(n), err,
+		)
+	}
+	return &streamedBody{path: fullPath, size: n}, nil
+}
+
+// streamWriter writes a streamed body to its file, echoes it, and reports
+// progress. The echo is best effort: once it fails (e.g. a closed pipe) it
+// is dropped and the file keeps being written.
+type streamWriter struct {
+	file     io.Writer
+	echo     io.Writer
+	received int64
+	total    int64
+	progress func(received, total int64)
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.received += int64(n)
+	if w.echo != nil && n > 0 {
+		if _, echoErr := w.echo.Write(p[:n]); echoErr != nil {
+			w.echo = nil
+		}
+	}
+	if w.progress != nil {
+		w.progress(w.received, w.total)
+	}
+	return n, err
+}
+
+// streamedResponse is the CustomResponse for a body that was streamed to
+// disk. Status, headers, and timing are kept for the outcome line and for
+// status, header, and duration assertions; there is no body to print.
+func streamedResponse(resp *http.Response, duration time.Duration, body *streamedBody) CustomResponse {
+	return CustomResponse{
+		Response: &ResponseInfo{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		},
+		Duration:    formatDuration(duration),
+		contentType: resp.Header.Get("Content-Type"),
+		header:      resp.Header,
+		elapsed:     duration,
+		streamed:    body,
+	}
+}
+
+// streamSummary is the line printed for a streamed response, e.g.
+// "streamed 512.3 MB (application/json) to export_response.json".
+func streamSummary(resp *CustomResponse) string {
+	return fmt.Sprintf(
+		"streamed %s (%s) to %s",
+		utils.FormatBytes(resp.streamed.size), mediaTypeOrUnknown(resp.contentType), resp.streamed.path,
+	)
+}
+
+// streamExtension picks the extension of a streamed response from its
+// headers and first bytes. The body can't be validated whole, so text
+// without a useful Content-Type is classified by its first character.
+func streamExtension(contentType, disposition string, head []byte, binary bool) string {
+	if binary {
+		return binaryExtension(contentType, disposition, head)
+	}
+	media, _, err := mime.ParseMediaType(contentType)
+	if err == nil && !isGenericMediaType(media) {
+		if ext, ok := extensionForMediaType(media); ok {
+			return ext
+		}
+	}
+	trimmed := bytes.TrimLeft(head, " \t\r\n")
+	switch {
+	case len(trimmed) == 0:
+		return ".txt"
+	case trimmed[0] == '{' || trimmed[0] == '[':
+		return ".json"
+	case trimmed[0] == '<':
+		lower := strings.ToLower(string(trimmed[:min(len(trimmed), 16)]))
+		if strings.HasPrefix(lower, "<!doctype html") || strings.HasPrefix(lower, "<html") {
+			return ".html"
+		}
+		return ".xml"
+	}
+	return ".txt"
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off at the end of b, so a
+// sample taken from the middle of a text body still validates as UTF-8.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
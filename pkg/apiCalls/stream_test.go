@@ -0,0 +1,219 @@
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestStreamTargetReceive(t *testing.T) {
+	jsonBody := `{"items":[` + strings.Repeat(`{"id":1},`, 100) + `{"id":2}]}`
+	tests := []struct {
+		name          string
+		force         bool
+		contentType   string
+		contentLength int64
+		body          []byte
+		wantStreamed  bool
+		wantFile      string
+		wantEcho      bool
+	}{
+		{
+			name:          "forced",
+			force:         true,
+			contentType:   "application/json",
+			contentLength: -1,
+			body:          []byte(jsonBody),
+			wantStreamed:  true,
+			wantFile:      "export.hk_response.json",
+			wantEcho:      true,
+		},
+		{
+			name:          "over the threshold",
+			contentType:   "text/csv",
+			contentLength: StreamThreshold + 1,
+			body:          []byte("id\n1\n"),
+			wantStreamed:  true,
+			wantFile:      "export.hk_response.csv",
+			wantEcho:      true,
+		},
+		{
+			name:          "binary is saved but not echoed",
+			force:         true,
+			contentType:   "",
+			contentLength: -1,
+			body:          pngBytes,
+			wantStreamed:  true,
+			wantFile:      "export.hk_response.png",
+		},
+		{
+			name:          "json without a content type",
+			force:         true,
+			contentLength: -1,
+			body:          []byte(" [1,2]"),
+			wantStreamed:  true,
+			wantFile:      "export.hk_response.json",
+			wantEcho:      true,
+		},
+		{
+			name:          "small body of unknown length is buffered",
+			contentType:   "application/json",
+			contentLength: -1,
+			body:          []byte(jsonBody),
+		},
+		{
+			name:          "small declared length is buffered",
+			contentType:   "application/json",
+			contentLength: int64(len(jsonBody)),
+			body:          []byte(jsonBody),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var echo bytes.Buffer
+			var lastReceived, lastTotal int64
+			target := &streamTarget{
+				force: tc.force,
+				path:  filepath.Join(dir, "export.hk.yaml"),
+				echo:  &echo,
+				progress: func(received, total int64) {
+					lastReceived, lastTotal = received, total
+				},
+			}
+			resp := &http.Response{
+				Header:        http.Header{"Content-Type": {tc.contentType}},
+				ContentLength: tc.contentLength,
+				Body:          io.NopCloser(bytes.NewReader(tc.body)),
+			}
+
+			streamed, err := target.receive(resp)
+			if err != nil {
+				t.Fatalf("receive: %v", err)
+			}
+			if !tc.wantStreamed {
+				if streamed != nil {
+					t.Fatalf("streamed = %+v, want the body left for processResponse", streamed)
+				}
+				rest, _ := io.ReadAll(resp.Body)
+				if !bytes.Equal(rest, tc.body) {
+					t.Errorf("resp.Body reads %q, want the whole body", rest)
+				}
+				return
+			}
+
+			if streamed == nil {
+				t.Fatal("streamed = nil, want the body on disk")
+			}
+			if want := filepath.Join(dir, tc.wantFile); streamed.path != want {
+				t.Errorf("path = %q, want %q", streamed.path, want)
+			}
+			onDisk, err := os.ReadFile(streamed.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(onDisk, tc.body) || streamed.size != int64(len(tc.body)) {
+				t.Errorf("on disk = %q (size %d), want %q", onDisk, streamed.size, tc.body)
+			}
+			if gotEcho := echo.Len() > 0; gotEcho != tc.wantEcho {
+				t.Errorf("echoed %q, want echo %v", echo.String(), tc.wantEcho)
+			}
+			if tc.wantEcho && echo.String() != string(tc.body) {
+				t.Errorf("echo = %q, want the body", echo.String())
+			}
+			if lastReceived != int64(len(tc.body)) || lastTotal != tc.contentLength {
+				t.Errorf("progress = %d/%d, want %d/%d", lastReceived, lastTotal, len(tc.body), tc.contentLength)
+			}
+		})
+	}
+}
+
+// TestSendRequest_Streamed verifies a streamed response keeps its status
+// and headers for the outcome line and assertions, with no body in memory.
+func TestSendRequest_Streamed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("X-Export", "done")
+		_, _ = w.Write([]byte("{\"id\":1}\n{\"id\":2}\n"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	target := &streamTarget{force: true, path: filepath.Join(dir, "export.hk.yaml")}
+	resp, err := sendRequest(
+		context.Background(),
+		yamlparser.APIInfo{Method: http.MethodGet, URL: server.URL},
+		false, http.DefaultClient, readOptions{stream: target},
+	)
+	if err != nil {
+		t.Fatalf("sendRequest: %v", err)
+	}
+	if resp.streamed == nil || resp.rawBody != nil {
+		t.Fatalf("streamed = %+v, rawBody = %q; want a streamed body only", resp.streamed, resp.rawBody)
+	}
+	if resp.Response.StatusCode != http.StatusOK || resp.header.Get("X-Export") != "done" {
+		t.Errorf("status %d, header %q; want 200 and the response headers",
+			resp.Response.StatusCode, resp.header.Get("X-Export"))
+	}
+	want := "streamed 18 B (application/x-ndjson) to " + filepath.Join(dir, "export.hk_response.ndjson")
+	if got := streamSummary(&resp); got != want {
+		t.Errorf("summary = %q, want %q", got, want)
+	}
+}
+
+func TestNewStreamTarget(t *testing.T) {
+	exists := true
+	tests := []struct {
+		name   string
+		opts   RequestOptions
+		config yamlparser.APICallFile
+		want   bool
+	}{
+		{"plain request", RequestOptions{}, yamlparser.APICallFile{}, true},
+		{"debug buffers", RequestOptions{Debug: true}, yamlparser.APICallFile{}, false},
+		{"no-save buffers", RequestOptions{NoSave: true}, yamlparser.APICallFile{}, false},
+		{
+			"status assertion streams",
+			RequestOptions{},
+			yamlparser.APICallFile{Assert: &yamlparser.Assert{Status: yamlparser.StatusCodes{200}}},
+			true,
+		},
+		{
+			"json assertion buffers",
+			RequestOptions{},
+			yamlparser.APICallFile{Assert: &yamlparser.Assert{
+				JSON: []yamlparser.JSONAssertion{{Path: "id", Exists: &exists}},
+			}},
+			false,
+		},
+		{
+			"header capture streams",
+			RequestOptions{},
+			yamlparser.APICallFile{Capture: yamlparser.Captures{{Name: "etag", Header: "ETag"}}},
+			true,
+		},
+		{
+			"path capture buffers",
+			RequestOptions{},
+			yamlparser.APICallFile{Capture: yamlparser.Captures{{Name: "id", Path: "id"}}},
+			false,
+		},
+		{"poll buffers", RequestOptions{}, yamlparser.APICallFile{Poll: &yamlparser.Poll{}}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := newStreamTarget(&tc.opts, &tc.config) != nil; got != tc.want {
+				t.Errorf("streams = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
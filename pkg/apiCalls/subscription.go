@@ -0,0 +1,287 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// graphqlWSProtocol is the WebSocket subprotocol GraphQL subscriptions run
+// over, as defined by the graphql-ws library:
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+const graphqlWSProtocol = "graphql-transport-ws"
+
+// connectionAckTimeout bounds the wait for the server to accept
+// connection_init, as graphql-ws servers do for the client's init.
+const connectionAckTimeout = 10 * time.Second
+
+// subscriptionID identifies the one operation run per connection.
+const subscriptionID = "1"
+
+// gqlWSMessage is one graphql-transport-ws message, in either direction.
+type gqlWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// subscription is an acknowledged graphql-transport-ws connection, ready
+// to run one operation.
+type subscription struct {
+	conn *websocket.Conn
+	// target is the ws:// or wss:// URL connected to.
+	target string
+	// payload is the subscribe payload: the {"query", "variables"} body.
+	payload []byte
+	// stopClose ends the watch that closes conn when ctx ends.
+	stopClose func() bool
+}
+
+// Subscribe runs the GraphQL subscription whose body is in apiInfo over
+// the graphql-transport-ws protocol, calling onNext with the payload of
+// every result as it arrives. The URL's http or https scheme becomes ws or
+// wss; headers are sent with the opening handshake.
+//
+// Subscribe returns nil when the server completes the subscription or
+// onNext returns false, which completes it from the client side. A GraphQL
+// error for the operation, a connection closed early, and ctx ending are
+// returned as errors.
+func Subscribe(
+	ctx context.Context,
+	apiInfo yamlparser.APIInfo,
+	onNext func(payload json.RawMessage) bool,
+) error {
+	sub, err := openSubscription(ctx, apiInfo)
+	if err != nil {
+		return err
+	}
+	defer sub.close()
+	return sub.run(ctx, onNext)
+}
+
+// openSubscription connects and sends connection_init, returning once the
+// server acknowledges it. The connection is closed when ctx ends, which
+// unblocks any read in progress.
+func openSubscription(ctx context.Context, apiInfo yamlparser.APIInfo) (*subscription, error) {
+	payload, err := readBody(apiInfo.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) == 0 {
+		return nil, errors.New("graphql subscription has no query")
+	}
+	target, err := subscriptionURL(PrepareURL(apiInfo.URL, apiInfo.URLParams))
+	if err != nil {
+		return nil, err
+	}
+	withToken, err := apiInfo.Bearer.Apply(apiInfo.Headers)
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string, len(withToken))
+	for key, val := range withToken {
+		// The JSON content type is for POSTed queries, not the handshake.
+		if !strings.EqualFold(key, "content-type") {
+			headers[key] = val
+		}
+	}
+
+	conn, err := dialWebSocket(ctx, target, headers, []string{graphqlWSProtocol}, apiInfo.TLS, apiInfo.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	sub := &subscription{
+		conn:      conn,
+		target:    target,
+		payload:   payload,
+		stopClose: context.AfterFunc(ctx, func() { _ = conn.Close() }),
+	}
+	if err := sub.init(ctx); err != nil {
+		sub.close()
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscriptionURL switches an http or https URL to ws or wss. WebSocket
+// URLs are kept as they are.
+func subscriptionURL(target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid subscription URL %s: %w", target, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		u.Scheme = "ws"
+	case "https", "wss":
+		u.Scheme = "wss"
+	default:
+		return "", fmt.Errorf("invalid subscription URL %s: use http(s) or ws(s)", target)
+	}
+	return u.String(), nil
+}
+
+// init sends connection_init and waits for connection_ack, answering any
+// ping in between.
+func (s *subscription) init(ctx context.Context) error {
+	if err := s.send(gqlWSMessage{Type: "connection_init"}); err != nil {
+		return err
+	}
+	_ = s.conn.SetReadDeadline(time.Now().Add(connectionAckTimeout))
+	defer func() { _ = s.conn.SetReadDeadline(time.Time{}) }()
+	for {
+		msg, err := s.receive(ctx)
+		if err != nil {
+			return fmt.Errorf("waiting for connection_ack: %w", err)
+		}
+		switch msg.Type {
+		case "connection_ack":
+			return nil
+		case "ping":
+			if err := s.send(gqlWSMessage{Type: "pong"}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// run subscribes and hands each result to onNext until the server
+// completes the operation or onNext returns false.
+func (s *subscription) run(ctx context.Context, onNext func(payload json.RawMessage) bool) error {
+	err := s.send(gqlWSMessage{ID: subscriptionID, Type: "subscribe", Payload: s.payload})
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := s.receive(ctx)
+		if err != nil {
+			return err
+		}
+		switch msg.Type {
+		case "next":
+			if msg.ID == subscriptionID && !onNext(msg.Payload) {
+				return s.send(gqlWSMessage{ID: subscriptionID, Type: "complete"})
+			}
+		case "error":
+			return subscriptionError(msg.Payload)
+		case "complete":
+			return nil
+		case "ping":
+			if err := s.send(gqlWSMessage{Type: "pong"}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// send writes one protocol message.
+func (s *subscription) send(msg gqlWSMessage) error {
+	if err := websocket.JSON.Send(s.conn, msg); err != nil {
+		return fmt.Errorf("sending %s: %w", msg.Type, err)
+	}
+	return nil
+}
+
+// receive reads one protocol message. A read cut short by ctx reports
+// ctx's error rather than the closed connection.
+func (s *subscription) receive(ctx context.Context) (gqlWSMessage, error) {
+	var msg gqlWSMessage
+	err := websocket.JSON.Receive(s.conn, &msg)
+	switch {
+	case err == nil:
+		return msg, nil
+	case ctx.Err() != nil:
+		return msg, ctx.Err()
+	case errors.Is(err, io.EOF):
+		return msg, errors.New("connection closed by the server")
+	}
+	return msg, fmt.Errorf("reading message: %w", err)
+}
+
+// close stops watching ctx and closes the connection.
+func (s *subscription) close() {
+	s.stopClose()
+	_ = s.conn.Close()
+}
+
+// subscriptionError turns the payload of an error message, a list of
+// GraphQL errors, into one error with their messages.
+func subscriptionError(payload json.RawMessage) error {
+	var errs []struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(payload, &errs); err != nil || len(errs) == 0 {
+		return fmt.Errorf("subscription failed: %s", payload)
+	}
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Message
+	}
+	return fmt.Errorf("subscription failed: %s", strings.Join(messages, "; "))
+}
+
+// subscribeEvents runs a GraphQL subscription for SendAndSaveAPIRequest,
+// reading results the way an event stream is read: printed as they
+// arrive, until a limit from the file's events section is reached or the
+// server completes it. The response is a 101 whose body is the NDJSON
+// transcript, one result per line, so assertions, captures, and saving
+// work as they do for event streams.
+func subscribeEvents(
+	ctx context.Context,
+	apiInfo yamlparser.APIInfo,
+	debug bool,
+	events *eventReader,
+) (CustomResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	sub, err := openSubscription(ctx, apiInfo)
+	if err != nil {
+		return CustomResponse{}, err
+	}
+	defer sub.close()
+	handshake := time.Since(start)
+
+	transcript, result, runErr := events.collect(func(emit emitFunc) error {
+		return sub.run(ctx, func(payload json.RawMessage) bool {
+			return emit(streamEvent{}, string(payload))
+		})
+	}, cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sub.target, nil)
+	if err != nil {
+		return CustomResponse{}, err
+	}
+	for key, val := range apiInfo.Headers {
+		req.Header.Set(key, val)
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusSwitchingProtocols,
+		Status:     webSocketStatus,
+		Proto:      "HTTP/1.1",
+		Header:     http.Header{"Sec-Websocket-Protocol": {graphqlWSProtocol}},
+		Body:       io.NopCloser(bytes.NewReader(transcript)),
+	}
+	out, err := processResponse(req, resp, handshake, debug, sub.payload)
+	if err != nil {
+		return out, err
+	}
+	if !debug {
+		out.contentType = transcriptType
+	}
+	out.events = &result
+	return out, runErr
+}
@@ -0,0 +1,256 @@
+package apicalls
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// newGraphQLWSServer serves graphql-transport-ws: it acknowledges
+// connection_init, reads the subscribe message, and hands the rest of the
+// connection to handle. It returns the server's http:// URL.
+func newGraphQLWSServer(t *testing.T, handle func(ws *websocket.Conn, subscribe gqlWSMessage)) string {
+	t.Helper()
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		var msg gqlWSMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil || msg.Type != "connection_init" {
+			return
+		}
+		_ = websocket.JSON.Send(ws, gqlWSMessage{Type: "ping"})
+		_ = websocket.JSON.Send(ws, gqlWSMessage{Type: "connection_ack"})
+		for {
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			if msg.Type == "subscribe" {
+				handle(ws, msg)
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// sendNext sends one result for the subscription.
+func sendNext(ws *websocket.Conn, payload string) {
+	_ = websocket.JSON.Send(ws, gqlWSMessage{ID: subscriptionID, Type: "next", Payload: json.RawMessage(payload)})
+}
+
+func TestSubscribe(t *testing.T) {
+	var gotProtocol, gotAuth, gotContentType string
+	var gotSubscribe gqlWSMessage
+	url := newGraphQLWSServer(t, func(ws *websocket.Conn, subscribe gqlWSMessage) {
+		gotProtocol = ws.Request().Header.Get("Sec-WebSocket-Protocol")
+		gotAuth = ws.Request().Header.Get("Authorization")
+		gotContentType = ws.Request().Header.Get("Content-Type")
+		gotSubscribe = subscribe
+		for i := 1; i <= 3; i++ {
+			sendNext(ws, fmt.Sprintf(`{"data":{"price":%d}}`, i))
+		}
+		_ = websocket.JSON.Send(ws, gqlWSMessage{ID: subscriptionID, Type: "complete"})
+	})
+
+	body, err := yamlparser.EncodeGraphQlBody("subscription { price }", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads []string
+	err = Subscribe(context.Background(), yamlparser.APIInfo{
+		URL: url,
+		Headers: map[string]string{
+			"Authorization": "Bearer token",
+			"content-type":  "application/json",
+		},
+		Body: body,
+	}, func(payload json.RawMessage) bool {
+		payloads = append(payloads, string(payload))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if gotProtocol != graphqlWSProtocol || gotAuth != "Bearer token" || gotContentType != "" {
+		t.Errorf("handshake protocol %q, auth %q, content type %q", gotProtocol, gotAuth, gotContentType)
+	}
+	if gotSubscribe.ID != subscriptionID || !strings.Contains(string(gotSubscribe.Payload), `"query":"subscription { price }"`) {
+		t.Errorf("subscribe message = %+v", gotSubscribe)
+	}
+	want := []string{`{"data":{"price":1}}`, `{"data":{"price":2}}`, `{"data":{"price":3}}`}
+	if strings.Join(payloads, " ") != strings.Join(want, " ") {
+		t.Errorf("payloads = %v, want %v", payloads, want)
+	}
+}
+
+func TestSubscribe_StopCompletes(t *testing.T) {
+	completed := make(chan gqlWSMessage, 1)
+	url := newGraphQLWSServer(t, func(ws *websocket.Conn, _ gqlWSMessage) {
+		for i := 1; i <= 5; i++ {
+			sendNext(ws, fmt.Sprintf(`{"data":{"n":%d}}`, i))
+		}
+		var msg gqlWSMessage
+		for websocket.JSON.Receive(ws, &msg) == nil {
+			if msg.Type == "complete" {
+				completed <- msg
+				return
+			}
+		}
+	})
+
+	body, _ := yamlparser.EncodeGraphQlBody("subscription { n }", nil)
+	count := 0
+	err := Subscribe(context.Background(), yamlparser.APIInfo{URL: url, Body: body}, func(json.RawMessage) bool {
+		count++
+		return count < 2
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("onNext called %d times, want 2", count)
+	}
+	select {
+	case msg := <-completed:
+		if msg.ID != subscriptionID {
+			t.Errorf("complete id = %q, want %q", msg.ID, subscriptionID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server never received complete")
+	}
+}
+
+func TestSubscribe_Fails(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  func(ws *websocket.Conn, subscribe gqlWSMessage)
+		wantErr string
+	}{
+		{
+			name: "graphql error",
+			handle: func(ws *websocket.Conn, _ gqlWSMessage) {
+				_ = websocket.JSON.Send(ws, gqlWSMessage{
+					ID:      subscriptionID,
+					Type:    "error",
+					Payload: json.RawMessage(`[{"message":"Cannot query field \"nope\""}]`),
+				})
+				_, _ = io.Copy(io.Discard, ws)
+			},
+			wantErr: `subscription failed: Cannot query field "nope"`,
+		},
+		{
+			name:    "server closes",
+			handle:  func(ws *websocket.Conn, _ gqlWSMessage) { _ = ws.Close() },
+			wantErr: "connection closed by the server",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body, _ := yamlparser.EncodeGraphQlBody("subscription { nope }", nil)
+			err := Subscribe(
+				context.Background(),
+				yamlparser.APIInfo{URL: newGraphQLWSServer(t, tc.handle), Body: body},
+				func(json.RawMessage) bool { return true },
+			)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscriptionURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "http://localhost:4000/graphql", want: "ws://localhost:4000/graphql"},
+		{in: "https://api.example.com/graphql?x=1", want: "wss://api.example.com/graphql?x=1"},
+		{in: "wss://api.example.com/graphql", want: "wss://api.example.com/graphql"},
+		{in: "ftp://example.com", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := subscriptionURL(tc.in)
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Errorf("subscriptionURL(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+			}
+		})
+	}
+}
+
+// TestSendAndSaveAPIRequest_Subscription verifies a file whose query is a
+// subscription runs over graphql-transport-ws and stops at its events
+// limits like an event stream.
+func TestSendAndSaveAPIRequest_Subscription(t *testing.T) {
+	// feed sends three results, then completes unless told to hang.
+	feed := func(hang bool) func(ws *websocket.Conn, _ gqlWSMessage) {
+		return func(ws *websocket.Conn, _ gqlWSMessage) {
+			for i := 1; i <= 3; i++ {
+				sendNext(ws, fmt.Sprintf(`{"data":{"tick":%d}}`, i))
+			}
+			if hang {
+				_, _ = io.Copy(io.Discard, ws)
+				return
+			}
+			_ = websocket.JSON.Send(ws, gqlWSMessage{ID: subscriptionID, Type: "complete"})
+		}
+	}
+	tests := []struct {
+		name      string
+		hang      bool
+		events    string
+		wantCount int
+		wantStop  string
+	}{
+		{"max_events", true, "events:\n  max_events: 2\n", 2, stopMaxEvents},
+		{"until", true, "events:\n  until: '\"tick\":2'\n", 2, stopUntil},
+		{"duration", true, "events:\n  duration: 300ms\n", 3, stopDuration},
+		{"server completes", false, "", 3, stopEOF},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			url := newGraphQLWSServer(t, feed(tc.hang))
+			path := filepath.Join(t.TempDir(), "ticks.hk.yaml")
+			doc := "method: POST\nurl: " + url + "\nbody:\n  graphql:\n    query: subscription { tick }\n" + tc.events
+			if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := SendAndSaveAPIRequest(context.Background(), RequestOptions{
+				Secrets: map[string]any{},
+				Path:    path,
+			})
+			if err != nil {
+				t.Fatalf("SendAndSaveAPIRequest: %v", err)
+			}
+			if result.Status != webSocketStatus || result.Attempts != 1 {
+				t.Errorf("status %q, attempts %d", result.Status, result.Attempts)
+			}
+			saved := filepath.Join(filepath.Dir(path), "ticks.hk_response.ndjson")
+			wantSummary := fmt.Sprintf("%d events in ", tc.wantCount)
+			if !strings.HasPrefix(result.Summary, wantSummary) ||
+				!strings.HasSuffix(result.Summary, "("+tc.wantStop+"), transcript saved to "+saved) {
+				t.Errorf("summary = %q, want %q... (%s)", result.Summary, wantSummary, tc.wantStop)
+			}
+			content, err := os.ReadFile(saved)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(content), `{"data":{"data":{"tick":1}},"elapsed_ms":`) {
+				t.Errorf("transcript =\n%s", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package apicalls
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings breaks down the time a request took, to tell a slow network from
+// a slow server. A phase that didn't happen is zero: DNS for an IP address,
+// connect and TLS over a reused connection, TLS for plain HTTP. After
+// redirects, DNS, Connect, and TLS add up every hop; FirstByte and
+// Transfer are the final response's.
+type Timings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// FirstByte is the wait from the request being sent to the first byte
+	// of the response: the time the server took.
+	FirstByte time.Duration
+	// Transfer is from the first byte of the response to the end of its
+	// body.
+	Transfer time.Duration
+	// Reused is set when the final request went over a kept-alive
+	// connection, so it had no DNS, connect, or TLS phase.
+	Reused bool
+}
+
+// MarshalJSON writes the phases like Duration, e.g. "12.34ms".
+func (t *Timings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		DNS       string `json:"dns"`
+		Connect   string `json:"connect"`
+		TLS       string `json:"tls"`
+		FirstByte string `json:"first_byte"`
+		Transfer  string `json:"transfer"`
+		Reused    bool   `json:"reused_connection,omitempty"`
+	}{
+		DNS:       formatDuration(t.DNS),
+		Connect:   formatDuration(t.Connect),
+		TLS:       formatDuration(t.TLS),
+		FirstByte: formatDuration(t.FirstByte),
+		Transfer:  formatDuration(t.Transfer),
+		Reused:    t.Reused,
+	})
+}
+
+// requestTrace collects the Timings of one request through httptrace. The
+// hooks may run on the transport's goroutines, hence the lock.
+type requestTrace struct {
+	mu       sync.Mutex
+	timings  Timings
+	dnsStart time.Time
+	// connectStart is by address: dual-stack hosts dial several at once.
+	connectStart map[string]time.Time
+	tlsStart     time.Time
+	wrote        time.Time
+	firstByte    time.Time
+	bodyDone     bool
+}
+
+// traceRequest returns ctx with hooks that time the phases of a request.
+func traceRequest(ctx context.Context) (context.Context, *requestTrace) {
+	t := &requestTrace{connectStart: map[string]time.Time{}}
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.at(func(now time.Time) { t.dnsStart = now })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.at(func(now time.Time) { t.timings.DNS += now.Sub(t.dnsStart) })
+		},
+		ConnectStart: func(_, addr string) {
+			t.at(func(now time.Time) { t.connectStart[addr] = now })
+		},
+		ConnectDone: func(_, addr string, err error) {
+			t.at(func(now time.Time) {
+				if start, ok := t.connectStart[addr]; ok && err == nil {
+					t.timings.Connect += now.Sub(start)
+				}
+				delete(t.connectStart, addr)
+			})
+		},
+		TLSHandshakeStart: func() {
+			t.at(func(now time.Time) { t.tlsStart = now })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.at(func(now time.Time) { t.timings.TLS += now.Sub(t.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.at(func(time.Time) { t.timings.Reused = info.Reused })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.at(func(now time.Time) { t.wrote = now })
+		},
+		GotFirstResponseByte: func() {
+			t.at(func(now time.Time) {
+				t.firstByte = now
+				t.timings.FirstByte = now.Sub(t.wrote)
+			})
+		},
+	}), t
+}
+
+// at runs record with the current time under the lock.
+func (t *requestTrace) at(record func(now time.Time)) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	record(now)
+}
+
+// body wraps a response body so the transfer ends when it is read to the
+// end or closed, whichever comes first.
+func (t *requestTrace) body(body io.ReadCloser) io.ReadCloser {
+	return &tracedBody{ReadCloser: body, trace: t}
+}
+
+// finishTransfer ends the transfer phase of the final response.
+func (t *requestTrace) finishTransfer() {
+	t.at(func(now time.Time) {
+		if !t.bodyDone && !t.firstByte.IsZero() {
+			t.bodyDone = true
+			t.timings.Transfer = now.Sub(t.firstByte)
+		}
+	})
+}
+
+// result returns the timings recorded so far.
+func (t *requestTrace) result() *Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := t.timings
+	return &timings
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	trace *requestTrace
+}
+
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if errors.Is(err, io.EOF) {
+		b.trace.finishTransfer()
+	}
+	return n, err
+}
+
+func (b *tracedBody) Close() error {
+	b.trace.finishTransfer()
+	return b.ReadCloser.Close()
+}
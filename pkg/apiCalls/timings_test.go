@@ -0,0 +1,77 @@
+package apicalls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+func TestStandardCall_Timings(t *testing.T) {
+	const wait = 30 * time.Millisecond
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(wait)
+		_, _ = w.Write([]byte(`{"part":`))
+		w.(http.Flusher).Flush()
+		time.Sleep(wait)
+		_, _ = w.Write([]byte(`1}`))
+	}))
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	info := yamlparser.APIInfo{
+		Method: http.MethodGet,
+		URL:    server.URL,
+		TLS:    &tls.Config{RootCAs: pool, ServerName: "example.com", MinVersion: tls.VersionTLS12},
+	}
+
+	first, err := StandardCall(context.Background(), info, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := first.Timings
+	switch {
+	case got == nil:
+		t.Fatal("no timings")
+	case got.DNS != 0:
+		t.Errorf("DNS = %v for an IP address", got.DNS)
+	case got.Connect <= 0 || got.TLS <= 0:
+		t.Errorf("a new connection should time connect and TLS: %+v", got)
+	case got.FirstByte < wait:
+		t.Errorf("FirstByte = %v, want the server's %v at least", got.FirstByte, wait)
+	case got.Transfer < wait:
+		t.Errorf("Transfer = %v, want the %v between the body's parts at least", got.Transfer, wait)
+	case got.Reused:
+		t.Error("the first request can't reuse a connection")
+	}
+	if out := string(SerializeResp(&first)); !strings.Contains(out, `"first_byte"`) {
+		t.Errorf("debug output has no timings:\n%s", out)
+	}
+
+	second, err := StandardCall(context.Background(), info, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.Timings; !got.Reused || got.Connect != 0 || got.TLS != 0 {
+		t.Errorf("a reused connection should have no connect or TLS phase: %+v", got)
+	}
+}
+
+func TestTimings_MarshalJSON(t *testing.T) {
+	b, err := json.Marshal(&Timings{DNS: 1500 * time.Microsecond, FirstByte: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"dns":"1.50ms","connect":"0.00ms","tls":"0.00ms","first_byte":"20.00ms","transfer":"0.00ms"}`
+	if string(b) != want {
+		t.Errorf("MarshalJSON() = %s, want %s", b, want)
+	}
+}
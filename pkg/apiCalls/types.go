@@ -1,6 +1,14 @@
 // Package apicalls has all things related to api call
 package apicalls
 
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+)
+
 // RequestOptions bundles the per-request flags SendAndSaveAPIRequest needs
 // from the runner. Keeps the call site readable when more flags get added
 // (next likely additions: timeout overrides).
@@ -18,14 +26,70 @@ type RequestOptions struct {
 	// by cliflags.ResolveOutputPath) instead of {name}_response.<ext> next to the
 	// request file. Empty means the default location. Ignored when NoSave is set.
 	OutPath string
+	// Retries is the --retries flag: how many times to resend a request
+	// that fails with a retryable error. Applies the default retry policy
+	// to files without a retry section; zero means no retries.
+	Retries int
+	// Row is the 1-based dataset row when the runner iterates the file over
+	// a data file. The saved response gets a _<row> suffix so rows don't
+	// overwrite each other. Zero for ordinary runs.
+	Row int
+	// Stream is the --stream flag: write the response body to disk as it
+	// arrives instead of buffering it. Bodies over StreamThreshold stream
+	// without it. Ignored with Debug or NoSave.
+	Stream bool
+	// StreamEcho receives a streamed text body as it arrives, e.g. stdout
+	// when it's piped. Nil saves the body without echoing it.
+	StreamEcho io.Writer
+	// EventOut receives the events of an event stream response as they
+	// arrive. Nil prints nothing until the stream is done.
+	EventOut io.Writer
+	// Progress, when set, is called as a streamed body arrives with the
+	// bytes received so far and the expected total (-1 when unknown).
+	Progress func(received, total int64)
+	// Jar is the run's cookie jar. Files with `cookies: true` send its
+	// cookies and keep the ones they get back in it. Nil for callers
+	// without one.
+	Jar *httpclient.Jar
+	// Cookies is the --cookie-jar flag: every file uses Jar unless it
+	// sets `cookies: false`.
+	Cookies bool
+}
+
+// RequestResult is what SendAndSaveAPIRequest hands back to its caller.
+type RequestResult struct {
+	// Body is the serialized response, ready to print. Nil for dry runs and
+	// for failures before a response arrived.
+	Body []byte
+	// Summary replaces Body in output for a binary response, e.g.
+	// "binary response (image/png, 48.2 KB) saved to ...". Empty for text.
+	Summary string
+	// Status is the HTTP status string, e.g. "200 OK". Empty when no
+	// response arrived.
+	Status string
+	// Captured maps each name in the file's capture list to the value pulled
+	// from the response. Nil when the file captures nothing.
+	Captured map[string]any
+	// Attempts is how many times the request was sent. Zero when it never
+	// went out (dry run, pre-flight failure).
+	Attempts int
+	// Timings breaks down the time the final request took. Nil when no
+	// response arrived.
+	Timings *Timings
 }
 
 // CustomResponse is structure of the result to print and save
 type CustomResponse struct {
-	Request  *RequestInfo  `json:"request,omitempty"`
-	Response *ResponseInfo `json:"response,omitempty"`
-	HTTPInfo *HTTPInfo     `json:"http_info,omitempty"`
-	Duration string        `json:"duration,omitempty"`
+	Request *RequestInfo `json:"request,omitempty"`
+	// Redirects lists the redirects followed on the way to Response, in
+	// order. Shown with --debug, like Request; otherwise saved beside the
+	// response by saveRedirects.
+	Redirects []httpclient.RedirectHop `json:"redirects,omitempty"`
+	Response  *ResponseInfo            `json:"response,omitempty"`
+	HTTPInfo  *HTTPInfo                `json:"http_info,omitempty"`
+	Duration  string                   `json:"duration,omitempty"`
+	// Timings breaks Duration down into phases. Only shown with --debug.
+	Timings *Timings `json:"timings,omitempty"`
 
 	// contentType captures the response Content-Type header so the saved
 	// file can use the right extension (#208). Unexported so it doesn't
@@ -37,6 +101,21 @@ type CustomResponse struct {
 	// server sent, byte-for-byte for non-JSON content and pretty-printed
 	// for JSON. Unexported — JSON encoder ignores it.
 	rawBody []byte
+
+	// header and elapsed keep the response headers and the unformatted
+	// request duration in every mode (Response.Headers is debug-only), so
+	// the assert section can check them without re-parsing Duration.
+	header  http.Header
+	elapsed time.Duration
+
+	// streamed is set when the body went straight to disk instead of
+	// rawBody; see streamTarget.
+	streamed *streamedBody
+
+	// events is set for an event stream response, whose rawBody is the
+	// NDJSON transcript (and contentType the transcript's, not the
+	// stream's, so it saves as .ndjson). See eventReader.
+	events *eventsResult
 }
 
 // isDebug reports whether this response was built in debug mode.
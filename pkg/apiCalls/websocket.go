@@ -0,0 +1,484 @@
+// Package apicalls has all things related to api call
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// webSocketStatus is the status reported for a session whose handshake
+// succeeded.
+const webSocketStatus = "101 Switching Protocols"
+
+// Reasons a WebSocket session ended, besides the events limits shared
+// with event streams.
+const (
+	stopScriptDone = "script done"
+	stopClosed     = "connection closed"
+)
+
+// SendWebSocket connects to the WebSocket in the file at opts.Path, runs its
+// script of messages to send and expect, and saves the session as an NDJSON
+// transcript next to the file, like an HTTP response. Messages print to
+// opts.EventOut as they are sent and received.
+//
+// A failed expect step, a connection the server closes mid-script, and ctx
+// ending all fail the request; the transcript up to that point is still
+// saved. result.Summary counts the messages; result.Body is the transcript.
+//
+// When opts.DryRun is true, the handshake and script are printed to stdout
+// and nothing is sent.
+func SendWebSocket(ctx context.Context, opts RequestOptions) (RequestResult, error) {
+	file, err := yamlparser.FinalStructForWebSocket(opts.Path, opts.Secrets)
+	if err != nil {
+		return RequestResult{}, err
+	}
+
+	if opts.DryRun {
+		out, err := FormatWebSocketDryRun(&file, opts.Show)
+		if err != nil {
+			return RequestResult{}, err
+		}
+		fmt.Print(out)
+		return RequestResult{}, nil
+	}
+
+	if file.Headers, err = file.Bearer().Apply(file.Headers); err != nil {
+		return RequestResult{}, err
+	}
+	target := PrepareURL(string(file.URL), file.URLParams)
+	conn, err := dialWebSocket(ctx, target, file.Headers, file.Subprotocols, file.ClientTLS(), file.ClientProxy())
+	if err != nil {
+		return RequestResult{Attempts: 1}, err
+	}
+	session := newWebSocketSession(conn, opts.EventOut)
+	runErr := session.run(ctx, &file)
+	session.close()
+
+	result := RequestResult{Status: webSocketStatus, Attempts: 1, Body: session.transcript.Bytes()}
+	if opts.NoSave {
+		result.Summary = session.summary("")
+		return result, runErr
+	}
+	saved, saveErr := writeFile(opts.Path, ".ndjson", result.Body, opts.OutPath, opts.Row)
+	result.Summary = session.summary(saved)
+	return result, errors.Join(runErr, saveErr)
+}
+
+// dialWebSocket performs the opening handshake with target, sending headers
+// and offering subprotocols. An Origin header replaces the default origin,
+// which is the URL's host over http or https. tlsConfig applies to wss://
+// targets; nil uses the defaults. The connection goes through proxy, or
+// the proxy in the environment variables when it is nil.
+func dialWebSocket(
+	ctx context.Context,
+	target string,
+	headers map[string]string,
+	subprotocols []string,
+	tlsConfig *tls.Config,
+	proxy *httpclient.Proxy,
+) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig(target, webSocketOrigin(target))
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket URL %s: %w", target, err)
+	}
+	config.Protocol = subprotocols
+	config.TlsConfig = tlsConfig
+	for key, val := range headers {
+		if strings.EqualFold(key, "origin") {
+			origin, err := url.Parse(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Origin header %q: %w", val, err)
+			}
+			config.Origin = origin
+			continue
+		}
+		config.Header.Set(key, val)
+	}
+
+	conn, err := handshake(ctx, config, proxy)
+	if err != nil {
+		// DialError repeats the URL and doesn't unwrap; report its cause.
+		var dialErr *websocket.DialError
+		if errors.As(err, &dialErr) {
+			err = dialErr.Err
+		}
+		if errors.Is(err, websocket.ErrBadStatus) {
+			return nil, fmt.Errorf("websocket handshake with %s: server answered without 101 Switching Protocols", target)
+		}
+		return nil, fmt.Errorf("websocket handshake with %s: %w", target, err)
+	}
+	return conn, nil
+}
+
+// handshake connects to config.Location through proxy and performs the
+// opening handshake, like config.DialContext, which can only dial directly.
+func handshake(ctx context.Context, config *websocket.Config, proxy *httpclient.Proxy) (*websocket.Conn, error) {
+	secure := config.Location.Scheme == "wss"
+	addr := config.Location.Host
+	if config.Location.Port() == "" {
+		port := "80"
+		if secure {
+			port = "443"
+		}
+		addr = net.JoinHostPort(config.Location.Hostname(), port)
+	}
+	conn, err := proxy.DialContext(ctx, addr, secure)
+	if err != nil {
+		return nil, err
+	}
+	if secure {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if config.TlsConfig != nil {
+			tlsConfig = config.TlsConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = config.Location.Hostname()
+		}
+		// The Upgrade is HTTP/1.1 only; a server offered h2 may pick it.
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	// The handshake ends with ctx; NewClient alone could block forever.
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Now()) })
+	ws, err := websocket.NewClient(config, conn)
+	if !stop() || err != nil {
+		_ = conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		return nil, err
+	}
+	return ws, nil
+}
+
+// webSocketOrigin is the default Origin for a ws:// or wss:// URL.
+func webSocketOrigin(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "http://localhost"
+	}
+	scheme := "http"
+	if u.Scheme == "wss" {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
+
+// wsFrame is one message read off the connection, or the error that ended
+// reading.
+type wsFrame struct {
+	data   []byte
+	binary bool
+	err    error
+}
+
+// frameCodec receives a whole message and keeps whether it was binary,
+// which websocket.Message only exposes through the target's type.
+var frameCodec = websocket.Codec{
+	Marshal: func(v any) ([]byte, byte, error) {
+		return []byte(v.(string)), websocket.TextFrame, nil
+	},
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		*v.(*wsFrame) = wsFrame{data: data, binary: payloadType == websocket.BinaryFrame}
+		return nil
+	},
+}
+
+// wsMessage is one line of a WebSocket transcript.
+type wsMessage struct {
+	// Direction is "sent" or "received".
+	Direction string `json:"direction"`
+	// Data is the message: embedded as is when it is JSON, otherwise as a
+	// string. Binary messages are base64.
+	Data   json.RawMessage `json:"data"`
+	Binary bool            `json:"binary,omitempty"`
+	// ElapsedMS is when the message was sent or received, in milliseconds
+	// after the handshake.
+	ElapsedMS int64 `json:"elapsed_ms"`
+}
+
+// webSocketSession runs a script over an open connection and records
+// every message in its transcript.
+type webSocketSession struct {
+	conn *websocket.Conn
+	// live receives each message as it is sent or received. Nil prints
+	// nothing.
+	live       io.Writer
+	start      time.Time
+	incoming   <-chan wsFrame
+	done       chan struct{}
+	transcript bytes.Buffer
+	sent       int
+	received   int
+	stop       string
+}
+
+// newWebSocketSession starts reading conn in the background. Messages wait
+// in incoming until a step or the events limits take them.
+func newWebSocketSession(conn *websocket.Conn, live io.Writer) *webSocketSession {
+	incoming := make(chan wsFrame)
+	done := make(chan struct{})
+	go func() {
+		defer close(incoming)
+		for {
+			var frame wsFrame
+			if err := frameCodec.Receive(conn, &frame); err != nil {
+				frame = wsFrame{err: err}
+			}
+			select {
+			case incoming <- frame:
+			case <-done:
+				return
+			}
+			if frame.err != nil {
+				return
+			}
+		}
+	}()
+	return &webSocketSession{conn: conn, live: live, start: time.Now(), incoming: incoming, done: done}
+}
+
+// close sends a close frame and stops the background reader.
+func (s *webSocketSession) close() {
+	close(s.done)
+	_ = s.conn.Close()
+}
+
+// run sends and expects each step in order, then reads more messages when
+// the file has an events section.
+func (s *webSocketSession) run(ctx context.Context, file *yamlparser.WebSocketFile) error {
+	for i := range file.Messages {
+		step := &file.Messages[i]
+		if step.Expect == nil {
+			if err := s.send(step); err != nil {
+				return fmt.Errorf("messages[%d]: %w", i, err)
+			}
+			continue
+		}
+		timeout, err := step.ParsedTimeout()
+		if err != nil {
+			return fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		if err := s.expect(ctx, step.Expect, timeout); err != nil {
+			return fmt.Errorf("messages[%d]: %w", i, err)
+		}
+	}
+	if file.Events == nil {
+		s.stop = stopScriptDone
+		return nil
+	}
+	return s.readEvents(ctx, file.Events)
+}
+
+// send sends a send or json step as a text message.
+func (s *webSocketSession) send(step *yamlparser.WebSocketStep) error {
+	payload, err := step.Payload()
+	if err != nil {
+		return err
+	}
+	if err := frameCodec.Send(s.conn, payload); err != nil {
+		return fmt.Errorf("sending message: %w", err)
+	}
+	s.sent++
+	s.record("sent", wsFrame{data: []byte(payload)})
+	return nil
+}
+
+// expect waits up to timeout for a received message that meets the json
+// and body checks of want. Messages that don't are recorded and skipped.
+// The error lists the checks the last message failed.
+func (s *webSocketSession) expect(ctx context.Context, want *yamlparser.Assert, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var lastMiss []string
+	for {
+		select {
+		case <-ctx.Done():
+			return expectError(fmt.Sprintf("expect: %s", ctx.Err()), lastMiss)
+		case <-timer.C:
+			return expectError(fmt.Sprintf("expect: no matching message within %s", timeout), lastMiss)
+		case frame, ok := <-s.incoming:
+			if err := s.receive(frame, ok); err != nil {
+				return expectError("expect: "+err.Error(), lastMiss)
+			}
+			miss := append(checkJSON(want.JSON, frame.data), checkBody(want.Body, frame.data)...)
+			if len(miss) == 0 {
+				return nil
+			}
+			lastMiss = miss
+		}
+	}
+}
+
+// expectError puts the checks the last message failed on detail lines, the
+// way a failed poll reports them.
+func expectError(headline string, lastMiss []string) error {
+	if len(lastMiss) == 0 {
+		return errors.New(headline)
+	}
+	return errors.New(headline + "\n" + strings.Join(lastMiss, "\n"))
+}
+
+// readEvents keeps reading after the script until a limit in events is
+// reached or the server closes the connection. The limits count messages
+// received from here on.
+func (s *webSocketSession) readEvents(ctx context.Context, events *yamlparser.Events) error {
+	until, err := events.UntilRegexp()
+	if err != nil {
+		return err
+	}
+	var deadline <-chan time.Time
+	if d, _ := events.ParsedDuration(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("reading messages after %d: %w", count, ctx.Err())
+		case <-deadline:
+			s.stop = stopDuration
+			return nil
+		case frame, ok := <-s.incoming:
+			if err := s.receive(frame, ok); err != nil {
+				if s.stop == stopClosed {
+					return nil
+				}
+				return err
+			}
+			count++
+			switch {
+			case until != nil && !frame.binary && until.Match(frame.data):
+				s.stop = stopUntil
+				return nil
+			case events.MaxEvents > 0 && count >= events.MaxEvents:
+				s.stop = stopMaxEvents
+				return nil
+			}
+		}
+	}
+}
+
+// receive records a message taken from incoming, or turns the end of the
+// connection into an error.
+func (s *webSocketSession) receive(frame wsFrame, ok bool) error {
+	if !ok || errors.Is(frame.err, io.EOF) {
+		s.stop = stopClosed
+		return errors.New("connection closed by the server")
+	}
+	if frame.err != nil {
+		return fmt.Errorf("reading message: %w", frame.err)
+	}
+	s.received++
+	s.record("received", frame)
+	return nil
+}
+
+// record appends a message to the transcript and prints it to s.live,
+// prefixed with > when sent and < when received.
+func (s *webSocketSession) record(direction string, frame wsFrame) {
+	msg := wsMessage{
+		Direction: direction,
+		Binary:    frame.binary,
+		ElapsedMS: time.Since(s.start).Milliseconds(),
+	}
+	shown := string(frame.data)
+	if frame.binary {
+		msg.Data, _ = json.Marshal(frame.data) // []byte marshals as base64
+		shown = fmt.Sprintf("<binary message, %s>", utils.FormatBytes(int64(len(frame.data))))
+	} else {
+		msg.Data = eventData(shown)
+	}
+	line, _ := json.Marshal(msg)
+	s.transcript.Write(line)
+	s.transcript.WriteByte('\n')
+
+	if s.live == nil {
+		return
+	}
+	arrow := "<"
+	if direction == "sent" {
+		arrow = ">"
+	}
+	_, _ = io.WriteString(s.live, arrow+" "+shown+"\n")
+}
+
+// summary is the line printed after a session, e.g. "2 sent, 5 received in
+// 1.3s (script done), transcript saved to chat_response.ndjson". savedPath
+// is "" when nothing was written.
+func (s *webSocketSession) summary(savedPath string) string {
+	stop := s.stop
+	if stop == "" {
+		stop = "failed"
+	}
+	summary := fmt.Sprintf(
+		"%d sent, %d received in %s (%s)",
+		s.sent, s.received, time.Since(s.start).Round(100*time.Millisecond), stop,
+	)
+	if savedPath == "" {
+		return summary
+	}
+	return summary + ", transcript saved to " + savedPath
+}
+
+// FormatWebSocketDryRun renders the handshake and script of a WebSocket
+// file without connecting. Sensitive headers are masked unless show is true.
+func FormatWebSocketDryRun(file *yamlparser.WebSocketFile, show bool) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "WEBSOCKET %s\n", PrepareURL(string(file.URL), file.URLParams))
+
+	headers := utils.RedactHeaders(file.Headers, show)
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s: %s\n", k, headers[k])
+	}
+	if len(file.Subprotocols) > 0 {
+		fmt.Fprintf(&b, "Sec-WebSocket-Protocol: %s\n", strings.Join(file.Subprotocols, ", "))
+	}
+	if len(file.Messages) == 0 {
+		return b.String(), nil
+	}
+
+	b.WriteByte('\n')
+	for i := range file.Messages {
+		step := &file.Messages[i]
+		if step.Expect == nil {
+			payload, err := step.Payload()
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&b, "> %s\n", payload)
+			continue
+		}
+		checks, _ := json.Marshal(step.Expect)
+		timeout, _ := step.ParsedTimeout()
+		fmt.Fprintf(&b, "< expect %s within %s\n", checks, timeout)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,367 @@
+package apicalls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// newWebSocketServer serves handler at ws://.../ and returns its URL.
+func newWebSocketServer(t *testing.T, handler func(ws *websocket.Conn)) string {
+	t.Helper()
+	server := httptest.NewServer(websocket.Handler(handler))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// newConnectProxy starts an HTTP proxy that tunnels every CONNECT to
+// backend, whatever address it asks for. It returns the proxy's URL and
+// the addresses asked for so far.
+func newConnectProxy(t *testing.T, backend string) (string, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var asked []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		asked = append(asked, r.Host)
+		mu.Unlock()
+		upstream, err := net.Dial("tcp", backend)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go func() { _, _ = io.Copy(upstream, rw) }()
+		_, _ = io.Copy(conn, upstream)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(asked)
+	}
+}
+
+// writeWebSocketFile writes a WebSocket request file for url and returns
+// its path.
+func writeWebSocketFile(t *testing.T, url, rest string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chat.hk.yaml")
+	content := "kind: WebSocket\nurl: " + url + "\n" + rest
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readTranscript decodes a saved NDJSON transcript.
+func readTranscript(t *testing.T, path string) []wsMessage {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []wsMessage
+	for line := range strings.SplitSeq(strings.TrimSpace(string(content)), "\n") {
+		var msg wsMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("transcript line %q: %v", line, err)
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestSendWebSocket_Script(t *testing.T) {
+	var gotHeader, gotProtocol string
+	url := newWebSocketServer(t, func(ws *websocket.Conn) {
+		gotHeader = ws.Request().Header.Get("X-Team")
+		gotProtocol = ws.Request().Header.Get("Sec-WebSocket-Protocol")
+		_, _ = io.Copy(ws, ws)
+	})
+	path := writeWebSocketFile(t, url, `headers:
+  X-Team: payments
+subprotocols: [echo.v1]
+messages:
+  - send: hello
+  - expect:
+      body: ["^hello$"]
+  - json:
+      op: subscribe
+      Channel: prices
+  - expect:
+      json:
+        - path: Channel
+          equals: prices
+`)
+
+	var live bytes.Buffer
+	result, err := SendWebSocket(context.Background(), RequestOptions{
+		Secrets:  map[string]any{},
+		Path:     path,
+		EventOut: &live,
+	})
+	if err != nil {
+		t.Fatalf("SendWebSocket: %v", err)
+	}
+	if gotHeader != "payments" || gotProtocol != "echo.v1" {
+		t.Errorf("handshake header %q, protocol %q; want payments, echo.v1", gotHeader, gotProtocol)
+	}
+	if result.Status != webSocketStatus || result.Attempts != 1 {
+		t.Errorf("status %q, attempts %d", result.Status, result.Attempts)
+	}
+
+	wantLive := "> hello\n< hello\n> {\"Channel\":\"prices\",\"op\":\"subscribe\"}\n< {\"Channel\":\"prices\",\"op\":\"subscribe\"}\n"
+	if live.String() != wantLive {
+		t.Errorf("live =\n%s\nwant\n%s", live.String(), wantLive)
+	}
+
+	saved := filepath.Join(filepath.Dir(path), "chat.hk_response.ndjson")
+	if !strings.HasPrefix(result.Summary, "2 sent, 2 received in ") ||
+		!strings.HasSuffix(result.Summary, "(script done), transcript saved to "+saved) {
+		t.Errorf("summary = %q", result.Summary)
+	}
+	messages := readTranscript(t, saved)
+	if len(messages) != 4 {
+		t.Fatalf("transcript has %d messages, want 4", len(messages))
+	}
+	if messages[0].Direction != "sent" || string(messages[0].Data) != `"hello"` {
+		t.Errorf("first message = %+v, want sent \"hello\"", messages[0])
+	}
+	if messages[3].Direction != "received" || string(messages[3].Data) != `{"Channel":"prices","op":"subscribe"}` {
+		t.Errorf("last message = %+v, want the JSON echo embedded as is", messages[3])
+	}
+}
+
+func TestSendWebSocket_Proxy(t *testing.T) {
+	backend := newWebSocketServer(t, func(ws *websocket.Conn) {
+		_, _ = io.Copy(ws, ws)
+	})
+	proxyURL, asked := newConnectProxy(t, strings.TrimPrefix(backend, "ws://"))
+	path := writeWebSocketFile(t, "ws://chat.example.test:8080/", `proxy: `+proxyURL+`
+messages:
+  - send: hello
+  - expect:
+      body: ["^hello$"]
+`)
+
+	if _, err := SendWebSocket(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path, NoSave: true}); err != nil {
+		t.Fatalf("SendWebSocket: %v", err)
+	}
+	if got := asked(); !slices.Equal(got, []string{"chat.example.test:8080"}) {
+		t.Errorf("proxy asked for %v, want the file's host", got)
+	}
+}
+
+// TestDialWebSocket_OffersHTTP1Only verifies a wss handshake works with a
+// tls config that advertises h2, as one shared with HTTP requests may,
+// against a server that speaks it.
+func TestDialWebSocket_OffersHTTP1Only(t *testing.T) {
+	server := httptest.NewUnstartedServer(websocket.Handler(func(ws *websocket.Conn) {
+		_, _ = io.Copy(ws, ws)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool, NextProtos: []string{"h2", "http/1.1"}}
+	target := "wss" + strings.TrimPrefix(server.URL, "https")
+	conn, err := dialWebSocket(context.Background(), target, nil, nil, tlsConfig, nil)
+	if err != nil {
+		t.Fatalf("dialWebSocket: %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestSendWebSocket_ExpectFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(ws *websocket.Conn)
+		wantErr []string
+	}{
+		{
+			name: "no matching message",
+			handler: func(ws *websocket.Conn) {
+				_ = websocket.Message.Send(ws, `{"status":"pending"}`)
+				_, _ = io.Copy(io.Discard, ws)
+			},
+			wantErr: []string{
+				"messages[0]: expect: no matching message within 200ms",
+				"json status: got pending, want ready",
+			},
+		},
+		{
+			name:    "server closes",
+			handler: func(ws *websocket.Conn) { _ = ws.Close() },
+			wantErr: []string{"messages[0]: expect: connection closed by the server"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeWebSocketFile(t, newWebSocketServer(t, tc.handler), `messages:
+  - expect:
+      json:
+        - path: status
+          equals: ready
+    timeout: 200ms
+`)
+			result, err := SendWebSocket(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path})
+			if err == nil {
+				t.Fatal("SendWebSocket succeeded, want the expect step to fail")
+			}
+			for _, want := range tc.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+			if _, statErr := os.Stat(filepath.Join(filepath.Dir(path), "chat.hk_response.ndjson")); statErr != nil {
+				t.Errorf("transcript not saved after a failed step: %v", statErr)
+			}
+			if result.Status != webSocketStatus {
+				t.Errorf("status = %q, want the handshake status", result.Status)
+			}
+		})
+	}
+}
+
+func TestSendWebSocket_Events(t *testing.T) {
+	// feed answers a subscribe with ticks, then "done", then closes.
+	feed := func(ws *websocket.Conn) {
+		var subscribe string
+		if err := websocket.Message.Receive(ws, &subscribe); err != nil {
+			return
+		}
+		for _, msg := range []string{`{"tick":1}`, `{"tick":2}`, `{"tick":3}`, "done"} {
+			_ = websocket.Message.Send(ws, msg)
+		}
+		_ = websocket.Message.Send(ws, []byte{0x00, 0x01})
+		_ = ws.Close()
+	}
+	tests := []struct {
+		name         string
+		events       string
+		wantReceived int
+		wantStop     string
+	}{
+		{"max_events", "events:\n  max_events: 2\n", 2, stopMaxEvents},
+		{"until", "events:\n  until: ^done$\n", 4, stopUntil},
+		{"server closes", "events: {}\n", 5, stopClosed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeWebSocketFile(t, newWebSocketServer(t, feed), "messages:\n  - send: subscribe\n"+tc.events)
+			result, err := SendWebSocket(context.Background(), RequestOptions{
+				Secrets: map[string]any{},
+				Path:    path,
+				NoSave:  true,
+			})
+			if err != nil {
+				t.Fatalf("SendWebSocket: %v", err)
+			}
+			wantSummary := fmt.Sprintf("1 sent, %d received in ", tc.wantReceived)
+			if !strings.HasPrefix(result.Summary, wantSummary) || !strings.HasSuffix(result.Summary, "("+tc.wantStop+")") {
+				t.Errorf("summary = %q, want %q... (%s)", result.Summary, wantSummary, tc.wantStop)
+			}
+			if lines := strings.Count(string(result.Body), "\n"); lines != tc.wantReceived+1 {
+				t.Errorf("transcript has %d lines, want %d", lines, tc.wantReceived+1)
+			}
+			if tc.wantStop == stopClosed && !strings.Contains(string(result.Body), `"data":"AAE=","binary":true`) {
+				t.Errorf("binary message not recorded as base64:\n%s", result.Body)
+			}
+		})
+	}
+}
+
+func TestSendWebSocket_Duration(t *testing.T) {
+	url := newWebSocketServer(t, func(ws *websocket.Conn) {
+		_ = websocket.Message.Send(ws, "hello")
+		_, _ = io.Copy(io.Discard, ws)
+	})
+	path := writeWebSocketFile(t, url, "events:\n  duration: 200ms\n")
+	start := time.Now()
+	result, err := SendWebSocket(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path, NoSave: true})
+	if err != nil {
+		t.Fatalf("SendWebSocket: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("the duration limit did not end the session")
+	}
+	if !strings.HasPrefix(result.Summary, "0 sent, 1 received") || !strings.HasSuffix(result.Summary, "("+stopDuration+")") {
+		t.Errorf("summary = %q", result.Summary)
+	}
+}
+
+func TestSendWebSocket_HandshakeRefused(t *testing.T) {
+	server := httptest.NewServer(nil) // 404 for everything
+	defer server.Close()
+	path := writeWebSocketFile(t, "ws"+strings.TrimPrefix(server.URL, "http"), "")
+	_, err := SendWebSocket(context.Background(), RequestOptions{Secrets: map[string]any{}, Path: path})
+	if err == nil || !strings.Contains(err.Error(), "without 101 Switching Protocols") {
+		t.Fatalf("err = %v, want a refused handshake", err)
+	}
+}
+
+func TestFormatWebSocketDryRun(t *testing.T) {
+	path := writeWebSocketFile(t, "wss://example.com/live", `urlparams:
+  room: "7"
+headers:
+  Authorization: Bearer secret
+subprotocols: [chat]
+messages:
+  - send: hi
+  - expect:
+      body: [hi]
+    timeout: 3s
+`)
+	file, err := yamlparser.FinalStructForWebSocket(path, map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := FormatWebSocketDryRun(&file, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"WEBSOCKET wss://example.com/live?room=7\n",
+		"Sec-WebSocket-Protocol: chat\n",
+		"\n> hi\n",
+		`< expect {"body":["hi"]} within 3s`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("dry run printed the Authorization header:\n%s", out)
+	}
+}
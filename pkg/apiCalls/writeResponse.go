@@ -9,8 +9,10 @@ import (
 	"mime"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/xaaha/hulak/pkg/httpclient"
 	"github.com/xaaha/hulak/pkg/userFlags/cliflags"
 	"github.com/xaaha/hulak/pkg/utils"
 	"golang.org/x/net/html"
@@ -116,6 +118,8 @@ func extensionForMediaType(media string) (string, bool) {
 		return ".txt", true
 	case "text/csv":
 		return ".csv", true
+	case "application/x-ndjson", "application/ndjson":
+		return ".ndjson", true
 	case "application/pdf":
 		return ".pdf", true
 	}
@@ -123,38 +127,120 @@ func extensionForMediaType(media string) (string, bool) {
 }
 
 // Write the content to the specified path with the appropriate file extension.
-// When outPath is set, the response lands at cliflags.ResolveOutputPath(outPath,
-// canonical) instead of next to the request file, and any missing parent
-// directories are created. Overwrites on collision, same as the default path.
-// Returns an error if the disk write fails so the caller can fail the task
-// instead of silently losing the response file.
-func writeFile(path, suffixType, contentBody, outPath string) error {
+// The file goes where responseFilePath puts it and overwrites on collision.
+// Returns the path written, or an error if the disk write fails so the caller
+// can fail the task instead of silently losing the response file. The bytes
+// are written as-is, so binary bodies land on disk byte-for-byte.
+func writeFile(path, suffixType string, content []byte, outPath string, row int) (string, error) {
+	fullFilePath, err := responseFilePath(path, suffixType, outPath, row)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(fullFilePath, content, 0o600); err != nil {
+		return "", fmt.Errorf("saving response %s: %w", fullFilePath, err)
+	}
+	return fullFilePath, nil
+}
+
+// responseFilePath is where the response to the request file at path is
+// saved: {name}_response<suffixType> next to it, or
+// cliflags.ResolveOutputPath(outPath, canonical) when outPath is set, in
+// which case any missing parent directories are created.
+//
+// A non-zero row (a data-driven run) suffixes the file name with _<row>, so
+// every dataset row keeps its own response: users_response_2.json, or
+// out_2.json for an explicit --out file.
+func responseFilePath(path, suffixType, outPath string, row int) (string, error) {
 	fileName := utils.FileNameWithoutExtension(path) + utils.ResponseBase
-	dir := filepath.Dir(path)
-	fullFilePath := filepath.Join(dir, fileName+suffixType)
+	if row > 0 {
+		fileName += "_" + strconv.Itoa(row)
+	}
+	if outPath == "" {
+		return filepath.Join(filepath.Dir(path), fileName+suffixType), nil
+	}
 
-	if outPath != "" {
-		resolved, err := cliflags.ResolveOutputPath(outPath, fileName+suffixType)
-		if err != nil {
-			return err
+	resolved, err := cliflags.ResolveOutputPath(outPath, fileName+suffixType)
+	if err != nil {
+		return "", err
+	}
+	if row > 0 && filepath.Base(resolved) != fileName+suffixType {
+		ext := filepath.Ext(resolved)
+		resolved = strings.TrimSuffix(resolved, ext) + "_" + strconv.Itoa(row) + ext
+	}
+	if err := os.MkdirAll(filepath.Dir(resolved), utils.DirPer); err != nil {
+		return "", fmt.Errorf("creating output dir for %s: %w", resolved, err)
+	}
+	return resolved, nil
+}
+
+// evalAndWriteRes picks the file extension via Content-Type (with body-sniff
+// fallback) and writes resBody to outPath (or next to path when outPath is "").
+// row is the dataset row for data-driven runs, 0 otherwise. Returns the
+// path written.
+func evalAndWriteRes(resBody, contentType, path, outPath string, row int) (string, error) {
+	if resBody == "" || path == "" {
+		return "", errors.New("invalid input: file path and resBody cannot be empty")
+	}
+	return writeFile(path, extensionFor(contentType, resBody), []byte(resBody), outPath, row)
+}
+
+// redirectsSuffix ends the name of the file saveRedirects writes, after the
+// response file's name without its extension.
+const redirectsSuffix = "_redirects.json"
+
+// savedRedirects is the file saveRedirects writes: the redirects followed,
+// as --debug lists them, and the status and Location of a redirect that
+// was returned as the response instead of followed.
+type savedRedirects struct {
+	Redirects []httpclient.RedirectHop `json:"redirects,omitempty"`
+	Status    string                   `json:"status,omitempty"`
+	Location  string                   `json:"location,omitempty"`
+}
+
+// saveRedirects saves the redirects of a default-mode response, whose
+// saved body has no room for them, to {name}_response_redirects.json (or
+// out_redirects.json for --out out.json). A response without redirects
+// removes the file, so one from an earlier run doesn't stay behind.
+func saveRedirects(resp *CustomResponse, path, outPath string, row int) error {
+	if path == "" {
+		return nil
+	}
+	responsePath, err := responseFilePath(path, ".json", outPath, row)
+	if err != nil {
+		return err
+	}
+	fullFilePath := strings.TrimSuffix(responsePath, filepath.Ext(responsePath)) + redirectsSuffix
+
+	saved := savedRedirects{Redirects: resp.Redirects}
+	if resp.Response != nil {
+		saved.Status = resp.Response.Status
+		if code := resp.Response.StatusCode; code >= 300 && code < 400 {
+			saved.Location = resp.header.Get("Location")
 		}
-		fullFilePath = resolved
-		if err := os.MkdirAll(filepath.Dir(fullFilePath), utils.DirPer); err != nil {
-			return fmt.Errorf("creating output dir for %s: %w", fullFilePath, err)
+	}
+	if len(saved.Redirects) == 0 && saved.Location == "" {
+		if err := os.Remove(fullFilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing %s: %w", fullFilePath, err)
 		}
+		return nil
 	}
 
-	if err := os.WriteFile(fullFilePath, []byte(contentBody), 0o600); err != nil {
-		return fmt.Errorf("saving response %s: %w", fullFilePath, err)
+	content, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fullFilePath, content, 0o600); err != nil {
+		return fmt.Errorf("saving redirects %s: %w", fullFilePath, err)
 	}
 	return nil
 }
 
-// evalAndWriteRes picks the file extension via Content-Type (with body-sniff
-// fallback) and writes resBody to outPath (or next to path when outPath is "").
-func evalAndWriteRes(resBody, contentType, path, outPath string) error {
-	if resBody == "" || path == "" {
-		return errors.New("invalid input: file path and resBody cannot be empty")
+// writeBinaryRes saves a binary response body byte-for-byte, with the
+// extension from binaryExtension, and returns the path written.
+func writeBinaryRes(resp *CustomResponse, path, outPath string, row int) (string, error) {
+	if path == "" {
+		return "", errors.New("invalid input: file path cannot be empty")
 	}
-	return writeFile(path, extensionFor(contentType, resBody), resBody, outPath)
+	ext := binaryExtension(resp.contentType, resp.header.Get("Content-Disposition"), resp.rawBody)
+	return writeFile(path, ext, resp.rawBody, outPath, row)
 }
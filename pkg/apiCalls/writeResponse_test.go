@@ -52,7 +52,7 @@ func TestEvalAndWriteRes(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			filePath := filepath.Join(tempDir, "test")
 
-			if err := evalAndWriteRes(tc.resBody, tc.contentType, filePath, ""); err != nil {
+			if _, err := evalAndWriteRes(tc.resBody, tc.contentType, filePath, "", 0); err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
 
@@ -67,7 +67,7 @@ func TestEvalAndWriteRes(t *testing.T) {
 	}
 
 	t.Run("Invalid inputs should not create files", func(t *testing.T) {
-		err := evalAndWriteRes("", "", "", "")
+		_, err := evalAndWriteRes("", "", "", "", 0)
 		if err == nil {
 			t.Fatal("Expected Error but did not get it")
 		}
@@ -82,6 +82,7 @@ func TestWriteFile_OutPath(t *testing.T) {
 	tests := []struct {
 		name    string
 		outPath string
+		row     int
 		wantRel string // path relative to dir that must exist after the write
 	}{
 		{
@@ -99,11 +100,22 @@ func TestWriteFile_OutPath(t *testing.T) {
 			outPath: filepath.Join(dir, "into") + string(filepath.Separator),
 			wantRel: filepath.Join("into", "req.hk_response.json"),
 		},
+		{
+			name:    "dataset row suffixes the canonical name",
+			row:     2,
+			wantRel: "req.hk_response_2.json",
+		},
+		{
+			name:    "dataset row suffixes an explicit out file",
+			outPath: filepath.Join(dir, "custom", "out.json"),
+			row:     3,
+			wantRel: filepath.Join("custom", "out_3.json"),
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			if err := writeFile(requestPath, ".json", `{"ok":true}`, tc.outPath); err != nil {
+			if _, err := writeFile(requestPath, ".json", []byte(`{"ok":true}`), tc.outPath, tc.row); err != nil {
 				t.Fatalf("writeFile: %v", err)
 			}
 			want := filepath.Join(dir, tc.wantRel)
@@ -132,7 +144,7 @@ func TestEvalAndWriteRes_ReadOnlyDir(t *testing.T) {
 	}
 	t.Cleanup(func() { _ = os.Chmod(tempDir, 0o755) })
 
-	err := evalAndWriteRes(`{"ok":true}`, "application/json", filepath.Join(tempDir, "req"), "")
+	_, err := evalAndWriteRes(`{"ok":true}`, "application/json", filepath.Join(tempDir, "req"), "", 0)
 	if err == nil {
 		t.Fatal("expected error writing to read-only dir, got nil")
 	}
@@ -208,6 +220,12 @@ func TestExtensionFor(t *testing.T) {
 			body:        "a,b\n1,2",
 			want:        ".csv",
 		},
+		{
+			name:        "ndjson",
+			contentType: "application/x-ndjson",
+			body:        "{\"id\":1}\n{\"id\":2}\n",
+			want:        ".ndjson",
+		},
 		{
 			name:        "application/pdf",
 			contentType: "application/pdf",
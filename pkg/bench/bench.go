@@ -0,0 +1,379 @@
+// Package bench load-tests request files: `hulak bench` sends a file's
+// request over and over, at a concurrency or a rate, for a while, and
+// reports how fast and how reliably the server answered. Files are built
+// with the same vault secrets and templates as `hulak run`.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/actions"
+	apicalls "github.com/xaaha/hulak/pkg/apiCalls"
+	"github.com/xaaha/hulak/pkg/features"
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/runner"
+	"github.com/xaaha/hulak/pkg/tui"
+	"github.com/xaaha/hulak/pkg/tui/envselect"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// DefaultConcurrency is how many requests are in flight at once when
+// --concurrency isn't set.
+const DefaultConcurrency = 10
+
+// DefaultDuration is how long each file is benched when --duration isn't
+// set.
+const DefaultDuration = 10 * time.Second
+
+// envSelector is the function used to show the interactive environment picker.
+// Package-level var so tests can replace it without TUI dependencies.
+var envSelector = envselect.RunEnvSelector
+
+// Flags holds the parsed `hulak bench` flags.
+type Flags struct {
+	Env    string
+	EnvSet bool
+	// Path is the request file, or a directory whose files are benched one
+	// after another.
+	Path string
+	// Concurrency is how many requests are in flight at once. With Rate it
+	// is the most that may be.
+	Concurrency int
+	// Rate sends this many requests per second instead of as many as
+	// Concurrency allows. Zero means no rate.
+	Rate float64
+	// Duration is how long each file is benched.
+	Duration time.Duration
+	// Timeout is the per-request timeout, as for `hulak run --timeout`. A
+	// file's `timeout:` wins.
+	Timeout time.Duration
+	// Out is where the JSON results are written. Empty writes none.
+	Out string
+	// SSHIdentity overrides the SSH key path for vault decryption, as for
+	// `hulak run`.
+	SSHIdentity string
+}
+
+// options are the load settings shared by every file of a bench.
+type options struct {
+	concurrency int
+	rate        float64
+	duration    time.Duration
+}
+
+// Execute benches the file or every file of the directory at f.Path,
+// prints a report for each, and writes them to f.Out when set. A file
+// that can't be built, or whose every request failed, fails the bench;
+// the other files still run. Ctrl-C stops the bench and reports what was
+// measured so far.
+func Execute(f *Flags) error {
+	if f.SSHIdentity != "" && os.Getenv(utils.SSHIdentityEnvVar) == "" {
+		if err := os.Setenv(utils.SSHIdentityEnvVar, f.SSHIdentity); err != nil {
+			return fmt.Errorf("failed to set %s: %w", utils.SSHIdentityEnvVar, err)
+		}
+		defer os.Unsetenv(utils.SSHIdentityEnvVar)
+	}
+
+	baseTimeout, err := runner.ResolveBaseTimeout(f.Timeout)
+	if err != nil {
+		return err
+	}
+
+	paths, err := filePaths(f.Path)
+	if err != nil {
+		return err
+	}
+
+	var secrets map[string]any
+	if slices.ContainsFunc(paths, utils.FileHasTemplateVars) {
+		if !utils.IsHulakProject() {
+			return fmt.Errorf("not a hulak project — run 'hulak init' to set up")
+		}
+		if !f.EnvSet {
+			picked, cancelled, err := envSelector()
+			if err != nil {
+				return fmt.Errorf("environment selector: %w", err)
+			}
+			if cancelled {
+				return nil
+			}
+			f.Env = picked
+		}
+		if secrets, err = runner.InitializeProject(f.Env, true); err != nil {
+			return err
+		}
+	}
+	// getValueOf on an Auth-kind file reads its cached token, as in a run.
+	secrets = actions.WithResponseSource(secrets, features.TokenSource(secrets))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts := options{concurrency: f.Concurrency, rate: f.Rate, duration: f.Duration}
+	var reports []Report
+	var errs []error
+	for _, path := range paths {
+		t, err := load(path, secrets, baseTimeout)
+		if errors.Is(err, errUnsupported) && len(paths) > 1 {
+			utils.PrintWarningStderr(fmt.Sprintf("skipping %s: %v", filepath.Base(path), err))
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+
+		report := runWithStatus(ctx, t, opts)
+		printReport(os.Stdout, &report)
+		reports = append(reports, report)
+		if report.Requests > 0 && report.Failed == report.Requests {
+			errs = append(errs, fmt.Errorf("%s: all %d requests failed", path, report.Requests))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if f.Out != "" && len(reports) > 0 {
+		if err := writeResults(f.Out, reports); err != nil {
+			errs = append(errs, fmt.Errorf("writing results to %s: %w", f.Out, err))
+		} else {
+			utils.PrintInfoStderr("Results written to " + f.Out)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// filePaths lists the request files to bench: path itself, or the
+// request files in the directory at path.
+func filePaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access %q: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	dirPaths, err := apicalls.ListDirPaths(path, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(dirPaths.Concurrent) == 0 {
+		return nil, fmt.Errorf("no request files in %q", path)
+	}
+	return dirPaths.Concurrent, nil
+}
+
+// errUnsupported marks a file bench can't send over and over.
+var errUnsupported = errors.New("bench sends API and GraphQL requests")
+
+// target is a request file built once, to be sent again and again with
+// the same values.
+type target struct {
+	path    string
+	info    yamlparser.APIInfo
+	body    []byte
+	timeout time.Duration
+}
+
+// load builds the request in the file at path, the way `hulak run` would.
+// Templates are resolved once, so every request of the bench sends the
+// same URL, headers, and body.
+func load(path string, secrets map[string]any, baseTimeout time.Duration) (*target, error) {
+	config, err := yamlparser.ParseConfig(path, secrets)
+	if err != nil {
+		return nil, err
+	}
+	if !config.IsAPI() && !config.IsGraphql() {
+		return nil, fmt.Errorf("%w, not %s files", errUnsupported, config.Kind)
+	}
+
+	apiConfig, _, err := yamlparser.FinalStructForAPI(path, secrets)
+	if err != nil {
+		return nil, err
+	}
+	if apiConfig.Body != nil && apiConfig.Body.Graphql.IsSubscription() {
+		return nil, fmt.Errorf("%w, not GraphQL subscriptions", errUnsupported)
+	}
+	info, err := apiConfig.PrepareStruct()
+	if err != nil {
+		return nil, err
+	}
+	// The bench's requests share one jar, as a run's do.
+	if apiConfig.UsesJar(false) {
+		info.Jar = httpclient.NewJar()
+	}
+
+	t := &target{path: path, info: info, timeout: baseTimeout}
+	if d, _ := config.ParsedTimeout(); d > 0 {
+		t.timeout = d
+	}
+	// Every request sends the same body, so read it once up front.
+	if info.Body != nil {
+		t.body, err = io.ReadAll(info.Body)
+		if c, ok := info.Body.(io.Closer); ok {
+			_ = c.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading request body: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// sample is the result of one request.
+type sample struct {
+	latency time.Duration
+	status  int   // zero when no response arrived
+	err     error // why no response arrived
+}
+
+// client returns the HTTP client for a bench of t with up to concurrency
+// requests in flight. It keeps an idle connection per worker, so each
+// request reuses one instead of opening a new connection, which would
+// measure the handshake and run out of local ports.
+func (t *target) client(concurrency int) *httpclient.Client {
+	return httpclient.NewWithOptions(httpclient.Options{
+		TLS:                 t.info.TLS,
+		Proxy:               t.info.Proxy,
+		Jar:                 t.info.Jar,
+		MaxIdleConnsPerHost: concurrency,
+	})
+}
+
+// send sends the request once with client. Retry and poll sections don't
+// apply: every request counts on its own.
+func (t *target) send(ctx context.Context, client httpclient.HTTPClient) sample {
+	info := t.info
+	if t.body != nil {
+		info.Body = bytes.NewReader(t.body)
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := apicalls.StandardCallWithClient(ctx, info, false, client)
+	s := sample{latency: time.Since(start), err: err}
+	if err == nil && resp.Response != nil {
+		s.status = resp.Response.StatusCode
+	}
+	return s
+}
+
+// failed reports whether the request counts as an error: no response, or
+// a 4xx or 5xx status.
+func (s *sample) failed() bool {
+	return s.err != nil || s.status >= http.StatusBadRequest
+}
+
+// recorder collects the samples of a bench from its workers.
+type recorder struct {
+	mu      sync.Mutex
+	samples []sample
+
+	sent   atomic.Int64
+	failed atomic.Int64
+}
+
+func (r *recorder) add(s sample) {
+	r.mu.Lock()
+	r.samples = append(r.samples, s)
+	r.mu.Unlock()
+	r.sent.Add(1)
+	if s.failed() {
+		r.failed.Add(1)
+	}
+}
+
+// run sends the request of t as opts says until opts.duration is up, then
+// waits for the requests in flight. Requests cut short by ctx, i.e. by
+// Ctrl-C, aren't counted.
+func run(ctx context.Context, t *target, opts options, rec *recorder) (started time.Time, elapsed time.Duration) {
+	client := t.client(max(opts.concurrency, 1))
+	defer client.HTTP.CloseIdleConnections()
+	started = time.Now()
+	jobs := make(chan struct{})
+	go schedule(ctx, opts, started.Add(opts.duration), jobs)
+
+	var wg sync.WaitGroup
+	for range max(opts.concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				s := t.send(ctx, client)
+				if ctx.Err() != nil {
+					return
+				}
+				rec.add(s)
+			}
+		}()
+	}
+	wg.Wait()
+	return started, time.Since(started)
+}
+
+// schedule hands out a job per request to send until stopAt, and closes
+// jobs. Without a rate, a job is taken as soon as a worker is free. With
+// one, jobs come at that rate; when every worker is busy the schedule
+// waits rather than catching up later in a burst, so the rate achieved
+// falls short of the one asked for.
+func schedule(ctx context.Context, opts options, stopAt time.Time, jobs chan<- struct{}) {
+	defer close(jobs)
+	var interval time.Duration
+	if opts.rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.rate)
+	}
+	next := time.Now()
+	for next.Before(stopAt) {
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case jobs <- struct{}{}:
+		}
+		next = next.Add(interval)
+		if now := time.Now(); next.Before(now) {
+			next = now
+		}
+	}
+}
+
+// runWithStatus runs the bench with a live count of the requests sent on
+// stderr, and reports on it.
+func runWithStatus(ctx context.Context, t *target, opts options) Report {
+	var rec recorder
+	name := filepath.Base(t.path)
+	start := time.Now()
+	status := func() string {
+		left := max(opts.duration-time.Since(start), 0).Round(time.Second)
+		return fmt.Sprintf("Benchmarking '%s': %d sent, %d failed, %s left",
+			name, rec.sent.Load(), rec.failed.Load(), left)
+	}
+	result, _ := tui.RunWithStatusOnStderr(status, func(_ func()) (any, error) {
+		started, elapsed := run(ctx, t, opts, &rec)
+		return summarize(t.path, opts, started, elapsed, rec.samples), nil
+	})
+	return result.(Report)
+}
@@ -0,0 +1,184 @@
+package bench
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "bench.hk.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestRun verifies every request resends the body and is counted by its
+// status.
+func TestRun(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"name":"ada"}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if calls.Add(1)%4 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	target, err := load(writeFile(t, "method: POST\nurl: "+server.URL+"\nbody:\n  raw: '{\"name\":\"ada\"}'\n"), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := options{concurrency: 4, duration: 200 * time.Millisecond}
+	var rec recorder
+	started, elapsed := run(context.Background(), target, opts, &rec)
+	report := summarize(target.path, opts, started, elapsed, rec.samples)
+
+	if report.Requests == 0 || int64(report.Requests) != calls.Load() {
+		t.Fatalf("Requests = %d, server saw %d", report.Requests, calls.Load())
+	}
+	if report.StatusCodes[http.StatusBadRequest] != 0 {
+		t.Errorf("%d requests sent the wrong body", report.StatusCodes[http.StatusBadRequest])
+	}
+	unavailable := report.StatusCodes[http.StatusServiceUnavailable]
+	if report.StatusCodes[http.StatusOK]+unavailable != report.Requests {
+		t.Errorf("StatusCodes = %v, want %d requests", report.StatusCodes, report.Requests)
+	}
+	if report.Failed != unavailable {
+		t.Errorf("Failed = %d, want the %d 503s", report.Failed, unavailable)
+	}
+	if elapsed < opts.duration {
+		t.Errorf("elapsed = %s, want at least %s", elapsed, opts.duration)
+	}
+}
+
+// TestRun_ReusesConnections verifies the workers keep their connections
+// open between requests rather than opening one per request.
+func TestRun_ReusesConnections(t *testing.T) {
+	var opened atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	target, err := load(writeFile(t, "method: GET\nurl: "+server.URL+"\n"), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := options{concurrency: 8, duration: 200 * time.Millisecond}
+	var rec recorder
+	run(context.Background(), target, opts, &rec)
+
+	if len(rec.samples) < 10*opts.concurrency {
+		t.Fatalf("sent %d requests, too few to tell", len(rec.samples))
+	}
+	// A worker may dial again while its last connection is still on its
+	// way back to the pool, so allow one spare each. Without reuse it is
+	// one connection per request.
+	if n := opened.Load(); n > int64(2*opts.concurrency) {
+		t.Errorf("opened %d connections for %d requests, want about one per worker", n, len(rec.samples))
+	}
+}
+
+// TestRun_Rate verifies --rate paces the requests instead of sending them
+// as fast as the workers can.
+func TestRun_Rate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	target, err := load(writeFile(t, "method: GET\nurl: "+server.URL+"\n"), nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec recorder
+	run(context.Background(), target, options{concurrency: 4, rate: 50, duration: 300 * time.Millisecond}, &rec)
+
+	// 50/s for 0.3s is 15 requests, give or take the timer.
+	if n := len(rec.samples); n < 10 || n > 17 {
+		t.Errorf("sent %d requests, want about 15", n)
+	}
+}
+
+// TestRun_Interrupted verifies a cancelled bench stops and drops the
+// requests it cut short.
+func TestRun_Interrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	target, err := load(writeFile(t, "method: GET\nurl: "+server.URL+"\n"), nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var rec recorder
+	_, elapsed := run(ctx, target, options{concurrency: 2, duration: time.Minute}, &rec)
+
+	if elapsed > 10*time.Second {
+		t.Errorf("elapsed = %s, want the bench to stop when cancelled", elapsed)
+	}
+	if len(rec.samples) != 0 {
+		t.Errorf("recorded %d requests cut short", len(rec.samples))
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantTimeout time.Duration
+		wantErr     error
+	}{
+		{name: "api file", content: "method: GET\nurl: http://localhost\n", wantTimeout: time.Second},
+		{name: "file timeout wins", content: "method: GET\nurl: http://localhost\ntimeout: 5s\n", wantTimeout: 5 * time.Second},
+		{name: "websocket file", content: "kind: WebSocket\nurl: ws://localhost\n", wantErr: errUnsupported},
+		{
+			name:    "graphql subscription",
+			content: "kind: GraphQL\nmethod: POST\nurl: http://localhost/graphql\nbody:\n  graphql:\n    query: 'subscription { ticks }'\n",
+			wantErr: errUnsupported,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			target, err := load(writeFile(t, tc.content), nil, time.Second)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("load() error = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target.timeout != tc.wantTimeout {
+				t.Errorf("timeout = %s, want %s", target.timeout, tc.wantTimeout)
+			}
+		})
+	}
+}
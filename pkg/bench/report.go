@@ -0,0 +1,284 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// Report is what a bench of one file measured. It is printed after the
+// bench and saved as is by --out.
+type Report struct {
+	File        string    `json:"file"`
+	StartedAt   time.Time `json:"started_at"`
+	DurationMS  int64     `json:"duration_ms"`
+	Concurrency int       `json:"concurrency"`
+	// Rate is the rate asked for, in requests per second; zero without
+	// --rate.
+	Rate float64 `json:"rate,omitempty"`
+	// Requests counts the requests that finished, Failed those that got
+	// no response or a 4xx or 5xx status.
+	Requests  int     `json:"requests"`
+	Failed    int     `json:"failed"`
+	ErrorRate float64 `json:"error_rate"`
+	// Throughput is the requests finished per second.
+	Throughput float64 `json:"throughput"`
+	// Latency and Histogram are over the requests that got a response,
+	// whatever its status. Both are left out when none did.
+	Latency     *Latency       `json:"latency_ms,omitempty"`
+	StatusCodes map[int]int    `json:"status_codes"`
+	Errors      map[string]int `json:"errors,omitempty"`
+	Histogram   []Bucket       `json:"histogram,omitempty"`
+}
+
+// Latency sums up the response times of a bench, in milliseconds.
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// Bucket counts the responses that took at most UpTo milliseconds and
+// longer than the bucket before it.
+type Bucket struct {
+	UpTo  float64 `json:"le_ms"`
+	Count int     `json:"count"`
+}
+
+// summarize turns the samples of a bench into its Report.
+func summarize(path string, opts options, started time.Time, elapsed time.Duration, samples []sample) Report {
+	r := Report{
+		File:        path,
+		StartedAt:   started.UTC().Truncate(time.Millisecond),
+		DurationMS:  elapsed.Milliseconds(),
+		Concurrency: opts.concurrency,
+		Rate:        opts.rate,
+		Requests:    len(samples),
+		StatusCodes: map[int]int{},
+	}
+	if elapsed > 0 {
+		r.Throughput = float64(len(samples)) / elapsed.Seconds()
+	}
+
+	var latencies []time.Duration
+	for i := range samples {
+		s := &samples[i]
+		if s.failed() {
+			r.Failed++
+		}
+		if s.err != nil {
+			if r.Errors == nil {
+				r.Errors = map[string]int{}
+			}
+			r.Errors[errorKind(s.err)]++
+			continue
+		}
+		r.StatusCodes[s.status]++
+		latencies = append(latencies, s.latency)
+	}
+	if r.Requests > 0 {
+		r.ErrorRate = float64(r.Failed) / float64(r.Requests)
+	}
+	if len(latencies) == 0 {
+		return r
+	}
+
+	slices.Sort(latencies)
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	r.Latency = &Latency{
+		Min:  ms(latencies[0]),
+		Mean: ms(total / time.Duration(len(latencies))),
+		P50:  ms(percentile(latencies, 50)),
+		P90:  ms(percentile(latencies, 90)),
+		P99:  ms(percentile(latencies, 99)),
+		Max:  ms(latencies[len(latencies)-1]),
+	}
+	r.Histogram = histogram(latencies)
+	return r
+}
+
+// percentile returns the p-th percentile of sorted by nearest rank: the
+// smallest value at least p percent of the values are at or below.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+// histogram counts sorted into buckets whose bounds go 0.1ms, 0.2ms,
+// 0.5ms, 1ms, 2ms, 5ms, and so on up to the slowest response. Fixed bounds
+// keep two benches comparable. Buckets below the fastest response are
+// left out.
+func histogram(sorted []time.Duration) []Bucket {
+	var buckets []Bucket
+	i := 0
+	for bound, step := 100*time.Microsecond, 0; i < len(sorted); step++ {
+		n := 0
+		for i < len(sorted) && sorted[i] <= bound {
+			n++
+			i++
+		}
+		if n > 0 || len(buckets) > 0 {
+			buckets = append(buckets, Bucket{UpTo: ms(bound), Count: n})
+		}
+		// 1, 2, 5, 10, ...
+		if step%3 == 1 {
+			bound = bound * 5 / 2
+		} else {
+			bound *= 2
+		}
+	}
+	return buckets
+}
+
+// errorKind names why a request got no response, the same way for every
+// request that failed alike. The innermost error leaves out what differs
+// between them, like the local port of a reset connection.
+func errorKind(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	for {
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			return err.Error()
+		}
+		err = inner
+	}
+}
+
+// ms converts d to milliseconds, rounded to the microsecond.
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// printReport writes the report of a bench to w: the totals, the
+// latencies, the share of each status and error, and the histogram.
+func printReport(w io.Writer, r *Report) {
+	load := fmt.Sprintf("%d concurrent", r.Concurrency)
+	if r.Rate > 0 {
+		load = fmt.Sprintf("%s/s, up to %s", formatRate(r.Rate), load)
+	}
+	elapsed := (time.Duration(r.DurationMS) * time.Millisecond).Round(100 * time.Millisecond)
+	fmt.Fprintf(w, "\n%s%s%s (%s for %s)\n\n", utils.Blue, filepath.Base(r.File), utils.ColorReset, load, elapsed)
+
+	fmt.Fprintf(w, "Requests:    %d, %s/s\n", r.Requests, formatRate(r.Throughput))
+	fmt.Fprintf(w, "Errors:      %d (%s)\n", r.Failed, formatShare(r.ErrorRate))
+	if l := r.Latency; l != nil {
+		fmt.Fprintf(w, "Latency:     min %s, mean %s, max %s\n",
+			formatLatency(l.Min), formatLatency(l.Mean), formatLatency(l.Max))
+		fmt.Fprintf(w, "Percentiles: p50 %s, p90 %s, p99 %s\n",
+			formatLatency(l.P50), formatLatency(l.P90), formatLatency(l.P99))
+	}
+	if r.Rate > 0 && r.Throughput < 0.9*r.Rate {
+		fmt.Fprintf(w, "%swarning:%s sent %s/s of the %s/s asked for; raise --concurrency\n",
+			utils.Yellow, utils.ColorReset, formatRate(r.Throughput), formatRate(r.Rate))
+	}
+	if r.Requests == 0 {
+		return
+	}
+
+	var rows [][]string
+	codes := make([]int, 0, len(r.StatusCodes))
+	for code := range r.StatusCodes {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		label := strings.TrimSpace(strconv.Itoa(code) + " " + http.StatusText(code))
+		if code >= http.StatusBadRequest {
+			label = utils.Red + label + utils.ColorReset
+		}
+		rows = append(rows, shareRow(label, r.StatusCodes[code], r.Requests))
+	}
+	kinds := make([]string, 0, len(r.Errors))
+	for kind := range r.Errors {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	for _, kind := range kinds {
+		rows = append(rows, shareRow(utils.Red+kind+utils.ColorReset, r.Errors[kind], r.Requests))
+	}
+	fmt.Fprintln(w)
+	_ = utils.PrintTable(w, []string{"RESULT", "COUNT", "SHARE"}, rows, utils.DefaultTableMaxCellWidth)
+
+	if len(r.Histogram) == 0 {
+		return
+	}
+	most := 0
+	for _, b := range r.Histogram {
+		most = max(most, b.Count)
+	}
+	rows = rows[:0]
+	for _, b := range r.Histogram {
+		bar := strings.Repeat("■", int(math.Ceil(float64(b.Count)*histogramWidth/float64(most))))
+		rows = append(rows, []string{"≤ " + formatBound(b.UpTo), strconv.Itoa(b.Count), bar})
+	}
+	fmt.Fprintln(w)
+	_ = utils.PrintTable(w, []string{"LATENCY", "COUNT", ""}, rows, 0)
+}
+
+// histogramWidth is the length of the longest histogram bar.
+const histogramWidth = 40
+
+func shareRow(label string, n, total int) []string {
+	return []string{label, strconv.Itoa(n), formatShare(float64(n) / float64(total))}
+}
+
+// formatLatency renders milliseconds tightly: 0.42ms, 38ms, 1.25s.
+func formatLatency(msec float64) string {
+	switch {
+	case msec < 10:
+		return strconv.FormatFloat(msec, 'f', 2, 64) + "ms"
+	case msec < 1000:
+		return strconv.FormatFloat(msec, 'f', 0, 64) + "ms"
+	default:
+		return strconv.FormatFloat(msec/1000, 'f', 2, 64) + "s"
+	}
+}
+
+// formatBound renders a histogram bound as it was chosen: 0.5ms, 20ms, 2s.
+func formatBound(msec float64) string {
+	if msec < 1000 {
+		return strconv.FormatFloat(msec, 'f', -1, 64) + "ms"
+	}
+	return strconv.FormatFloat(msec/1000, 'f', -1, 64) + "s"
+}
+
+func formatRate(perSecond float64) string {
+	return strconv.FormatFloat(perSecond, 'f', 1, 64)
+}
+
+func formatShare(fraction float64) string {
+	return strconv.FormatFloat(fraction*100, 'f', 2, 64) + "%"
+}
+
+// resultsFile is the JSON document --out writes.
+type resultsFile struct {
+	Files []Report `json:"files"`
+}
+
+// writeResults writes reports to path as JSON.
+func writeResults(path string, reports []Report) error {
+	content, err := json.MarshalIndent(resultsFile{Files: reports}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(path, append(content, '\n'), utils.FilePer, utils.DirPer)
+}
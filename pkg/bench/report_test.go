@@ -0,0 +1,130 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	var samples []sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, sample{latency: time.Duration(i) * time.Millisecond, status: 200})
+	}
+	samples[99].status = 500
+	samples = append(samples,
+		sample{latency: time.Millisecond, err: fmt.Errorf("sending: %w", refused)},
+		sample{latency: time.Second, err: context.DeadlineExceeded},
+	)
+
+	started := time.Date(2026, 1, 2, 3, 4, 5, 6_000_000, time.UTC)
+	r := summarize("get.hk.yaml", options{concurrency: 4}, started, 2*time.Second, samples)
+
+	if r.Requests != 102 || r.Failed != 3 {
+		t.Errorf("Requests = %d, Failed = %d, want 102 and 3", r.Requests, r.Failed)
+	}
+	if r.Throughput != 51 {
+		t.Errorf("Throughput = %g, want 51", r.Throughput)
+	}
+	wantLatency := Latency{Min: 1, Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}
+	if r.Latency == nil || *r.Latency != wantLatency {
+		t.Errorf("Latency = %+v, want %+v", r.Latency, wantLatency)
+	}
+	if want := map[int]int{200: 99, 500: 1}; !reflect.DeepEqual(r.StatusCodes, want) {
+		t.Errorf("StatusCodes = %v, want %v", r.StatusCodes, want)
+	}
+	if want := map[string]int{"connection refused": 1, "timeout": 1}; !reflect.DeepEqual(r.Errors, want) {
+		t.Errorf("Errors = %v, want %v", r.Errors, want)
+	}
+	wantHistogram := []Bucket{{1, 1}, {2, 1}, {5, 3}, {10, 5}, {20, 10}, {50, 30}, {100, 50}}
+	if !reflect.DeepEqual(r.Histogram, wantHistogram) {
+		t.Errorf("Histogram = %v, want %v", r.Histogram, wantHistogram)
+	}
+}
+
+func TestSummarize_NoResponses(t *testing.T) {
+	r := summarize("get.hk.yaml", options{concurrency: 1}, time.Now(), time.Second, []sample{
+		{err: errors.New("no such host")},
+	})
+	if r.Failed != 1 || r.ErrorRate != 1 || r.Latency != nil || r.Histogram != nil {
+		t.Errorf("report = %+v, want one failure and no latencies", r)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	got := histogram([]time.Duration{150 * time.Microsecond, 3 * time.Millisecond, 1500 * time.Millisecond})
+	want := []Bucket{{0.2, 1}, {0.5, 0}, {1, 0}, {2, 0}, {5, 1}, {10, 0}, {20, 0}, {50, 0}, {100, 0}, {200, 0}, {500, 0}, {1000, 0}, {2000, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("histogram() = %v, want %v", got, want)
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	r := summarize("dir/get.hk.yaml", options{concurrency: 2, rate: 100}, time.Now(), time.Second, []sample{
+		{latency: 2 * time.Millisecond, status: 200},
+		{latency: 40 * time.Millisecond, status: 503},
+		{err: context.DeadlineExceeded},
+	})
+	var out bytes.Buffer
+	printReport(&out, &r)
+
+	for _, want := range []string{
+		"get.hk.yaml",
+		"100.0/s, up to 2 concurrent for 1s",
+		"Requests:    3, 3.0/s",
+		"Errors:      2 (66.67%)",
+		"p50 2.00ms, p90 40ms, p99 40ms",
+		"sent 3.0/s of the 100.0/s asked for",
+		"200 OK",
+		"503 Service Unavailable",
+		"timeout",
+		"≤ 50ms",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results", "bench.json")
+	report := summarize("get.hk.yaml", options{concurrency: 1}, time.Now(), time.Second, []sample{
+		{latency: time.Millisecond, status: 200},
+	})
+	if err := writeResults(path, []Report{report}); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Files []struct {
+			File        string         `json:"file"`
+			Requests    int            `json:"requests"`
+			StatusCodes map[string]int `json:"status_codes"`
+			Latency     map[string]any `json:"latency_ms"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, content)
+	}
+	if len(doc.Files) != 1 || doc.Files[0].Requests != 1 || doc.Files[0].StatusCodes["200"] != 1 {
+		t.Errorf("results = %+v", doc)
+	}
+	if doc.Files[0].Latency["p99"] != 1.0 {
+		t.Errorf("latency_ms = %v, want p99 1", doc.Files[0].Latency)
+	}
+}
@@ -260,6 +260,15 @@ func GenerateSecretsMap(envFromFlag string, isCli bool) (map[string]any, error)
 		if isCli {
 			utils.PrintInfoStderr("Environment: " + envFromFlag)
 		}
+		// Set like in env/ mode, for whatever needs the environment's name,
+		// e.g. the OAuth token cache.
+		envName := envFromFlag
+		if envName == "" {
+			envName = utils.DefaultEnvVal
+		}
+		if err := os.Setenv(utils.EnvKey, envName); err != nil {
+			return nil, fmt.Errorf("error setting environment variable: %w", err)
+		}
 		return loadSecretsFromVault(envFromFlag)
 	}
 
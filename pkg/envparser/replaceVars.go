@@ -91,7 +91,7 @@ func replaceVariables(
 	}
 
 	funcMap := template.FuncMap{
-		utils.TemplateFuncGetValueOf: actions.GetValueOf,
+		utils.TemplateFuncGetValueOf: actions.GetValueOfWith(secretsMap),
 		utils.TemplateFuncGetFile:    getFileFor(currentFile),
 		utils.TemplateFuncBasicAuth:  actions.BasicAuth,
 		utils.TemplateFuncOs:         os.Getenv,
@@ -133,6 +133,13 @@ func prepareMap(secretsMap map[string]any, currentFile string) (map[string]any,
 			updatedMap[key] = changedValue
 		case json.Number, bool, int, float64, nil:
 			updatedMap[key] = v
+		case map[string]any:
+			// Run-scoped namespaces such as captured values. They are already
+			// resolved, so they pass through for {{.ns.key}} lookups.
+			updatedMap[key] = v
+		case actions.ResponseSource:
+			// The run's source for getValueOf; see actions.WithResponseSource.
+			updatedMap[key] = v
 		default:
 			return nil, fmt.Errorf("unsupported type for key '%s': %T", key, val)
 		}
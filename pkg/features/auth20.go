@@ -3,14 +3,18 @@ package features
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"net"
 	"net/http"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	apicalls "github.com/xaaha/hulak/pkg/apiCalls"
@@ -22,10 +26,18 @@ const (
 	portNum           = ":2982"
 	timeout           = 60 * time.Second
 	readHeaderTimeout = 10 * time.Second
-	redirectURI       = "http://localhost" + portNum + "/callback"
 	responseType      = utils.ResponseType // for consistency
+	pkceMethod        = "S256"
 )
 
+// callbackAddr is where the callback server listens; the redirect URI is
+// http://localhost:2982/callback. A var so tests can use a free port.
+var callbackAddr = portNum
+
+// openURL opens the authorize page. A var so tests can follow it without
+// a browser.
+var openURL = OpenURL
+
 // OpenURL Opens the url in the brwoser based on the user's OS
 // copied from Github https://gist.github.com/sevkin/9798d67b2cb9d07cb05f89f14ba682f8?permalink_comment_id=5084817#gistcomment-5084817
 func OpenURL(url string) error {
@@ -58,87 +70,191 @@ func OpenURL(url string) error {
 	return exec.Command(cmd, args...).Start()
 }
 
-// Channel to communicate the received code
-var codeChan = make(chan string)
-
-// handle '/callback' to processes the OAuth server
-func callback(w http.ResponseWriter, r *http.Request) {
-	code := r.URL.Query().Get("code")
-	if code != "" {
-		authHTML := filepath.Join("assets", "auth.html")
-		http.ServeFile(w, r, authHTML)
-		// Send the code to the channel and close it
-		codeChan <- code
-		close(codeChan)
-	} else {
-		fmt.Fprint(w, "No 'code' query parameter found.")
+// callbackResult is what the provider sent to /callback: a code, or why
+// the login failed.
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackHandler handles '/callback', where the provider redirects the
+// browser after login. A code is accepted only with the state the
+// authorize URL carried, so a callback forged by another page can't log
+// hulak in to the wrong account. The first result goes to results, which
+// must have room for it.
+func callbackHandler(state string, results chan<- callbackResult) http.HandlerFunc {
+	var once sync.Once
+	deliver := func(res callbackResult) {
+		once.Do(func() { results <- res })
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := query.Get("code")
+		switch {
+		case query.Get("error") != "":
+			reason := query.Get("error")
+			if desc := query.Get("error_description"); desc != "" {
+				reason += ": " + desc
+			}
+			http.Error(w, "Authorization failed: "+reason, http.StatusBadRequest)
+			deliver(callbackResult{err: fmt.Errorf("authorization failed: %s", reason)})
+		case code == "":
+			fmt.Fprint(w, "No 'code' query parameter found.")
+		case subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state)) != 1:
+			http.Error(w, "Invalid 'state' query parameter.", http.StatusBadRequest)
+			deliver(callbackResult{err: errors.New("the callback's state doesn't match this login; its code was not used")})
+		default:
+			authHTML := filepath.Join("assets", "auth.html")
+			http.ServeFile(w, r, authHTML)
+			deliver(callbackResult{code: code})
+		}
 	}
 }
 
-func server() {
-	http.HandleFunc("/callback", callback)
-	server := &http.Server{Addr: portNum, Handler: nil, ReadHeaderTimeout: readHeaderTimeout}
-	err := server.ListenAndServe()
+// startCallbackServer listens on callbackAddr and serves the callback
+// handler until stop is called. It returns the redirect_uri to send.
+func startCallbackServer(state string, results chan<- callbackResult) (string, func(), error) {
+	lis, err := net.Listen("tcp", callbackAddr)
 	if err != nil {
-		log.Fatal(err)
+		return "", nil, fmt.Errorf("starting the callback server on %s: %w", callbackAddr, err)
+	}
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+
+	mux := http.NewServeMux()
+	mux.Handle("/callback", callbackHandler(state, results))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+	go func() { _ = server.Serve(lis) }()
+
+	stop := func() {
+		// Let the page the browser is loading finish.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
 	}
+	return "http://localhost:" + port + "/callback", stop, nil
 }
 
 // openBrowserAndGetCode starts the callback server and opens the browser for OAuth flow
-// Returns the code coming from the ur
-func openBrowserAndGetCode(filePath string, secretsMap map[string]any) (string, error) {
+// Returns the code coming from the ur, and the redirect_uri it was issued for.
+func openBrowserAndGetCode(
+	ctx context.Context,
+	authReqBody *yamlparser.AuthRequestFile,
+	state, challenge string,
+) (string, string, error) {
 	// Create and start the callback server
-	go server()
-	// Prepare the OAuth URL
-	authReqBody, err := yamlparser.FinalStructForOAuth2(filePath, secretsMap)
+	results := make(chan callbackResult, 1)
+	redirectURI, stop, err := startCallbackServer(state, results)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
+	defer stop()
 
 	// required fields for oAuth web flow. This is true github and Okta.
 	// from my testing, extra field does not do any harm, if this is not the case, I'll revisit
 	reqField := make(map[string]string)
 	reqField["response_type"] = responseType
 	reqField["redirect_uri"] = redirectURI
-	authReqBody.URLParams = utils.MergeMaps(authReqBody.URLParams, reqField)
-	urlStr := apicalls.PrepareURL(string(authReqBody.URL), authReqBody.URLParams)
+	reqField["state"] = state
+	if challenge != "" {
+		reqField["code_challenge"] = challenge
+		reqField["code_challenge_method"] = pkceMethod
+	}
+	params := utils.MergeMaps(maps.Clone(authReqBody.URLParams), reqField)
+	urlStr := apicalls.PrepareURL(string(authReqBody.URL), params)
 
 	// Open the browser
 	log.Println("Opening browser for authentication...")
-	if err := OpenURL(urlStr); err != nil {
-		return "", fmt.Errorf("error opening browser: %w", err)
+	if err := openURL(urlStr); err != nil {
+		return "", "", fmt.Errorf("error opening browser: %w", err)
 	}
 	// Wait for the code or a timeout
 	select {
-	case code := <-codeChan:
-		return code, nil
+	case res := <-results:
+		return res.code, redirectURI, res.err
+	case <-ctx.Done():
+		return "", "", fmt.Errorf("waiting for the code: %w", ctx.Err())
 	case <-time.After(timeout):
-		return "", errors.New("timeout waiting for the code")
+		return "", "", errors.New("timeout waiting for the code")
 	}
 }
 
 // SendAPIRequestForAuth2  calls the PrepareStruct using the provided envMap
 // and makes the Api Call with StandardCall and prints the response in console
+//
+// auth.grant_type picks the flow: the browser authorization code flow by
+// default, or client_credentials, password, or device_code, which need no
+// browser. A token endpoint error fails the request without overwriting
+// the saved response, so the last good token stays in place. In a vault
+// project the token is also cached for getValueOf; see TokenSource.
 func SendAPIRequestForAuth2(ctx context.Context, secretsMap map[string]any, filePath string, debug bool) error {
-	code, err := openBrowserAndGetCode(filePath, secretsMap)
+	authReqConfig, err := yamlparser.FinalStructForOAuth2(filePath, secretsMap)
 	if err != nil {
 		return err
 	}
 
-	authReqConfig, err := yamlparser.FinalStructForOAuth2(filePath, secretsMap)
+	resp, err := obtainToken(ctx, &authReqConfig, debug)
 	if err != nil {
 		return err
 	}
+	cacheToken(filePath, &resp, nil)
+	return apicalls.PrintAndSaveFinalResp(&resp, filePath)
+}
 
-	apiInfo, err := authReqConfig.PrepareStruct(code)
+// obtainToken runs the file's grant and returns the token response.
+func obtainToken(
+	ctx context.Context,
+	authReqConfig *yamlparser.AuthRequestFile,
+	debug bool,
+) (apicalls.CustomResponse, error) {
+	switch grant, _ := authReqConfig.Auth.Grant(); grant {
+	case yamlparser.GrantClientCredentials, yamlparser.GrantPassword:
+		return requestToken(ctx, authReqConfig, grant, debug)
+	case yamlparser.GrantDeviceCode:
+		return deviceFlow(ctx, authReqConfig, debug)
+	default:
+		return authorizationCodeFlow(ctx, authReqConfig, debug)
+	}
+}
+
+// authorizationCodeFlow gets a code through the browser and exchanges it
+// at access_token_url. Unless auth.pkce is false, the code is bound to
+// this login with PKCE (RFC 7636): the authorize URL carries the S256
+// challenge and the token request the verifier.
+func authorizationCodeFlow(
+	ctx context.Context,
+	authReqConfig *yamlparser.AuthRequestFile,
+	debug bool,
+) (apicalls.CustomResponse, error) {
+	state := randomToken()
+	var verifier, challenge string
+	if authReqConfig.Auth.UsesPKCE() {
+		verifier = randomToken()
+		challenge = pkceChallenge(verifier)
+	}
+
+	code, redirectURI, err := openBrowserAndGetCode(ctx, authReqConfig, state, challenge)
 	if err != nil {
-		return err
+		return apicalls.CustomResponse{}, err
+	}
+
+	fields := map[string]string{
+		responseType:   code,
+		"grant_type":   string(yamlparser.GrantAuthorizationCode),
+		"redirect_uri": redirectURI,
+	}
+	if verifier != "" {
+		fields["code_verifier"] = verifier
+	}
+	apiInfo, err := authReqConfig.PrepareTokenRequest(fields)
+	if err != nil {
+		return apicalls.CustomResponse{}, err
 	}
 	resp, err := apicalls.StandardCall(ctx, apiInfo, debug)
 	if err != nil {
-		return err
+		return resp, err
 	}
-	return apicalls.PrintAndSaveFinalResp(&resp, filePath)
+	_, err = readToken(&resp)
+	return resp, err
 }
 
 // isWSL checks if the Go program is running inside Windows Subsystem for Linux
@@ -0,0 +1,193 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636, appendix B.
+	got := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("pkceChallenge = %q, want %q", got, want)
+	}
+
+	a, b := randomToken(), randomToken()
+	if len(a) != 43 || a == b {
+		t.Errorf("randomToken = %q, %q; want two different 43 character tokens", a, b)
+	}
+}
+
+func TestCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantCode   string
+		wantErr    string
+	}{
+		{
+			name:     "matching state",
+			query:    "code=abc&state=st-1",
+			wantCode: "abc",
+		},
+		{
+			name:       "wrong state",
+			query:      "code=abc&state=forged",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "the callback's state doesn't match this login; its code was not used",
+		},
+		{
+			name:       "missing state",
+			query:      "code=abc",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "the callback's state doesn't match this login; its code was not used",
+		},
+		{
+			name:       "provider error",
+			query:      "error=access_denied&error_description=user+said+no&state=st-1",
+			wantStatus: http.StatusBadRequest,
+			wantErr:    "authorization failed: access_denied: user said no",
+		},
+		{
+			name:       "no code",
+			query:      "state=st-1",
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results := make(chan callbackResult, 1)
+			rec := httptest.NewRecorder()
+			callbackHandler("st-1", results).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback?"+tc.query, nil))
+
+			if tc.wantStatus != 0 && rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			select {
+			case res := <-results:
+				if tc.wantCode == "" && tc.wantErr == "" {
+					t.Fatalf("unexpected result %+v", res)
+				}
+				if res.code != tc.wantCode {
+					t.Errorf("code = %q, want %q", res.code, tc.wantCode)
+				}
+				if (res.err == nil && tc.wantErr != "") || (res.err != nil && res.err.Error() != tc.wantErr) {
+					t.Errorf("err = %v, want %q", res.err, tc.wantErr)
+				}
+			default:
+				if tc.wantCode != "" || tc.wantErr != "" {
+					t.Fatal("nothing was delivered")
+				}
+			}
+		})
+	}
+}
+
+// followAuthorize stands in for the browser: it records the authorize URL
+// and redirects to its redirect_uri with code, and the URL's state unless
+// state is set.
+func followAuthorize(t *testing.T, state string, seen *url.Values) func(string) error {
+	t.Helper()
+	return func(authorize string) error {
+		u, err := url.Parse(authorize)
+		if err != nil {
+			return err
+		}
+		*seen = u.Query()
+		if state == "" {
+			state = seen.Get("state")
+		}
+		callback := seen.Get("redirect_uri") + "?" + url.Values{"code": {"the-code"}, "state": {state}}.Encode()
+		go func() {
+			resp, err := http.Get(callback)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil
+	}
+}
+
+func TestSendAPIRequestForAuth2_AuthorizationCode(t *testing.T) {
+	callbackAddr = "127.0.0.1:0"
+	t.Cleanup(func() {
+		callbackAddr = portNum
+		openURL = OpenURL
+	})
+
+	tests := []struct {
+		name     string
+		pkce     string
+		state    string
+		wantPKCE bool
+		wantErr  string
+	}{
+		{name: "pkce by default", wantPKCE: true},
+		{name: "pkce off", pkce: "  pkce: false\n"},
+		{
+			name:     "forged state",
+			state:    "forged",
+			wantPKCE: true,
+			wantErr:  "the callback's state doesn't match this login; its code was not used",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var authorize url.Values
+			openURL = followAuthorize(t, tc.state, &authorize)
+			server := newFakeTokenServer(t, accessToken)
+			path, _ := writeAuthFile(t, fmt.Sprintf("kind: Auth\nmethod: POST\nurl: %s/authorize\n"+
+				"urlparams:\n  client_id: cli\n  scope: repo\n"+
+				"auth:\n  type: OAuth2.0\n  access_token_url: %s/token\n%s"+
+				"body:\n  urlencodedformdata:\n    client_id: cli\n", server.url, server.url, tc.pkce))
+
+			err := SendAPIRequestForAuth2(context.Background(), map[string]any{}, path, false)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+				if forms := server.tokenForms(); len(forms) != 0 {
+					t.Errorf("the code was exchanged: %v", forms)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SendAPIRequestForAuth2: %v", err)
+			}
+
+			if authorize.Get("client_id") != "cli" || authorize.Get("response_type") != "code" ||
+				len(authorize.Get("state")) != 43 || !strings.HasSuffix(authorize.Get("redirect_uri"), "/callback") {
+				t.Errorf("authorize params = %v", authorize)
+			}
+			forms := server.tokenForms()
+			if len(forms) != 1 {
+				t.Fatalf("token endpoint called %d times, want 1", len(forms))
+			}
+			form := forms[0]
+			if form["code"] != "the-code" || form["grant_type"] != "authorization_code" ||
+				form["redirect_uri"] != authorize.Get("redirect_uri") {
+				t.Errorf("token form = %v", form)
+			}
+
+			challenge := authorize.Get("code_challenge")
+			if !tc.wantPKCE {
+				if challenge != "" || authorize.Has("code_challenge_method") || form["code_verifier"] != "" {
+					t.Errorf("pkce sent with pkce: false; authorize %v, token %v", authorize, form)
+				}
+				return
+			}
+			if authorize.Get("code_challenge_method") != "S256" {
+				t.Errorf("code_challenge_method = %q", authorize.Get("code_challenge_method"))
+			}
+			if verifier := form["code_verifier"]; verifier == "" || pkceChallenge(verifier) != challenge {
+				t.Errorf("code_verifier %q doesn't match code_challenge %q", verifier, challenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	apicalls "github.com/xaaha/hulak/pkg/apiCalls"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// Token endpoint errors the device code grant keeps polling through,
+// RFC 8628 section 3.5.
+const (
+	errAuthorizationPending = "authorization_pending"
+	errSlowDown             = "slow_down"
+)
+
+// deviceInterval is the unit of the device grant's polling interval. A
+// var so tests can poll in milliseconds.
+var deviceInterval = time.Second
+
+// defaultDevicePoll is the polling interval, in deviceInterval units, when
+// the server doesn't give one; slow_down adds the same again.
+const defaultDevicePoll = 5
+
+// oauthResponse is the part of a token or device authorization response
+// hulak reads.
+type oauthResponse struct {
+	AccessToken      string `json:"access_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// deviceAuthorization is the device authorization response, RFC 8628
+// section 3.2.
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURL         string `json:"verification_url"` // Google's name
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// decodeBody decodes the JSON response body of resp into v.
+func decodeBody(resp *apicalls.CustomResponse, v any) error {
+	if resp.Response == nil {
+		return errors.New("no response")
+	}
+	raw, err := json.Marshal(resp.Response.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// oauthError reports a failed OAuth response: an "error" field, which
+// some servers send with 200, or an error status. code is the "error"
+// field, e.g. authorization_pending.
+func oauthError(resp *apicalls.CustomResponse, step string) (code string, err error) {
+	var body oauthResponse
+	_ = decodeBody(resp, &body)
+	switch {
+	case body.Error != "" && body.ErrorDescription != "":
+		return body.Error, fmt.Errorf("%s failed: %s: %s", step, body.Error, body.ErrorDescription)
+	case body.Error != "":
+		return body.Error, fmt.Errorf("%s failed: %s", step, body.Error)
+	case resp.Response != nil && resp.Response.StatusCode >= 400:
+		return "", fmt.Errorf("%s failed: %s", step, resp.Response.Status)
+	}
+	return "", nil
+}
+
+// readToken checks a token endpoint response and that it has an
+// access_token.
+func readToken(resp *apicalls.CustomResponse) (code string, err error) {
+	if code, err = oauthError(resp, "token request"); err != nil {
+		return code, err
+	}
+	var token oauthResponse
+	if err := decodeBody(resp, &token); err != nil || token.AccessToken == "" {
+		return "", errors.New("token response has no access_token")
+	}
+	return "", nil
+}
+
+// requestToken runs a grant that needs no browser or user code, e.g.
+// client_credentials or password: one call to access_token_url with the
+// body's form fields and grant_type.
+func requestToken(
+	ctx context.Context,
+	config *yamlparser.AuthRequestFile,
+	grant yamlparser.GrantType,
+	debug bool,
+) (apicalls.CustomResponse, error) {
+	apiInfo, err := config.PrepareTokenRequest(map[string]string{"grant_type": string(grant)})
+	if err != nil {
+		return apicalls.CustomResponse{}, err
+	}
+	resp, err := apicalls.StandardCall(ctx, apiInfo, debug)
+	if err != nil {
+		return resp, err
+	}
+	_, err = readToken(&resp)
+	return resp, err
+}
+
+// deviceFlow runs the device authorization grant, RFC 8628: it asks the
+// file's url for a user code, prints where to enter it, and polls
+// access_token_url until the user approves, denies, or the code expires.
+// The file's timeout bounds the wait.
+func deviceFlow(
+	ctx context.Context,
+	config *yamlparser.AuthRequestFile,
+	debug bool,
+) (apicalls.CustomResponse, error) {
+	apiInfo, err := config.PrepareDeviceAuthorization()
+	if err != nil {
+		return apicalls.CustomResponse{}, err
+	}
+	resp, err := apicalls.StandardCall(ctx, apiInfo, false)
+	if err != nil {
+		return resp, err
+	}
+	if _, err := oauthError(&resp, "device authorization"); err != nil {
+		return resp, err
+	}
+	var device deviceAuthorization
+	if err := decodeBody(&resp, &device); err != nil || device.DeviceCode == "" {
+		return resp, errors.New("device authorization response has no device_code")
+	}
+	utils.PrintInfoStderr(device.prompt())
+
+	interval := device.Interval
+	if interval <= 0 {
+		interval = defaultDevicePoll
+	}
+	var expired <-chan time.Time
+	if device.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(device.ExpiresIn) * deviceInterval)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		wait := time.NewTimer(time.Duration(interval) * deviceInterval)
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return apicalls.CustomResponse{}, fmt.Errorf("waiting for device authorization: %w", ctx.Err())
+		case <-expired:
+			wait.Stop()
+			return apicalls.CustomResponse{}, errors.New("the device code expired before it was authorized")
+		case <-wait.C:
+		}
+
+		apiInfo, err := config.PrepareTokenRequest(map[string]string{
+			"grant_type":  string(yamlparser.GrantDeviceCode),
+			"device_code": device.DeviceCode,
+		})
+		if err != nil {
+			return apicalls.CustomResponse{}, err
+		}
+		resp, err := apicalls.StandardCall(ctx, apiInfo, debug)
+		if err != nil {
+			return resp, err
+		}
+		code, err := readToken(&resp)
+		switch code {
+		case errAuthorizationPending:
+			continue
+		case errSlowDown:
+			interval += defaultDevicePoll
+			continue
+		}
+		return resp, err
+	}
+}
+
+// prompt tells the user where to enter the code, e.g. "To sign in, open
+// https://github.com/login/device and enter the code WDJB-MJHT".
+func (d *deviceAuthorization) prompt() string {
+	uri := d.VerificationURI
+	if uri == "" {
+		uri = d.VerificationURL
+	}
+	msg := fmt.Sprintf("To sign in, open %s and enter the code %s", uri, d.UserCode)
+	if d.VerificationURIComplete != "" {
+		msg += fmt.Sprintf("\nor open %s", d.VerificationURIComplete)
+	}
+	return msg
+}
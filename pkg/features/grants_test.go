@@ -0,0 +1,236 @@
+package features
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTokenServer is an OAuth server whose /token endpoint answers with
+// the next of tokenReplies on each call (the last one repeats) and whose
+// /device endpoint starts a device authorization. It records the forms it
+// received.
+type fakeTokenServer struct {
+	url          string
+	tokenReplies []string
+	mu           sync.Mutex
+	forms        []map[string]string
+}
+
+func newFakeTokenServer(t *testing.T, tokenReplies ...string) *fakeTokenServer {
+	t.Helper()
+	f := &fakeTokenServer{tokenReplies: tokenReplies}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		form := map[string]string{"path": r.URL.Path}
+		for k := range r.PostForm {
+			form[k] = r.PostForm.Get(k)
+		}
+		f.mu.Lock()
+		f.forms = append(f.forms, form)
+		calls := 0
+		for _, seen := range f.forms {
+			if seen["path"] == "/token" {
+				calls++
+			}
+		}
+		f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"device_code":      "dev-123",
+				"user_code":        "WDJB-MJHT",
+				"verification_uri": "https://example.com/device",
+				"expires_in":       200,
+				"interval":         1,
+			})
+		case "/token":
+			reply := f.tokenReplies[min(calls, len(f.tokenReplies))-1]
+			if strings.Contains(reply, "invalid_client") {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			_, _ = w.Write([]byte(reply))
+		}
+	}))
+	t.Cleanup(server.Close)
+	f.url = server.URL
+	return f
+}
+
+func (f *fakeTokenServer) tokenForms() []map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []map[string]string
+	for _, form := range f.forms {
+		if form["path"] == "/token" {
+			out = append(out, form)
+		}
+	}
+	return out
+}
+
+// writeAuthFile writes an Auth file into a temp dir and returns its path
+// and the path its response is saved to. The test runs in that dir, outside
+// any project, so no token is cached in a real vault.
+func writeAuthFile(t *testing.T, doc string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "login.hk.yaml")
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, filepath.Join(dir, "login.hk_response.json")
+}
+
+const accessToken = `{"access_token":"tok-1","token_type":"Bearer","expires_in":3600}`
+
+func TestSendAPIRequestForAuth2_DirectGrants(t *testing.T) {
+	tests := []struct {
+		name     string
+		grant    string
+		body     string
+		wantForm map[string]string
+	}{
+		{
+			name:  "client_credentials",
+			grant: "client_credentials",
+			body:  "    client_id: ci\n    client_secret: \"{{.secret}}\"\n    scope: read\n",
+			wantForm: map[string]string{
+				"grant_type": "client_credentials", "client_id": "ci", "client_secret": "s3cret", "scope": "read",
+			},
+		},
+		{
+			name:  "password",
+			grant: "password",
+			body:  "    client_id: ci\n    username: ada\n    password: \"{{.secret}}\"\n",
+			wantForm: map[string]string{
+				"grant_type": "password", "client_id": "ci", "username": "ada", "password": "s3cret",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeTokenServer(t, accessToken)
+			path, saved := writeAuthFile(t, "kind: Auth\nmethod: POST\nauth:\n  type: OAuth2.0\n  grant_type: "+tc.grant+
+				"\n  access_token_url: "+server.url+"/token\nbody:\n  urlencodedformdata:\n"+tc.body)
+
+			err := SendAPIRequestForAuth2(context.Background(), map[string]any{"secret": "s3cret"}, path, false)
+			if err != nil {
+				t.Fatalf("SendAPIRequestForAuth2: %v", err)
+			}
+			forms := server.tokenForms()
+			if len(forms) != 1 {
+				t.Fatalf("token endpoint called %d times, want 1", len(forms))
+			}
+			for k, want := range tc.wantForm {
+				if forms[0][k] != want {
+					t.Errorf("form %s = %q, want %q", k, forms[0][k], want)
+				}
+			}
+			content, err := os.ReadFile(saved)
+			if err != nil || !strings.Contains(string(content), `"access_token": "tok-1"`) {
+				t.Errorf("saved response = %s, %v", content, err)
+			}
+		})
+	}
+}
+
+// TestSendAPIRequestForAuth2_TokenError verifies a rejected token request
+// fails and keeps the previously saved token.
+func TestSendAPIRequestForAuth2_TokenError(t *testing.T) {
+	server := newFakeTokenServer(t, `{"error":"invalid_client","error_description":"bad secret"}`)
+	path, saved := writeAuthFile(t, "kind: Auth\nmethod: POST\nauth:\n  type: OAuth2.0\n  grant_type: client_credentials\n"+
+		"  access_token_url: "+server.url+"/token\nbody:\n  urlencodedformdata:\n    client_id: ci\n")
+	if err := os.WriteFile(saved, []byte(accessToken), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := SendAPIRequestForAuth2(context.Background(), map[string]any{}, path, false)
+	if err == nil || err.Error() != "token request failed: invalid_client: bad secret" {
+		t.Fatalf("err = %v", err)
+	}
+	if content, _ := os.ReadFile(saved); string(content) != accessToken {
+		t.Errorf("the saved token was overwritten with %s", content)
+	}
+}
+
+func TestSendAPIRequestForAuth2_DeviceCode(t *testing.T) {
+	deviceInterval = time.Millisecond
+	t.Cleanup(func() { deviceInterval = time.Second })
+
+	pending := `{"error":"authorization_pending"}`
+	tests := []struct {
+		name      string
+		replies   []string
+		wantPolls int
+		wantErr   string
+	}{
+		{
+			name:      "approved",
+			replies:   []string{pending, `{"error":"slow_down"}`, pending, accessToken},
+			wantPolls: 4,
+		},
+		{
+			name:      "denied",
+			replies:   []string{pending, `{"error":"access_denied"}`},
+			wantPolls: 2,
+			wantErr:   "token request failed: access_denied",
+		},
+		{
+			name:    "expired",
+			replies: []string{pending},
+			wantErr: "the device code expired before it was authorized",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeTokenServer(t, tc.replies...)
+			path, saved := writeAuthFile(t, "kind: Auth\nmethod: POST\nurl: "+server.url+"/device\n"+
+				"urlparams:\n  client_id: cli\n  scope: repo\n"+
+				"auth:\n  type: OAuth2.0\n  grant_type: device_code\n  access_token_url: "+server.url+"/token\n"+
+				"body:\n  urlencodedformdata:\n    client_id: cli\n")
+
+			err := SendAPIRequestForAuth2(context.Background(), map[string]any{}, path, false)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("err = %v, want %q", err, tc.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("SendAPIRequestForAuth2: %v", err)
+			}
+
+			server.mu.Lock()
+			device := server.forms[0]
+			server.mu.Unlock()
+			if device["path"] != "/device" || device["client_id"] != "cli" || device["scope"] != "repo" {
+				t.Errorf("device authorization form = %v", device)
+			}
+			forms := server.tokenForms()
+			if tc.wantPolls > 0 && len(forms) != tc.wantPolls {
+				t.Errorf("token endpoint polled %d times, want %d", len(forms), tc.wantPolls)
+			}
+			for _, form := range forms {
+				if form["grant_type"] != "urn:ietf:params:oauth:grant-type:device_code" || form["device_code"] != "dev-123" {
+					t.Fatalf("token form = %v", form)
+				}
+			}
+			_, statErr := os.Stat(saved)
+			if (statErr == nil) != (tc.wantErr == "") {
+				t.Errorf("response saved = %v, want %v", statErr == nil, tc.wantErr == "")
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package features
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// randomToken returns 32 random bytes, base64url encoded: 43 characters,
+// the shortest PKCE code verifier RFC 7636 allows, and an unguessable
+// state.
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // never fails, per crypto/rand
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// pkceChallenge is the S256 code challenge for verifier:
+// BASE64URL(SHA256(verifier)) without padding.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,237 @@
+package features
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/actions"
+	apicalls "github.com/xaaha/hulak/pkg/apiCalls"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/vault"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// grantRefreshToken is the grant_type of a refresh, RFC 6749 section 6.
+// The response field holding the refresh token has the same name.
+const grantRefreshToken = "refresh_token"
+
+// refreshSkew renews a token this long before it expires, so it doesn't
+// expire on its way to the API.
+const refreshSkew = 30 * time.Second
+
+// TokenSource returns the actions.ResponseSource for a run in a vault
+// project. getValueOf on an Auth-kind file whose token is cached for this
+// environment reads the cached response. An expired token is renewed
+// first: with the refresh_token grant when the response had a
+// refresh_token, otherwise, or when the refresh is rejected, by running
+// the file's own grant again, e.g. the browser flow. A file without a
+// cached token, or a project without a vault, reads _response.json as
+// before.
+//
+// A dry run has no TokenSource, so building a request never signs in or
+// writes a token.
+func TokenSource(secretsMap map[string]any) actions.ResponseSource {
+	return func(requestFile string) (any, time.Time, bool) {
+		if vault.DetectStore() != vault.StoreAge {
+			return nil, time.Time{}, false
+		}
+		if kind, err := yamlparser.PeekKind(requestFile); err != nil || kind != yamlparser.KindAuth {
+			return nil, time.Time{}, false
+		}
+		cache, err := vault.ReadTokenCache()
+		if err != nil {
+			utils.PrintWarningStderr("reading the token cache: " + err.Error())
+			return nil, time.Time{}, false
+		}
+		cached, ok := cache[tokenKey(requestFile)]
+		if !ok {
+			return nil, time.Time{}, false
+		}
+		if !cached.ExpiresBefore(time.Now().Add(refreshSkew)) {
+			return cached.Response, usableUntil(cached.ExpiresAt), true
+		}
+
+		response, err := renewToken(secretsMap, requestFile, cached)
+		if err != nil {
+			utils.PrintErrorStderr(fmt.Sprintf(
+				"renewing the expired token of %s: %v", filepath.Base(requestFile), err,
+			))
+			return nil, time.Time{}, false
+		}
+		return response, usableUntil(tokenExpiry(response)), true
+	}
+}
+
+// usableUntil is when getValueOf stops using a token that expires at
+// expiresAt and renews it: refreshSkew before, or never for a token
+// without an expiry.
+func usableUntil(expiresAt time.Time) time.Time {
+	if expiresAt.IsZero() {
+		return time.Time{}
+	}
+	return expiresAt.Add(-refreshSkew)
+}
+
+// renewToken replaces an expired token, saving the new response next to
+// the file and in the cache.
+func renewToken(
+	secretsMap map[string]any,
+	filePath string,
+	cached vault.CachedToken,
+) (map[string]any, error) {
+	config, err := yamlparser.FinalStructForOAuth2(filePath, secretsMap)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp apicalls.CustomResponse
+	refresh, _ := cached.Response[grantRefreshToken].(string)
+	if refresh != "" {
+		resp, err = withTokenTimeout(filePath, func(ctx context.Context) (apicalls.CustomResponse, error) {
+			return refreshAccessToken(ctx, &config, refresh)
+		})
+		if err != nil {
+			utils.PrintWarningStderr(fmt.Sprintf(
+				"refreshing the token of %s: %v; signing in again", filepath.Base(filePath), err,
+			))
+		}
+	}
+	if refresh == "" || err != nil {
+		resp, err = withTokenTimeout(filePath, func(ctx context.Context) (apicalls.CustomResponse, error) {
+			return obtainToken(ctx, &config, false)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := apicalls.SerializeAndSaveResp(&resp, filePath, ""); err != nil {
+		return nil, err
+	}
+	return cacheToken(filePath, &resp, cached.Response), nil
+}
+
+// refreshAccessToken exchanges a refresh token for a new access token at
+// access_token_url. The body's form fields, e.g. client_id and
+// client_secret, go along to authenticate the client.
+func refreshAccessToken(
+	ctx context.Context,
+	config *yamlparser.AuthRequestFile,
+	refresh string,
+) (apicalls.CustomResponse, error) {
+	apiInfo, err := config.PrepareTokenRequest(map[string]string{
+		"grant_type":      grantRefreshToken,
+		grantRefreshToken: refresh,
+	})
+	if err != nil {
+		return apicalls.CustomResponse{}, err
+	}
+	resp, err := apicalls.StandardCall(ctx, apiInfo, false)
+	if err != nil {
+		return resp, err
+	}
+	_, err = readToken(&resp)
+	return resp, err
+}
+
+// withTokenTimeout runs one token request under the file's timeout, or the
+// same 60s default a run uses.
+func withTokenTimeout(
+	filePath string,
+	fn func(ctx context.Context) (apicalls.CustomResponse, error),
+) (apicalls.CustomResponse, error) {
+	limit := timeout
+	if cfg, err := yamlparser.PeekConfig(filePath); err == nil {
+		if d, _ := cfg.ParsedTimeout(); d > 0 {
+			limit = d
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), limit)
+	defer cancel()
+	return fn(ctx)
+}
+
+// cacheToken caches the token response of an Auth-kind file and returns
+// it as a map. A refresh response without a refresh_token keeps the
+// previous one, which stays valid. The cache is kept only in vault
+// projects, and is added to the project's .gitignore, since it holds the
+// user's own tokens. A failure to write either is a warning, never an
+// error.
+func cacheToken(filePath string, resp *apicalls.CustomResponse, previous map[string]any) map[string]any {
+	var response map[string]any
+	if err := decodeBody(resp, &response); err != nil || response == nil {
+		return nil
+	}
+	if _, ok := response[grantRefreshToken]; !ok && previous[grantRefreshToken] != nil {
+		response[grantRefreshToken] = previous[grantRefreshToken]
+	}
+	if vault.DetectStore() != vault.StoreAge {
+		return response
+	}
+
+	token := vault.CachedToken{Response: response, ExpiresAt: tokenExpiry(response)}
+	key := tokenKey(filePath)
+	err := vault.WithStoreLock(func() error {
+		cache, err := vault.ReadTokenCache()
+		if err != nil {
+			// e.g. encrypted to a rotated key; the cache is rebuilt as
+			// files sign in again.
+			cache = vault.TokenCache{}
+		}
+		cache[key] = token
+		return vault.WriteTokenCache(cache)
+	})
+	if err != nil {
+		utils.PrintWarningStderr("caching the token: " + err.Error())
+		return response
+	}
+	if err := utils.EnsureGitignoreEntry(utils.HiddenProjectName + "/" + vault.TokenCacheFile); err != nil {
+		utils.PrintWarningStderr(fmt.Sprintf("could not update .gitignore: %v", err))
+	}
+	return response
+}
+
+// tokenExpiry is when the token of a response that just arrived expires,
+// from its expires_in; zero when it doesn't say.
+func tokenExpiry(response map[string]any) time.Time {
+	seconds := expiresIn(response["expires_in"])
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(seconds) * time.Second)
+}
+
+// expiresIn reads expires_in, which some servers send as a string.
+func expiresIn(v any) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case string:
+		seconds, _ := strconv.ParseInt(n, 10, 64)
+		return seconds
+	}
+	return 0
+}
+
+// tokenKey returns the cache key of an Auth-kind file in the current
+// environment, with the file relative to the project root.
+func tokenKey(filePath string) string {
+	env := os.Getenv(utils.EnvKey)
+	if env == "" {
+		env = utils.DefaultEnvVal
+	}
+	file := filePath
+	if abs, err := filepath.Abs(filePath); err == nil {
+		file = abs
+		if root, ok := utils.FindProjectRoot(); ok {
+			if rel, err := filepath.Rel(root, abs); err == nil {
+				file = rel
+			}
+		}
+	}
+	return vault.TokenKey(env, file)
+}
@@ -0,0 +1,204 @@
+package features
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/vault"
+)
+
+// vaultProject makes a temp dir a vault project, with the master key as
+// its identity, and changes into it.
+func vaultProject(t *testing.T) string {
+	t.Helper()
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	t.Setenv(utils.EnvKey, "staging")
+	id, _ := age.GenerateX25519Identity()
+	t.Setenv(utils.MasterKey, id.String())
+	if err := os.Mkdir(utils.HiddenProjectName, utils.DirPer); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.EnsureRecipientsFile(id.Recipient().String(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.WriteStore(&vault.Store{Envs: map[string]vault.Env{"staging": {}}}, id.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// clientCredentialsFile writes login.hk.yaml for server into dir.
+func clientCredentialsFile(t *testing.T, dir string, server *fakeTokenServer) string {
+	t.Helper()
+	path := filepath.Join(dir, "login.hk.yaml")
+	doc := "kind: Auth\nmethod: POST\nauth:\n  type: OAuth2.0\n  grant_type: client_credentials\n" +
+		"  access_token_url: " + server.url + "/token\nbody:\n  urlencodedformdata:\n    client_id: ci\n"
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSendAPIRequestForAuth2_CachesToken(t *testing.T) {
+	dir := vaultProject(t)
+	server := newFakeTokenServer(t, `{"access_token":"tok-1","refresh_token":"ref-1","expires_in":3600}`)
+	path := clientCredentialsFile(t, dir, server)
+
+	if err := SendAPIRequestForAuth2(context.Background(), map[string]any{}, path, false); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := vault.ReadTokenCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, ok := cache["staging:login.hk.yaml"]
+	if !ok {
+		t.Fatalf("token not cached under staging:login.hk.yaml: %v", cache)
+	}
+	if token.Response["access_token"] != "tok-1" || token.Response["refresh_token"] != "ref-1" {
+		t.Errorf("cached response = %v", token.Response)
+	}
+	if left := time.Until(token.ExpiresAt); left < 59*time.Minute || left > time.Hour {
+		t.Errorf("ExpiresAt is %v away, want about an hour", left)
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	const fresh = `{"access_token":"tok-2","expires_in":3600}`
+	tests := []struct {
+		name        string
+		cached      *vault.CachedToken
+		replies     []string
+		wantToken   any
+		wantGrants  []string
+		wantRefresh any
+	}{
+		{
+			name:      "valid token is read from the cache",
+			cached:    &vault.CachedToken{Response: map[string]any{"access_token": "tok-1"}, ExpiresAt: time.Now().Add(time.Hour)},
+			replies:   []string{fresh},
+			wantToken: "tok-1",
+		},
+		{
+			name: "expired token is refreshed",
+			cached: &vault.CachedToken{
+				Response:  map[string]any{"access_token": "tok-1", "refresh_token": "ref-1"},
+				ExpiresAt: time.Now().Add(10 * time.Second), // within refreshSkew
+			},
+			replies:     []string{fresh},
+			wantToken:   "tok-2",
+			wantGrants:  []string{"refresh_token"},
+			wantRefresh: "ref-1",
+		},
+		{
+			name: "rejected refresh runs the grant",
+			cached: &vault.CachedToken{
+				Response:  map[string]any{"access_token": "tok-1", "refresh_token": "ref-1"},
+				ExpiresAt: time.Now().Add(-time.Hour),
+			},
+			replies:     []string{`{"error":"invalid_grant"}`, fresh},
+			wantToken:   "tok-2",
+			wantGrants:  []string{"refresh_token", "client_credentials"},
+			wantRefresh: "ref-1",
+		},
+		{
+			name:       "expired token without refresh_token runs the grant",
+			cached:     &vault.CachedToken{Response: map[string]any{"access_token": "tok-1"}, ExpiresAt: time.Now().Add(-time.Hour)},
+			replies:    []string{fresh},
+			wantToken:  "tok-2",
+			wantGrants: []string{"client_credentials"},
+		},
+		{
+			name:    "no cached token reads the saved response",
+			replies: []string{fresh},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := vaultProject(t)
+			server := newFakeTokenServer(t, tc.replies...)
+			path := clientCredentialsFile(t, dir, server)
+			if tc.cached != nil {
+				if err := vault.WriteTokenCache(vault.TokenCache{"staging:login.hk.yaml": *tc.cached}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, until, ok := TokenSource(map[string]any{})(path)
+			if ok != (tc.wantToken != nil) {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantToken != nil)
+			}
+			if !ok {
+				return
+			}
+			response := got.(map[string]any)
+			if response["access_token"] != tc.wantToken {
+				t.Errorf("access_token = %v, want %v", response["access_token"], tc.wantToken)
+			}
+			// Both tokens last an hour; getValueOf renews refreshSkew early.
+			if until.IsZero() || until.After(time.Now().Add(time.Hour-refreshSkew)) {
+				t.Errorf("until = %v, want refreshSkew before the token expires", until)
+			}
+
+			forms := server.tokenForms()
+			if len(forms) != len(tc.wantGrants) {
+				t.Fatalf("token endpoint called %d times, want %d", len(forms), len(tc.wantGrants))
+			}
+			for i, grant := range tc.wantGrants {
+				if forms[i]["grant_type"] != grant {
+					t.Errorf("call %d grant_type = %q, want %q", i, forms[i]["grant_type"], grant)
+				}
+			}
+			if len(forms) > 0 && forms[0]["grant_type"] == "refresh_token" && forms[0]["refresh_token"] != "ref-1" {
+				t.Errorf("refresh form = %v", forms[0])
+			}
+			if len(tc.wantGrants) == 0 {
+				return
+			}
+
+			cache, err := vault.ReadTokenCache()
+			if err != nil {
+				t.Fatal(err)
+			}
+			renewed := cache["staging:login.hk.yaml"]
+			if renewed.Response["access_token"] != "tok-2" || renewed.Response["refresh_token"] != tc.wantRefresh {
+				t.Errorf("cached response = %v", renewed.Response)
+			}
+			if renewed.ExpiresBefore(time.Now().Add(time.Minute)) {
+				t.Errorf("renewed token expires at %v", renewed.ExpiresAt)
+			}
+			ignored, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+			if !strings.Contains(string(ignored), ".hulak/tokens.age") {
+				t.Errorf(".gitignore = %q, want the token cache ignored", ignored)
+			}
+			saved, _ := os.ReadFile(filepath.Join(dir, "login.hk"+utils.ResponseFileName))
+			if !strings.Contains(string(saved), "tok-2") {
+				t.Errorf("_response.json = %s, want the renewed token", saved)
+			}
+		})
+	}
+}
+
+func TestTokenSource_WithoutVault(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Mkdir(utils.EnvironmentFolder, utils.DirPer); err != nil {
+		t.Fatal(err)
+	}
+	server := newFakeTokenServer(t, accessToken)
+	path := clientCredentialsFile(t, dir, server)
+	if _, _, ok := TokenSource(map[string]any{})(path); ok {
+		t.Error("an env/ project has no token cache")
+	}
+}
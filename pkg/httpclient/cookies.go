@@ -0,0 +1,125 @@
+package httpclient
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Jar is a cookie jar that can be saved and loaded again, so a session
+// cookie from one run is sent by the next. Matching cookies to requests is
+// left to net/http/cookiejar; Jar keeps the Set-Cookie values it was given
+// alongside, since cookiejar can't list its cookies. It is safe for
+// concurrent use.
+type Jar struct {
+	jar *cookiejar.Jar
+
+	mu    sync.Mutex
+	saved map[cookieID]SavedCookie
+}
+
+// SavedCookie is a cookie as Jar saves it: the Set-Cookie value and the
+// URL of the response that set it.
+type SavedCookie struct {
+	URL       string `json:"url"`
+	SetCookie string `json:"set_cookie"`
+}
+
+// cookieID identifies a cookie the way the jar replaces them: by domain,
+// path, and name. host is only set for host-only cookies.
+type cookieID struct {
+	host, domain, path, name string
+}
+
+// NewJar returns an empty Jar.
+func NewJar() *Jar {
+	jar, _ := cookiejar.New(nil) // only errors on options it isn't given
+	return &Jar{jar: jar, saved: map[cookieID]SavedCookie{}}
+}
+
+// SetCookies stores the cookies of a response from u. It implements
+// http.CookieJar.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		id := cookieID{domain: strings.ToLower(strings.TrimPrefix(c.Domain, ".")), path: c.Path, name: c.Name}
+		if id.domain == "" {
+			id.host = strings.ToLower(u.Hostname())
+		}
+		if id.path == "" || id.path[0] != '/' {
+			id.path = defaultCookiePath(u.Path)
+		}
+		if c.MaxAge < 0 || (c.MaxAge == 0 && !c.Expires.IsZero() && !c.Expires.After(now)) {
+			delete(j.saved, id)
+			continue
+		}
+		// Max-Age counts from now; save the time it ends instead, so the
+		// cookie expires when it would have once loaded again.
+		keep := *c
+		if keep.MaxAge > 0 {
+			keep.Expires = now.Add(time.Duration(keep.MaxAge) * time.Second)
+			keep.MaxAge = 0
+		}
+		j.saved[id] = SavedCookie{URL: origin, SetCookie: keep.String()}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u. It implements
+// http.CookieJar.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Saved returns the jar's cookies to save, sorted so the same cookies
+// always save the same way. Session cookies are included: the session a
+// saved jar keeps is the point of saving it.
+func (j *Jar) Saved() []SavedCookie {
+	j.mu.Lock()
+	saved := make([]SavedCookie, 0, len(j.saved))
+	for _, c := range j.saved {
+		saved = append(saved, c)
+	}
+	j.mu.Unlock()
+	slices.SortFunc(saved, func(a, b SavedCookie) int {
+		return cmp.Or(cmp.Compare(a.URL, b.URL), cmp.Compare(a.SetCookie, b.SetCookie))
+	})
+	return saved
+}
+
+// Load stores saved cookies as if their responses had just arrived.
+// Cookies that have expired since are dropped.
+func (j *Jar) Load(saved []SavedCookie) error {
+	for _, s := range saved {
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			return fmt.Errorf("invalid saved cookie url %q: %w", s.URL, err)
+		}
+		c, err := http.ParseSetCookie(s.SetCookie)
+		if err != nil {
+			return fmt.Errorf("invalid saved cookie for %s: %w", s.URL, err)
+		}
+		j.SetCookies(u, []*http.Cookie{c})
+	}
+	return nil
+}
+
+// defaultCookiePath is the path of a cookie set without one: the
+// directory of the request path (RFC 6265 section 5.1.4).
+func defaultCookiePath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	return path.Dir(requestPath)
+}
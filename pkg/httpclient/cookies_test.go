@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJar_SaveAndLoad(t *testing.T) {
+	login, _ := url.Parse("https://app.example.test/account/login")
+	api, _ := url.Parse("https://app.example.test/account/orders")
+	other, _ := url.Parse("https://api.example.test/")
+
+	jar := NewJar()
+	jar.SetCookies(login, []*http.Cookie{
+		{Name: "session", Value: "s1"},
+		{Name: "theme", Value: "dark", Domain: "example.test", Path: "/", MaxAge: 3600},
+		{Name: "old", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+	// A later response replaces the cookie with the same name and path.
+	jar.SetCookies(api, []*http.Cookie{{Name: "session", Value: "s2"}})
+
+	saved := jar.Saved()
+	if len(saved) != 2 {
+		t.Fatalf("Saved() = %+v, want the session and theme cookies", saved)
+	}
+	for _, s := range saved {
+		if strings.Contains(s.SetCookie, "Max-Age") {
+			t.Errorf("saved %q with Max-Age, want the Expires it ends at", s.SetCookie)
+		}
+	}
+
+	loaded := NewJar()
+	if err := loaded.Load(saved); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		u    *url.URL
+		want string
+	}{
+		{api, "session=s2; theme=dark"},
+		{other, "theme=dark"},
+	}
+	for _, tc := range tests {
+		if got := cookieHeader(loaded.Cookies(tc.u)); got != tc.want {
+			t.Errorf("Cookies(%s) = %q, want %q", tc.u, got, tc.want)
+		}
+	}
+
+	// Max-Age=-1 deletes the cookie from both the jar and what it saves.
+	loaded.SetCookies(api, []*http.Cookie{{Name: "session", MaxAge: -1}})
+	if got := cookieHeader(loaded.Cookies(api)); got != "theme=dark" {
+		t.Errorf("Cookies after delete = %q", got)
+	}
+	if saved := loaded.Saved(); len(saved) != 1 {
+		t.Errorf("Saved() after delete = %+v", saved)
+	}
+}
+
+func TestJar_LoadDropsExpired(t *testing.T) {
+	u, _ := url.Parse("http://app.example.test/")
+	jar := NewJar()
+	err := jar.Load([]SavedCookie{{
+		URL:       u.String(),
+		SetCookie: "session=s1; Expires=Mon, 02 Jan 2006 15:04:05 GMT",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jar.Cookies(u); len(got) != 0 || len(jar.Saved()) != 0 {
+		t.Errorf("an expired cookie was loaded: %v", got)
+	}
+
+	if err := jar.Load([]SavedCookie{{URL: u.String(), SetCookie: ";;"}}); err == nil {
+		t.Error("Load() accepted an invalid Set-Cookie value")
+	}
+}
+
+func cookieHeader(cookies []*http.Cookie) string {
+	parts := make([]string, len(cookies))
+	for i, c := range cookies {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, "; ")
+}
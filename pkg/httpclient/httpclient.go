@@ -5,7 +5,7 @@
 package httpclient
 
 import (
-	"fmt"
+	"crypto/tls"
 	"io"
 	"net/http"
 )
@@ -24,7 +24,8 @@ type Client struct {
 
 // New returns a Client with sensible defaults:
 //   - No client-level timeout (use context for per-request deadlines)
-//   - Redirects follow Go default (10), but HTTPS → HTTP downgrades are blocked
+//   - Redirects follow Go default (10), but HTTPS → HTTP downgrades are blocked;
+//     WithRedirects sets another policy for a request
 //
 // Callers control timeout via context.WithTimeout on each request.
 func New() *Client {
@@ -35,6 +36,49 @@ func New() *Client {
 	}
 }
 
+// Options customize a Client beyond New's defaults. The zero value is New.
+type Options struct {
+	// TLS sets the transport's TLS settings, e.g. a client certificate or
+	// extra CA bundles. Nil uses the defaults.
+	TLS *tls.Config
+	// Proxy routes requests through a proxy. Nil uses the HTTP_PROXY,
+	// HTTPS_PROXY, and NO_PROXY environment variables.
+	Proxy *Proxy
+	// Jar sends and stores cookies. Nil sends only the cookies a request
+	// sets itself.
+	Jar *Jar
+	// MaxIdleConnsPerHost is how many idle connections to keep open per
+	// host for reuse. Zero keeps the default, 2, which is too few for more
+	// requests than that in flight at once, e.g. a bench.
+	MaxIdleConnsPerHost int
+}
+
+// NewWithOptions is New with the settings in opts.
+func NewWithOptions(opts Options) *Client {
+	c := New()
+	if opts.Jar != nil {
+		c.HTTP.Jar = opts.Jar
+	}
+	if opts.TLS == nil && opts.Proxy == nil && opts.MaxIdleConnsPerHost == 0 {
+		return c
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.TLS != nil {
+		// The transport adds HTTP/2 to its config's NextProtos, and opts.TLS
+		// is shared by every file with the same tls settings.
+		transport.TLSClientConfig = opts.TLS.Clone()
+	}
+	if opts.Proxy != nil {
+		transport.Proxy = opts.Proxy.proxyFunc()
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		transport.MaxIdleConns = max(transport.MaxIdleConns, opts.MaxIdleConnsPerHost)
+	}
+	c.HTTP.Transport = transport
+	return c
+}
+
 // Do executes an HTTP request. Shorthand for c.HTTP.Do(req).
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return c.HTTP.Do(req) //nolint:gosec // G704: hulak is a CLI — URLs are operator-provided, not untrusted
@@ -50,16 +94,3 @@ func ReadBody(resp *http.Response, maxBytes int64) ([]byte, error) {
 	}
 	return io.ReadAll(reader)
 }
-
-// safeRedirectPolicy refuses HTTPS → HTTP downgrades.
-// Redirect count is left to Go's default (10). Callers needing stricter
-// limits (e.g. key fetcher) should enforce it at their own level.
-func safeRedirectPolicy(req *http.Request, via []*http.Request) error {
-	if len(via) >= 10 {
-		return fmt.Errorf("too many redirects")
-	}
-	if req.URL.Scheme == "http" && len(via) > 0 && via[0].URL.Scheme == "https" {
-		return fmt.Errorf("refusing redirect from HTTPS to HTTP")
-	}
-	return nil
-}
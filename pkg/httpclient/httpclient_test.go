@@ -2,6 +2,8 @@ package httpclient
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -163,3 +165,31 @@ func (tc *trackingCloser) Close() error {
 	tc.onClose()
 	return nil
 }
+
+// TestNewWithOptions_TLSUnchanged verifies a request leaves opts.TLS as it
+// was, since files with the same tls settings share it.
+func TestNewWithOptions_TLSUnchanged(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	c := NewWithOptions(Options{TLS: tlsConfig})
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("proto = %s, want HTTP/2", resp.Proto)
+	}
+	if len(tlsConfig.NextProtos) != 0 {
+		t.Errorf("NextProtos = %q, want the config left as is", tlsConfig.NextProtos)
+	}
+}
@@ -0,0 +1,198 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// Proxy routes requests through an HTTP, HTTPS, or SOCKS5 proxy.
+//
+// Requests to localhost and loopback addresses never go through a proxy.
+type Proxy struct {
+	// URL is the proxy, e.g. http://proxy.corp:3128 or
+	// socks5://localhost:1080. Its user info, if any, authenticates with
+	// the proxy. Nil uses the proxy in HTTP_PROXY and HTTPS_PROXY.
+	URL *url.URL
+	// NoProxy lists hosts reached directly, in NO_PROXY syntax: a domain
+	// matches itself and its subdomains, ".domain" only its subdomains,
+	// an IP address or CIDR range the addresses in it, and "*" every host.
+	// Any of them may end in ":port". With a nil URL, these add to the
+	// hosts in NO_PROXY.
+	NoProxy []string
+}
+
+// ProxySchemes are the proxy URL schemes a Proxy supports.
+var ProxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// ParseProxyURL parses a proxy URL and checks its scheme and host.
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		// url.Error repeats the URL, password and all; report its cause.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	if !slices.Contains(ProxySchemes, u.Scheme) {
+		return nil, fmt.Errorf("invalid proxy url %s: use http, https, socks5, or socks5h", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %s: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// proxyFunc returns the transport's Proxy function for p.
+func (p *Proxy) proxyFunc() func(*http.Request) (*url.URL, error) {
+	proxyForURL := p.proxyForURL()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyForURL(req.URL)
+	}
+}
+
+// proxyForURL returns the proxy to use for a URL, nil for none.
+func (p *Proxy) proxyForURL() func(*url.URL) (*url.URL, error) {
+	cfg := httpproxy.FromEnvironment()
+	noProxy := p.NoProxy
+	if p.URL != nil {
+		cfg.HTTPProxy = p.URL.String()
+		cfg.HTTPSProxy = cfg.HTTPProxy
+	} else if cfg.NoProxy != "" {
+		noProxy = append([]string{cfg.NoProxy}, noProxy...)
+	}
+	cfg.NoProxy = strings.Join(noProxy, ",")
+	return cfg.ProxyFunc()
+}
+
+// DialContext opens a TCP connection to addr, a host:port, through the
+// proxy a request to it would use: tunneled with CONNECT through an HTTP or
+// HTTPS proxy, or through a SOCKS5 one. secure picks HTTPS_PROXY over
+// HTTP_PROXY when p has no URL. A nil p uses the environment variables,
+// like a nil Options.Proxy. Connections that don't speak HTTP, such as
+// WebSocket and gRPC ones, dial with it to honor the same proxy.
+func (p *Proxy) DialContext(ctx context.Context, addr string, secure bool) (net.Conn, error) {
+	if p == nil {
+		p = &Proxy{}
+	}
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	proxyURL, err := p.proxyForURL()(&url.URL{Scheme: scheme, Host: addr})
+	if err != nil {
+		return nil, err
+	}
+	var dialer net.Dialer
+	if proxyURL == nil {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(proxyURL, &dialer)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("socks5 proxy %s: %w", proxyURL.Redacted(), err)
+		}
+		return conn, nil
+	default:
+		return dialConnect(ctx, &dialer, proxyURL, addr)
+	}
+}
+
+// dialConnect opens a tunnel to addr through the HTTP or HTTPS proxy at
+// proxyURL with a CONNECT request.
+func dialConnect(ctx context.Context, dialer *net.Dialer, proxyURL *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Redacted(), err)
+	}
+	if proxyURL.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{
+			ServerName: proxyURL.Hostname(),
+			MinVersion: tls.VersionTLS12,
+		})
+	}
+
+	// The handshake ends with the context, like the dial before it.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u := proxyURL.User; u != nil {
+		password, _ := u.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	tunnel, err := connect(conn, req)
+	if err != nil {
+		_ = conn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("proxy %s: %w", proxyURL.Redacted(), err)
+	}
+	return tunnel, nil
+}
+
+// connect sends req, a CONNECT request, on conn and returns the tunnel once
+// the proxy accepts it.
+func connect(conn net.Conn, req *http.Request) (net.Conn, error) {
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", req.Host, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// The server spoke first; keep what was read past the response.
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a net.Conn whose first bytes were already read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
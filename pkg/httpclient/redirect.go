@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// DefaultMaxRedirects is how many redirects a request follows by default.
+const DefaultMaxRedirects = 10
+
+var (
+	errTooManyRedirects = errors.New("too many redirects")
+	errDowngrade        = errors.New("refusing redirect from HTTPS to HTTP")
+)
+
+// RedirectPolicy says which redirects a request follows.
+type RedirectPolicy struct {
+	// Max is how many redirects to follow. The next redirect response is
+	// returned as the response instead of followed. Zero follows none.
+	Max int
+	// AllowDowngrade follows redirects from HTTPS to HTTP, which are
+	// refused otherwise.
+	AllowDowngrade bool
+}
+
+// RedirectHop is one redirect a request followed: the URL that answered
+// with it, the redirect's status, and where it led.
+type RedirectHop struct {
+	URL      string `json:"url"`
+	Status   string `json:"status"`
+	Location string `json:"location"`
+}
+
+// Redirects follows the redirects of a request by its policy, and records
+// the ones it follows. See WithRedirects.
+type Redirects struct {
+	policy *RedirectPolicy
+	hops   []RedirectHop
+}
+
+type redirectsKey struct{}
+
+// WithRedirects returns a context for a request that follows redirects by
+// policy, and the Redirects recording them. A nil policy keeps the
+// default: follow up to DefaultMaxRedirects, fail after that, and never
+// from HTTPS to HTTP. Only clients from New read the context.
+func WithRedirects(ctx context.Context, policy *RedirectPolicy) (context.Context, *Redirects) {
+	r := &Redirects{policy: policy}
+	return context.WithValue(ctx, redirectsKey{}, r), r
+}
+
+// Hops returns the redirects followed, in order. Read it once the
+// response has arrived.
+func (r *Redirects) Hops() []RedirectHop {
+	return r.hops
+}
+
+// check is the CheckRedirect of a request made with WithRedirects.
+func (r *Redirects) check(req *http.Request, via []*http.Request) error {
+	if r.policy == nil {
+		if err := defaultRedirectCheck(req, via); err != nil {
+			return err
+		}
+	} else {
+		if len(via) > r.policy.Max {
+			return http.ErrUseLastResponse
+		}
+		if !r.policy.AllowDowngrade && isDowngrade(req, via) {
+			return errDowngrade
+		}
+	}
+
+	hop := RedirectHop{URL: via[len(via)-1].URL.Redacted(), Location: req.URL.Redacted()}
+	if req.Response != nil {
+		hop.Status = req.Response.Status
+	}
+	r.hops = append(r.hops, hop)
+	return nil
+}
+
+// safeRedirectPolicy follows the policy of a request made with
+// WithRedirects, and otherwise the default: up to DefaultMaxRedirects, and
+// no HTTPS → HTTP downgrades. Callers needing stricter limits (e.g. key
+// fetcher) should enforce it at their own level.
+func safeRedirectPolicy(req *http.Request, via []*http.Request) error {
+	if r, ok := req.Context().Value(redirectsKey{}).(*Redirects); ok {
+		return r.check(req, via)
+	}
+	return defaultRedirectCheck(req, via)
+}
+
+func defaultRedirectCheck(req *http.Request, via []*http.Request) error {
+	if len(via) >= DefaultMaxRedirects {
+		return errTooManyRedirects
+	}
+	if isDowngrade(req, via) {
+		return errDowngrade
+	}
+	return nil
+}
+
+// isDowngrade reports whether req goes to HTTP after the request chain
+// started on HTTPS.
+func isDowngrade(req *http.Request, via []*http.Request) bool {
+	return req.URL.Scheme == "http" && len(via) > 0 && via[0].URL.Scheme == "https"
+}
@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithRedirects(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusMovedPermanently)
+		default:
+			_, _ = w.Write([]byte("final"))
+		}
+	}))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/c", http.StatusFound)
+	}))
+	defer secure.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(secure.Certificate())
+	client := NewWithOptions(Options{TLS: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}})
+
+	tests := []struct {
+		name       string
+		url        string
+		policy     *RedirectPolicy
+		wantStatus int
+		wantHops   []string
+		wantErr    string
+	}{
+		{
+			name:       "default follows every redirect",
+			url:        plain.URL + "/a",
+			wantStatus: http.StatusOK,
+			wantHops:   []string{"302 Found /a -> /b", "301 Moved Permanently /b -> /c"},
+		},
+		{
+			name:       "none returns the first redirect",
+			url:        plain.URL + "/a",
+			policy:     &RedirectPolicy{},
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:       "max stops after that many",
+			url:        plain.URL + "/a",
+			policy:     &RedirectPolicy{Max: 1},
+			wantStatus: http.StatusMovedPermanently,
+			wantHops:   []string{"302 Found /a -> /b"},
+		},
+		{
+			name:    "default refuses a downgrade",
+			url:     secure.URL + "/login",
+			wantErr: "refusing redirect from HTTPS to HTTP",
+		},
+		{
+			name:    "a policy refuses a downgrade too",
+			url:     secure.URL + "/login",
+			policy:  &RedirectPolicy{Max: DefaultMaxRedirects},
+			wantErr: "refusing redirect from HTTPS to HTTP",
+		},
+		{
+			name:       "allowed downgrade",
+			url:        secure.URL + "/login",
+			policy:     &RedirectPolicy{Max: DefaultMaxRedirects, AllowDowngrade: true},
+			wantStatus: http.StatusOK,
+			wantHops:   []string{"302 Found /login -> /c"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, redirects := WithRedirects(context.Background(), tc.policy)
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, tc.url, nil)
+			resp, err := client.Do(req)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Do() error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+
+			var hops []string
+			for _, hop := range redirects.Hops() {
+				hops = append(hops, hop.Status+" "+urlPath(hop.URL)+" -> "+urlPath(hop.Location))
+			}
+			if strings.Join(hops, "\n") != strings.Join(tc.wantHops, "\n") {
+				t.Errorf("hops = %q, want %q", hops, tc.wantHops)
+			}
+		})
+	}
+}
+
+func urlPath(raw string) string {
+	return mustParseURL(raw).Path
+}
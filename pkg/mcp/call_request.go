@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
@@ -73,7 +74,14 @@ func (s *Server) handleCallRequest(
 		}
 		callCtx, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
-		respBytes, st, err := apicalls.SendAndSaveAPIRequest(callCtx, apicalls.RequestOptions{
+		send := apicalls.SendAndSaveAPIRequest
+		switch kind, _ := yamlparser.PeekKind(m.Path); kind {
+		case yamlparser.KindWebSocket:
+			send = apicalls.SendWebSocket
+		case yamlparser.KindGRPC:
+			send = apicalls.SendGRPC
+		}
+		result, err := send(callCtx, apicalls.RequestOptions{
 			Secrets: secrets,
 			Path:    m.Path,
 			// Agents default to no-save so they don't litter the repo with
@@ -84,7 +92,11 @@ func (s *Server) handleCallRequest(
 		if err != nil {
 			return err
 		}
-		body, status = string(respBytes), st
+		body, status = string(result.Body), result.Status
+		if result.Summary != "" && !utf8.Valid(result.Body) {
+			// Binary bytes don't survive a JSON string; describe them instead.
+			body = result.Summary
+		}
 		return nil
 	})
 	if err != nil {
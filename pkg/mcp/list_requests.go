@@ -103,9 +103,9 @@ func listProjectRequests(project, root string) ([]RequestSummary, error) {
 	return out, nil
 }
 
-// requestKind returns the file's kind (API/GraphQL/Auth), best-effort: "" when
-// it can't be read. PeekKind reads only the kind field, so template vars and
-// getFile references do not block the listing.
+// requestKind returns the file's kind (API/GraphQL/Auth/WebSocket/gRPC),
+// best-effort: "" when it can't be read. PeekKind reads only the kind field,
+// so template vars and getFile references do not block the listing.
 func requestKind(path string) string {
 	k, err := yamlparser.PeekKind(path)
 	if err != nil {
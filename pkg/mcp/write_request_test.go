@@ -126,6 +126,202 @@ func TestHandleWriteRequest_SchemaValidation(t *testing.T) {
 		}
 	})
 
+	t.Run("rejects missing method", func(t *testing.T) {
+		s := newServer(t)
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: "url: http://x\n"}); err == nil {
+			t.Error("missing method should be rejected by schema")
+		}
+	})
+
+	t.Run("accepts websocket request without method", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: WebSocket\nurl: ws://localhost/echo\nmessages:\n  - send: hi\n  - expect:\n      body: [hi]\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "ws", YamlContent: content}); err != nil {
+			t.Errorf("valid websocket request rejected: %v", err)
+		}
+	})
+
+	t.Run("rejects websocket step doing two things", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: WebSocket\nurl: ws://localhost/echo\nmessages:\n  - send: hi\n    json: {a: 1}\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+			t.Error("a step with both send and json should be rejected by schema")
+		}
+	})
+
+	t.Run("accepts grpc request with a full method name", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: gRPC\nurl: localhost:50051\nplaintext: true\nmethod: users.v1.Users/GetUser\nprotos: [\"*.proto\"]\nmessage:\n  userId: u-7\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "grpc", YamlContent: content}); err != nil {
+			t.Errorf("valid grpc request rejected: %v", err)
+		}
+	})
+
+	t.Run("rejects grpc request with an http method", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: gRPC\nurl: localhost:50051\nmethod: GET\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+			t.Error("a grpc request needs package.Service/Method")
+		}
+	})
+
+	t.Run("rejects full method name outside grpc", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: API\nurl: http://x\nmethod: users.v1.Users/GetUser\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+			t.Error("an API request needs an HTTP method")
+		}
+	})
+
+	t.Run("accepts client_credentials auth without url", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: Auth\nmethod: POST\nauth:\n  type: OAuth2.0\n  grant_type: client_credentials\n" +
+			"  access_token_url: https://auth.example.com/token\nbody:\n  urlencodedformdata:\n    client_id: ci\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "m2m", YamlContent: content}); err != nil {
+			t.Errorf("valid client_credentials file rejected: %v", err)
+		}
+	})
+
+	t.Run("rejects device_code auth without url", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: Auth\nmethod: POST\nauth:\n  type: OAuth2.0\n  grant_type: device_code\n" +
+			"  access_token_url: https://auth.example.com/token\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+			t.Error("the device_code grant needs its device authorization url")
+		}
+	})
+
+	t.Run("accepts auth with pkce turned off", func(t *testing.T) {
+		s := newServer(t)
+		content := "kind: Auth\nmethod: POST\nurl: https://auth.example.com/authorize\nauth:\n  type: OAuth2.0\n  pkce: false\n" +
+			"  access_token_url: https://auth.example.com/token\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "login", YamlContent: content}); err != nil {
+			t.Errorf("auth file with pkce: false rejected: %v", err)
+		}
+	})
+
+	t.Run("accepts request auth on an API request", func(t *testing.T) {
+		s := newServer(t)
+		for name, auth := range map[string]string{
+			"use":    "  use: login\n",
+			"bearer": "  type: bearer\n  token: '{{.apiToken}}'\n",
+			"apikey": "  type: apikey\n  key: k\n  name: api_key\n  in: query\n",
+			"sigv4":  "  type: sigv4\n  access_key: a\n  secret_key: s\n  region: us-east-1\n  service: execute-api\n",
+			"hmac":   "  type: hmac\n  key: k\n  string_to_sign: \"{method}\\n{path}\"\n  encoding: base64\n",
+			"digest": "  type: digest\n  username: u\n  password: p\n",
+		} {
+			content := "method: GET\nurl: https://api.example.com\nauth:\n" + auth
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: name, YamlContent: content}); err != nil {
+				t.Errorf("%s auth rejected: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("rejects invalid request auth", func(t *testing.T) {
+		s := newServer(t)
+		for name, auth := range map[string]string{
+			"unknown type": "  type: ntlm\n",
+			"use and type": "  use: login\n  type: bearer\n  token: t\n",
+			"oauth fields": "  type: OAuth2.0\n  access_token_url: https://auth.example.com/token\n",
+		} {
+			content := "method: GET\nurl: https://api.example.com\nauth:\n" + auth
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+				t.Errorf("%s should be rejected by schema", name)
+			}
+		}
+	})
+
+	t.Run("accepts tls settings", func(t *testing.T) {
+		s := newServer(t)
+		content := "method: GET\nurl: https://api.internal\ntls:\n  cert: certs/client.pem\n  key: '{{.clientKey}}'\n" +
+			"  ca: [\"*.pem\", certs/ca.pem]\n  server_name: api.internal\n  min_version: 1.3\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "mtls", YamlContent: content}); err != nil {
+			t.Errorf("valid tls section rejected: %v", err)
+		}
+	})
+
+	t.Run("rejects invalid tls settings", func(t *testing.T) {
+		s := newServer(t)
+		for name, tls := range map[string]string{
+			"cert without key": "  cert: certs/client.pem\n",
+			"unknown version":  "  min_version: \"1.4\"\n",
+			"unknown field":    "  insecure: true\n",
+		} {
+			content := "method: GET\nurl: https://api.internal\ntls:\n" + tls
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+				t.Errorf("%s should be rejected by schema", name)
+			}
+		}
+	})
+
+	t.Run("accepts proxy settings", func(t *testing.T) {
+		s := newServer(t)
+		for name, content := range map[string]string{
+			"url":       "method: GET\nurl: https://api.example.com\nproxy: socks5://localhost:1080\n",
+			"section":   "method: GET\nurl: https://api.example.com\nproxy:\n  url: http://proxy.corp:3128\n  username: '{{.proxyUser}}'\n  password: '{{.proxyPassword}}'\n  no_proxy: [localhost, .internal]\n",
+			"websocket": "kind: WebSocket\nurl: wss://chat.example.com\nproxy: http://proxy.corp:3128\n",
+			"grpc":      "kind: gRPC\nurl: api.example.com:443\nmethod: pkg.Service/Method\nproxy:\n  url: socks5://localhost:1080\n",
+		} {
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: name, YamlContent: content}); err != nil {
+				t.Errorf("%s proxy rejected: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("rejects invalid proxy settings", func(t *testing.T) {
+		s := newServer(t)
+		for name, proxy := range map[string]string{
+			"unsupported scheme":      " ftp://proxy.corp\n",
+			"credentials without url": "\n  username: u\n",
+		} {
+			content := "method: GET\nurl: https://api.example.com\nproxy:" + proxy
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+				t.Errorf("%s should be rejected by schema", name)
+			}
+		}
+	})
+
+	t.Run("accepts redirect settings", func(t *testing.T) {
+		s := newServer(t)
+		for name, redirects := range map[string]string{
+			"follow":  " follow\n",
+			"none":    " none\n",
+			"max":     " 3\n",
+			"section": "\n  max: 2\n  allow_downgrade: true\n",
+		} {
+			content := "method: GET\nurl: https://api.example.com\nredirects:" + redirects
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: name, YamlContent: content}); err != nil {
+				t.Errorf("%s redirects rejected: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("rejects invalid redirect settings", func(t *testing.T) {
+		s := newServer(t)
+		for name, redirects := range map[string]string{
+			"unknown shorthand": " always\n",
+			"negative max":      "\n  max: -1\n",
+			"unknown key":       "\n  limit: 2\n",
+		} {
+			content := "method: GET\nurl: https://api.example.com\nredirects:" + redirects
+			if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+				t.Errorf("%s should be rejected by schema", name)
+			}
+		}
+	})
+
+	t.Run("cookies must be a boolean", func(t *testing.T) {
+		s := newServer(t)
+		content := "method: GET\nurl: https://api.example.com\ncookies: true\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "cookies", YamlContent: content}); err != nil {
+			t.Errorf("cookies: true rejected: %v", err)
+		}
+		content = "method: GET\nurl: https://api.example.com\ncookies: session\n"
+		if _, _, err := s.handleWriteRequest(ctx, nil, writeRequestInput{Name: "bad", YamlContent: content}); err == nil {
+			t.Error("cookies: session should be rejected by schema")
+		}
+	})
+
 	t.Run("rejects invalid method", func(t *testing.T) {
 		s := newServer(t)
 		content := "method: FETCH\nurl: http://x\n"
@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/vault"
+)
+
+// cookieJarFile is the saved form of a --cookie-jar file.
+type cookieJarFile struct {
+	Cookies []httpclient.SavedCookie `json:"cookies"`
+}
+
+// loadCookieJar returns a jar holding the cookies saved at path. A missing
+// file gives an empty jar; it is created when the run ends. In a vault
+// project the file is encrypted to the store's recipients.
+func loadCookieJar(path string) (*httpclient.Jar, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if vault.DetectStore() == vault.StoreAge {
+		content, err = vault.ReadSealedFile(path)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	jar := httpclient.NewJar()
+	if os.IsNotExist(err) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie jar %s: %w", path, err)
+	}
+
+	var saved cookieJarFile
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return nil, fmt.Errorf("reading cookie jar %s: %w", path, err)
+	}
+	if err := jar.Load(saved.Cookies); err != nil {
+		return nil, fmt.Errorf("reading cookie jar %s: %w", path, err)
+	}
+	return jar, nil
+}
+
+// saveCookieJar writes the cookies in jar to path, the way loadCookieJar
+// reads them. Cookies are credentials, so a plain file is only readable
+// by the owner.
+func saveCookieJar(path string, jar *httpclient.Jar) error {
+	content, err := json.MarshalIndent(cookieJarFile{Cookies: jar.Saved()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("writing cookie jar %s: %w", path, err)
+	}
+	if vault.DetectStore() == vault.StoreAge {
+		err = vault.WriteSealedFile(path, content)
+	} else {
+		err = utils.AtomicWriteFile(path, content, utils.SecretPer, utils.DirPer)
+	}
+	if err != nil {
+		return fmt.Errorf("writing cookie jar %s: %w", path, err)
+	}
+	return nil
+}
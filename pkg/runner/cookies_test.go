@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/vault"
+)
+
+func TestProcessFilesSequentially_Cookies(t *testing.T) {
+	var gotCookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		} else {
+			gotCookie = r.Header.Get("Cookie")
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name      string
+		setting   string
+		cookieJar string
+		want      string
+	}{
+		{name: "cookies true", setting: "cookies: true\n", want: "session=s1"},
+		{name: "no setting"},
+		{name: "--cookie-jar", cookieJar: "cookies.json", want: "session=s1"},
+		{name: "cookies false wins over --cookie-jar", setting: "cookies: false\n", cookieJar: "cookies.json"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			gotCookie = ""
+			dir := t.TempDir()
+			login := filepath.Join(dir, "1-login.hk.yaml")
+			me := filepath.Join(dir, "2-me.hk.yaml")
+			files := map[string]string{
+				login: fmt.Sprintf("method: POST\nurl: %q\n%s", server.URL+"/login", tc.setting),
+				me:    fmt.Sprintf("method: GET\nurl: %q\n%s", server.URL+"/me", tc.setting),
+			}
+			for path, doc := range files {
+				if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			opts := runOptions{CookieJar: tc.cookieJar, jar: httpclient.NewJar()}
+			outcomes := processFilesSequentially(
+				[]string{login, me}, map[string]any{}, opts, true, 5*time.Second,
+			)
+			for _, o := range outcomes {
+				if !o.ok {
+					t.Fatalf("%s failed: %v", o.path, o.err)
+				}
+			}
+			if gotCookie != tc.want {
+				t.Errorf("Cookie = %q, want %q", gotCookie, tc.want)
+			}
+		})
+	}
+}
+
+func TestCookieJarFile(t *testing.T) {
+	u, _ := url.Parse("https://app.example.test/login")
+	roundTrip := func(t *testing.T, path string) {
+		t.Helper()
+		jar, err := loadCookieJar(path)
+		if err != nil {
+			t.Fatalf("loadCookieJar() on a missing file: %v", err)
+		}
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "s1"}})
+		if err := saveCookieJar(path, jar); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := loadCookieJar(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := loaded.Cookies(u); len(got) != 1 || got[0].Value != "s1" {
+			t.Errorf("loaded cookies = %v", got)
+		}
+	}
+
+	t.Run("plain file", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		path := filepath.Join("state", "cookies.json")
+		roundTrip(t, path)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != utils.SecretPer {
+			t.Errorf("cookie jar mode = %o, want %o", perm, utils.SecretPer)
+		}
+
+		if err := os.WriteFile(path, []byte("not json"), utils.SecretPer); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadCookieJar(path); err == nil {
+			t.Error("loadCookieJar() accepted a broken file")
+		}
+	})
+
+	t.Run("vault project", func(t *testing.T) {
+		root, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Mkdir(filepath.Join(root, utils.HiddenProjectName), utils.DirPer); err != nil {
+			t.Fatal(err)
+		}
+		t.Chdir(root)
+		id, _ := age.GenerateX25519Identity()
+		t.Setenv(utils.MasterKey, id.String())
+		if err := vault.EnsureRecipientsFile(id.Recipient().String(), "test"); err != nil {
+			t.Fatal(err)
+		}
+		if err := vault.WriteStoreToRecipients(&vault.Store{}); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(root, utils.HiddenProjectName, "cookies.age")
+		roundTrip(t, path)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := vault.DecryptText(raw, id); err != nil {
+			t.Errorf("the cookie jar is not encrypted to the recipients: %v", err)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// runFile runs one request file: once, or once per dataset row when the
+// file has a `data:` key or the run has --data. Rows run in order, each with
+// its columns merged over the secrets map so they resolve as {{.column}}.
+// Returns one outcome per run, tagged with its 1-based row.
+//
+// A dataset that can't be read fails the file without sending anything.
+func runFile(
+	path string,
+	secretsMap map[string]any,
+	opts runOptions,
+	baseTimeout time.Duration,
+) []outcome {
+	dataPath, err := datasetPath(path, opts.Data)
+	if err == nil && dataPath == "" {
+		return []outcome{processTask(path, secretsMap, opts, baseTimeout)}
+	}
+	var rows []map[string]any
+	if err == nil {
+		rows, err = loadDataset(dataPath)
+	}
+	if err != nil {
+		return []outcome{{path: path, err: fmt.Errorf("data: %w", err)}}
+	}
+
+	outcomes := make([]outcome, 0, len(rows))
+	for i, row := range rows {
+		secrets := utils.CopyEnvMap(secretsMap)
+		maps.Copy(secrets, row)
+		rowOpts := opts
+		rowOpts.Row = i + 1
+		o := processTask(path, secrets, rowOpts, baseTimeout)
+		o.row = i + 1
+		outcomes = append(outcomes, o)
+	}
+	return outcomes
+}
+
+// datasetPath returns the data file a request file iterates over, or ""
+// when it runs once. The file's `data:` key wins over --data (flagData).
+// The key resolves like getFile, see utils.ResolveRequestFile. --data is a
+// plain CLI path.
+//
+// A file that can't be peeked runs once; processTask reports the real
+// parse error.
+func datasetPath(requestPath, flagData string) (string, error) {
+	cfg, err := yamlparser.PeekConfig(requestPath)
+	if err != nil || cfg.Data == "" {
+		return flagData, nil
+	}
+	return utils.ResolveRequestFile(requestPath, cfg.Data)
+}
+
+// loadDataset reads the rows of a .csv or .json data file.
+//
+// CSV files need a header row; each later record becomes one row keyed by
+// the header names, with string values. JSON files hold an array of objects;
+// numbers keep their literal form (json.Number) so ids and amounts render
+// exactly as written.
+func loadDataset(path string) ([]map[string]any, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		rows, err = parseCSVRows(content)
+	case utils.JSON:
+		rows, err = parseJSONRows(content)
+	default:
+		return nil, fmt.Errorf("%s: unsupported data file type %q (use .csv or .json)", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s has no rows", path)
+	}
+	for _, row := range rows {
+		if _, ok := row[utils.CapturedVarsKey]; ok {
+			return nil, fmt.Errorf("%s: %q is reserved for captured values", path, utils.CapturedVarsKey)
+		}
+	}
+	return rows, nil
+}
+
+// parseCSVRows maps every record after the header to its column names.
+// Records with a different field count than the header are an error.
+func parseCSVRows(content []byte) ([]map[string]any, error) {
+	content = bytes.TrimPrefix(content, []byte("\ufeff")) // Excel's UTF-8 BOM
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("header column %d is empty", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("header column %q appears more than once", name)
+		}
+		seen[name] = true
+		header[i] = name
+	}
+
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			row[name] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseJSONRows decodes an array of objects. Values may be strings,
+// numbers, booleans, null, or nested objects (read as {{.col.key}}).
+func parseJSONRows(content []byte) ([]map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	var items []any
+	if err := dec.Decode(&items); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of objects: %w", err)
+	}
+
+	rows := make([]map[string]any, 0, len(items))
+	for i, item := range items {
+		row, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("item %d is not an object", i+1)
+		}
+		// Template values can't be lists; say so here rather than failing
+		// every request with an unsupported-type error.
+		for key, val := range row {
+			if _, isList := val.([]any); isList {
+				return nil, fmt.Errorf("item %d: %q is an array; use a string, number, boolean, or object", i+1, key)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,234 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// chdirProject makes dir a hulak project and the working directory for the
+// rest of the test.
+func chdirProject(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.Mkdir(filepath.Join(dir, utils.EnvironmentFolder), utils.DirPer); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+}
+
+func TestLoadDataset(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []map[string]any
+		wantErr string
+	}{
+		{
+			name:    "csv with header",
+			file:    "users.csv",
+			content: "id, name\n1,Ada\n2,\"Grace, H\"\n",
+			want: []map[string]any{
+				{"id": "1", "name": "Ada"},
+				{"id": "2", "name": "Grace, H"},
+			},
+		},
+		{
+			name:    "csv with byte order mark",
+			file:    "users.csv",
+			content: "\ufeffid\n7\n",
+			want:    []map[string]any{{"id": "7"}},
+		},
+		{
+			name:    "json array of objects",
+			file:    "users.json",
+			content: `[{"id": 1, "name": "Ada", "admin": true, "meta": {"team": "core"}}]`,
+			want: []map[string]any{{
+				"id": json.Number("1"), "name": "Ada", "admin": true,
+				"meta": map[string]any{"team": "core"},
+			}},
+		},
+		{
+			name:    "csv header only",
+			file:    "users.csv",
+			content: "id,name\n",
+			wantErr: "has no rows",
+		},
+		{
+			name:    "csv ragged row",
+			file:    "users.csv",
+			content: "id,name\n1\n",
+			wantErr: "wrong number of fields",
+		},
+		{
+			name:    "csv duplicate column",
+			file:    "users.csv",
+			content: "id,id\n1,2\n",
+			wantErr: "appears more than once",
+		},
+		{
+			name:    "json not an array",
+			file:    "users.json",
+			content: `{"id": 1}`,
+			wantErr: "expected a JSON array of objects",
+		},
+		{
+			name:    "json array value",
+			file:    "users.json",
+			content: `[{"ids": [1, 2]}]`,
+			wantErr: `"ids" is an array`,
+		},
+		{
+			name:    "reserved column",
+			file:    "users.csv",
+			content: "captured\nx\n",
+			wantErr: "reserved for captured values",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "users.txt",
+			content: "id\n1\n",
+			wantErr: "unsupported data file type",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.file)
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadDataset(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("rows = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRunFile_IteratesRows verifies a file runs once per dataset row, with
+// each row's columns as template variables and its own response file.
+func TestRunFile_IteratesRows(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/users/bad" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.Path + `"}`))
+	}))
+	defer server.Close()
+
+	request := "method: GET\nurl: \"{{.baseUrl}}/users/{{.id}}\"\n" +
+		"assert:\n  status: 200\n"
+
+	t.Run("data key next to the file", func(t *testing.T) {
+		paths = nil
+		files := writeRequests(t, [][2]string{
+			{"users.hk.yaml", request + "data: \"*.csv\"\n"},
+			{"users.csv", "id\n1\nbad\n3\n"},
+		})
+		chdirProject(t, filepath.Dir(files[0]))
+		secrets := map[string]any{"baseUrl": server.URL}
+
+		outcomes := runFile(files[0], secrets, runOptions{}, 5*time.Second)
+		if len(outcomes) != 3 {
+			t.Fatalf("got %d outcomes, want 3", len(outcomes))
+		}
+		if want := []string{"/users/1", "/users/bad", "/users/3"}; !slices.Equal(paths, want) {
+			t.Errorf("requests = %v, want %v", paths, want)
+		}
+		for i, o := range outcomes {
+			if o.row != i+1 {
+				t.Errorf("outcome %d row = %d, want %d", i, o.row, i+1)
+			}
+			if wantOK := i != 1; o.ok != wantOK {
+				t.Errorf("%s ok = %v, want %v (err %v)", o.name(), o.ok, wantOK, o.err)
+			}
+		}
+		if got := outcomes[1].name(); got != "users.hk.yaml#2" {
+			t.Errorf("name = %q, want users.hk.yaml#2", got)
+		}
+
+		dir := filepath.Dir(files[0])
+		for _, row := range []string{"1", "2", "3"} {
+			name := filepath.Join(dir, "users.hk_response_"+row+".json")
+			if _, err := os.Stat(name); err != nil {
+				t.Errorf("expected response file %s: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("data flag", func(t *testing.T) {
+		paths = nil
+		files := writeRequests(t, [][2]string{
+			{"users.hk.yaml", request},
+			{"users.json", `[{"id": 42}]`},
+		})
+		secrets := map[string]any{"baseUrl": server.URL}
+
+		outcomes := runFile(files[0], secrets, runOptions{Data: files[1]}, 5*time.Second)
+		if len(outcomes) != 1 || !outcomes[0].ok {
+			t.Fatalf("outcomes = %+v, want one ok outcome", outcomes)
+		}
+		if !slices.Equal(paths, []string{"/users/42"}) {
+			t.Errorf("requests = %v, want [/users/42]", paths)
+		}
+	})
+
+	t.Run("data key outside the project", func(t *testing.T) {
+		paths = nil
+		files := writeRequests(t, [][2]string{
+			{"users.hk.yaml", request + "data: \"*.csv\"\n"},
+			{"users.csv", "id\n1\n"},
+		})
+		chdirProject(t, t.TempDir())
+
+		outcomes := runFile(files[0], map[string]any{"baseUrl": server.URL}, runOptions{}, 5*time.Second)
+		if len(outcomes) != 1 || outcomes[0].ok || !strings.Contains(outcomes[0].err.Error(), "outside the project root") {
+			t.Fatalf("outcomes = %+v, want one error for a data file outside the project", outcomes)
+		}
+		if len(paths) != 0 {
+			t.Errorf("requests = %v, want none", paths)
+		}
+	})
+
+	t.Run("unreadable dataset fails the file", func(t *testing.T) {
+		paths = nil
+		files := writeRequests(t, [][2]string{{"users.hk.yaml", request + "data: \"*.csv\"\n"}})
+		chdirProject(t, filepath.Dir(files[0]))
+
+		outcomes := runFile(files[0], map[string]any{"baseUrl": server.URL}, runOptions{}, 5*time.Second)
+		if len(outcomes) != 1 || outcomes[0].ok || !strings.HasPrefix(outcomes[0].err.Error(), "data:") {
+			t.Fatalf("outcomes = %+v, want one data error", outcomes)
+		}
+		if len(paths) != 0 {
+			t.Errorf("requests = %v, want none", paths)
+		}
+	})
+}
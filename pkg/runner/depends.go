@@ -0,0 +1,253 @@
+package runner
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// graphNode is one request file in a depends_on run.
+type graphNode struct {
+	path       string
+	deps       []int // indexes of the files this one waits for
+	dependents []int // indexes of the files waiting for this one
+	// err is a planning error (unknown name, cycle). The file fails without
+	// being sent and its dependents are skipped.
+	err error
+}
+
+// planGraph resolves each file's depends_on names against the other files in
+// the run. hasDeps is false when no file declares depends_on, so the caller
+// can keep the plain worker pool.
+//
+// Names match by request stem (utils.RequestStem), so "login",
+// "login.hk.yaml", and "LOGIN.yml" all refer to login.hk.yaml. A file that
+// can't be peeked gets no edges; processTask reports the real parse error
+// when it runs.
+func planGraph(paths []string) (nodes []graphNode, hasDeps bool) {
+	nodes = make([]graphNode, len(paths))
+	byStem := make(map[string][]int, len(paths))
+	for i, path := range paths {
+		nodes[i].path = path
+		stem := utils.RequestStem(path)
+		byStem[stem] = append(byStem[stem], i)
+	}
+
+	for i := range nodes {
+		cfg, err := yamlparser.PeekConfig(nodes[i].path)
+		if err != nil || len(cfg.DependsOn) == 0 {
+			continue
+		}
+		hasDeps = true
+		seen := make(map[int]bool, len(cfg.DependsOn))
+		for _, name := range cfg.DependsOn {
+			matches := byStem[utils.RequestStem(name)]
+			switch {
+			case len(matches) == 0:
+				nodes[i].err = fmt.Errorf("depends_on: no file named %q in this run", name)
+			case len(matches) > 1:
+				nodes[i].err = fmt.Errorf(
+					"depends_on: %q matches more than one file: %s",
+					name, joinBases(nodes, matches, ", "),
+				)
+			case matches[0] == i:
+				nodes[i].err = fmt.Errorf("depends_on: %q is this file", name)
+			case !seen[matches[0]]:
+				seen[matches[0]] = true
+				nodes[i].deps = append(nodes[i].deps, matches[0])
+				nodes[matches[0]].dependents = append(nodes[matches[0]].dependents, i)
+			}
+			if nodes[i].err != nil {
+				break
+			}
+		}
+	}
+
+	markCycles(nodes)
+	return nodes, hasDeps
+}
+
+// markCycles sets err on every file that sits on a dependency cycle. Files
+// that merely depend on a cycle keep err nil; they are skipped at run time
+// like any other dependent of a failed file.
+func markCycles(nodes []graphNode) {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(nodes))
+	var stack []int
+
+	var visit func(i int)
+	visit = func(i int) {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range nodes[i].deps {
+			switch state[d] {
+			case unvisited:
+				visit(d)
+			case visiting:
+				start := len(stack) - 1
+				for stack[start] != d {
+					start--
+				}
+				cycle := append(append([]int{}, stack[start:]...), d)
+				err := fmt.Errorf("depends_on: dependency cycle: %s", joinBases(nodes, cycle, " → "))
+				for _, c := range stack[start:] {
+					if nodes[c].err == nil {
+						nodes[c].err = err
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = done
+	}
+
+	for i := range nodes {
+		if state[i] == unvisited {
+			visit(i)
+		}
+	}
+}
+
+// joinBases renders the base names of the given nodes joined by sep.
+func joinBases(nodes []graphNode, idx []int, sep string) string {
+	names := make([]string, len(idx))
+	for i, n := range idx {
+		names[i] = filepath.Base(nodes[n].path)
+	}
+	return strings.Join(names, sep)
+}
+
+// runGraph runs the files planned by planGraph. A file starts as soon as
+// every file it depends on has succeeded, so independent files still run
+// concurrently (bounded by the same worker count as runTasks). When a
+// prerequisite fails, its dependents — direct and transitive — are skipped
+// and reported as failed. A data-driven file counts as failed when any of
+// its rows does. Returns one outcome per file (per dataset row for
+// data-driven files) in the order they finished.
+//
+// Values captured by a file are passed to its dependents as
+// {{.captured.<name>}}, together with everything its own prerequisites
+// captured. Files with no path between them don't see each other's values.
+func runGraph(
+	nodes []graphNode,
+	secretsMap map[string]any,
+	opts runOptions,
+	baseTimeout time.Duration,
+) []outcome {
+	type finished struct {
+		idx      int
+		outcomes []outcome // one per dataset row; a single one otherwise
+	}
+
+	var (
+		outcomes  = make([]outcome, 0, len(nodes))
+		waiting   = make([]int, len(nodes))
+		settled   = make([]bool, len(nodes))
+		failed    = make([]bool, len(nodes))
+		inherited = make([]map[string]any, len(nodes))
+		exported  = make([]map[string]any, len(nodes))
+		results   = make(chan finished, len(nodes))
+		workers   = make(chan struct{}, utils.GetWorkers(nil))
+		running   = 0
+		readyList []int
+	)
+	for i := range nodes {
+		waiting[i] = len(nodes[i].deps)
+	}
+
+	settle := func(i int, results ...outcome) {
+		settled[i] = true
+		failed[i] = slices.ContainsFunc(results, func(o outcome) bool { return !o.ok })
+		outcomes = append(outcomes, results...)
+		for _, d := range nodes[i].dependents {
+			waiting[d]--
+			if waiting[d] == 0 && !settled[d] {
+				readyList = append(readyList, d)
+			}
+		}
+	}
+
+	start := func(i int) {
+		for _, d := range nodes[i].deps {
+			if failed[d] {
+				o := outcome{
+					path:    nodes[i].path,
+					skipped: true,
+					err: fmt.Errorf(
+						"skipped: depends on %s, which failed", filepath.Base(nodes[d].path),
+					),
+				}
+				printOutcome(&o)
+				settle(i, o)
+				return
+			}
+		}
+
+		captured := make(map[string]any)
+		for _, d := range nodes[i].deps {
+			maps.Copy(captured, exported[d])
+		}
+		inherited[i] = captured
+		secrets := utils.CopyEnvMap(secretsMap)
+		secrets[utils.CapturedVarsKey] = maps.Clone(captured)
+
+		running++
+		go func() {
+			workers <- struct{}{}
+			defer func() { <-workers }()
+			results <- finished{i, runFile(nodes[i].path, secrets, opts, baseTimeout)}
+		}()
+	}
+
+	for i := range nodes {
+		if waiting[i] == 0 && nodes[i].err == nil {
+			readyList = append(readyList, i)
+		}
+	}
+	// Planning errors fail up front so their dependents are released (and
+	// skipped) before the first request goes out.
+	for i := range nodes {
+		if nodes[i].err != nil {
+			o := outcome{path: nodes[i].path, err: nodes[i].err}
+			printOutcome(&o)
+			settle(i, o)
+		}
+	}
+
+	for {
+		for len(readyList) > 0 {
+			next := readyList[0]
+			readyList = readyList[1:]
+			if !settled[next] {
+				start(next)
+			}
+		}
+		if running == 0 {
+			break
+		}
+		r := <-results
+		running--
+		// A file's own capture wins over one with the same name inherited
+		// from its prerequisites; across dataset rows, the last row wins.
+		exported[r.idx] = maps.Clone(inherited[r.idx])
+		for i := range r.outcomes {
+			printResponse(&r.outcomes[i])
+			if !r.outcomes[i].ok {
+				printOutcome(&r.outcomes[i])
+			}
+			maps.Copy(exported[r.idx], r.outcomes[i].captured)
+		}
+		settle(r.idx, r.outcomes...)
+	}
+	return outcomes
+}
@@ -0,0 +1,233 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeRequests writes name → YAML request files into a temp dir and returns
+// their paths in the order given.
+func writeRequests(t *testing.T, files [][2]string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(dir, f[0])
+		if err := os.WriteFile(path, []byte(f[1]), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestPlanGraph(t *testing.T) {
+	const get = "method: GET\nurl: https://example.com\n"
+
+	tests := []struct {
+		name    string
+		files   [][2]string
+		hasDeps bool
+		// wantErr maps a file name to a substring of its planning error.
+		// Files not listed must plan cleanly.
+		wantErr  map[string]string
+		wantDeps map[string][]string
+	}{
+		{
+			name:    "no depends_on",
+			files:   [][2]string{{"a.hk.yaml", get}, {"b.hk.yaml", get}},
+			hasDeps: false,
+		},
+		{
+			name: "resolves by stem, any extension or case",
+			files: [][2]string{
+				{"login.hk.yaml", get},
+				{"user.yml", get},
+				{"me.hk.yaml", get + "depends_on: [LOGIN, user.yml, login]\n"},
+			},
+			hasDeps:  true,
+			wantDeps: map[string][]string{"me.hk.yaml": {"login.hk.yaml", "user.yml"}},
+		},
+		{
+			name:    "unknown name",
+			files:   [][2]string{{"a.hk.yaml", get + "depends_on: [nope]\n"}},
+			hasDeps: true,
+			wantErr: map[string]string{"a.hk.yaml": `no file named "nope"`},
+		},
+		{
+			name: "ambiguous name",
+			files: [][2]string{
+				{"login.hk.yaml", get},
+				{"login.yml", get},
+				{"a.hk.yaml", get + "depends_on: [login]\n"},
+			},
+			hasDeps: true,
+			wantErr: map[string]string{"a.hk.yaml": "matches more than one file"},
+		},
+		{
+			name:    "self dependency",
+			files:   [][2]string{{"a.hk.yaml", get + "depends_on: [a]\n"}},
+			hasDeps: true,
+			wantErr: map[string]string{"a.hk.yaml": "is this file"},
+		},
+		{
+			name: "cycle marks only its members",
+			files: [][2]string{
+				{"a.hk.yaml", get + "depends_on: [b]\n"},
+				{"b.hk.yaml", get + "depends_on: [a]\n"},
+				{"c.hk.yaml", get + "depends_on: [a]\n"},
+			},
+			hasDeps: true,
+			wantErr: map[string]string{
+				"a.hk.yaml": "dependency cycle",
+				"b.hk.yaml": "dependency cycle",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes, hasDeps := planGraph(writeRequests(t, tc.files))
+			if hasDeps != tc.hasDeps {
+				t.Errorf("hasDeps = %v, want %v", hasDeps, tc.hasDeps)
+			}
+			for _, n := range nodes {
+				base := filepath.Base(n.path)
+				want, ok := tc.wantErr[base]
+				switch {
+				case !ok && n.err != nil:
+					t.Errorf("%s: unexpected error %v", base, n.err)
+				case ok && (n.err == nil || !strings.Contains(n.err.Error(), want)):
+					t.Errorf("%s: err = %v, want it to contain %q", base, n.err, want)
+				}
+				if wantDeps, ok := tc.wantDeps[base]; ok {
+					got := make([]string, len(n.deps))
+					for i, d := range n.deps {
+						got[i] = filepath.Base(nodes[d].path)
+					}
+					if strings.Join(got, ",") != strings.Join(wantDeps, ",") {
+						t.Errorf("%s: deps = %v, want %v", base, got, wantDeps)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRunGraph_OrderAndCaptures verifies dependents wait for their
+// prerequisite and receive its captured values.
+func TestRunGraph_OrderAndCaptures(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+		auth  = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		order = append(order, r.URL.Path)
+		auth[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		if r.URL.Path == "/login" {
+			// Slow enough that a dependent started early would be seen
+			// before the login request lands.
+			time.Sleep(50 * time.Millisecond)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token":"abc123"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	withAuth := "headers:\n  Authorization: Bearer {{.captured.token}}\ndepends_on: [login]\n"
+	paths := writeRequests(t, [][2]string{
+		{"me.hk.yaml", fmt.Sprintf("method: GET\nurl: %q\n%s", server.URL+"/me", withAuth)},
+		{"orders.hk.yaml", fmt.Sprintf("method: GET\nurl: %q\n%s", server.URL+"/orders", withAuth)},
+		{"login.hk.yaml", fmt.Sprintf(
+			"method: GET\nurl: %q\ncapture:\n  - name: token\n    path: token\n", server.URL+"/login",
+		)},
+	})
+
+	nodes, hasDeps := planGraph(paths)
+	if !hasDeps {
+		t.Fatal("hasDeps = false, want true")
+	}
+	outcomes := runGraph(nodes, map[string]any{}, runOptions{}, 5*time.Second)
+	if len(outcomes) != 3 {
+		t.Fatalf("got %d outcomes, want 3", len(outcomes))
+	}
+	for _, o := range outcomes {
+		if !o.ok {
+			t.Fatalf("%s failed: %v", filepath.Base(o.path), o.err)
+		}
+	}
+	if order[0] != "/login" {
+		t.Errorf("request order = %v, want /login first", order)
+	}
+	for _, p := range []string{"/me", "/orders"} {
+		if auth[p] != "Bearer abc123" {
+			t.Errorf("%s Authorization = %q, want %q", p, auth[p], "Bearer abc123")
+		}
+	}
+}
+
+// TestRunGraph_SkipsDependentsOfFailure verifies a failed prerequisite skips
+// its direct and transitive dependents while unrelated files still run.
+func TestRunGraph_SkipsDependentsOfFailure(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		hits = map[string]bool{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path] = true
+		mu.Unlock()
+		if r.URL.Path == "/login" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	req := func(path, extra string) string {
+		return fmt.Sprintf("method: GET\nurl: %q\n%s", server.URL+path, extra)
+	}
+	paths := writeRequests(t, [][2]string{
+		{"login.hk.yaml", req("/login", "assert:\n  status: 200\n")},
+		{"me.hk.yaml", req("/me", "depends_on: [login]\n")},
+		{"avatar.hk.yaml", req("/avatar", "depends_on: [me]\n")},
+		{"health.hk.yaml", req("/health", "")},
+	})
+
+	nodes, _ := planGraph(paths)
+	outcomes := runGraph(nodes, map[string]any{}, runOptions{}, 5*time.Second)
+
+	got := map[string]outcome{}
+	for _, o := range outcomes {
+		got[filepath.Base(o.path)] = o
+	}
+	if len(got) != 4 {
+		t.Fatalf("got outcomes for %d files, want 4", len(got))
+	}
+	if o := got["health.hk.yaml"]; !o.ok {
+		t.Errorf("health should run and pass, got %v", o.err)
+	}
+	for name, want := range map[string]string{
+		"me.hk.yaml":     "skipped: depends on login.hk.yaml",
+		"avatar.hk.yaml": "skipped: depends on me.hk.yaml",
+	} {
+		o := got[name]
+		if o.ok || o.err == nil || !strings.Contains(o.err.Error(), want) {
+			t.Errorf("%s: ok=%v err=%v, want error containing %q", name, o.ok, o.err, want)
+		}
+	}
+	if hits["/me"] || hits["/avatar"] {
+		t.Errorf("skipped files must not be sent, hits = %v", hits)
+	}
+}
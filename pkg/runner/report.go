@@ -0,0 +1,245 @@
+package runner
+
+import (
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// Report formats accepted by `hulak run --report <format>=<path>`.
+const (
+	ReportJUnit = "junit"
+	ReportJSON  = "json"
+)
+
+// Report is one `--report` destination.
+type Report struct {
+	Format string
+	Path   string
+}
+
+// ParseReport parses a `--report` value of the form format=path, e.g.
+// junit=reports/hulak.xml.
+func ParseReport(spec string) (Report, error) {
+	format, path, ok := strings.Cut(spec, "=")
+	format = strings.ToLower(strings.TrimSpace(format))
+	path = strings.TrimSpace(path)
+	if !ok || path == "" {
+		return Report{}, fmt.Errorf("expected format=path, got %q", spec)
+	}
+	if format != ReportJUnit && format != ReportJSON {
+		return Report{}, fmt.Errorf(
+			"unknown report format %q (use %s or %s)", format, ReportJUnit, ReportJSON,
+		)
+	}
+	return Report{Format: format, Path: path}, nil
+}
+
+// reportFile is one request file's row in a report, shared by both formats.
+type reportFile struct {
+	File       string `json:"file"`
+	Name       string `json:"name"`
+	Row        int    `json:"row,omitempty"`
+	OK         bool   `json:"ok"`
+	Skipped    bool   `json:"skipped,omitempty"`
+	Status     string `json:"status,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Attempts   int    `json:"attempts,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// jsonReport is the document written by --report json=path.
+type jsonReport struct {
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMS int64        `json:"duration_ms"`
+	Total      int          `json:"total"`
+	Succeeded  int          `json:"succeeded"`
+	Failed     int          `json:"failed"`
+	Skipped    int          `json:"skipped"`
+	Files      []reportFile `json:"files"`
+}
+
+// reportFiles converts outcomes into report rows sorted by path, then
+// dataset row, so two runs of the same directory produce reports that diff
+// cleanly regardless of which concurrent file finished first.
+func reportFiles(outcomes []outcome) []reportFile {
+	files := make([]reportFile, 0, len(outcomes))
+	for _, o := range outcomes {
+		headline, detail := splitErrorForOutcome(o.err)
+		files = append(files, reportFile{
+			File:       filepath.ToSlash(o.path),
+			Name:       o.name(),
+			Row:        o.row,
+			OK:         o.ok,
+			Skipped:    o.skipped,
+			Status:     o.status,
+			DurationMS: o.duration.Milliseconds(),
+			Attempts:   o.attempts,
+			Error:      headline,
+			Detail:     detail,
+		})
+	}
+	slices.SortStableFunc(files, func(a, b reportFile) int {
+		return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Row, b.Row))
+	})
+	return files
+}
+
+// writeReports writes every requested report. All reports are attempted
+// even when one fails; the errors are joined.
+func writeReports(reports []Report, outcomes []outcome, started time.Time, total time.Duration) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	files := reportFiles(outcomes)
+
+	var errs []error
+	for _, r := range reports {
+		var (
+			content []byte
+			err     error
+		)
+		switch r.Format {
+		case ReportJUnit:
+			content, err = junitReport(files, started, total)
+		case ReportJSON:
+			content, err = jsonReportBytes(files, started, total)
+		default:
+			err = fmt.Errorf("unknown report format %q", r.Format)
+		}
+		if err == nil {
+			err = writeReportFile(r.Path, content)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("writing %s report %s: %w", r.Format, r.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func writeReportFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), utils.DirPer); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, utils.FilePer)
+}
+
+func jsonReportBytes(files []reportFile, started time.Time, total time.Duration) ([]byte, error) {
+	doc := jsonReport{
+		StartedAt:  started.UTC().Truncate(time.Millisecond),
+		DurationMS: total.Milliseconds(),
+		Total:      len(files),
+		Files:      files,
+	}
+	for _, f := range files {
+		switch {
+		case f.OK:
+			doc.Succeeded++
+		case f.Skipped:
+			doc.Failed++
+			doc.Skipped++
+		default:
+			doc.Failed++
+		}
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// JUnit XML elements. The layout follows the de facto schema most CI
+// systems read: one <testsuite> per run, one <testcase> per request file
+// (per dataset row for data-driven files).
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func junitReport(files []reportFile, started time.Time, total time.Duration) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:      "hulak run",
+		Tests:     len(files),
+		Time:      junitSeconds(total),
+		Timestamp: started.UTC().Format("2006-01-02T15:04:05"),
+	}
+	for _, f := range files {
+		tc := junitTestCase{
+			Name:      f.Name,
+			Classname: filepath.ToSlash(filepath.Dir(f.File)),
+			Time:      junitSeconds(time.Duration(f.DurationMS) * time.Millisecond),
+		}
+		if f.Status != "" {
+			tc.SystemOut = "status: " + f.Status
+		}
+		switch {
+		case f.Skipped:
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: f.Error}
+		case !f.OK:
+			suite.Failures++
+			tc.Failure = &junitMessage{Message: f.Error, Body: f.Detail}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	doc := junitTestSuites{
+		Name:     "hulak",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(b, '\n')...), nil
+}
+
+// junitSeconds renders a duration as the seconds value JUnit expects.
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Report
+		wantErr string
+	}{
+		{"junit=out/report.xml", Report{ReportJUnit, "out/report.xml"}, ""},
+		{"JSON = report.json", Report{ReportJSON, "report.json"}, ""},
+		{"junit", Report{}, "expected format=path"},
+		{"junit=", Report{}, "expected format=path"},
+		{"html=report.html", Report{}, `unknown report format "html"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			got, err := ParseReport(tc.spec)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Fatalf("ParseReport(%q) = %+v, %v; want %+v", tc.spec, got, err, tc.want)
+			}
+		})
+	}
+}
+
+// reportOutcomes is a mixed run: a pass, a failure with detail, and a file
+// skipped because its prerequisite failed. Listed out of path order on
+// purpose.
+func reportOutcomes() []outcome {
+	return []outcome{
+		{
+			path:     "reqs/me.hk.yaml",
+			skipped:  true,
+			err:      errors.New("skipped: depends on login.hk.yaml, which failed"),
+			duration: 0,
+		},
+		{
+			path:     "reqs/login.hk.yaml",
+			status:   "500 Internal Server Error",
+			duration: 142 * time.Millisecond,
+			attempts: 3,
+			err:      errors.New("1 assertion failed\nstatus: got 500, want 200"),
+		},
+		{path: "reqs/health.hk.yaml", ok: true, status: "200 OK", duration: 41 * time.Millisecond, attempts: 1},
+	}
+}
+
+func TestWriteReports_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "report.json")
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := writeReports([]Report{{ReportJSON, path}}, reportOutcomes(), started, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got jsonReport
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, raw)
+	}
+	if got.Total != 3 || got.Succeeded != 1 || got.Failed != 2 || got.Skipped != 1 {
+		t.Errorf("totals = %d/%d/%d/%d, want 3/1/2/1", got.Total, got.Succeeded, got.Failed, got.Skipped)
+	}
+	if got.DurationMS != 2000 || !got.StartedAt.Equal(started) {
+		t.Errorf("duration/started = %d/%v", got.DurationMS, got.StartedAt)
+	}
+
+	var names []string
+	for _, f := range got.Files {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "health.hk.yaml,login.hk.yaml,me.hk.yaml" {
+		t.Errorf("files should be sorted by path, got %v", names)
+	}
+	login := got.Files[1]
+	if login.Error != "1 assertion failed" || login.Detail != "status: got 500, want 200" {
+		t.Errorf("login error/detail = %q / %q", login.Error, login.Detail)
+	}
+	if login.Status != "500 Internal Server Error" || login.DurationMS != 142 || login.Attempts != 3 {
+		t.Errorf("login row = %+v", login)
+	}
+}
+
+func TestWriteReports_JUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junit.xml")
+	if err := writeReports([]Report{{ReportJUnit, path}}, reportOutcomes(), time.Now(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(raw), xml.Header) {
+		t.Error("report should start with the XML header")
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("report is not valid XML: %v\n%s", err, raw)
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Skipped != 1 || len(got.Suites) != 1 {
+		t.Fatalf("testsuites = %+v", got)
+	}
+
+	cases := map[string]junitTestCase{}
+	for _, tc := range got.Suites[0].Cases {
+		cases[tc.Name] = tc
+	}
+	if tc := cases["health.hk.yaml"]; tc.Failure != nil || tc.Skipped != nil || tc.Time != "0.041" {
+		t.Errorf("health case = %+v", tc)
+	}
+	if tc := cases["login.hk.yaml"]; tc.Failure == nil ||
+		tc.Failure.Message != "1 assertion failed" ||
+		strings.TrimSpace(tc.Failure.Body) != "status: got 500, want 200" ||
+		tc.Classname != "reqs" {
+		t.Errorf("login case = %+v", tc)
+	}
+	if tc := cases["me.hk.yaml"]; tc.Skipped == nil || tc.Failure != nil {
+		t.Errorf("me case should be skipped, got %+v", tc)
+	}
+}
+
+func TestWriteReports_ReportsEveryFailure(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	good := filepath.Join(dir, "ok.json")
+	err := writeReports([]Report{
+		{ReportJUnit, filepath.Join(blocker, "junit.xml")}, // parent is a file
+		{ReportJSON, good},
+	}, reportOutcomes(), time.Now(), time.Second)
+	if err == nil || !strings.Contains(err.Error(), "writing junit report") {
+		t.Fatalf("err = %v, want the junit write failure", err)
+	}
+	if _, statErr := os.Stat(good); statErr != nil {
+		t.Errorf("the json report should still be written: %v", statErr)
+	}
+}
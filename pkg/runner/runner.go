@@ -6,22 +6,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/xaaha/hulak/pkg/actions"
 	apicalls "github.com/xaaha/hulak/pkg/apiCalls"
 	"github.com/xaaha/hulak/pkg/envparser"
 	"github.com/xaaha/hulak/pkg/features"
+	"github.com/xaaha/hulak/pkg/httpclient"
 	"github.com/xaaha/hulak/pkg/tui"
 	"github.com/xaaha/hulak/pkg/tui/envselect"
 	"github.com/xaaha/hulak/pkg/utils"
 	"github.com/xaaha/hulak/pkg/vault"
 	"github.com/xaaha/hulak/pkg/yamlparser"
+	"golang.org/x/term"
 )
 
 // envSelector is the function used to show the interactive environment picker.
@@ -57,6 +64,28 @@ type Flags struct {
 	// location. Only valid for single-file runs (the run subcommand rejects
 	// combining it with a directory target).
 	Out string
+	// Retries resends a request that fails with a retryable error up to this
+	// many times, using the default retry policy. A file's own `retry:`
+	// section wins. Zero means no retries.
+	Retries int
+	// Reports lists the --report destinations written after the run, for
+	// single files and directories alike.
+	Reports []Report
+	// Data is a CSV or JSON dataset every file in the run iterates over,
+	// once per row. A file's own `data:` key wins. Empty means each file
+	// runs once.
+	Data string
+	// Stream writes every response body to disk as it arrives instead of
+	// buffering it. Bodies over apicalls.StreamThreshold stream without it.
+	Stream bool
+	// CookieJar is a file the run's cookie jar is loaded from and saved
+	// back to. Setting it also sends every request's cookies through the
+	// jar, not only those of files with `cookies: true`. Empty keeps the
+	// jar in memory for the run.
+	CookieJar string
+	// Timings adds the phases of each request's time to the run summary,
+	// and prints the summary for a single file too.
+	Timings bool
 }
 
 // runOptions bundles per-run flags that every internal helper needs to
@@ -64,10 +93,38 @@ type Flags struct {
 // so adding a new flag (e.g. record mode) is a single-field change rather
 // than a parameter rewrite across five signatures.
 type runOptions struct {
-	Debug  bool
-	DryRun bool
-	Show   bool
-	Out    string
+	Debug   bool
+	DryRun  bool
+	Show    bool
+	Out     string
+	Retries int
+	Reports []Report
+	Data    string
+	// Row is the dataset row processTask is running, set per iteration by
+	// runFile. Zero outside data-driven runs.
+	Row    int
+	Stream bool
+	// streamEcho receives streamed response bodies as they arrive. Set for
+	// single-file runs whose stdout is piped; a terminal gets progress in
+	// the spinner instead.
+	streamEcho io.Writer
+	// progress tracks the bytes of a streamed response for the single-file
+	// spinner. Nil elsewhere.
+	progress *streamProgress
+	// eventOut prints the events of an event stream response as they
+	// arrive. Set for single-file runs; with several files the output
+	// would interleave, so their events are only saved.
+	eventOut io.Writer
+	// CookieJar is the --cookie-jar file, saved when the run ends.
+	CookieJar string
+	// jar is the run's cookie jar, shared by the requests that use it.
+	// handleAPIRequests starts an empty one when it is nil.
+	jar *httpclient.Jar
+	// Timings is the --timings flag; see Flags.Timings.
+	Timings bool
+	// tokens supplies getValueOf the cached OAuth tokens of Auth-kind
+	// files, renewed once expired. Nil on a dry run.
+	tokens actions.ResponseSource
 }
 
 // DefaultTimeout is the per-request timeout used when no override is set
@@ -141,7 +198,26 @@ func Execute(f *Flags) error {
 		}
 	}
 
-	opts := runOptions{Debug: f.Debug, DryRun: f.DryRun, Show: f.Show, Out: f.Out}
+	opts := runOptions{
+		Debug:   f.Debug,
+		DryRun:  f.DryRun,
+		Show:    f.Show,
+		Out:     f.Out,
+		Retries: f.Retries,
+		Reports: f.Reports,
+		Data:    f.Data,
+		Stream:  f.Stream,
+		Timings: f.Timings,
+	}
+	if !f.DryRun {
+		opts.tokens = features.TokenSource(envMap)
+	}
+	if f.CookieJar != "" && !f.DryRun {
+		opts.CookieJar = f.CookieJar
+		if opts.jar, err = loadCookieJar(f.CookieJar); err != nil {
+			return err
+		}
+	}
 	return handleAPIRequests(
 		envMap,
 		f.Quiet,
@@ -167,7 +243,8 @@ func ExecuteSingleFile(
 	if err != nil {
 		return err
 	}
-	return handleAPIRequests(envMap, false, runOptions{Debug: debug}, []string{filePath}, nil, baseTimeout)
+	opts := runOptions{Debug: debug, tokens: features.TokenSource(envMap)}
+	return handleAPIRequests(envMap, false, opts, []string{filePath}, nil, baseTimeout)
 }
 
 // ResolveBaseTimeout combines the --timeout flag and HULAK_TIMEOUT env var
@@ -266,6 +343,33 @@ type outcome struct {
 	// after the spinner clears (single-file mode) or inline (multi-file mode).
 	// Empty for non-API kinds, pre-flight errors, and transport failures.
 	respBytes []byte
+	// summary replaces respBytes in output for a binary response, which is
+	// saved to disk rather than dumped on the terminal.
+	summary string
+	// captured holds the values named by the file's capture list, handed to
+	// later files in a sequential run as {{.captured.<name>}}.
+	captured map[string]any
+	// attempts is how many times the request was sent; above 1 when a
+	// retry policy resent it. Zero when it never went out.
+	attempts int
+	// skipped marks a file that was never sent because a file it depends on
+	// failed. It still counts as failed; reports list it as skipped.
+	skipped bool
+	// row is the 1-based dataset row this outcome ran with; zero when the
+	// file ran once.
+	row int
+	// timings breaks down the time the request took. Nil for kinds that
+	// don't send an HTTP request and when no response arrived.
+	timings *apicalls.Timings
+}
+
+// name is the outcome's display name: the file's base name, plus #<row>
+// for one row of a data-driven run.
+func (o *outcome) name() string {
+	if o.row > 0 {
+		return filepath.Base(o.path) + "#" + strconv.Itoa(o.row)
+	}
+	return filepath.Base(o.path)
 }
 
 // handleAPIRequests processes API requests from pre-discovered file lists.
@@ -287,6 +391,18 @@ func handleAPIRequests(
 	// let the user track which file finished. With a single file there's
 	// exactly one outcome and the line is just noise; suppress it.
 	multiFile := totalFiles > 1
+	// A single file's streamed body is piped through to stdout as it
+	// arrives. Concurrent files would interleave, and a terminal is better
+	// served by the progress line than by hundreds of MB scrolling by.
+	if !multiFile && !term.IsTerminal(int(os.Stdout.Fd())) { //nolint:gosec // G115 fd is small non-neg
+		opts.streamEcho = os.Stdout
+	}
+	if !multiFile {
+		opts.eventOut = os.Stdout
+	}
+	if opts.jar == nil {
+		opts.jar = httpclient.NewJar()
+	}
 
 	overallStart := time.Now()
 	var outcomes []outcome
@@ -301,17 +417,28 @@ func handleAPIRequests(
 		outcomes = append(outcomes, runSingleWithSpinner(
 			firstNonEmpty(concurrentFiles, sequentialFiles),
 			secrets, opts, baseTimeout,
-		))
+		)...)
 	default:
 		if len(concurrentFiles) > 0 {
-			if len(concurrentFiles) > 1 || len(sequentialFiles) > 0 {
-				utils.PrintInfoStderr(
-					fmt.Sprintf("Processing %d files concurrently...", len(concurrentFiles)),
-				)
+			// depends_on only changes scheduling when some file declares it;
+			// otherwise keep the plain worker pool.
+			nodes, hasDeps := planGraph(concurrentFiles)
+			if hasDeps {
+				utils.PrintInfoStderr(fmt.Sprintf(
+					"Processing %d files concurrently, following depends_on...",
+					len(concurrentFiles),
+				))
+				outcomes = append(outcomes, runGraph(nodes, secrets, opts, baseTimeout)...)
+			} else {
+				if len(concurrentFiles) > 1 || len(sequentialFiles) > 0 {
+					utils.PrintInfoStderr(
+						fmt.Sprintf("Processing %d files concurrently...", len(concurrentFiles)),
+					)
+				}
+				outcomes = append(
+					outcomes,
+					runTasks(concurrentFiles, secrets, opts, baseTimeout)...)
 			}
-			outcomes = append(
-				outcomes,
-				runTasks(concurrentFiles, secrets, opts, baseTimeout)...)
 		}
 		if len(sequentialFiles) > 0 {
 			utils.PrintInfoStderr(
@@ -330,24 +457,44 @@ func handleAPIRequests(
 		return nil
 	}
 
-	if multiFile && !quiet {
-		printRunSummary(outcomes, time.Since(overallStart))
+	elapsed := time.Since(overallStart)
+	// A single file iterated over a dataset gets the summary too: one row
+	// per dataset row. So does a single file with --timings, which are only
+	// shown there.
+	if (len(outcomes) > 1 || opts.Timings) && !quiet {
+		printRunSummary(outcomes, elapsed, opts.Timings)
+	}
+
+	// Reports are written for every run, quiet or not, so CI gets them
+	// even when the terminal output is suppressed.
+	reportErr := writeReports(opts.Reports, outcomes, overallStart, elapsed)
+	if opts.CookieJar != "" {
+		// Saved whatever the outcome: a failing file doesn't undo the
+		// session an earlier one signed in to.
+		reportErr = errors.Join(reportErr, saveCookieJar(opts.CookieJar, opts.jar))
 	}
 
 	// Aggregate failures into a single error so the exit code reflects them.
 	// Per-file detail has already been printed by printOutcome; the error
 	// returned here is just a short headline a top-level handler can surface
 	// without duplicating what's already on screen.
-	failed := 0
+	// Failures are counted per file, so a file that failed on several
+	// dataset rows counts once.
+	failedFiles := make(map[string]bool)
 	for _, o := range outcomes {
 		if !o.ok {
-			failed++
+			failedFiles[o.path] = true
 		}
 	}
-	if failed > 0 {
+	if failed := len(failedFiles); failed > 0 {
+		// The caller skips printing run failures, so surface a report
+		// error here instead of folding it into the returned error.
+		if reportErr != nil {
+			utils.PrintErrorStderr(reportErr.Error())
+		}
 		return &runFailureError{failed: failed, total: totalFiles}
 	}
-	return nil
+	return reportErr
 }
 
 // runFailureError signals "n of m files failed" so the exit code flips
@@ -381,16 +528,27 @@ func IsRunFailure(err error) bool {
 
 // printRunSummary prints a table of all outcomes followed by a totals line.
 // Only invoked for multi-file runs — single-file outcome is already obvious
-// from the per-file outcome line.
-func printRunSummary(outcomes []outcome, total time.Duration) {
+// from the per-file outcome line — unless timings asks for the phase
+// columns.
+func printRunSummary(outcomes []outcome, total time.Duration, timings bool) {
 	headers := []string{"FILE", "RESULT", "STATUS", "DURATION"}
+	if timings {
+		headers = append(headers, "DNS", "CONNECT", "TLS", "FIRST BYTE", "TRANSFER")
+	}
 	var rows [][]string
 
+	// The ATTEMPTS column only appears when something was retried, so runs
+	// without retries keep the familiar four-column table.
+	retried := slices.ContainsFunc(outcomes, func(o outcome) bool { return o.attempts > 1 })
+	if retried {
+		headers = append(headers, "ATTEMPTS")
+	}
+
 	succeeded := 0
 	failed := 0
 	for _, o := range outcomes {
 		result := utils.Green + utils.CheckMark + utils.ColorReset
-		name := utils.Blue + filepath.Base(o.path) + utils.ColorReset
+		name := utils.Blue + o.name() + utils.ColorReset
 		errMsg := ""
 		if o.ok {
 			succeeded++
@@ -400,13 +558,23 @@ func printRunSummary(outcomes []outcome, total time.Duration) {
 			headline, _ := splitErrorForOutcome(o.err)
 			errMsg = headline
 		}
-		rows = append(rows, []string{
+		row := []string{
 			name,
 			result,
 			o.status,
 			formatDuration(o.duration),
-			errMsg,
-		})
+		}
+		if timings {
+			row = append(row, timingColumns(o.timings)...)
+		}
+		if retried {
+			attempts := ""
+			if o.attempts > 0 {
+				attempts = strconv.Itoa(o.attempts)
+			}
+			row = append(row, attempts)
+		}
+		rows = append(rows, append(row, errMsg))
 	}
 	if failed > 0 {
 		headers = append(headers, "ERROR")
@@ -419,6 +587,21 @@ func printRunSummary(outcomes []outcome, total time.Duration) {
 	))
 }
 
+// timingColumns renders the phases of a request for the summary table,
+// or blank cells when it has none.
+func timingColumns(t *apicalls.Timings) []string {
+	if t == nil {
+		return make([]string, 5)
+	}
+	return []string{
+		formatDuration(t.DNS),
+		formatDuration(t.Connect),
+		formatDuration(t.TLS),
+		formatDuration(t.FirstByte),
+		formatDuration(t.Transfer),
+	}
+}
+
 // formatDuration renders a duration tightly: 142ms, 1.2s, 1m23s.
 // time.Duration's String() can yield clutter like 1.234567s; this trims it.
 func formatDuration(d time.Duration) string {
@@ -434,13 +617,24 @@ func formatDuration(d time.Duration) string {
 	}
 }
 
+// printResponse prints a file's response: the body on stdout, or for a
+// binary response a one-line summary on stderr.
+func printResponse(o *outcome) {
+	switch {
+	case o.summary != "":
+		utils.PrintInfoStderr(o.summary)
+	case o.respBytes != nil:
+		apicalls.PrintRespBytes(o.respBytes)
+	}
+}
+
 // printOutcome renders one ✓/✗ line per file. status is empty for non-API
 // kinds (Auth2, future kinds) — the line just shows the timing. Errors are
 // flattened to a single line so the outcome list stays scannable; if the
 // underlying error has actionable detail (e.g. a hint), it gets printed on
 // a follow-up indented line so the cause is still discoverable.
 func printOutcome(o *outcome) {
-	name := filepath.Base(o.path)
+	name := o.name()
 	dur := formatDuration(o.duration)
 	if o.ok {
 		bracket := dur
@@ -506,8 +700,9 @@ func splitErrorForOutcome(err error) (headline, detail string) {
 var ansiInOutcome = regexp.MustCompile(`\x1b\[[0-9;]*m`)
 
 // runTasks manages the go tasks with a limited worker pool. Returns one
-// outcome per file in the order they finished. Per-file outcome lines are
-// not printed here — the summary table handles concurrent results.
+// outcome per file (per dataset row for data-driven files) in the order
+// they finished. Per-file outcome lines are not printed here — the summary
+// table handles concurrent results.
 //
 // baseTimeout is the per-request timeout when a file has no YAML `timeout:`
 // override. processTask resolves the YAML override internally (single
@@ -523,7 +718,7 @@ func runTasks(
 
 	var wg sync.WaitGroup
 	taskChan := make(chan string, len(filePathList))
-	resultChan := make(chan outcome, len(filePathList))
+	resultChan := make(chan []outcome, len(filePathList))
 
 	for _, path := range filePathList {
 		taskChan <- path
@@ -536,14 +731,14 @@ func runTasks(
 			defer wg.Done()
 
 			for path := range taskChan {
-				final := processTask(path, utils.CopyEnvMap(secretsMap), opts, baseTimeout)
-				if final.respBytes != nil {
-					apicalls.PrintRespBytes(final.respBytes)
+				results := runFile(path, utils.CopyEnvMap(secretsMap), opts, baseTimeout)
+				for i := range results {
+					printResponse(&results[i])
+					if !results[i].ok {
+						printOutcome(&results[i])
+					}
 				}
-				if !final.ok {
-					printOutcome(&final)
-				}
-				resultChan <- final
+				resultChan <- results
 			}
 		}(i)
 	}
@@ -551,37 +746,87 @@ func runTasks(
 	close(resultChan)
 
 	outcomes := make([]outcome, 0, len(filePathList))
-	for o := range resultChan {
-		outcomes = append(outcomes, o)
+	for results := range resultChan {
+		outcomes = append(outcomes, results...)
 	}
 	return outcomes
 }
 
-// runSingleWithSpinner wraps a single processTask call with a stderr spinner.
-// TTY detection lives in tui.RunWithSpinnerOnStderr — when stderr is not a
+// runSingleWithSpinner wraps a single runFile call with a stderr spinner.
+// TTY detection lives in tui.RunWithStatusOnStderr — when stderr is not a
 // terminal (piped, redirected, CI) the wrapper falls through to the task
 // directly and emits nothing during the wait. Failures are printed via the
-// usual printOutcome path so the multi-line detail block surfaces.
+// usual printOutcome path so the multi-line detail block surfaces. While a
+// response streams to disk, the spinner shows the bytes received.
 func runSingleWithSpinner(
 	path string,
 	secrets map[string]any,
 	opts runOptions,
 	baseTimeout time.Duration,
-) outcome {
+) []outcome {
 	msg := fmt.Sprintf("Running '%s'...", filepath.Base(path))
-	result, _ := tui.RunWithSpinnerOnStderr(msg, func() (any, error) {
-		return processTask(path, utils.CopyEnvMap(secrets), opts, baseTimeout), nil
+	opts.progress = &streamProgress{}
+	status := func() string { return opts.progress.status(msg) }
+	result, _ := tui.RunWithStatusOnStderr(status, func(hide func()) (any, error) {
+		if opts.eventOut != nil {
+			opts.eventOut = &hideOnWrite{w: opts.eventOut, hide: hide}
+		}
+		return runFile(path, utils.CopyEnvMap(secrets), opts, baseTimeout), nil
 	})
-	o := result.(outcome)
+	outcomes := result.([]outcome)
 	// Spinner has cleared by this point. Print the response now so it lands
 	// on a clean stderr line instead of overlapping with the spinner frame.
-	if o.respBytes != nil {
-		apicalls.PrintRespBytes(o.respBytes)
+	for i := range outcomes {
+		printResponse(&outcomes[i])
+		if !outcomes[i].ok {
+			printOutcome(&outcomes[i])
+		}
 	}
-	if !o.ok {
-		printOutcome(&o)
+	return outcomes
+}
+
+// hideOnWrite hides the spinner before every write, so events printed while
+// the request runs don't collide with spinner frames. hide is idempotent.
+type hideOnWrite struct {
+	w    io.Writer
+	hide func()
+}
+
+func (h *hideOnWrite) Write(p []byte) (int, error) {
+	h.hide()
+	return h.w.Write(p)
+}
+
+// streamProgress is the byte count of a response being streamed, written by
+// the request and read by the spinner.
+type streamProgress struct {
+	received atomic.Int64
+	total    atomic.Int64
+}
+
+// reporter returns the callback apicalls invokes as a streamed body
+// arrives; nil when nothing displays progress.
+func (p *streamProgress) reporter() func(received, total int64) {
+	if p == nil {
+		return nil
+	}
+	return func(received, total int64) {
+		p.received.Store(received)
+		p.total.Store(total)
+	}
+}
+
+// status appends the bytes received to msg once a response is streaming,
+// e.g. "Running 'export.hk.yaml'... 120.5 MB / 512.0 MB".
+func (p *streamProgress) status(msg string) string {
+	received := p.received.Load()
+	if received == 0 {
+		return msg
 	}
-	return o
+	if total := p.total.Load(); total > 0 {
+		return fmt.Sprintf("%s %s / %s", msg, utils.FormatBytes(received), utils.FormatBytes(total))
+	}
+	return fmt.Sprintf("%s %s received", msg, utils.FormatBytes(received))
 }
 
 // firstNonEmpty returns the first element of the first non-empty slice. The
@@ -617,6 +862,7 @@ func processTask(
 	baseTimeout time.Duration,
 ) outcome {
 	start := time.Now()
+	secretsMap = actions.WithResponseSource(secretsMap, opts.tokens)
 	config, err := yamlparser.ParseConfig(path, secretsMap)
 	if err != nil {
 		return outcome{path: path, ok: false, duration: time.Since(start), err: err}
@@ -627,6 +873,15 @@ func processTask(
 	if d, _ := config.ParsedTimeout(); d > 0 {
 		timeout = d
 	}
+	// A longer poll timeout raises the budget so the wait isn't cut short.
+	if d, _ := config.Poll.ParsedTimeout(); d > timeout {
+		timeout = d
+	}
+	// An event stream is read for events.duration after the headers
+	// arrive; the timeout still bounds the wait for them.
+	if d, _ := config.Events.ParsedDuration(); d > 0 {
+		timeout += d
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -634,22 +889,41 @@ func processTask(
 	case config.IsAuth():
 		err := features.SendAPIRequestForAuth2(ctx, secretsMap, path, opts.Debug)
 		return outcome{path: path, ok: err == nil, duration: time.Since(start), err: err}
-	case config.IsAPI() || config.IsGraphql():
-		respBytes, status, err := apicalls.SendAndSaveAPIRequest(ctx, apicalls.RequestOptions{
-			Secrets: secretsMap,
-			Path:    path,
-			Debug:   opts.Debug,
-			DryRun:  opts.DryRun,
-			Show:    opts.Show,
-			OutPath: opts.Out,
+	case config.IsAPI() || config.IsGraphql() || config.IsWebSocket() || config.IsGRPC():
+		send := apicalls.SendAndSaveAPIRequest
+		switch {
+		case config.IsWebSocket():
+			send = apicalls.SendWebSocket
+		case config.IsGRPC():
+			send = apicalls.SendGRPC
+		}
+		result, err := send(ctx, apicalls.RequestOptions{
+			Secrets:    secretsMap,
+			Path:       path,
+			Debug:      opts.Debug,
+			DryRun:     opts.DryRun,
+			Show:       opts.Show,
+			OutPath:    opts.Out,
+			Retries:    opts.Retries,
+			Row:        opts.Row,
+			Stream:     opts.Stream,
+			StreamEcho: opts.streamEcho,
+			EventOut:   opts.eventOut,
+			Progress:   opts.progress.reporter(),
+			Jar:        opts.jar,
+			Cookies:    opts.CookieJar != "",
 		})
 		return outcome{
 			path:      path,
 			ok:        err == nil,
-			status:    status,
+			status:    result.Status,
 			duration:  time.Since(start),
 			err:       err,
-			respBytes: respBytes,
+			respBytes: result.Body,
+			summary:   result.Summary,
+			captured:  result.Captured,
+			attempts:  result.Attempts,
+			timings:   result.Timings,
 		}
 	default:
 		return outcome{
@@ -669,6 +943,11 @@ func processTask(
 // baseTimeout is the per-request timeout when a file has no YAML override.
 // processTask resolves the YAML override internally and threads the context
 // into the HTTP client — same cancellation path as runTasks.
+//
+// Values captured by a file are exposed to every later file in the run as
+// {{.captured.<name>}}. A later capture with the same name overwrites the
+// earlier one; for a data-driven file, the last row's value wins. Captures
+// live only for this run — nothing is written to disk.
 func processFilesSequentially(
 	filePaths []string,
 	secretsMap map[string]any,
@@ -677,15 +956,18 @@ func processFilesSequentially(
 	baseTimeout time.Duration,
 ) []outcome {
 	outcomes := make([]outcome, 0, len(filePaths))
+	captured := make(map[string]any)
 	for _, path := range filePaths {
-		o := processTask(path, utils.CopyEnvMap(secretsMap), opts, baseTimeout)
-		if o.respBytes != nil {
-			apicalls.PrintRespBytes(o.respBytes)
-		}
-		if multiFile || !o.ok {
-			printOutcome(&o)
+		secrets := utils.CopyEnvMap(secretsMap)
+		secrets[utils.CapturedVarsKey] = maps.Clone(captured)
+		for _, o := range runFile(path, secrets, opts, baseTimeout) {
+			maps.Copy(captured, o.captured)
+			printResponse(&o)
+			if multiFile || !o.ok {
+				printOutcome(&o)
+			}
+			outcomes = append(outcomes, o)
 		}
-		outcomes = append(outcomes, o)
 	}
 	return outcomes
 }
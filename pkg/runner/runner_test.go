@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -678,7 +679,11 @@ func TestRunSingleWithSpinner_RealRequest(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	o := runSingleWithSpinner(tmp, nil, runOptions{}, 5*time.Second)
+	results := runSingleWithSpinner(tmp, nil, runOptions{}, 5*time.Second)
+	if len(results) != 1 {
+		t.Fatalf("got %d outcomes, want 1", len(results))
+	}
+	o := results[0]
 	if !o.ok {
 		t.Errorf("expected ok outcome, got err=%v", o.err)
 	}
@@ -689,3 +694,152 @@ func TestRunSingleWithSpinner_RealRequest(t *testing.T) {
 		t.Errorf("duration should be > 0, got %v", o.duration)
 	}
 }
+
+// TestProcessFilesSequentially_CapturedValues verifies a value captured by
+// one file reaches a later file as {{.captured.<name>}} in the same run.
+func TestProcessFilesSequentially_CapturedValues(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"token":"abc123"}`))
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	login := filepath.Join(dir, "1-login.hk.yaml")
+	me := filepath.Join(dir, "2-me.hk.yaml")
+	files := map[string]string{
+		login: fmt.Sprintf("method: GET\nurl: %q\ncapture:\n  - name: token\n    path: token\n", server.URL+"/login"),
+		me: fmt.Sprintf(
+			"method: GET\nurl: %q\nheaders:\n  Authorization: Bearer {{.captured.token}}\n",
+			server.URL+"/me",
+		),
+	}
+	for path, doc := range files {
+		if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	outcomes := processFilesSequentially(
+		[]string{login, me}, map[string]any{}, runOptions{}, true, 5*time.Second,
+	)
+	for _, o := range outcomes {
+		if !o.ok {
+			t.Fatalf("%s failed: %v", o.path, o.err)
+		}
+	}
+	if gotAuth != "Bearer abc123" {
+		t.Errorf("Authorization = %q, want %q", gotAuth, "Bearer abc123")
+	}
+}
+
+func TestStreamProgressStatus(t *testing.T) {
+	p := &streamProgress{}
+	if got := p.status("Running..."); got != "Running..." {
+		t.Errorf("before streaming: %q", got)
+	}
+	report := p.reporter()
+	report(1536, -1)
+	if got, want := p.status("Running..."), "Running... 1.5 KB received"; got != want {
+		t.Errorf("unknown total: %q, want %q", got, want)
+	}
+	report(1<<20, 4<<20)
+	if got, want := p.status("Running..."), "Running... 1.0 MB / 4.0 MB"; got != want {
+		t.Errorf("known total: %q, want %q", got, want)
+	}
+	if (*streamProgress)(nil).reporter() != nil {
+		t.Error("a nil streamProgress should report nothing")
+	}
+}
+
+func TestProcessTask_Timings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "ping.hk.yaml")
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("method: GET\nurl: %q\n", server.URL)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	o := processTask(path, map[string]any{}, runOptions{}, 5*time.Second)
+	if !o.ok || o.timings == nil {
+		t.Fatalf("outcome = %+v, want timings", o)
+	}
+
+	if cols := timingColumns(o.timings); len(cols) != 5 || cols[1] == "" {
+		t.Errorf("timingColumns() = %q", cols)
+	}
+	if cols := timingColumns(nil); len(cols) != 5 || strings.Join(cols, "") != "" {
+		t.Errorf("timingColumns(nil) = %q, want blank cells", cols)
+	}
+}
+
+// TestProcessTask_SavesRedirects checks the redirects of a default-mode
+// run are saved beside the response, with the Location of one that isn't
+// followed.
+func TestProcessTask_SavesRedirects(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.Redirect(w, r, "/authorize", http.StatusFound)
+		case "/authorize":
+			http.Redirect(w, r, "/callback?code=abc", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		redirects    string
+		wantHops     int
+		wantLocation string
+	}{
+		{name: "none", urlPath: "/login", redirects: "none", wantLocation: "/authorize"},
+		{name: "one", urlPath: "/login", redirects: "1", wantHops: 1, wantLocation: "/callback?code=abc"},
+		{name: "follow", urlPath: "/login", redirects: "follow", wantHops: 2},
+		{name: "no redirects", urlPath: "/callback", redirects: "follow"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "login.hk.yaml")
+			doc := fmt.Sprintf("method: GET\nurl: %q\nredirects: %s\n", server.URL+tc.urlPath, tc.redirects)
+			if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if o := processTask(path, map[string]any{}, runOptions{}, 5*time.Second); !o.ok {
+				t.Fatalf("processTask: %v", o.err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(filepath.Dir(path), "login.hk_response_redirects.json"))
+			if tc.wantHops == 0 && tc.wantLocation == "" {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("redirects saved without any: %s", content)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("redirects not saved: %v", err)
+			}
+			var saved struct {
+				Redirects []struct{ Location string } `json:"redirects"`
+				Location  string                      `json:"location"`
+			}
+			if err := json.Unmarshal(content, &saved); err != nil {
+				t.Fatal(err)
+			}
+			if len(saved.Redirects) != tc.wantHops || saved.Location != tc.wantLocation {
+				t.Errorf("saved redirects = %s, want %d hops and location %q", content, tc.wantHops, tc.wantLocation)
+			}
+		})
+	}
+}
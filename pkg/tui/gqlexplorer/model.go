@@ -128,6 +128,7 @@ type Model struct {
 	responseSearch      tui.PanelSearch
 	executing           bool
 	spinnerFrame        int
+	subscription        *liveSubscription
 	focus               tui.FocusRing
 	pendingG            bool
 	helpBarH            int
@@ -383,6 +384,18 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.updateActionRow()
 		cmd := m.enqueueNotification(tui.NotificationError, msg.err.Error())
 		return m, cmd
+	case subscriptionEventMsg:
+		if msg.sub != m.subscription {
+			return m, nil // stopped; a late result
+		}
+		m.appendSubscriptionEvent(msg.payload)
+		return m, msg.sub.next()
+	case subscriptionEndedMsg:
+		if msg.sub != m.subscription {
+			return m, nil
+		}
+		cmd := m.handleSubscriptionEnded(msg)
+		return m, cmd
 	case spinnerTickMsg:
 		if !m.executing {
 			return m, nil
@@ -992,18 +1005,15 @@ func (m *Model) executeQuery() tea.Cmd {
 	if m.executing {
 		return nil
 	}
+	if m.subscription != nil {
+		m.stopSubscription()
+		return nil
+	}
 	if len(m.filtered) == 0 || m.cursor >= len(m.filtered) {
 		return nil
 	}
 	op := &m.filtered[m.cursor]
 
-	if op.Type == TypeSubscription {
-		return m.enqueueNotification(
-			tui.NotificationWarn,
-			"Subscriptions execution is not supported yet",
-		)
-	}
-
 	info, ok := m.apiInfos[op.Endpoint]
 	if !ok {
 		return m.enqueueNotification(
@@ -1026,6 +1036,10 @@ func (m *Model) executeQuery() tea.Cmd {
 	}
 	apiInfo.Body = body
 
+	if op.Type == TypeSubscription {
+		return m.startSubscription(apiInfo)
+	}
+
 	m.executing = true
 	m.spinnerFrame = 0
 	m.clearResponse()
@@ -1365,11 +1379,7 @@ func (m *Model) canSend() bool {
 	if len(m.filtered) == 0 || m.cursor >= len(m.filtered) {
 		return false
 	}
-	op := &m.filtered[m.cursor]
-	if op.Type == TypeSubscription {
-		return false
-	}
-	_, ok := m.apiInfos[op.Endpoint]
+	_, ok := m.apiInfos[m.filtered[m.cursor].Endpoint]
 	return ok
 }
 
@@ -1378,6 +1388,10 @@ func (m *Model) canSaveOrCreate() bool {
 }
 
 func (m *Model) updateActionRow() {
+	sendLabel := "Send            ctrl+o"
+	if m.subscription != nil {
+		sendLabel = "Stop            ctrl+o"
+	}
 	items := []tui.ActionItem{
 		{
 			ID:      "refresh",
@@ -1387,9 +1401,9 @@ func (m *Model) updateActionRow() {
 		},
 		{
 			ID:      "send",
-			Label:   "Send            ctrl+o",
+			Label:   sendLabel,
 			Key:     tui.KeySend,
-			Enabled: m.canSend(),
+			Enabled: m.subscription != nil || m.canSend(),
 		},
 		{
 			ID:      "saveQuery",
@@ -1504,6 +1518,9 @@ func (m *Model) syncViewport() {
 			if m.detailForm != nil && m.detailFormKey != "" {
 				m.formCache[m.detailFormKey] = m.detailForm
 			}
+			// A subscription's results belong to its operation; leaving
+			// it stops the subscription and caches what arrived.
+			m.stopSubscription()
 			if !m.executing {
 				m.saveResponseToCache()
 			}
@@ -1700,5 +1717,6 @@ func runExplorerModel(model *Model) error {
 		tea.WithMouseCellMotion(),
 	)
 	_, err := p.Run()
+	model.stopSubscription()
 	return err
 }
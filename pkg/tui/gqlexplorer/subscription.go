@@ -0,0 +1,167 @@
+package gqlexplorer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	apicalls "github.com/xaaha/hulak/pkg/apiCalls"
+	"github.com/xaaha/hulak/pkg/tui"
+	"github.com/xaaha/hulak/pkg/utils"
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// liveSubscription is a subscription running in the background. Its
+// results reach Update one at a time through events, so the Response panel
+// can append each as it arrives.
+type liveSubscription struct {
+	cancel context.CancelFunc
+	events chan json.RawMessage
+	// err is why the subscription ended; read only after events is closed.
+	err   error
+	start time.Time
+	count int
+}
+
+// subscriptionEventMsg carries one result of sub.
+type subscriptionEventMsg struct {
+	sub     *liveSubscription
+	payload json.RawMessage
+}
+
+// subscriptionEndedMsg reports that sub ended on its own: the server
+// completed it, or it failed with err.
+type subscriptionEndedMsg struct {
+	sub *liveSubscription
+	err error
+}
+
+// subscribe starts apiInfo's subscription over graphql-transport-ws.
+func subscribe(apiInfo yamlparser.APIInfo) *liveSubscription {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := &liveSubscription{
+		cancel: cancel,
+		events: make(chan json.RawMessage),
+		start:  time.Now(),
+	}
+	go func() {
+		defer close(sub.events)
+		err := apicalls.Subscribe(ctx, apiInfo, func(payload json.RawMessage) bool {
+			select {
+			case sub.events <- payload:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if ctx.Err() == nil {
+			sub.err = err
+		}
+	}()
+	return sub
+}
+
+// next waits for the subscription's next result, or its end.
+func (s *liveSubscription) next() tea.Cmd {
+	return func() tea.Msg {
+		payload, ok := <-s.events
+		if !ok {
+			return subscriptionEndedMsg{sub: s, err: s.err}
+		}
+		return subscriptionEventMsg{sub: s, payload: payload}
+	}
+}
+
+// progress is shown in the Response header in place of a duration, e.g.
+// "3 events" while live and "3 events in 4.2s" once ended.
+func (s *liveSubscription) progress(ended bool) string {
+	label := eventCount(s.count)
+	if !ended {
+		return label
+	}
+	return label + " in " + time.Since(s.start).Round(100*time.Millisecond).String()
+}
+
+func eventCount(n int) string {
+	if n == 1 {
+		return "1 event"
+	}
+	return fmt.Sprintf("%d events", n)
+}
+
+// startSubscription replaces the response with a live one and waits for
+// the first result. Send (ctrl+o) stops it.
+func (m *Model) startSubscription(apiInfo yamlparser.APIInfo) tea.Cmd {
+	m.clearResponse()
+	m.subscription = subscribe(apiInfo)
+	m.responsePanel.SetContent(tui.HelpStyle.Render("Subscribed, waiting for events... ctrl+o stops"), "")
+	m.updateActionRow()
+	return m.subscription.next()
+}
+
+// appendSubscriptionEvent adds one result to the end of the response,
+// following it when the panel was already scrolled to the bottom.
+func (m *Model) appendSubscriptionEvent(payload json.RawMessage) {
+	sub := m.subscription
+	sub.count++
+	follow := sub.count == 1 || m.responsePanel.ScrollPercent() >= 1
+
+	pretty, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		pretty = payload
+	}
+	colored, err := utils.FormatJSONColored(pretty, utils.JSONColors)
+	if err != nil {
+		colored = string(pretty)
+	}
+	if m.responseBody != "" {
+		m.responseBody += "\n"
+		m.responseColoredBody += "\n"
+	}
+	m.responseBody += string(pretty)
+	m.responseColoredBody += colored
+	m.responseDuration = sub.progress(false)
+
+	if !m.responseSearch.Active() {
+		m.setResponseContent()
+	}
+	if follow {
+		m.responsePanel.GotoBottom()
+	}
+}
+
+// stopSubscription cancels the running subscription, if any, keeping the
+// results received so far.
+func (m *Model) stopSubscription() {
+	if m.subscription == nil {
+		return
+	}
+	m.subscription.cancel()
+	m.endSubscription()
+}
+
+// endSubscription settles the response of a subscription that stopped.
+func (m *Model) endSubscription() {
+	sub := m.subscription
+	m.subscription = nil
+	m.updateActionRow()
+	if sub.count == 0 {
+		m.clearResponse()
+		return
+	}
+	m.responseDuration = sub.progress(true)
+	m.setResponseContent()
+	m.saveResponseToCache()
+}
+
+// handleSubscriptionEnded reports why a subscription ended on its own.
+func (m *Model) handleSubscriptionEnded(msg subscriptionEndedMsg) tea.Cmd {
+	count := msg.sub.count
+	m.endSubscription()
+	if msg.err != nil {
+		return m.enqueueNotification(tui.NotificationError, msg.err.Error())
+	}
+	return m.enqueueNotification(tui.NotificationInfo, "Subscription completed after "+eventCount(count))
+}
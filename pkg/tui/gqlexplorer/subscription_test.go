@@ -0,0 +1,129 @@
+package gqlexplorer
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/websocket"
+
+	"github.com/xaaha/hulak/pkg/yamlparser"
+)
+
+// gqlWSServer answers graphql-transport-ws: it acknowledges the
+// connection, waits for subscribe, sends each payload as a result, and
+// then completes the subscription or, with hang, keeps it open.
+func gqlWSServer(t *testing.T, payloads []string, hang bool) string {
+	t.Helper()
+	type message struct {
+		ID      string `json:"id,omitempty"`
+		Type    string `json:"type"`
+		Payload any    `json:"payload,omitempty"`
+	}
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		var msg message
+		for websocket.JSON.Receive(ws, &msg) == nil {
+			switch msg.Type {
+			case "connection_init":
+				_ = websocket.JSON.Send(ws, message{Type: "connection_ack"})
+			case "subscribe":
+				for _, p := range payloads {
+					_ = websocket.Message.Send(ws, `{"id":"`+msg.ID+`","type":"next","payload":`+p+`}`)
+				}
+				if hang {
+					_, _ = io.Copy(io.Discard, ws)
+					return
+				}
+				_ = websocket.JSON.Send(ws, message{ID: msg.ID, Type: "complete"})
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func subscriptionModel(url string) *Model {
+	ops := []UnifiedOperation{{Name: "onPrice", Type: TypeSubscription, Endpoint: url}}
+	m := NewModel(ops, nil, nil, nil, nil, nil, map[string]yamlparser.APIInfo{
+		url: {Method: "POST", URL: url, Headers: map[string]string{"content-type": "application/json"}},
+	})
+	m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	return &m
+}
+
+// deliver runs cmd and hands its message to the model, returning the next
+// command.
+func deliver(m *Model, cmd tea.Cmd) (tea.Msg, tea.Cmd) {
+	msg := cmd()
+	_, next := m.Update(msg)
+	return msg, next
+}
+
+func TestSubscriptionAppendsEventsLive(t *testing.T) {
+	url := gqlWSServer(t, []string{`{"data":{"price":1}}`, `{"data":{"price":2}}`}, true)
+	m := subscriptionModel(url)
+	if !m.canSend() {
+		t.Fatal("a subscription with an API configuration should be sendable")
+	}
+
+	cmd := m.executeQuery()
+	if m.subscription == nil || cmd == nil {
+		t.Fatal("executeQuery did not start the subscription")
+	}
+	if !strings.Contains(m.actionRow.View(), "Stop") {
+		t.Error("the Send action should read Stop while subscribed")
+	}
+
+	_, cmd = deliver(m, cmd)
+	if !strings.Contains(m.responseBody, `"price": 1`) || m.responseDuration != "1 event" {
+		t.Fatalf("after one event: body %q, progress %q", m.responseBody, m.responseDuration)
+	}
+	_, cmd = deliver(m, cmd)
+	if !strings.Contains(m.responseBody, `"price": 1`) || !strings.Contains(m.responseBody, `"price": 2`) {
+		t.Fatalf("the second event should be appended, body:\n%s", m.responseBody)
+	}
+	if m.responseDuration != "2 events" {
+		t.Errorf("progress = %q, want 2 events", m.responseDuration)
+	}
+
+	// ctrl+o again stops it and keeps what arrived.
+	m.Update(tea.KeyMsg{Type: tea.KeyCtrlO})
+	if m.subscription != nil {
+		t.Fatal("ctrl+o did not stop the subscription")
+	}
+	if !strings.HasPrefix(m.responseDuration, "2 events in ") || !strings.Contains(m.responseBody, `"price": 2`) {
+		t.Errorf("after stop: body %q, progress %q", m.responseBody, m.responseDuration)
+	}
+
+	// The stopped subscription's end arrives late and is ignored.
+	msg, _ := deliver(m, cmd)
+	if _, ok := msg.(subscriptionEndedMsg); !ok {
+		t.Fatalf("got %T, want the end of the stopped subscription", msg)
+	}
+	if m.notification.HasLast() {
+		t.Error("stopping a subscription should not notify")
+	}
+}
+
+func TestSubscriptionCompletedByServer(t *testing.T) {
+	m := subscriptionModel(gqlWSServer(t, []string{`{"data":{"price":7}}`}, false))
+
+	cmd := m.executeQuery()
+	_, cmd = deliver(m, cmd)
+	msg, _ := deliver(m, cmd)
+	if _, ok := msg.(subscriptionEndedMsg); !ok {
+		t.Fatalf("got %T, want the subscription to end", msg)
+	}
+	if m.subscription != nil {
+		t.Error("the subscription should be over")
+	}
+	if !strings.HasPrefix(m.responseDuration, "1 event in ") || !strings.Contains(m.responseBody, `"price": 7`) {
+		t.Errorf("body %q, progress %q", m.responseBody, m.responseDuration)
+	}
+	if !m.notification.HasLast() {
+		t.Error("completion should be notified")
+	}
+}
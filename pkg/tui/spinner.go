@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 	"time"
 )
 
@@ -15,14 +16,36 @@ var SpinnerFrames = []rune{'|', '/', '-', '\\'}
 // spinner only appears when both stdin and stdout are TTYs. It is shown after
 // spinnerDelay, so quick tasks finish silently.
 func RunWithSpinnerAfter(message string, task func() (any, error)) (any, error) {
-	return runWithSpinner(os.Stdout, os.Stdout, message, task)
+	return runWithSpinner(os.Stdout, os.Stdout, fixedStatus(message), ignoreHide(task))
 }
 
 // RunWithSpinnerOnStderr is the stderr variant. Use this when the task's
 // stdout carries the result the user wants to capture (e.g. a piped response
 // body) and the spinner needs to live on the side channel instead.
 func RunWithSpinnerOnStderr(message string, task func() (any, error)) (any, error) {
-	return runWithSpinner(os.Stderr, os.Stderr, message, task)
+	return runWithSpinner(os.Stderr, os.Stderr, fixedStatus(message), ignoreHide(task))
+}
+
+// RunWithStatusOnStderr is RunWithSpinnerOnStderr with a message that can
+// change while the task runs, e.g. to show bytes downloaded. status is
+// called on every frame, from the spinner's goroutine.
+//
+// The task gets a hide func that clears the spinner for good, for tasks that
+// start printing before they finish (e.g. events as they arrive). Once hide
+// returns, no more frames are drawn. It is safe to call more than once, and
+// is a no-op when no spinner is shown.
+func RunWithStatusOnStderr(status func() string, task func(hide func()) (any, error)) (any, error) {
+	return runWithSpinner(os.Stderr, os.Stderr, status, task)
+}
+
+// fixedStatus is a status func that always returns message.
+func fixedStatus(message string) func() string {
+	return func() string { return message }
+}
+
+// ignoreHide adapts a task that never prints while it runs.
+func ignoreHide(task func() (any, error)) func(func()) (any, error) {
+	return func(func()) (any, error) { return task() }
 }
 
 // runWithSpinner does the work. ttyProbe is the file used for the isatty
@@ -32,11 +55,11 @@ func RunWithSpinnerOnStderr(message string, task func() (any, error)) (any, erro
 func runWithSpinner(
 	ttyProbe *os.File,
 	out io.Writer,
-	message string,
-	task func() (any, error),
+	status func() string,
+	task func(hide func()) (any, error),
 ) (any, error) {
 	if !isInteractiveTerminal(ttyProbe) {
-		return task()
+		return task(func() {})
 	}
 
 	type taskResult struct {
@@ -44,9 +67,31 @@ func runWithSpinner(
 		err    error
 	}
 
+	var (
+		// mu orders frames against hide, so no frame lands after it.
+		mu     sync.Mutex
+		hidden bool
+		// width is the longest line drawn so far; a shorter message pads
+		// to it so no characters of the previous one are left behind.
+		width int
+	)
+	clearLine := func() {
+		if width > 0 {
+			_, _ = fmt.Fprint(out, "\r"+fmt.Sprintf("%*s", width, "")+"\r")
+		}
+	}
+	hide := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !hidden {
+			clearLine()
+			hidden = true
+		}
+	}
+
 	done := make(chan taskResult, 1)
 	go func() {
-		result, err := task()
+		result, err := task(hide)
 		done <- taskResult{result: result, err: err}
 	}()
 
@@ -62,17 +107,21 @@ func runWithSpinner(
 	frames := SpinnerFrames
 	ticker := time.NewTicker(100 * time.Millisecond)
 	defer ticker.Stop()
-	clearLine := "\r" + fmt.Sprintf("%*s", len(message)+2, "") + "\r"
-
 	index := 0
 	for {
 		select {
 		case completed := <-done:
-			_, _ = fmt.Fprint(out, clearLine)
+			hide()
 			return completed.result, completed.err
 		case <-ticker.C:
-			_, _ = fmt.Fprintf(out, "\r%c %s", frames[index], message)
-			index = (index + 1) % len(frames)
+			mu.Lock()
+			if !hidden {
+				line := fmt.Sprintf("%c %s", frames[index], status())
+				width = max(width, len(line))
+				_, _ = fmt.Fprintf(out, "\r%-*s", width, line)
+				index = (index + 1) % len(frames)
+			}
+			mu.Unlock()
 		}
 	}
 }
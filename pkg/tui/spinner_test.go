@@ -86,3 +86,19 @@ func TestRunWithSpinnerOnStderrPropagatesError(t *testing.T) {
 		t.Fatalf("expected %v, got %v", wantErr, err)
 	}
 }
+
+// TestRunWithStatusOnStderr verifies the task gets a hide func that is safe
+// to call (twice, even) when no spinner is shown.
+func TestRunWithStatusOnStderr(t *testing.T) {
+	result, err := RunWithStatusOnStderr(
+		func() string { return "Running..." },
+		func(hide func()) (any, error) {
+			hide()
+			hide()
+			return "ok", nil
+		},
+	)
+	if err != nil || result != "ok" {
+		t.Fatalf("got %v, %v; want ok, nil", result, err)
+	}
+}
@@ -0,0 +1,170 @@
+// Package benchcmd implements the `hulak bench` subcommand: sends a
+// request file, or each file of a directory, over and over for a while
+// and reports throughput and latency. New() builds the command for
+// registration by the top-level dispatch.
+package benchcmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/bench"
+	"github.com/xaaha/hulak/pkg/userFlags/cli"
+	"github.com/xaaha/hulak/pkg/userFlags/cliflags"
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// resultsFileName is the file --out writes into when it names a
+// directory.
+const resultsFileName = "hulak-bench.json"
+
+// New builds the `hulak bench` command.
+func New() *cli.Command {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	envFlagVal := cliflags.RegisterEnv(fs, "", "Environment to use")
+	var concurrency int
+	var rate float64
+	var duration time.Duration
+	var timeout time.Duration
+	var sshIdentity string
+	fs.IntVar(
+		&concurrency,
+		"concurrency",
+		bench.DefaultConcurrency,
+		"Requests in flight at once; with --rate, the most that may be",
+	)
+	fs.Float64Var(
+		&rate,
+		"rate",
+		0,
+		"Send this many requests per second instead of as many as --concurrency allows",
+	)
+	fs.DurationVar(
+		&duration,
+		"duration",
+		bench.DefaultDuration,
+		"How long to bench each file, e.g. 30s or 2m",
+	)
+	fs.DurationVar(
+		&timeout,
+		"timeout",
+		0,
+		"Per-request timeout, e.g. 5s (default 60s)",
+	)
+	out := cliflags.RegisterOutput(fs, "Write the results as JSON to this path")
+	fs.StringVar(&sshIdentity, "ssh-identity", "", "Path to SSH private key for vault decryption")
+
+	benchCmd := &cli.Command{
+		Name:  "bench",
+		Short: "Load test a request file or directory",
+		Long: "Send a request over and over for a while and report throughput, latency\n" +
+			"percentiles, errors by status code, and a latency histogram.\n\n" +
+			"The file is built once, with the same environment and secrets as 'hulak run'.\n" +
+			"A directory benches each of its files in turn.",
+		Examples: []*utils.CommandHelp{
+			{
+				Command:     "hulak bench path/to/file.yaml --env staging",
+				Description: "Send the request from 10 workers for 10s",
+			},
+			{
+				Command:     "hulak bench path/to/file.yaml --concurrency 50 --duration 1m",
+				Description: "Keep 50 requests in flight for a minute",
+			},
+			{
+				Command:     "hulak bench path/to/file.yaml --rate 200",
+				Description: "Send 200 requests per second, whatever the response times",
+			},
+			{
+				Command:     "hulak bench path/to/dir/ -o results/bench.json",
+				Description: "Bench each file in turn and save the results as JSON",
+			},
+		},
+		Flags: fs,
+		Args: []cli.ArgDef{
+			{Name: "path", Required: true, Desc: "File or directory to bench", Kind: "yaml"},
+		},
+	}
+
+	// Run is assigned after construction so the help fallback can reference
+	// benchCmd, as in runcmd.
+	benchCmd.Run = func(args []string) error {
+		if len(args) == 0 {
+			benchCmd.PrintHelp()
+			return nil
+		}
+
+		f, err := parseBenchArgs(benchCmdArgs{
+			Env:         *envFlagVal,
+			Concurrency: concurrency,
+			Rate:        rate,
+			Duration:    duration,
+			Timeout:     timeout,
+			Out:         *out,
+			SSHIdentity: sshIdentity,
+			Args:        args,
+		})
+		if err != nil {
+			return err
+		}
+		return bench.Execute(f)
+	}
+
+	return benchCmd
+}
+
+// benchCmdArgs bundles the values parsed from the `bench` subcommand
+// flagset plus the positional args.
+type benchCmdArgs struct {
+	Env         string
+	Concurrency int
+	Rate        float64
+	Duration    time.Duration
+	Timeout     time.Duration
+	Out         string
+	SSHIdentity string
+	Args        []string
+}
+
+// parseBenchArgs checks the flag values and builds a bench.Flags from
+// them.
+func parseBenchArgs(a benchCmdArgs) (*bench.Flags, error) { //nolint:gocritic // called once per invocation, like parseRunArgs
+	path := a.Args[0]
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot access %q: %w", path, err)
+	}
+	if a.Concurrency < 1 {
+		return nil, fmt.Errorf("--concurrency must be at least 1, got %d", a.Concurrency)
+	}
+	if a.Rate < 0 {
+		return nil, fmt.Errorf("--rate must not be negative, got %g", a.Rate)
+	}
+	if a.Duration <= 0 {
+		return nil, fmt.Errorf("--duration must be positive, got %s", a.Duration)
+	}
+	if a.Timeout < 0 {
+		return nil, fmt.Errorf("--timeout must not be negative, got %s", a.Timeout)
+	}
+
+	f := &bench.Flags{
+		Path:        path,
+		Concurrency: a.Concurrency,
+		Rate:        a.Rate,
+		Duration:    a.Duration,
+		Timeout:     a.Timeout,
+		SSHIdentity: a.SSHIdentity,
+	}
+	if a.Env != "" {
+		f.Env = a.Env
+		f.EnvSet = true
+	}
+	if a.Out != "" {
+		outPath, err := cliflags.ResolveOutputPath(a.Out, resultsFileName, ".json")
+		if err != nil {
+			return nil, err
+		}
+		f.Out = outPath
+	}
+	return f, nil
+}
@@ -0,0 +1,76 @@
+package benchcmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBenchArgs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.hk.yaml")
+	if err := os.WriteFile(file, []byte("kind: API"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	valid := benchCmdArgs{Concurrency: 10, Duration: time.Second, Args: []string{file}}
+
+	tests := []struct {
+		name    string
+		change  func(a *benchCmdArgs)
+		wantErr string
+	}{
+		{name: "defaults", change: func(*benchCmdArgs) {}},
+		{name: "rate", change: func(a *benchCmdArgs) { a.Rate = 50 }},
+		{name: "missing path", change: func(a *benchCmdArgs) { a.Args = []string{filepath.Join(dir, "nope.yaml")} }, wantErr: "cannot access"},
+		{name: "no concurrency", change: func(a *benchCmdArgs) { a.Concurrency = 0 }, wantErr: "--concurrency must be at least 1"},
+		{name: "negative rate", change: func(a *benchCmdArgs) { a.Rate = -1 }, wantErr: "--rate must not be negative"},
+		{name: "zero duration", change: func(a *benchCmdArgs) { a.Duration = 0 }, wantErr: "--duration must be positive"},
+		{name: "negative timeout", change: func(a *benchCmdArgs) { a.Timeout = -time.Second }, wantErr: "--timeout must not be negative"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := valid
+			tc.change(&a)
+			f, err := parseBenchArgs(a)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("parseBenchArgs() error = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if f.Path != file || f.Concurrency != a.Concurrency || f.Rate != a.Rate || f.Duration != a.Duration {
+				t.Errorf("Flags = %+v, want the args passed through", f)
+			}
+			if f.EnvSet || f.Out != "" {
+				t.Errorf("Flags = %+v, want no env and no output", f)
+			}
+		})
+	}
+}
+
+// TestParseBenchArgsEnvAndOut verifies --env marks the env as set and
+// --out pointing at a directory gets the results file name.
+func TestParseBenchArgsEnvAndOut(t *testing.T) {
+	dir := t.TempDir()
+	f, err := parseBenchArgs(benchCmdArgs{
+		Env:         "staging",
+		Concurrency: 1,
+		Duration:    time.Second,
+		Out:         dir,
+		Args:        []string{dir},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Env != "staging" || !f.EnvSet {
+		t.Errorf("Env = %q, EnvSet = %v, want staging and true", f.Env, f.EnvSet)
+	}
+	if want := filepath.Join(dir, resultsFileName); f.Out != want {
+		t.Errorf("Out = %q, want %q", f.Out, want)
+	}
+}
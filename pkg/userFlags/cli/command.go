@@ -48,7 +48,7 @@ var PathFlagNames = map[string]bool{
 	"out": true, "o": true, // cliflags.RegisterOutput
 	"file-path": true, "fp": true, // root --file-path/--fp
 	"file": true, "f": true, // root --file/-f
-	"ssh-identity": true, // run/init
+	"ssh-identity": true, // run/init/bench
 	"dir":          true, // root --dir
 	"dirseq":       true, // root --dirseq
 }
@@ -25,6 +25,8 @@ var exampleType = map[string]string{
 	"urlencoded": "example-urlencoded.hk.yaml",
 	"graphql":    "example-graphql.hk.yaml",
 	"auth":       "example-auth.hk.yaml",
+	"websocket":  "example-websocket.hk.yaml",
+	"grpc":       "example-grpc.hk.yaml",
 	"options":    utils.OptionsReference,
 }
 
@@ -35,6 +37,7 @@ var exampleType = map[string]string{
 var exampleAliases = map[string]string{
 	"gql":                "graphql",
 	"urlencodedformdata": "urlencoded",
+	"ws":                 "websocket",
 }
 
 func New() *cli.Command {
@@ -49,9 +52,9 @@ func New() *cli.Command {
 		Short: "Scaffold an example request file",
 		Long: "Scaffold a starter request file into the current directory.\n\n" +
 			"Each type writes a self-contained, schema-valid file that runs against a\n" +
-			"public test API (jsonplaceholder, httpbin, trevorblades countries). The\n" +
-			"'options' type writes a reference card listing every available request\n" +
-			"field — it's not runnable on its own.\n\n" +
+			"public test API (jsonplaceholder, httpbin, trevorblades countries, the\n" +
+			"websocket.org echo server, grpcb.in). The 'options' type writes a reference card\n" +
+			"listing every available request field — it's not runnable on its own.\n\n" +
 			"Use -o/--out to write somewhere other than the current directory. Pass a\n" +
 			"directory to keep the canonical filename, or a full path to rename. Parent\n" +
 			"directories are created on demand.\n\n" +
@@ -63,6 +66,8 @@ func New() *cli.Command {
 			{Command: "hulak example urlencoded", Description: "Scaffold an application/x-www-form-urlencoded POST"},
 			{Command: "hulak example graphql", Description: "Scaffold a GraphQL query (alias: gql)"},
 			{Command: "hulak example auth", Description: "Scaffold an OAuth 2.0 flow template"},
+			{Command: "hulak example websocket", Description: "Scaffold a WebSocket session (alias: ws)"},
+			{Command: "hulak example grpc", Description: "Scaffold a gRPC call using server reflection"},
 			{Command: "hulak example options", Description: "Scaffold the reference card of every request field"},
 			{Command: "hulak example api -o requests/", Description: "Write into a subdirectory (canonical filename)"},
 			{Command: "hulak example api -o requests/health.hk.yaml", Description: "Rename on write"},
@@ -70,7 +75,7 @@ func New() *cli.Command {
 		},
 		Flags: fs,
 		Args: []cli.ArgDef{
-			{Name: "type", Desc: "Example type to scaffold (api, formdata, urlencoded, graphql, auth, options)"},
+			{Name: "type", Desc: "Example type to scaffold (api, formdata, urlencoded, graphql, auth, websocket, grpc, options)"},
 		},
 		Run: func(args []string) error {
 			if len(args) == 0 {
@@ -25,6 +25,9 @@ func TestScaffoldExample_AllTypes(t *testing.T) {
 		{"graphql", "example-graphql.hk.yaml"},
 		{"gql", "example-graphql.hk.yaml"}, // alias → graphql
 		{"auth", "example-auth.hk.yaml"},
+		{"websocket", "example-websocket.hk.yaml"},
+		{"ws", "example-websocket.hk.yaml"}, // alias → websocket
+		{"grpc", "example-grpc.hk.yaml"},
 		{"options", utils.OptionsReference},
 	}
 
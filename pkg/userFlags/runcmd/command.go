@@ -8,6 +8,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/xaaha/hulak/pkg/runner"
@@ -16,6 +17,29 @@ import (
 	"github.com/xaaha/hulak/pkg/utils"
 )
 
+// reportList collects repeated `--report format=path` flags.
+type reportList []runner.Report
+
+func (r *reportList) String() string {
+	if r == nil || len(*r) == 0 {
+		return ""
+	}
+	specs := make([]string, len(*r))
+	for i, rep := range *r {
+		specs[i] = rep.Format + "=" + rep.Path
+	}
+	return strings.Join(specs, ", ")
+}
+
+func (r *reportList) Set(v string) error {
+	rep, err := runner.ParseReport(v)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rep)
+	return nil
+}
+
 // New builds the `hulak run` command.
 func New() *cli.Command {
 	fs := flag.NewFlagSet("run", flag.ContinueOnError)
@@ -24,6 +48,12 @@ func New() *cli.Command {
 	var debug bool
 	var quiet bool
 	var timeout time.Duration
+	var retries int
+	var reports reportList
+	var data string
+	var stream bool
+	var cookieJar string
+	var timings bool
 	var sshIdentity string
 	fs.BoolVar(&sequential, "sequential", false, "Run directory files sequentially")
 	fs.BoolVar(&sequential, "seq", false, "Run directory files sequentially")
@@ -45,6 +75,41 @@ func New() *cli.Command {
 		0,
 		"Per-request timeout, e.g. 5m or 90s (default 60s)",
 	)
+	fs.IntVar(
+		&retries,
+		"retries",
+		0,
+		"Retry failed requests up to N times on 429/502/503/504 and network errors",
+	)
+	fs.Var(
+		&reports,
+		"report",
+		"Write a run report as junit=path.xml or json=path.json (repeatable)",
+	)
+	fs.StringVar(
+		&data,
+		"data",
+		"",
+		"Run each request once per row of this CSV or JSON file",
+	)
+	fs.BoolVar(
+		&stream,
+		"stream",
+		false,
+		"Save response bodies as they arrive; a single file's also goes to stdout when piped",
+	)
+	fs.StringVar(
+		&cookieJar,
+		"cookie-jar",
+		"",
+		"Share cookies between the run's requests, loaded from and saved to this file",
+	)
+	fs.BoolVar(
+		&timings,
+		"timings",
+		false,
+		"Add DNS, connect, TLS, first byte, and transfer times to the run summary",
+	)
 	fs.StringVar(&sshIdentity, "ssh-identity", "", "Path to SSH private key for vault decryption")
 
 	runCmd := &cli.Command{
@@ -67,6 +132,30 @@ func New() *cli.Command {
 				Command:     "hulak run path/to/dir/ --sequential",
 				Description: "Run directory files sequentially",
 			},
+			{
+				Command:     "hulak run path/to/dir/ --retries 2",
+				Description: "Retry each failing request up to twice with backoff",
+			},
+			{
+				Command:     "hulak run path/to/dir/ --report junit=reports/hulak.xml",
+				Description: "Write a JUnit XML report for CI",
+			},
+			{
+				Command:     "hulak run path/to/file.yaml --data users.csv",
+				Description: "Send the request once per row, with columns as template variables",
+			},
+			{
+				Command:     "hulak run path/to/export.yaml --stream > export.json",
+				Description: "Stream a large response to disk and stdout as it arrives",
+			},
+			{
+				Command:     "hulak run path/to/dir/ --seq --cookie-jar cookies.jar",
+				Description: "Keep the session cookie of a login request for the rest of the run and the next",
+			},
+			{
+				Command:     "hulak run path/to/dir/ --timings",
+				Description: "Show where each request's time went, to tell a slow network from a slow server",
+			},
 			{
 				Command:     "hulak run path/to/file.yaml --ssh-identity ~/.ssh/work_ed25519",
 				Description: "Use a specific SSH key for vault decryption",
@@ -107,6 +196,12 @@ func New() *cli.Command {
 			DryRun:      *dryRun,
 			Show:        *show,
 			Timeout:     timeout,
+			Retries:     retries,
+			Reports:     reports,
+			Data:        data,
+			Stream:      stream,
+			CookieJar:   cookieJar,
+			Timings:     timings,
 			SSHIdentity: sshIdentity,
 			Out:         *out,
 			Args:        args,
@@ -135,6 +230,12 @@ type runCmdArgs struct {
 	DryRun      bool
 	Show        bool
 	Timeout     time.Duration
+	Retries     int
+	Reports     []runner.Report
+	Data        string
+	Stream      bool
+	CookieJar   string
+	Timings     bool
 	SSHIdentity string
 	Out         string
 	Args        []string
@@ -150,6 +251,26 @@ func parseRunArgs(a runCmdArgs) (*runner.Flags, error) { //nolint:gocritic // ca
 		return nil, fmt.Errorf("cannot access %q: %w", path, err)
 	}
 
+	if a.Retries < 0 {
+		return nil, fmt.Errorf("--retries must not be negative, got %d", a.Retries)
+	}
+
+	if a.Data != "" {
+		dataInfo, err := os.Stat(a.Data)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access --data %q: %w", a.Data, err)
+		}
+		if dataInfo.IsDir() {
+			return nil, fmt.Errorf("--data %q is a directory, not a CSV or JSON file", a.Data)
+		}
+	}
+
+	if a.CookieJar != "" {
+		if jarInfo, err := os.Stat(a.CookieJar); err == nil && jarInfo.IsDir() {
+			return nil, fmt.Errorf("--cookie-jar %q is a directory, not a file", a.CookieJar)
+		}
+	}
+
 	if info.IsDir() && a.Out != "" {
 		return nil, errors.New("--out is only valid when running a single file, not a directory")
 	}
@@ -160,6 +281,12 @@ func parseRunArgs(a runCmdArgs) (*runner.Flags, error) { //nolint:gocritic // ca
 		DryRun:      a.DryRun,
 		Show:        a.Show,
 		Timeout:     a.Timeout,
+		Retries:     a.Retries,
+		Reports:     a.Reports,
+		Data:        a.Data,
+		Stream:      a.Stream,
+		CookieJar:   a.CookieJar,
+		Timings:     a.Timings,
 		SSHIdentity: a.SSHIdentity,
 		Out:         a.Out,
 	}
@@ -177,6 +177,94 @@ func TestParseRunArgsTimeoutPlumbed(t *testing.T) {
 	}
 }
 
+// TestParseRunArgsRetriesPlumbed verifies --retries lands on runner.Flags
+// and that a negative count is rejected before any request runs.
+func TestParseRunArgsRetriesPlumbed(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test.hk.yaml")
+	if err := os.WriteFile(tmpFile, []byte("kind: API"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parseRunArgs(runCmdArgs{Retries: 2, Args: []string{tmpFile}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Retries != 2 {
+		t.Errorf("Retries = %d, want 2", f.Retries)
+	}
+
+	if _, err := parseRunArgs(runCmdArgs{Retries: -1, Args: []string{tmpFile}}); err == nil {
+		t.Error("expected an error for negative --retries, got nil")
+	}
+}
+
+// TestParseRunArgsDataPlumbed verifies --data lands on runner.Flags and that
+// a missing or directory dataset is rejected before any request runs.
+func TestParseRunArgsDataPlumbed(t *testing.T) {
+	dir := t.TempDir()
+	tmpFile := filepath.Join(dir, "test.hk.yaml")
+	dataFile := filepath.Join(dir, "users.csv")
+	for _, p := range []string{tmpFile, dataFile} {
+		if err := os.WriteFile(p, []byte("kind: API"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := parseRunArgs(runCmdArgs{Data: dataFile, Args: []string{tmpFile}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.Data != dataFile {
+		t.Errorf("Data = %q, want %q", f.Data, dataFile)
+	}
+
+	for _, bad := range []string{filepath.Join(dir, "missing.csv"), dir} {
+		if _, err := parseRunArgs(runCmdArgs{Data: bad, Args: []string{tmpFile}}); err == nil {
+			t.Errorf("expected an error for --data %q, got nil", bad)
+		}
+	}
+}
+
+// TestParseRunArgsCookieJarPlumbed verifies --cookie-jar lands on
+// runner.Flags, that a jar that doesn't exist yet is accepted, and that a
+// directory is rejected.
+func TestParseRunArgsCookieJarPlumbed(t *testing.T) {
+	dir := t.TempDir()
+	tmpFile := filepath.Join(dir, "test.hk.yaml")
+	if err := os.WriteFile(tmpFile, []byte("kind: API"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jar := filepath.Join(dir, "cookies.json")
+	f, err := parseRunArgs(runCmdArgs{CookieJar: jar, Args: []string{tmpFile}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if f.CookieJar != jar {
+		t.Errorf("CookieJar = %q, want %q", f.CookieJar, jar)
+	}
+
+	if _, err := parseRunArgs(runCmdArgs{CookieJar: dir, Args: []string{tmpFile}}); err == nil {
+		t.Errorf("expected an error for --cookie-jar %q, got nil", dir)
+	}
+}
+
+// TestParseRunArgsTimingsPlumbed verifies --timings lands on runner.Flags.
+func TestParseRunArgsTimingsPlumbed(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "test.hk.yaml")
+	if err := os.WriteFile(tmpFile, []byte("kind: API"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parseRunArgs(runCmdArgs{Timings: true, Args: []string{tmpFile}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !f.Timings {
+		t.Error("Timings should mirror the timings arg")
+	}
+}
+
 // TestParseRunArgsOutPlumbed verifies -o value lands on runner.Flags.Out for a
 // single-file target.
 func TestParseRunArgsOutPlumbed(t *testing.T) {
@@ -237,3 +325,20 @@ func TestParseRunArgsNoEnv(t *testing.T) {
 		t.Error("EnvSet should be false when no env is provided")
 	}
 }
+
+// TestReportListSet verifies repeated --report values accumulate and that a
+// malformed value is rejected at flag parse time.
+func TestReportListSet(t *testing.T) {
+	var reports reportList
+	for _, v := range []string{"junit=out.xml", "json=out.json"} {
+		if err := reports.Set(v); err != nil {
+			t.Fatalf("Set(%q): %v", v, err)
+		}
+	}
+	if reports.String() != "junit=out.xml, json=out.json" {
+		t.Errorf("String() = %q", reports.String())
+	}
+	if err := reports.Set("xml"); err == nil {
+		t.Error("expected an error for a value without format=path")
+	}
+}
@@ -1,4 +1,4 @@
-// Builds the root command tree. Heavy leaves (run, init, doctor, gql,
+// Builds the root command tree. Heavy leaves (run, bench, init, doctor, gql,
 // example, secrets) come from their own subpackages via New() constructors;
 // trivial ones (version, migrate, help) stay here because a folder per
 // 20-line handler is more friction than it's worth.
@@ -8,6 +8,7 @@ import (
 	"flag"
 
 	"github.com/xaaha/hulak/pkg/migration"
+	"github.com/xaaha/hulak/pkg/userFlags/benchcmd"
 	"github.com/xaaha/hulak/pkg/userFlags/cli"
 	"github.com/xaaha/hulak/pkg/userFlags/doctor"
 	"github.com/xaaha/hulak/pkg/userFlags/example"
@@ -52,6 +53,7 @@ func subCommands() *cli.Command {
 
 	root.SubCommands = []*cli.Command{
 		runcmd.New(),
+		benchcmd.New(),
 		newVersionCmd(),
 		initcmd.New(),
 		example.New(),
@@ -9,6 +9,15 @@ const (
 	TemplateFuncOs         = "os"
 )
 
+// CapturedVarsKey is the template key holding values captured by earlier
+// files in a sequential run: {{.captured.userId}}.
+const CapturedVarsKey = "captured"
+
+// ResponseSourceKey is the key under which a run hands getValueOf its
+// actions.ResponseSource, through the secrets map every template of the
+// run is rendered with. It is not meant to be read by templates.
+const ResponseSourceKey = "_hulakResponseSource"
+
 // templateFuncNames is the canonical set of template action names. It is the
 // single source the name resolver derives its variants from — add a new action
 // here and every case/underscore spelling of it resolves automatically.
@@ -152,3 +152,20 @@ func HelpfulError(title, heading string, bullets []string) error {
 	}
 	return errors.New(strings.TrimRight(b.String(), "\n"))
 }
+
+// FormatBytes renders a byte count for humans: "512 B", "48.2 KB",
+// "310.5 MB", "1.2 GB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	size, suffix := float64(n)/unit, "KB"
+	for _, next := range []string{"MB", "GB"} {
+		if size < unit {
+			break
+		}
+		size, suffix = size/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", size, suffix)
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 )
@@ -34,3 +35,22 @@ func SiblingPath(currentFile, arg string) (string, bool) {
 	suffix := arg[len("*"):]
 	return filepath.Join(filepath.Dir(currentFile), stem+suffix), true
 }
+
+// ResolveRequestFile resolves a file path written inside a request file the
+// same way getFile does: "*<suffix>" names a file next to currentFile, and
+// anything else goes through ResolveProjectFile. Either way the file must
+// exist inside the project root. Returns the absolute path.
+func ResolveRequestFile(currentFile, arg string) (string, error) {
+	sibling, ok := SiblingPath(currentFile, arg)
+	if !ok {
+		return ResolveProjectFile(arg)
+	}
+	if arg == "*" {
+		return "", fmt.Errorf(`%q needs an extension, e.g. "*.png"`, arg)
+	}
+	abs, err := filepath.Abs(sibling)
+	if err != nil {
+		return "", err
+	}
+	return ResolveProjectFile(abs)
+}
@@ -152,12 +152,13 @@ func GetEnvFiles() ([]string, error) {
 }
 
 // ConvertKeysToLowerCase converts all keys in a map to lowercase recursively
-// except "variables" as Graphql variables is case-sensitive
+// except "variables" as Graphql variables is case-sensitive, and "message",
+// a gRPC request message whose field names are too
 func ConvertKeysToLowerCase(dict map[string]any) map[string]any {
 	loweredMap := make(map[string]any)
 	for key, val := range dict {
-		// for graphql variables are case sensitive
-		if key == "variables" {
+		// graphql variables and grpc messages are case sensitive
+		if key == "variables" || key == "message" {
 			loweredMap[key] = val
 			continue
 		}
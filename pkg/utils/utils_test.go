@@ -187,6 +187,17 @@ func TestToLowercaseMap(t *testing.T) {
 				"nilkey":   nil,
 			},
 		},
+		{
+			name: "gRPC message keeps its field names",
+			input: map[string]any{
+				"Method":  "pkg.Users/Get",
+				"message": map[string]any{"userId": "7", "Filter": map[string]any{"pageSize": 2}},
+			},
+			expected: map[string]any{
+				"method":  "pkg.Users/Get",
+				"message": map[string]any{"userId": "7", "Filter": map[string]any{"pageSize": 2}},
+			},
+		},
 		{
 			name: "Nested empty map",
 			input: map[string]any{
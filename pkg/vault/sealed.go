@@ -0,0 +1,40 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// Contains reading and writing files encrypted to the store's recipients,
+// for data that belongs with the vault but not in store.age.
+
+// ReadSealedFile reads the file at path and decrypts it with the same
+// identity resolution as ReadStore. A missing file returns an error
+// satisfying os.IsNotExist.
+func ReadSealedFile(path string) ([]byte, error) {
+	cipherText, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	_, plainText, err := resolveAndDecrypt(cipherText)
+	if err != nil {
+		return nil, err
+	}
+	return plainText, nil
+}
+
+// WriteSealedFile encrypts plainText to .hulak/recipients.txt and writes it
+// atomically to path, readable only by the owner.
+func WriteSealedFile(path string, plainText []byte) error {
+	recipients, err := LoadRecipients()
+	if err != nil {
+		return fmt.Errorf("failed to load recipients: %w", err)
+	}
+	cipherText, err := EncryptText(plainText, recipients...)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return utils.AtomicWriteFile(path, cipherText, utils.SecretPer, utils.DirPer)
+}
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// Contains the OAuth token cache: the last token each Auth-kind file got,
+// encrypted to the same recipients as the store.
+
+// TokenCacheFile is the basename of the token cache inside .hulak/. It is
+// separate from store.age so tokens never show up in `hulak secrets` and
+// the cache can be deleted at any time; the next run signs in again.
+const TokenCacheFile = "tokens.age"
+
+// CachedToken is the token response an Auth-kind file got, with its expiry.
+type CachedToken struct {
+	// Response is the token endpoint's JSON response, the same object
+	// getValueOf reads from the file's _response.json.
+	Response map[string]any `json:"response"`
+	// ExpiresAt is when the access token expires, from expires_in. Zero
+	// when the response had no expires_in.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// ExpiresBefore reports whether the token expires before t. A token without
+// an expiry never does.
+func (c CachedToken) ExpiresBefore(t time.Time) bool {
+	return !c.ExpiresAt.IsZero() && c.ExpiresAt.Before(t)
+}
+
+// TokenCache holds the cached tokens by TokenKey.
+type TokenCache map[string]CachedToken
+
+// TokenKey returns the cache key of an Auth-kind file in an environment.
+// file is project-root relative, so the key is the same on every machine.
+func TokenKey(env, file string) string {
+	return env + ":" + filepath.ToSlash(file)
+}
+
+// TokenCachePath returns the absolute path to .hulak/tokens.age in the
+// project root.
+func TokenCachePath() (string, error) {
+	markerPath, err := utils.GetProjectMarker()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(markerPath, TokenCacheFile), nil
+}
+
+// ReadTokenCache reads and decrypts the token cache with the same identity
+// resolution as ReadStore. A missing file returns an empty cache.
+func ReadTokenCache() (TokenCache, error) {
+	path, err := TokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+	plainText, err := ReadSealedFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return TokenCache{}, nil
+		}
+		return nil, fmt.Errorf("failed to read token cache: %w", err)
+	}
+	cache := TokenCache{}
+	if err := json.Unmarshal(plainText, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse token cache: %w", err)
+	}
+	return cache, nil
+}
+
+// WriteTokenCache encrypts the cache to .hulak/recipients.txt and writes
+// it atomically. Hold WithStoreLock around a read-modify-write.
+func WriteTokenCache(cache TokenCache) error {
+	path, err := TokenCachePath()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(cache); err != nil {
+		return fmt.Errorf("failed to marshal token cache: %w", err)
+	}
+	if err := WriteSealedFile(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write token cache: %w", err)
+	}
+	return nil
+}
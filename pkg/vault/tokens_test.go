@@ -0,0 +1,79 @@
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+func TestTokenCacheRoundTrip(t *testing.T) {
+	projectDir := setupHulakProject(t)
+	id, _ := age.GenerateX25519Identity()
+	t.Setenv(utils.MasterKey, id.String())
+	if err := EnsureRecipientsFile(id.Recipient().String(), "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	empty, err := ReadTokenCache()
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("ReadTokenCache() on a new project = %v, %v; want an empty cache", empty, err)
+	}
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	key := TokenKey("prod", filepath.Join("auth", "login.hk.yaml"))
+	cache := TokenCache{key: {
+		Response:  map[string]any{"access_token": "tok-1", "refresh_token": "ref-1"},
+		ExpiresAt: expires,
+	}}
+	if err := WriteTokenCache(cache); err != nil {
+		t.Fatalf("WriteTokenCache() error: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(projectDir, utils.HiddenProjectName, TokenCacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecryptText(raw, id); err != nil {
+		t.Fatalf("tokens.age is not encrypted to the recipients: %v", err)
+	}
+
+	got, err := ReadTokenCache()
+	if err != nil {
+		t.Fatalf("ReadTokenCache() error: %v", err)
+	}
+	if key != "prod:auth/login.hk.yaml" {
+		t.Errorf("TokenKey() = %q", key)
+	}
+	token := got[key]
+	if token.Response["access_token"] != "tok-1" || token.Response["refresh_token"] != "ref-1" {
+		t.Errorf("Response = %v", token.Response)
+	}
+	if !token.ExpiresAt.Equal(expires) {
+		t.Errorf("ExpiresAt = %v, want %v", token.ExpiresAt, expires)
+	}
+}
+
+func TestCachedTokenExpiresBefore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token CachedToken
+		want  bool
+	}{
+		{"expired", CachedToken{ExpiresAt: now.Add(-time.Minute)}, true},
+		{"valid", CachedToken{ExpiresAt: now.Add(time.Hour)}, false},
+		{"no expiry", CachedToken{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.ExpiresBefore(now); got != tt.want {
+				t.Errorf("ExpiresBefore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package yamlparser
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/xaaha/hulak/pkg/httpclient"
 	"github.com/xaaha/hulak/pkg/utils"
 )
 
@@ -53,6 +55,23 @@ type APIInfo struct {
 	URLParams map[string]string
 	Method    string
 	URL       string
+	// Signing signs the request as it is sent, each attempt anew: sigv4,
+	// hmac, or digest. Nil for requests that aren't signed.
+	Signing *RequestAuth
+	// Bearer is the token of an `auth: use:` section, read as the request
+	// is sent. Nil for other requests.
+	Bearer *BearerToken
+	// TLS holds the request's TLS settings, e.g. a client certificate.
+	// Nil uses the defaults.
+	TLS *tls.Config
+	// Proxy routes the request through a proxy. Nil uses the proxy
+	// environment variables.
+	Proxy *httpclient.Proxy
+	// Jar is the run's cookie jar when the request uses it. See
+	// APICallFile.UsesJar.
+	Jar *httpclient.Jar
+	// Redirects is the request's redirect policy. Nil uses the default.
+	Redirects *httpclient.RedirectPolicy
 }
 
 type URL string
@@ -71,6 +90,48 @@ type APICallFile struct {
 	Body      *Body             `json:"body,omitempty"      yaml:"body"`
 	Method    HTTPMethodType    `json:"method,omitempty"    yaml:"method"`
 	URL       URL               `json:"url,omitempty"       yaml:"url"`
+	// Assert holds optional response checks. See Assert.
+	Assert *Assert `json:"assert,omitempty" yaml:"assert"`
+	// Capture names response values to expose to later files in the same
+	// sequential run. See Capture.
+	Capture Captures `json:"capture,omitempty" yaml:"capture"`
+	// Retry resends the request on retryable failures. See Retry.
+	Retry *Retry `json:"retry,omitempty" yaml:"retry"`
+	// Poll re-sends the request until its response meets a condition. See
+	// Poll.
+	Poll *Poll `json:"poll,omitempty" yaml:"poll"`
+	// Events limits how long an event stream response is read. See Events.
+	Events *Events `json:"events,omitempty" yaml:"events"`
+	// Auth adds credentials to the request, e.g. the token of an Auth-kind
+	// file. See RequestAuth.
+	Auth *RequestAuth `json:"auth,omitempty" yaml:"auth"`
+	// TLS sets a client certificate, CA bundles, and other TLS settings.
+	// See TLSConfig.
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls"`
+	// Proxy routes the request through a proxy. See ProxyConfig.
+	Proxy *ProxyConfig `json:"proxy,omitempty" yaml:"proxy"`
+	// Cookies sends the run's cookies with the request and keeps the ones
+	// it gets back for later requests. See UsesJar.
+	Cookies *bool `json:"cookies,omitempty" yaml:"cookies"`
+	// Redirects says which redirects the request follows. See Redirects.
+	Redirects *Redirects `json:"redirects,omitempty" yaml:"redirects"`
+
+	// clientTLS and clientProxy are TLS and Proxy built over the
+	// environment's settings, and bearer the token of a use section, by
+	// the FinalStruct functions.
+	clientTLS   *tls.Config
+	clientProxy *httpclient.Proxy
+	bearer      *BearerToken
+}
+
+// UsesJar reports whether the request uses the run's cookie jar. A file's
+// cookies setting wins; without one, it does when every request of the run
+// does (hulak run --cookie-jar).
+func (user *APICallFile) UsesJar(runJar bool) bool {
+	if user.Cookies == nil {
+		return runJar
+	}
+	return *user.Cookies
 }
 
 // IsValid checks whether the user has valid file
@@ -101,6 +162,48 @@ func (user *APICallFile) IsValid(filePath string) (bool, error) {
 			user.Body,
 		)
 	}
+
+	if valid, err := user.Assert.IsValid(); !valid {
+		return false, fmt.Errorf("invalid assert section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Capture.IsValid(); !valid {
+		return false, fmt.Errorf("invalid capture section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Retry.IsValid(); !valid {
+		return false, fmt.Errorf("invalid retry section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Poll.IsValid(); !valid {
+		return false, fmt.Errorf("invalid poll section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Events.IsValid(); !valid {
+		return false, fmt.Errorf("invalid events section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Auth.IsValid(); !valid {
+		return false, fmt.Errorf("invalid auth section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.TLS.IsValid(); !valid {
+		return false, fmt.Errorf("invalid tls section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Proxy.IsValid(); !valid {
+		return false, fmt.Errorf("invalid proxy section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Redirects.IsValid(); !valid {
+		return false, fmt.Errorf("invalid redirects setting in '%s': %w", filePath, err)
+	}
+	if user.Events != nil && user.Poll != nil {
+		return false, fmt.Errorf("invalid file '%s': %w", filePath, errEventsWithPoll)
+	}
+	if user.Poll != nil && user.Body != nil && user.Body.Graphql.IsSubscription() {
+		return false, fmt.Errorf("invalid file '%s': %w", filePath, errSubscriptionWithPoll)
+	}
 	return true, nil
 }
 
@@ -111,7 +214,10 @@ func (user *APICallFile) PrepareStruct() (APIInfo, error) {
 		return APIInfo{}, fmt.Errorf("%s: %w", utils.ErrBodyEncoding, err)
 	}
 
-	if contentType != "" {
+	// A binary body's detected type is only a default; the user's own
+	// Content-Type header wins. Form bodies always need the generated one
+	// (it carries the multipart boundary).
+	if contentType != "" && (user.Body.Binary == "" || !hasHeader(user.Headers, "content-type")) {
 		if user.Headers == nil {
 			user.Headers = make(map[string]string)
 		}
@@ -124,17 +230,43 @@ func (user *APICallFile) PrepareStruct() (APIInfo, error) {
 		URLParams: user.URLParams,
 		Headers:   user.Headers,
 		Body:      body,
+		Signing:   user.signing(),
+		Bearer:    user.bearer,
+		TLS:       user.clientTLS,
+		Proxy:     user.clientProxy,
+		Redirects: user.Redirects.Policy(),
 	}, nil
 }
 
+// signing returns the auth section when it signs requests as they are sent.
+func (user *APICallFile) signing() *RequestAuth {
+	if !user.Auth.Signs() {
+		return nil
+	}
+	return user.Auth
+}
+
+// hasHeader reports whether headers has name, case-insensitively.
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
 // Body represents Body in a yaml file
-// binary type is not yet configured
 // Only one is possible that could be passed
 type Body struct {
+	// FormData values starting with FormFilePrefix ("@file:path") are
+	// uploaded as file parts; the rest are text fields.
 	FormData           map[string]string `json:"formdata,omitempty"           yaml:"formdata"`
 	URLEncodedFormData map[string]string `json:"urlencodedformdata,omitempty" yaml:"urlencodedformdata"`
 	Graphql            *GraphQl          `json:"graphql,omitempty"            yaml:"graphql"`
 	Raw                string            `json:"raw,omitempty"                yaml:"raw"`
+	// Binary is a file sent as the whole request body, streamed from disk.
+	Binary string `json:"binary,omitempty" yaml:"binary"`
 }
 
 // IsValid checks whether body is valid when,
@@ -218,6 +350,13 @@ func (b *Body) EncodeBody() (io.Reader, string, error) {
 	case b.Raw != "":
 		body = strings.NewReader(b.Raw)
 
+	case b.Binary != "":
+		encodedBody, ct, err := encodeBinaryBody(b.Binary)
+		if err != nil {
+			return nil, "", fmt.Errorf("error encoding binary body: %w", err)
+		}
+		body, contentType = encodedBody, ct
+
 	default:
 		return nil, "", errors.New("no valid body type provided")
 	}
@@ -248,11 +387,15 @@ func EncodeXwwwFormURLBody(keyValue map[string]string) (io.Reader, error) {
 }
 
 // EncodeFormData encodes multipart/form-data other than x-www-form-urlencoded,
-// Returns the payload, Content-Type for the headers and error
+// Returns the payload, Content-Type for the headers and error.
+// Values starting with FormFilePrefix are uploaded as file parts.
 func EncodeFormData(keyValue map[string]string) (io.Reader, string, error) {
 	if len(keyValue) == 0 {
 		return nil, "", errors.New("no key-value pairs to encode")
 	}
+	if hasFormFiles(keyValue) {
+		return encodeMultipartFiles(keyValue)
+	}
 
 	payload := &bytes.Buffer{}
 	writer := multipart.NewWriter(payload)
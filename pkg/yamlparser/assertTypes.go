@@ -0,0 +1,156 @@
+package yamlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Assert represents the optional `assert:` section of a request file.
+// Every check that is set must hold for the request to count as passed;
+// unset checks are skipped. A file with no assert section passes on any
+// response, same as before assertions existed.
+type Assert struct {
+	// Status lists the acceptable status codes. `status: 200` and
+	// `status: [200, 201]` are both accepted.
+	Status StatusCodes `json:"status,omitempty"       yaml:"status"`
+	// Headers maps a header name (case insensitive) to text its value must
+	// contain. An empty value only requires the header to be present.
+	Headers map[string]string `json:"headers,omitempty"      yaml:"headers"`
+	// JSON checks values inside a JSON response body. Paths use the same
+	// dot/bracket syntax as getValueOf.
+	JSON []JSONAssertion `json:"json,omitempty"         yaml:"json"`
+	// Body lists regular expressions the raw response body must match.
+	Body []string `json:"body,omitempty"         yaml:"body"`
+	// MaxDuration fails the request when it takes longer than this Go
+	// duration, e.g. "500ms" or "2s".
+	MaxDuration string `json:"max_duration,omitempty" yaml:"max_duration"`
+}
+
+// JSONAssertion is one check against a value in the JSON response body.
+// Path is required. Equals compares the stringified values, so `42` and
+// `"42"` are equal. Exists asserts presence (true) or absence (false) of the
+// path. Matches is a regex applied to the stringified value.
+type JSONAssertion struct {
+	Path    string `json:"path"              yaml:"path"`
+	Equals  any    `json:"equals,omitempty"  yaml:"equals"`
+	Exists  *bool  `json:"exists,omitempty"  yaml:"exists"`
+	Matches string `json:"matches,omitempty" yaml:"matches"`
+}
+
+// StatusCodes is a list of HTTP status codes that also decodes from a
+// single scalar, so `status: 200` reads the same as `status: [200]`.
+type StatusCodes []int
+
+// UnmarshalYAML accepts a single code or a list of codes. Codes may be
+// numbers or numeric strings (templated values often arrive as strings).
+func (s *StatusCodes) UnmarshalYAML(unmarshal func(any) error) error {
+	var raw any
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	items, ok := raw.([]any)
+	if !ok {
+		items = []any{raw}
+	}
+
+	codes := make([]int, 0, len(items))
+	for _, item := range items {
+		code, err := toStatusCode(item)
+		if err != nil {
+			return err
+		}
+		codes = append(codes, code)
+	}
+	*s = codes
+	return nil
+}
+
+// toStatusCode converts a decoded YAML scalar into an HTTP status code.
+func toStatusCode(v any) (int, error) {
+	var code int
+	switch val := v.(type) {
+	case int:
+		code = val
+	case int64:
+		code = int(val)
+	case uint64:
+		code = int(val) //nolint:gosec // G115: range-checked below
+	case float64:
+		code = int(val)
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return 0, fmt.Errorf("invalid status code %q", val)
+		}
+		code = parsed
+	default:
+		return 0, fmt.Errorf("invalid status code %v", v)
+	}
+	if code < 100 || code > 599 {
+		return 0, fmt.Errorf("status code %d is out of range (100-599)", code)
+	}
+	return code, nil
+}
+
+// IsValid checks that every configured assertion can be evaluated: regexes
+// compile, JSON checks have a path and at least one expectation, and
+// max_duration is a positive Go duration. A nil Assert is valid.
+func (a *Assert) IsValid() (bool, error) {
+	if a == nil {
+		return true, nil
+	}
+
+	for i, check := range a.JSON {
+		if strings.TrimSpace(check.Path) == "" {
+			return false, fmt.Errorf("assert.json[%d]: path is required", i)
+		}
+		if check.Equals == nil && check.Exists == nil && check.Matches == "" {
+			return false, fmt.Errorf(
+				"assert.json[%d] (%s): set at least one of equals, exists, or matches",
+				i, check.Path,
+			)
+		}
+		if check.Matches != "" {
+			if _, err := regexp.Compile(check.Matches); err != nil {
+				return false, fmt.Errorf("assert.json[%d] (%s): invalid matches: %w", i, check.Path, err)
+			}
+		}
+	}
+
+	for i, pattern := range a.Body {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return false, fmt.Errorf("assert.body[%d]: invalid regex: %w", i, err)
+		}
+	}
+
+	if _, err := a.ParsedMaxDuration(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ParsedMaxDuration returns the configured max_duration, or 0 if unset.
+func (a *Assert) ParsedMaxDuration() (time.Duration, error) {
+	if a == nil || a.MaxDuration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(a.MaxDuration)
+	if err != nil {
+		return 0, fmt.Errorf("assert.max_duration %q: %w", a.MaxDuration, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("assert.max_duration must be positive, got %q", a.MaxDuration)
+	}
+	return d, nil
+}
+
+// ReadsBody reports whether any check needs the response body in memory
+// (json or body checks). Status, header, and duration checks don't.
+func (a *Assert) ReadsBody() bool {
+	return a != nil && (len(a.JSON) > 0 || len(a.Body) > 0)
+}
@@ -0,0 +1,138 @@
+package yamlparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	yaml "github.com/goccy/go-yaml"
+)
+
+func TestStatusCodes_UnmarshalYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    StatusCodes
+		wantErr string
+	}{
+		{"single number", "status: 200", StatusCodes{200}, ""},
+		{"list of numbers", "status: [200, 201]", StatusCodes{200, 201}, ""},
+		{"numeric string", `status: "204"`, StatusCodes{204}, ""},
+		{"mixed list", `status: [200, "202"]`, StatusCodes{200, 202}, ""},
+		{"not a number", "status: ok", nil, "invalid status code"},
+		{"out of range", "status: 42", nil, "out of range"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var a Assert
+			err := yaml.Unmarshal([]byte(tc.input), &a)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(a.Status, tc.want) {
+				t.Errorf("Status = %v, want %v", a.Status, tc.want)
+			}
+		})
+	}
+}
+
+func TestAssert_IsValid(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name    string
+		assert  *Assert
+		wantErr string
+	}{
+		{"nil assert is valid", nil, ""},
+		{"empty assert is valid", &Assert{}, ""},
+		{
+			"full assert is valid",
+			&Assert{
+				Status:      StatusCodes{200},
+				Headers:     map[string]string{"content-type": "json"},
+				JSON:        []JSONAssertion{{Path: "data.id", Equals: 1}, {Path: "ok", Exists: &yes}},
+				Body:        []string{`"ok":\s*true`},
+				MaxDuration: "500ms",
+			},
+			"",
+		},
+		{"json check without path", &Assert{JSON: []JSONAssertion{{Equals: 1}}}, "path is required"},
+		{
+			"json check without expectation",
+			&Assert{JSON: []JSONAssertion{{Path: "id"}}},
+			"set at least one of",
+		},
+		{
+			"invalid json matches regex",
+			&Assert{JSON: []JSONAssertion{{Path: "id", Matches: "("}}},
+			"invalid matches",
+		},
+		{"invalid body regex", &Assert{Body: []string{"("}}, "assert.body[0]"},
+		{"invalid max duration", &Assert{MaxDuration: "500"}, "assert.max_duration"},
+		{"negative max duration", &Assert{MaxDuration: "-1s"}, "must be positive"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.assert.IsValid()
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v; want false and error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFinalStructForAPI_AssertSection(t *testing.T) {
+	content := `
+method: GET
+url: https://example.com
+assert:
+  status: [200, 201]
+  headers:
+    Content-Type: application/json
+  json:
+    - path: data.userId
+      equals: 42
+  body:
+    - '"ok"'
+  max_duration: 2s
+`
+	path := createTempYAMLFile(t, content)
+	file, valid, err := FinalStructForAPI(path, map[string]any{})
+	if err != nil || !valid {
+		t.Fatalf("FinalStructForAPI() = %v, %v", valid, err)
+	}
+	if file.Assert == nil {
+		t.Fatal("Assert section was not decoded")
+	}
+	if !reflect.DeepEqual(file.Assert.Status, StatusCodes{200, 201}) {
+		t.Errorf("Status = %v", file.Assert.Status)
+	}
+	// Header names are lowercased with every other key; matching is
+	// case-insensitive so that is harmless.
+	if file.Assert.Headers["content-type"] != "application/json" {
+		t.Errorf("Headers = %v", file.Assert.Headers)
+	}
+	// JSON paths live inside a list, so their case is preserved.
+	if len(file.Assert.JSON) != 1 || file.Assert.JSON[0].Path != "data.userId" {
+		t.Errorf("JSON = %+v", file.Assert.JSON)
+	}
+
+	bad := createTempYAMLFile(t, "method: GET\nurl: https://example.com\nassert:\n  body: ['(']\n")
+	if _, valid, err := FinalStructForAPI(bad, map[string]any{}); valid || err == nil {
+		t.Errorf("expected invalid assert section to fail validation, got %v, %v", valid, err)
+	}
+}
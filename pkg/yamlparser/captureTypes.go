@@ -0,0 +1,78 @@
+package yamlparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// captureNameRe restricts capture names to Go template identifiers so
+// {{.captured.name}} parses. Hyphens and dots would be read as operators.
+var captureNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Capture is one entry in the optional `capture:` list of a request file.
+// It names a value pulled out of the response so later files in the same
+// sequential run can reference it as {{.captured.<name>}}.
+//
+// Exactly one source must be set:
+//   - Path: JSON path into the body, same syntax as getValueOf
+//   - Header: response header name (case insensitive)
+//   - Regex: regular expression over the raw body; the first capture group
+//     is used when present, otherwise the whole match
+type Capture struct {
+	Name   string `json:"name"             yaml:"name"`
+	Path   string `json:"path,omitempty"   yaml:"path"`
+	Header string `json:"header,omitempty" yaml:"header"`
+	Regex  string `json:"regex,omitempty"  yaml:"regex"`
+}
+
+// Captures is the `capture:` list of a request file.
+type Captures []Capture
+
+// IsValid checks that every capture has a usable, unique name and exactly
+// one source, and that regex sources compile. An empty list is valid.
+func (c Captures) IsValid() (bool, error) {
+	seen := make(map[string]bool, len(c))
+	for i, capture := range c {
+		if !captureNameRe.MatchString(capture.Name) {
+			return false, fmt.Errorf(
+				"capture[%d]: name %q must start with a letter or underscore and contain only letters, digits, and underscores",
+				i, capture.Name,
+			)
+		}
+		if seen[capture.Name] {
+			return false, fmt.Errorf("capture[%d]: duplicate name %q", i, capture.Name)
+		}
+		seen[capture.Name] = true
+
+		sources := 0
+		for _, src := range []string{capture.Path, capture.Header, capture.Regex} {
+			if strings.TrimSpace(src) != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return false, fmt.Errorf(
+				"capture %q: set exactly one of path, header, or regex", capture.Name,
+			)
+		}
+
+		if capture.Regex != "" {
+			if _, err := regexp.Compile(capture.Regex); err != nil {
+				return false, fmt.Errorf("capture %q: invalid regex: %w", capture.Name, err)
+			}
+		}
+	}
+	return true, nil
+}
+
+// ReadsBody reports whether any capture reads the response body (a path or
+// regex source) rather than a header.
+func (c Captures) ReadsBody() bool {
+	for _, capture := range c {
+		if capture.Header == "" {
+			return true
+		}
+	}
+	return false
+}
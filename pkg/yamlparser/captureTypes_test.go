@@ -0,0 +1,105 @@
+package yamlparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaptures_IsValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		captures Captures
+		wantErr  string
+	}{
+		{"empty list is valid", nil, ""},
+		{
+			"one of each source",
+			Captures{
+				{Name: "userId", Path: "data.id"},
+				{Name: "etag", Header: "ETag"},
+				{Name: "csrf", Regex: `name="csrf" value="([^"]+)"`},
+			},
+			"",
+		},
+		{"name with hyphen", Captures{{Name: "user-id", Path: "id"}}, "must start with a letter"},
+		{"empty name", Captures{{Path: "id"}}, "must start with a letter"},
+		{
+			"duplicate name",
+			Captures{{Name: "id", Path: "id"}, {Name: "id", Header: "X-Id"}},
+			`duplicate name "id"`,
+		},
+		{"no source", Captures{{Name: "id"}}, "set exactly one of"},
+		{"two sources", Captures{{Name: "id", Path: "id", Header: "X-Id"}}, "set exactly one of"},
+		{"bad regex", Captures{{Name: "id", Regex: "("}}, "invalid regex"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.captures.IsValid()
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v; want false and error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFinalStructForAPI_CaptureSection(t *testing.T) {
+	content := `
+method: GET
+url: https://example.com
+capture:
+  - name: userId
+    path: data.userId
+  - name: etag
+    header: ETag
+`
+	path := createTempYAMLFile(t, content)
+	file, valid, err := FinalStructForAPI(path, map[string]any{})
+	if err != nil || !valid {
+		t.Fatalf("FinalStructForAPI() = %v, %v", valid, err)
+	}
+	// Capture entries live inside a list, so names and paths keep their case.
+	want := Captures{{Name: "userId", Path: "data.userId"}, {Name: "etag", Header: "ETag"}}
+	if len(file.Capture) != len(want) {
+		t.Fatalf("Capture = %+v, want %+v", file.Capture, want)
+	}
+	for i := range want {
+		if file.Capture[i] != want[i] {
+			t.Errorf("Capture[%d] = %+v, want %+v", i, file.Capture[i], want[i])
+		}
+	}
+
+	bad := createTempYAMLFile(t, "method: GET\nurl: https://example.com\ncapture:\n  - name: id\n")
+	if _, valid, err := FinalStructForAPI(bad, map[string]any{}); valid || err == nil {
+		t.Errorf("expected invalid capture section to fail validation, got %v, %v", valid, err)
+	}
+}
+
+func TestFinalStructForAPI_CapturedValueKeepsType(t *testing.T) {
+	content := `
+method: POST
+url: https://example.com
+body:
+  graphql:
+    query: "query($id: Int!) { user(id: $id) { name } }"
+    variables:
+      id: "{{.captured.userId}}"
+`
+	path := createTempYAMLFile(t, content)
+	secrets := map[string]any{"captured": map[string]any{"userId": 42.0}}
+	file, valid, err := FinalStructForAPI(path, secrets)
+	if err != nil || !valid {
+		t.Fatalf("FinalStructForAPI() = %v, %v", valid, err)
+	}
+	vars, _ := file.Body.Graphql.Variables.(map[string]any)
+	got := vars["id"]
+	if _, isString := got.(string); isString {
+		t.Errorf("variables.id = %#v, want a number", got)
+	}
+}
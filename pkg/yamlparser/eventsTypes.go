@@ -0,0 +1,74 @@
+package yamlparser
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Events represents the optional `events:` section of a request file. It
+// controls how an event stream is read: Server-Sent Events
+// (text/event-stream), or with this section any other chunked response of
+// newline-delimited records, such as NDJSON. Reading stops at the first
+// limit reached. Without a section, an SSE response is read until the
+// server closes it or the request times out.
+type Events struct {
+	// MaxEvents stops after this many events. Zero means no limit.
+	MaxEvents int `json:"max_events,omitempty" yaml:"max_events"`
+	// Duration stops reading this long after the response headers arrive,
+	// as a Go duration. It is added to the request timeout, which then only
+	// bounds the wait for the headers.
+	Duration string `json:"duration,omitempty"   yaml:"duration"`
+	// Until stops after the first event whose type or data matches this
+	// regular expression, e.g. `^\[DONE\]$`. The matching event is kept.
+	Until string `json:"until,omitempty"      yaml:"until"`
+}
+
+// IsValid checks the limits. A nil Events is valid.
+func (e *Events) IsValid() (bool, error) {
+	if e == nil {
+		return true, nil
+	}
+	if e.MaxEvents < 0 {
+		return false, fmt.Errorf("events.max_events must not be negative, got %d", e.MaxEvents)
+	}
+	if _, err := e.ParsedDuration(); err != nil {
+		return false, err
+	}
+	if _, err := e.UntilRegexp(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ParsedDuration returns events.duration, or 0 when unset.
+func (e *Events) ParsedDuration() (time.Duration, error) {
+	if e == nil || e.Duration == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(e.Duration)
+	if err != nil {
+		return 0, fmt.Errorf("events.duration %q: %w", e.Duration, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("events.duration must be positive, got %q", e.Duration)
+	}
+	return d, nil
+}
+
+// UntilRegexp compiles events.until, or returns nil when unset.
+func (e *Events) UntilRegexp() (*regexp.Regexp, error) {
+	if e == nil || e.Until == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(e.Until)
+	if err != nil {
+		return nil, fmt.Errorf("events.until: %w", err)
+	}
+	return re, nil
+}
+
+// errEventsWithPoll rejects a file that both polls and reads an event
+// stream: each poll would wait on a stream that may never end.
+var errEventsWithPoll = errors.New("events and poll can't be combined")
@@ -0,0 +1,68 @@
+package yamlparser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvents_IsValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  *Events
+		wantErr string
+	}{
+		{"nil events is valid", nil, ""},
+		{"empty section", &Events{}, ""},
+		{"all limits", &Events{MaxEvents: 10, Duration: "30s", Until: `^\[DONE\]$`}, ""},
+		{"negative max_events", &Events{MaxEvents: -1}, "events.max_events must not be negative"},
+		{"bad duration", &Events{Duration: "30"}, "events.duration"},
+		{"zero duration", &Events{Duration: "0s"}, "events.duration must be positive"},
+		{"bad until", &Events{Until: "("}, "events.until"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.events.IsValid()
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v; want false and error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPICallFile_EventsWithPoll(t *testing.T) {
+	file := &APICallFile{
+		Method: GET,
+		URL:    "https://example.com/stream",
+		Events: &Events{MaxEvents: 1},
+		Poll:   &Poll{Until: &Assert{Status: StatusCodes{200}}},
+	}
+	valid, err := file.IsValid("stream.hk.yaml")
+	if valid || !errors.Is(err, errEventsWithPoll) {
+		t.Fatalf("IsValid() = %v, %v; want errEventsWithPoll", valid, err)
+	}
+}
+
+func TestPeekConfig_EventsDuration(t *testing.T) {
+	path := createTempYAMLFile(t, `
+method: GET
+url: https://example.com/stream
+events:
+  duration: 45s
+`)
+	cfg, err := PeekConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := cfg.Events.ParsedDuration(); err != nil || d != 45*time.Second {
+		t.Errorf("Events.ParsedDuration() = %v, %v; want 45s", d, err)
+	}
+}
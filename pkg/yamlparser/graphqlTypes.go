@@ -1,9 +1,95 @@
 package yamlparser
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
+// errSubscriptionWithPoll rejects a file that polls a subscription: a
+// subscription is already a stream of results, read until its events
+// limits.
+var errSubscriptionWithPoll = errors.New("poll can't be used with a graphql subscription")
+
+// IsSubscription reports whether the query's first operation is a
+// subscription. Nil-safe, so a body without graphql is simply not one.
+func (g *GraphQl) IsSubscription() bool {
+	return g != nil && operationKeyword(g.Query) == "subscription"
+}
+
+// operationKeyword returns the keyword of the first operation in a GraphQL
+// document: "query", "mutation", or "subscription". The shorthand form,
+// a bare selection set, is a query. Comments, strings, and fragment
+// definitions are skipped. Empty when the document has no operation.
+func operationKeyword(doc string) string {
+	depth := 0
+	inFragment := false
+	for i := 0; i < len(doc); i++ {
+		c := doc[i]
+		switch {
+		case c == '#':
+			for i < len(doc) && doc[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			i = skipGraphQLString(doc, i)
+		case c == '{':
+			if depth == 0 && !inFragment {
+				return "query"
+			}
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				inFragment = false
+			}
+		case depth == 0 && isNameStart(c):
+			start := i
+			for i+1 < len(doc) && isNameChar(doc[i+1]) {
+				i++
+			}
+			switch word := doc[start : i+1]; word {
+			case "query", "mutation", "subscription":
+				if !inFragment {
+					return word
+				}
+			case "fragment":
+				inFragment = true
+			}
+		}
+	}
+	return ""
+}
+
+// skipGraphQLString returns the index of the closing quote of the string
+// or block string starting at doc[i].
+func skipGraphQLString(doc string, i int) int {
+	if strings.HasPrefix(doc[i:], `"""`) {
+		end := strings.Index(doc[i+3:], `"""`)
+		if end < 0 {
+			return len(doc)
+		}
+		return i + 3 + end + 2
+	}
+	for i++; i < len(doc); i++ {
+		switch doc[i] {
+		case '\\':
+			i++
+		case '"', '\n':
+			return i
+		}
+	}
+	return i
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
 // IsValidForGraphQL validates a GraphQL file and applies defaults.
 // Unlike IsValid, this does NOT require a body/query since the TUI will provide it.
 // It ensures the file has a valid URL and applies default method (POST) and
@@ -29,6 +115,22 @@ func (user *APICallFile) IsValidForGraphQL(filePath string) (bool, error) {
 		return false, fmt.Errorf("missing or invalid URL: %s in file %s", user.URL, filePath)
 	}
 
+	if valid, err := user.Auth.IsValid(); !valid {
+		return false, fmt.Errorf("invalid auth section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.TLS.IsValid(); !valid {
+		return false, fmt.Errorf("invalid tls section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Proxy.IsValid(); !valid {
+		return false, fmt.Errorf("invalid proxy section in '%s': %w", filePath, err)
+	}
+
+	if valid, err := user.Redirects.IsValid(); !valid {
+		return false, fmt.Errorf("invalid redirects setting in '%s': %w", filePath, err)
+	}
+
 	// Default Content-Type header to application/json
 	if user.Headers == nil {
 		user.Headers = make(map[string]string)
@@ -51,6 +153,11 @@ func (user *APICallFile) PrepareGraphQLStruct() APIInfo {
 		URLParams: user.URLParams,
 		Headers:   user.Headers,
 		Body:      nil, // Body will be set separately for GraphQL
+		Signing:   user.signing(),
+		Bearer:    user.bearer,
+		TLS:       user.clientTLS,
+		Proxy:     user.clientProxy,
+		Redirects: user.Redirects.Policy(),
 	}
 }
 
@@ -59,8 +166,14 @@ func (user *APICallFile) PrepareGraphQLStruct() APIInfo {
 // and to get a fresh body slot (io.Reader is single-use).
 func CloneAPIInfo(src APIInfo) APIInfo {
 	clone := APIInfo{
-		Method: src.Method,
-		URL:    src.URL,
+		Method:    src.Method,
+		URL:       src.URL,
+		Signing:   src.Signing,
+		Bearer:    src.Bearer,
+		TLS:       src.TLS,
+		Proxy:     src.Proxy,
+		Jar:       src.Jar,
+		Redirects: src.Redirects,
 	}
 	if src.Headers != nil {
 		clone.Headers = make(map[string]string, len(src.Headers))
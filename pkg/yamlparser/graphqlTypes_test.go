@@ -1,6 +1,7 @@
 package yamlparser
 
 import (
+	"errors"
 	"strings"
 	"testing"
 )
@@ -359,3 +360,47 @@ func TestCloneAPIInfo(t *testing.T) {
 		}
 	})
 }
+
+func TestGraphQl_IsSubscription(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  bool
+	}{
+		{"subscription", "subscription OnPrice { price }", true},
+		{"anonymous subscription", "  subscription{price}", true},
+		{"query", "query Prices { prices }", false},
+		{"shorthand query", "{ prices }", false},
+		{"mutation", "mutation { buy }", false},
+		{"comment first", "# subscription in a comment\nquery Q { a }", false},
+		{"comment before subscription", "# watch prices\nsubscription { price }", true},
+		{"fragment first", "fragment P on Price { subscription }\nsubscription S { price { ...P } }", true},
+		{"description string", "\"\"\"query docs\"\"\" subscription S { a }", true},
+		{"empty", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &GraphQl{Query: tc.query}
+			if got := g.IsSubscription(); got != tc.want {
+				t.Errorf("IsSubscription(%q) = %v, want %v", tc.query, got, tc.want)
+			}
+		})
+	}
+	var none *GraphQl
+	if none.IsSubscription() {
+		t.Error("nil GraphQl reported as a subscription")
+	}
+}
+
+func TestAPICallFile_SubscriptionWithPoll(t *testing.T) {
+	file := &APICallFile{
+		Method: POST,
+		URL:    "https://example.com/graphql",
+		Body:   &Body{Graphql: &GraphQl{Query: "subscription { price }"}},
+		Poll:   &Poll{Until: &Assert{Status: StatusCodes{200}}},
+	}
+	valid, err := file.IsValid("prices.hk.yaml")
+	if valid || !errors.Is(err, errSubscriptionWithPoll) {
+		t.Fatalf("IsValid() = %v, %v; want errSubscriptionWithPoll", valid, err)
+	}
+}
@@ -0,0 +1,206 @@
+package yamlparser
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/xaaha/hulak/pkg/httpclient"
+)
+
+// GRPCFile represents a request file of kind gRPC: one call to a unary or
+// server-streaming method. The method's descriptor comes from the Protos
+// files when set, otherwise from the server's reflection service.
+type GRPCFile struct {
+	// URL is the server address: host:port, or an http:// (plaintext) or
+	// https:// URL whose port defaults to 80 or 443.
+	URL URL `json:"url,omitempty"       yaml:"url"`
+	// Method is the full method name, "package.Service/Method".
+	Method string `json:"method,omitempty"    yaml:"method"`
+	// Plaintext connects to a host:port URL without TLS.
+	Plaintext bool `json:"plaintext,omitempty" yaml:"plaintext"`
+	// Protos are the .proto files that define Method, resolved like
+	// getFile: project-root relative, or "*.proto" for the file of the same
+	// name next to this one. Imports resolve from each file's directory,
+	// then the project root.
+	Protos []string `json:"protos,omitempty"    yaml:"protos"`
+	// Headers are sent as request metadata, e.g. authorization.
+	Headers map[string]string `json:"headers,omitempty"   yaml:"headers"`
+	// Auth adds credentials to the headers; see RequestAuth.
+	Auth *RequestAuth `json:"auth,omitempty"       yaml:"auth"`
+	// Message is the request message, written as YAML or as a JSON string
+	// in the protobuf JSON mapping. Empty sends the default message.
+	Message any `json:"message,omitempty"   yaml:"message"`
+	// Events limits how long a server-streaming response is read; see
+	// Events.
+	Events *Events `json:"events,omitempty"    yaml:"events"`
+	// TLS sets a client certificate, CA bundles, and other TLS settings;
+	// see TLSConfig. It can't be used with plaintext.
+	TLS *TLSConfig `json:"tls,omitempty"       yaml:"tls"`
+	// Proxy tunnels the connection through a proxy; see ProxyConfig.
+	Proxy *ProxyConfig `json:"proxy,omitempty"     yaml:"proxy"`
+
+	// clientTLS and clientProxy are TLS and Proxy built over the
+	// environment's settings, and bearer the token of a use section.
+	clientTLS   *tls.Config
+	clientProxy *httpclient.Proxy
+	bearer      *BearerToken
+}
+
+// Bearer returns the token of an `auth: use:` section, read as the call
+// is made, or nil. It is set by FinalStructForGRPC.
+func (g *GRPCFile) Bearer() *BearerToken {
+	return g.bearer
+}
+
+// ClientTLS returns the connection's TLS settings, or nil for the
+// defaults. It is set by FinalStructForGRPC, and always nil for plaintext.
+func (g *GRPCFile) ClientTLS() *tls.Config {
+	return g.clientTLS
+}
+
+// ClientProxy returns the proxy to connect through, or nil for the one in
+// the environment variables. It is set by FinalStructForGRPC.
+func (g *GRPCFile) ClientProxy() *httpclient.Proxy {
+	return g.clientProxy
+}
+
+// IsValid checks the address, method name, message, and events limits.
+func (g *GRPCFile) IsValid(filePath string) (bool, error) {
+	if g == nil {
+		return false, errors.New("requested grpc file is not valid")
+	}
+	if _, _, err := g.Target(); err != nil {
+		return false, fmt.Errorf("%w in file %s", err, filePath)
+	}
+	if _, _, err := g.ServiceMethod(); err != nil {
+		return false, fmt.Errorf("%w in file %s", err, filePath)
+	}
+	if _, err := g.MessageJSON(); err != nil {
+		return false, fmt.Errorf("invalid message in '%s': %w", filePath, err)
+	}
+	for _, p := range g.Protos {
+		if !strings.HasSuffix(p, ".proto") {
+			return false, fmt.Errorf("invalid protos entry %q in '%s': want a .proto file", p, filePath)
+		}
+	}
+	if valid, err := g.Auth.IsValid(); !valid {
+		return false, fmt.Errorf("invalid auth section in '%s': %w", filePath, err)
+	}
+	if valid, err := g.Events.IsValid(); !valid {
+		return false, fmt.Errorf("invalid events section in '%s': %w", filePath, err)
+	}
+	if valid, err := g.TLS.IsValid(); !valid {
+		return false, fmt.Errorf("invalid tls section in '%s': %w", filePath, err)
+	}
+	if valid, err := g.Proxy.IsValid(); !valid {
+		return false, fmt.Errorf("invalid proxy section in '%s': %w", filePath, err)
+	}
+	if _, plaintext, _ := g.Target(); plaintext && g.TLS != nil {
+		return false, fmt.Errorf("tls can't be used with a plaintext connection in '%s'", filePath)
+	}
+	return true, nil
+}
+
+// Target returns the host:port to dial and whether to skip TLS.
+func (g *GRPCFile) Target() (string, bool, error) {
+	raw := string(g.URL)
+	if !strings.Contains(raw, "://") {
+		if _, port, err := net.SplitHostPort(raw); err != nil || port == "" {
+			return "", false, fmt.Errorf("missing or invalid URL: %s; use host:port", raw)
+		}
+		return raw, g.Plaintext, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || strings.Trim(u.Path, "/") != "" {
+		return "", false, fmt.Errorf("missing or invalid URL: %s; use host:port", raw)
+	}
+	var port string
+	switch u.Scheme {
+	case "http":
+		port = "80"
+	case "https":
+		if g.Plaintext {
+			return "", false, fmt.Errorf("plaintext can't be used with the https URL %s", raw)
+		}
+		port = "443"
+	default:
+		return "", false, fmt.Errorf("invalid URL scheme %q in %s; use host:port, http, or https", u.Scheme, raw)
+	}
+	if u.Port() != "" {
+		port = u.Port()
+	}
+	return net.JoinHostPort(u.Hostname(), port), u.Scheme == "http", nil
+}
+
+// ServiceMethod splits Method into the full service name and the method
+// name, e.g. "helloworld.Greeter" and "SayHello". A leading slash is
+// allowed.
+func (g *GRPCFile) ServiceMethod() (string, string, error) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(g.Method, "/"), "/")
+	if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+		return "", "", fmt.Errorf("invalid method %q; use package.Service/Method", g.Method)
+	}
+	return service, method, nil
+}
+
+// MessageJSON returns the request message as JSON: a string is taken as
+// JSON text, e.g. from getFile, anything else is encoded.
+func (g *GRPCFile) MessageJSON() ([]byte, error) {
+	switch msg := g.Message.(type) {
+	case nil:
+		return []byte("{}"), nil
+	case string:
+		if strings.TrimSpace(msg) == "" {
+			return []byte("{}"), nil
+		}
+		if !json.Valid([]byte(msg)) {
+			return nil, errors.New("a string message must be JSON")
+		}
+		return []byte(msg), nil
+	default:
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("encoding message: %w", err)
+		}
+		return b, nil
+	}
+}
+
+// FinalStructForGRPC builds and validates a gRPC request file, resolving
+// templates with secretsMap like the other kinds.
+func FinalStructForGRPC(filePath string, secretsMap map[string]any) (GRPCFile, error) {
+	buf, err := checkYamlFile(filePath, secretsMap)
+	if err != nil {
+		return GRPCFile{}, err
+	}
+
+	var file GRPCFile
+	dec := yaml.NewDecoder(buf)
+	if err := dec.Decode(&file); err != nil {
+		return GRPCFile{}, fmt.Errorf("decoding %s: %w", filePath, err)
+	}
+
+	if valid, err := file.IsValid(filePath); !valid {
+		return GRPCFile{}, err
+	}
+	if err := file.applyAuth(secretsMap); err != nil {
+		return GRPCFile{}, fmt.Errorf("auth in '%s': %w", filePath, err)
+	}
+	// The environment's TLS settings don't apply to plaintext connections.
+	if _, plaintext, _ := file.Target(); !plaintext {
+		if file.clientTLS, err = resolveTLS(file.TLS, secretsMap, filePath); err != nil {
+			return GRPCFile{}, fmt.Errorf("tls in '%s': %w", filePath, err)
+		}
+	}
+	if file.clientProxy, err = resolveProxy(file.Proxy, secretsMap); err != nil {
+		return GRPCFile{}, fmt.Errorf("proxy in '%s': %w", filePath, err)
+	}
+	return file, nil
+}
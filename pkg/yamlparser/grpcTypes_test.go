@@ -0,0 +1,100 @@
+package yamlparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGRPCFile_IsValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    GRPCFile
+		wantErr string
+	}{
+		{"host and port", GRPCFile{URL: "localhost:50051", Method: "helloworld.Greeter/SayHello"}, ""},
+		{"https url", GRPCFile{URL: "https://api.example.com", Method: "/pkg.Svc/Get"}, ""},
+		{"protos", GRPCFile{URL: "x:1", Method: "pkg.Svc/Get", Protos: []string{"*.proto", "protos/a.proto"}}, ""},
+		{"json string message", GRPCFile{URL: "x:1", Method: "pkg.Svc/Get", Message: `{"id":"7"}`}, ""},
+		{"missing url", GRPCFile{Method: "pkg.Svc/Get"}, "missing or invalid URL"},
+		{"no port", GRPCFile{URL: "localhost", Method: "pkg.Svc/Get"}, "use host:port"},
+		{"url with path", GRPCFile{URL: "https://example.com/api", Method: "pkg.Svc/Get"}, "missing or invalid URL"},
+		{"ws scheme", GRPCFile{URL: "ws://example.com", Method: "pkg.Svc/Get"}, `invalid URL scheme "ws"`},
+		{
+			"plaintext https",
+			GRPCFile{URL: "https://example.com", Method: "pkg.Svc/Get", Plaintext: true},
+			"plaintext can't be used",
+		},
+		{"missing method", GRPCFile{URL: "x:1"}, `invalid method ""`},
+		{"dotted method", GRPCFile{URL: "x:1", Method: "pkg.Svc.Get"}, "use package.Service/Method"},
+		{"bad json message", GRPCFile{URL: "x:1", Method: "pkg.Svc/Get", Message: "{nope"}, "a string message must be JSON"},
+		{"not a proto", GRPCFile{URL: "x:1", Method: "pkg.Svc/Get", Protos: []string{"a.json"}}, "want a .proto file"},
+		{"bad events", GRPCFile{URL: "x:1", Method: "pkg.Svc/Get", Events: &Events{Duration: "soon"}}, "invalid events section"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.file.IsValid("svc.hk.yaml")
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v; want false and error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestGRPCFile_Target(t *testing.T) {
+	tests := []struct {
+		url           URL
+		plaintext     bool
+		wantAddr      string
+		wantPlaintext bool
+	}{
+		{"localhost:50051", false, "localhost:50051", false},
+		{"localhost:50051", true, "localhost:50051", true},
+		{"http://localhost:8080", false, "localhost:8080", true},
+		{"http://grpc.internal", false, "grpc.internal:80", true},
+		{"https://api.example.com/", false, "api.example.com:443", false},
+	}
+	for _, tc := range tests {
+		t.Run(string(tc.url), func(t *testing.T) {
+			g := &GRPCFile{URL: tc.url, Plaintext: tc.plaintext}
+			addr, plaintext, err := g.Target()
+			if err != nil || addr != tc.wantAddr || plaintext != tc.wantPlaintext {
+				t.Errorf("Target() = %q, %v, %v; want %q, %v", addr, plaintext, err, tc.wantAddr, tc.wantPlaintext)
+			}
+		})
+	}
+}
+
+func TestFinalStructForGRPC(t *testing.T) {
+	path := createTempYAMLFile(t, `kind: gRPC
+url: "{{.grpcHost}}"
+method: users.v1.Users/GetUser
+plaintext: true
+headers:
+  Authorization: Bearer {{.token}}
+message:
+  userId: "{{.userId}}"
+  Filter:
+    pageSize: 2
+`)
+	file, err := FinalStructForGRPC(path, map[string]any{"grpcHost": "localhost:50051", "token": "t0k", "userId": "u-7"})
+	if err != nil {
+		t.Fatalf("FinalStructForGRPC: %v", err)
+	}
+	if file.URL != "localhost:50051" || !file.Plaintext || file.Headers["authorization"] != "Bearer t0k" {
+		t.Errorf("file = %+v; want templates resolved", file)
+	}
+	msg, err := file.MessageJSON()
+	if err != nil || string(msg) != `{"Filter":{"pageSize":2},"userId":"u-7"}` {
+		t.Errorf("MessageJSON() = %s, %v; want field names kept", msg, err)
+	}
+	if service, method, _ := file.ServiceMethod(); service != "users.v1.Users" || method != "GetUser" {
+		t.Errorf("ServiceMethod() = %q, %q", service, method)
+	}
+}
@@ -15,9 +15,11 @@ type Kind string
 
 // Allowed configuration kinds.
 const (
-	KindAuth    Kind = "Auth"
-	KindAPI     Kind = "API"
-	KindGraphQL Kind = "GraphQL"
+	KindAuth      Kind = "Auth"
+	KindAPI       Kind = "API"
+	KindGraphQL   Kind = "GraphQL"
+	KindWebSocket Kind = "WebSocket"
+	KindGRPC      Kind = "gRPC"
 )
 
 // Holds the registered kinds and default selection logic.
@@ -34,6 +36,8 @@ func newKindRegistry() *kindRegistry {
 	r.register(KindAuth)
 	r.register(KindAPI)
 	r.register(KindGraphQL)
+	r.register(KindWebSocket)
+	r.register(KindGRPC)
 	return r
 }
 
@@ -50,6 +54,21 @@ type ConfigType struct {
 	// Parsed via time.ParseDuration; see ParsedTimeout for resolution. Empty
 	// string falls through to the runner's flag/env/default chain.
 	Timeout string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// DependsOn names other request files in the same directory run that
+	// must succeed before this one starts. Names match by stem, so "login"
+	// refers to login.hk.yaml. Ignored by sequential runs, which already
+	// follow file order.
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	// Poll is read here only for its timeout: the runner raises the
+	// request timeout to it when it is longer.
+	Poll *Poll `json:"poll,omitempty"       yaml:"poll,omitempty"`
+	// Events is read here only for its duration, which the runner adds to
+	// the request timeout.
+	Events *Events `json:"events,omitempty"     yaml:"events,omitempty"`
+	// Data is a CSV or JSON dataset the runner iterates the request over,
+	// once per row. Resolved like getFile: project-root relative, or "*.csv"
+	// for a file next to this one. Wins over the --data flag.
+	Data string `json:"data,omitempty"       yaml:"data,omitempty"`
 }
 
 // ParsedTimeout returns the configured per-request timeout, or 0 if unset.
@@ -70,11 +89,11 @@ func (c *ConfigType) ParsedTimeout() (time.Duration, error) {
 	return d, nil
 }
 
-// PeekConfig reads a request file's top-level config (kind, timeout) without
-// resolving templates or secrets. Other request fields (url, body, ...) are
-// ignored, so a file with unresolved template vars still peeks cleanly. Kind
-// is normalized (defaulting to API). Use it to classify or time a file
-// without a full parse.
+// PeekConfig reads a request file's top-level config (kind, timeout,
+// depends_on, data) without resolving templates or secrets. Other request
+// fields (url, body, ...) are ignored, so a file with unresolved template
+// vars still peeks cleanly. Kind is normalized (defaulting to API). Use it
+// to classify, time, or order a file without a full parse.
 func PeekConfig(filePath string) (*ConfigType, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -131,6 +150,16 @@ func (c *ConfigType) IsGraphql() bool {
 	return strings.EqualFold(string(c.getKind()), string(KindGraphQL))
 }
 
+// IsWebSocket returns true when the configuration kind is "WebSocket".
+func (c *ConfigType) IsWebSocket() bool {
+	return strings.EqualFold(string(c.getKind()), string(KindWebSocket))
+}
+
+// IsGRPC returns true when the configuration kind is "gRPC".
+func (c *ConfigType) IsGRPC() bool {
+	return strings.EqualFold(string(c.getKind()), string(KindGRPC))
+}
+
 // ParseConfig parses a YAML file into ConfigType.
 func ParseConfig(filePath string, secretsMap map[string]any) (*ConfigType, error) {
 	// checkYamlFile errors already carry the file path; don't wrap with a
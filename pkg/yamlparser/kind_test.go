@@ -64,15 +64,21 @@ func TestKindHelpers(t *testing.T) {
 		wantAuth bool
 		wantAPI  bool
 		wantGQL  bool
+		wantWS   bool
+		wantGRPC bool
 	}{
-		{"empty => API", "", false, true, false},
-		{"API", "API", false, true, false},
-		{"api lower", "api", false, true, false},
-		{"Auth", "Auth", true, false, false},
-		{"auth lower", "auth", true, false, false},
-		{"GraphQL", "GraphQL", false, false, true},
-		{"graphql lower", "graphql", false, false, true},
-		{"invalid => none", "invalid", false, false, false},
+		{"empty => API", "", false, true, false, false, false},
+		{"API", "API", false, true, false, false, false},
+		{"api lower", "api", false, true, false, false, false},
+		{"Auth", "Auth", true, false, false, false, false},
+		{"auth lower", "auth", true, false, false, false, false},
+		{"GraphQL", "GraphQL", false, false, true, false, false},
+		{"graphql lower", "graphql", false, false, true, false, false},
+		{"WebSocket", "WebSocket", false, false, false, true, false},
+		{"websocket lower", "websocket", false, false, false, true, false},
+		{"gRPC", "gRPC", false, false, false, false, true},
+		{"grpc lower", "grpc", false, false, false, false, true},
+		{"invalid => none", "invalid", false, false, false, false, false},
 	}
 
 	for _, tt := range tests {
@@ -87,6 +93,12 @@ func TestKindHelpers(t *testing.T) {
 			if got := conf.IsGraphql(); got != tt.wantGQL {
 				t.Errorf("IsGraphql() = %v, want %v", got, tt.wantGQL)
 			}
+			if got := conf.IsWebSocket(); got != tt.wantWS {
+				t.Errorf("IsWebSocket() = %v, want %v", got, tt.wantWS)
+			}
+			if got := conf.IsGRPC(); got != tt.wantGRPC {
+				t.Errorf("IsGRPC() = %v, want %v", got, tt.wantGRPC)
+			}
 		})
 	}
 }
@@ -112,6 +124,10 @@ func TestParseConfig(t *testing.T) {
 		{"GraphQL", `kind: GraphQL`, true, ConfigType{Kind: KindGraphQL}},
 		{"graphql lower", `kind: graphql`, true, ConfigType{Kind: KindGraphQL}},
 
+		// WebSocket kinds
+		{"WebSocket", `kind: WebSocket`, true, ConfigType{Kind: KindWebSocket}},
+		{"websocket lower", `kind: websocket`, true, ConfigType{Kind: KindWebSocket}},
+
 		// Missing kind (defaults to API)
 		{"missing kind defaults to API", `method: POST`, true, ConfigType{Kind: KindAPI}},
 
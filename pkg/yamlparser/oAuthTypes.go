@@ -2,10 +2,14 @@
 package yamlparser
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"strings"
 
+	"github.com/xaaha/hulak/pkg/httpclient"
 	"github.com/xaaha/hulak/pkg/utils"
 )
 
@@ -19,10 +23,61 @@ const (
 	Oauth2type3 authtype = "oauth2.0"
 )
 
+// GrantType is the OAuth 2.0 grant an Auth file uses to get its token.
+type GrantType string
+
+// Supported values of auth.grant_type. Only the authorization code grant
+// opens a browser; the others run in CI and over SSH.
+const (
+	GrantAuthorizationCode GrantType = "authorization_code"
+	GrantClientCredentials GrantType = "client_credentials"
+	GrantPassword          GrantType = "password"
+	GrantDeviceCode        GrantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// grantAliases maps the short forms accepted in auth.grant_type to the
+// grant_type value sent to the token endpoint.
+var grantAliases = map[string]GrantType{
+	"":                                       GrantAuthorizationCode,
+	"authorization_code":                     GrantAuthorizationCode,
+	"client_credentials":                     GrantClientCredentials,
+	"password":                               GrantPassword,
+	"device_code":                            GrantDeviceCode,
+	strings.ToLower(string(GrantDeviceCode)): GrantDeviceCode,
+}
+
 // Auth Represents how Auth section in yaml looks like
 type Auth struct {
-	Type           authtype `json:"type"             yaml:"type"`
-	AccessTokenURL URL      `json:"access_token_url" yaml:"access_token_url"`
+	Type           authtype `json:"type"                 yaml:"type"`
+	AccessTokenURL URL      `json:"access_token_url"     yaml:"access_token_url"`
+	// GrantType selects the flow; empty is the browser authorization code
+	// flow. See Grant.
+	GrantType GrantType `json:"grant_type,omitempty" yaml:"grant_type"`
+	// PKCE binds the authorization code to this login, RFC 7636. Nil is
+	// on; false is for a provider that rejects code_challenge. See UsesPKCE.
+	PKCE *bool `json:"pkce,omitempty"       yaml:"pkce"`
+}
+
+// UsesPKCE reports whether the authorization code flow sends a PKCE
+// challenge and verifier. It's on unless pkce is false.
+func (a *Auth) UsesPKCE() bool {
+	return a.PKCE == nil || *a.PKCE
+}
+
+// Grant returns the grant type with its short form resolved, e.g.
+// "device_code" to the device code URN. ok is false for an unknown grant.
+func (a *Auth) Grant() (GrantType, bool) {
+	grant, ok := grantAliases[strings.ToLower(strings.TrimSpace(string(a.GrantType)))]
+	return grant, ok
+}
+
+// NeedsURL reports whether the grant starts at the file's url: the
+// authorize page for the authorization code grant, the device
+// authorization endpoint for the device code grant. The other grants only
+// call access_token_url.
+func (a *Auth) NeedsURL() bool {
+	grant, _ := a.Grant()
+	return grant == GrantAuthorizationCode || grant == GrantDeviceCode
 }
 
 // IsValid checks if auth key contains type and has at least 1 item in Extras
@@ -31,6 +86,9 @@ func (a *Auth) IsValid() bool {
 	if a == nil {
 		return false
 	}
+	if _, ok := a.Grant(); !ok {
+		return false
+	}
 
 	switch a.Type {
 	case Oauth2type1, Oauth2type2, Oauth2type3:
@@ -84,6 +142,13 @@ func (b *Auth2Body) IsValid() bool {
 
 // EncodeBody encodes the *Auth2Body
 func (b *Auth2Body) EncodeBody(code string) (io.Reader, string, error) {
+	return b.EncodeBodyWith(map[string]string{utils.ResponseType: code})
+}
+
+// EncodeBodyWith encodes the *Auth2Body with the fields in extra added,
+// e.g. grant_type, replacing fields of the same name. The body itself is
+// left as is, so one file can make several token requests.
+func (b *Auth2Body) EncodeBodyWith(extra map[string]string) (io.Reader, string, error) {
 	var body io.Reader
 	var contentType string
 
@@ -91,9 +156,7 @@ func (b *Auth2Body) EncodeBody(code string) (io.Reader, string, error) {
 		return nil, "", nil
 	}
 
-	codeMap := make(map[string]string)
-	codeMap[utils.ResponseType] = code
-	mergedMap := utils.MergeMaps(b.URLEncodedFormData, codeMap)
+	mergedMap := utils.MergeMaps(maps.Clone(b.URLEncodedFormData), extra)
 
 	switch {
 	case len(b.URLEncodedFormData) > 0:
@@ -117,6 +180,17 @@ type AuthRequestFile struct {
 	Auth      *Auth             `json:"auth"                yaml:"auth"`
 	Headers   map[string]string `json:"headers,omitempty"   yaml:"headers"`
 	Body      *Auth2Body
+	// TLS applies to the token and device authorization requests. See
+	// TLSConfig.
+	TLS *TLSConfig `json:"tls,omitempty" yaml:"tls"`
+	// Proxy applies to the token and device authorization requests. See
+	// ProxyConfig.
+	Proxy *ProxyConfig `json:"proxy,omitempty" yaml:"proxy"`
+
+	// clientTLS and clientProxy are TLS and Proxy built over the
+	// environment's settings.
+	clientTLS   *tls.Config
+	clientProxy *httpclient.Proxy
 }
 
 // IsValid checks if AuthRequestBody is valid ,
@@ -148,14 +222,21 @@ func (auth2Body *AuthRequestFile) IsValid() (bool, error) {
 		return false, errors.New("when 'Kind: auth' is present, auth section is required")
 	}
 
+	if _, ok := auth2Body.Auth.Grant(); !ok {
+		return false, fmt.Errorf(
+			"unsupported grant_type %q; use authorization_code, client_credentials, password, or device_code",
+			auth2Body.Auth.GrantType,
+		)
+	}
+
 	if valid := auth2Body.Auth.IsValid(); !valid {
 		return false, errors.New(
 			"invalid 'auth' section. Make sure the Auth2.0 file contains valid auth section with 'type' && access_token_url",
 		)
 	}
 
-	// Validate URL
-	if !auth2Body.URL.IsValidURL() {
+	// Validate URL. Grants that only call access_token_url don't need one.
+	if auth2Body.Auth.NeedsURL() && !auth2Body.URL.IsValidURL() {
 		return false, errors.New("missing or invalid URL in auth request body")
 	}
 
@@ -164,17 +245,36 @@ func (auth2Body *AuthRequestFile) IsValid() (bool, error) {
 		return false, errors.New("invalid URL parameters")
 	}
 
+	// The device authorization request sends urlparams, so it needs them
+	if grant, _ := auth2Body.Auth.Grant(); grant == GrantDeviceCode && !auth2Body.URLParams.IsValid() {
+		return false, errors.New("the device_code grant needs urlparams with client_id")
+	}
+
 	// Validate Body
 	if !auth2Body.Body.IsValid() {
 		return false, errors.New("invalid body content")
 	}
 
+	if valid, err := auth2Body.TLS.IsValid(); !valid {
+		return false, fmt.Errorf("invalid tls section: %w", err)
+	}
+
+	if valid, err := auth2Body.Proxy.IsValid(); !valid {
+		return false, fmt.Errorf("invalid proxy section: %w", err)
+	}
+
 	return true, nil
 }
 
 // PrepareStruct prepars struct for the standard call
 func (auth2Body *AuthRequestFile) PrepareStruct(code string) (APIInfo, error) {
-	body, contentType, err := auth2Body.Body.EncodeBody(code)
+	return auth2Body.PrepareTokenRequest(map[string]string{utils.ResponseType: code})
+}
+
+// PrepareTokenRequest prepares the call to access_token_url, sending the
+// body's form fields with fields added, e.g. grant_type and device_code.
+func (auth2Body *AuthRequestFile) PrepareTokenRequest(fields map[string]string) (APIInfo, error) {
+	body, contentType, err := auth2Body.Body.EncodeBodyWith(fields)
 	if err != nil {
 		return APIInfo{}, fmt.Errorf("%s: %w", utils.ErrBodyEncoding, err)
 	}
@@ -192,5 +292,29 @@ func (auth2Body *AuthRequestFile) PrepareStruct(code string) (APIInfo, error) {
 		URLParams: auth2Body.URLParams,
 		Headers:   auth2Body.Headers,
 		Body:      body,
+		TLS:       auth2Body.clientTLS,
+		Proxy:     auth2Body.clientProxy,
+	}, nil
+}
+
+// PrepareDeviceAuthorization prepares the device authorization request of
+// the device code grant: urlparams, e.g. client_id and scope, are sent as a
+// form to the file's url.
+func (auth2Body *AuthRequestFile) PrepareDeviceAuthorization() (APIInfo, error) {
+	body, err := EncodeXwwwFormURLBody(auth2Body.URLParams)
+	if err != nil {
+		return APIInfo{}, fmt.Errorf("%s: %w", utils.ErrBodyEncoding, err)
+	}
+	headers := make(map[string]string, len(auth2Body.Headers)+1)
+	maps.Copy(headers, auth2Body.Headers)
+	headers["content-type"] = "application/x-www-form-urlencoded"
+
+	return APIInfo{
+		Method:  string(auth2Body.Method),
+		URL:     string(auth2Body.URL),
+		Headers: headers,
+		Body:    body,
+		TLS:     auth2Body.clientTLS,
+		Proxy:   auth2Body.clientProxy,
 	}, nil
 }
@@ -1,6 +1,7 @@
 package yamlparser
 
 import (
+	"io"
 	"strings"
 	"testing"
 )
@@ -262,6 +263,80 @@ func TestAuthRequestBody_IsValid(t *testing.T) {
 			expectedBool: true,
 			expectedErr:  "",
 		},
+		{
+			name: "client_credentials grant without URL",
+			authRequest: AuthRequestFile{
+				Auth: &Auth{
+					Type:           Oauth2type1,
+					AccessTokenURL: "https://auth.example.com/token",
+					GrantType:      "client_credentials",
+				},
+				Body: &Auth2Body{
+					URLEncodedFormData: map[string]string{"client_id": "ci", "client_secret": "s"},
+				},
+			},
+			expectedBool: true,
+			expectedErr:  "",
+		},
+		{
+			name: "device_code grant with client_id",
+			authRequest: AuthRequestFile{
+				URL:       "https://auth.example.com/device",
+				URLParams: URLPARAMS{"client_id": "cli"},
+				Auth: &Auth{
+					Type:           Oauth2type1,
+					AccessTokenURL: "https://auth.example.com/token",
+					GrantType:      "device_code",
+				},
+				Body: &Auth2Body{
+					URLEncodedFormData: map[string]string{"client_id": "cli"},
+				},
+			},
+			expectedBool: true,
+			expectedErr:  "",
+		},
+		{
+			name: "device_code grant without urlparams",
+			authRequest: AuthRequestFile{
+				URL: "https://auth.example.com/device",
+				Auth: &Auth{
+					Type:           Oauth2type1,
+					AccessTokenURL: "https://auth.example.com/token",
+					GrantType:      "device_code",
+				},
+				Body: &Auth2Body{
+					URLEncodedFormData: map[string]string{"client_id": "cli"},
+				},
+			},
+			expectedBool: false,
+			expectedErr:  "the device_code grant needs urlparams with client_id",
+		},
+		{
+			name: "device_code grant without URL",
+			authRequest: AuthRequestFile{
+				URLParams: URLPARAMS{"client_id": "cli"},
+				Auth: &Auth{
+					Type:           Oauth2type1,
+					AccessTokenURL: "https://auth.example.com/token",
+					GrantType:      "device_code",
+				},
+			},
+			expectedBool: false,
+			expectedErr:  "missing or invalid URL in auth request body",
+		},
+		{
+			name: "Unsupported grant type",
+			authRequest: AuthRequestFile{
+				URL: "https://api.example.com",
+				Auth: &Auth{
+					Type:           Oauth2type1,
+					AccessTokenURL: "https://auth.example.com/token",
+					GrantType:      "implicit",
+				},
+			},
+			expectedBool: false,
+			expectedErr:  `unsupported grant_type "implicit"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -287,3 +362,52 @@ func TestAuthRequestBody_IsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestAuth_Grant(t *testing.T) {
+	tests := []struct {
+		in     GrantType
+		want   GrantType
+		wantOK bool
+	}{
+		{"", GrantAuthorizationCode, true},
+		{"authorization_code", GrantAuthorizationCode, true},
+		{"Client_Credentials", GrantClientCredentials, true},
+		{"password", GrantPassword, true},
+		{"device_code", GrantDeviceCode, true},
+		{"urn:ietf:params:oauth:grant-type:device_code", GrantDeviceCode, true},
+		{"implicit", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.in), func(t *testing.T) {
+			a := &Auth{GrantType: tt.in}
+			got, ok := a.Grant()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Grant() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAuthRequestFile_PrepareTokenRequest(t *testing.T) {
+	file := AuthRequestFile{
+		Method: POST,
+		Auth:   &Auth{Type: Oauth2type1, AccessTokenURL: "https://auth.example.com/token"},
+		Body: &Auth2Body{
+			URLEncodedFormData: map[string]string{"client_id": "ci", "scope": "read"},
+		},
+	}
+	info, err := file.PrepareTokenRequest(map[string]string{"grant_type": string(GrantClientCredentials)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(info.Body)
+	if info.URL != "https://auth.example.com/token" || string(body) != "client_id=ci&grant_type=client_credentials&scope=read" {
+		t.Errorf("url %q, body %q", info.URL, body)
+	}
+	if info.Headers["content-type"] != "application/x-www-form-urlencoded" {
+		t.Errorf("headers = %v", info.Headers)
+	}
+	if _, ok := file.Body.URLEncodedFormData["grant_type"]; ok {
+		t.Errorf("body = %v, want the file's fields only", file.Body.URLEncodedFormData)
+	}
+}
@@ -0,0 +1,80 @@
+package yamlparser
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultPollInterval is the wait between poll requests when
+// `poll.interval` is unset.
+const DefaultPollInterval = 2 * time.Second
+
+// Poll represents the optional `poll:` section of a request file. The
+// request is re-sent every Interval until the response satisfies Until, or
+// Timeout passes.
+type Poll struct {
+	// Interval is the wait between requests, as a Go duration.
+	Interval string `json:"interval,omitempty" yaml:"interval"`
+	// Timeout bounds the whole wait. It also raises the file's request
+	// timeout when it is longer, so polling isn't cut short. Empty means
+	// the file's timeout is the only bound.
+	Timeout string `json:"timeout,omitempty"  yaml:"timeout"`
+	// Until is the condition to wait for. It takes the same checks as
+	// `assert:` (status, headers, json, body), except max_duration.
+	Until *Assert `json:"until,omitempty"    yaml:"until"`
+}
+
+// IsValid checks that the section has a usable condition and durations. A
+// nil Poll is valid.
+func (p *Poll) IsValid() (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	u := p.Until
+	if u == nil || (len(u.Status) == 0 && len(u.Headers) == 0 && len(u.JSON) == 0 && len(u.Body) == 0) {
+		return false, errors.New("poll.until needs at least one of status, headers, json, or body")
+	}
+	if u.MaxDuration != "" {
+		return false, errors.New("poll.until does not support max_duration; use poll.timeout")
+	}
+	if valid, err := u.IsValid(); !valid {
+		return false, fmt.Errorf("poll.until: %w", err)
+	}
+	if _, err := p.ParsedInterval(); err != nil {
+		return false, err
+	}
+	if _, err := p.ParsedTimeout(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ParsedInterval returns the wait between requests, or
+// DefaultPollInterval when unset.
+func (p *Poll) ParsedInterval() (time.Duration, error) {
+	if p == nil || p.Interval == "" {
+		return DefaultPollInterval, nil
+	}
+	return parsePollDuration("interval", p.Interval)
+}
+
+// ParsedTimeout returns the poll timeout, or 0 when unset.
+func (p *Poll) ParsedTimeout() (time.Duration, error) {
+	if p == nil || p.Timeout == "" {
+		return 0, nil
+	}
+	return parsePollDuration("timeout", p.Timeout)
+}
+
+// parsePollDuration parses a positive poll duration.
+func parsePollDuration(field, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("poll.%s %q: %w", field, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("poll.%s must be positive, got %q", field, value)
+	}
+	return d, nil
+}
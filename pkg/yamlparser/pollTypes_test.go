@@ -0,0 +1,76 @@
+package yamlparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoll_IsValid(t *testing.T) {
+	done := &Assert{JSON: []JSONAssertion{{Path: "status", Equals: "DONE"}}}
+
+	tests := []struct {
+		name    string
+		poll    *Poll
+		wantErr string
+	}{
+		{"nil poll is valid", nil, ""},
+		{"json condition", &Poll{Until: done}, ""},
+		{"status condition with durations", &Poll{Interval: "1s", Timeout: "2m", Until: &Assert{Status: StatusCodes{200}}}, ""},
+		{"missing until", &Poll{Interval: "1s"}, "needs at least one of"},
+		{"empty until", &Poll{Until: &Assert{}}, "needs at least one of"},
+		{
+			"max_duration not supported",
+			&Poll{Until: &Assert{Status: StatusCodes{200}, MaxDuration: "1s"}},
+			"does not support max_duration",
+		},
+		{"invalid condition", &Poll{Until: &Assert{Body: []string{"("}}}, "poll.until: assert.body[0]"},
+		{"bad interval", &Poll{Interval: "5", Until: done}, "poll.interval"},
+		{"zero timeout", &Poll{Timeout: "0s", Until: done}, "poll.timeout must be positive"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.poll.IsValid()
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v; want false and error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPoll_ParsedInterval_Default(t *testing.T) {
+	var p *Poll
+	if d, err := p.ParsedInterval(); err != nil || d != DefaultPollInterval {
+		t.Errorf("nil ParsedInterval() = %v, %v; want %v", d, err, DefaultPollInterval)
+	}
+	if d, err := (&Poll{Interval: "250ms"}).ParsedInterval(); err != nil || d != 250*time.Millisecond {
+		t.Errorf("ParsedInterval() = %v, %v; want 250ms", d, err)
+	}
+}
+
+func TestPeekConfig_PollTimeout(t *testing.T) {
+	path := createTempYAMLFile(t, `
+method: GET
+url: https://example.com
+poll:
+  timeout: 5m
+  until:
+    json:
+      - path: status
+        equals: DONE
+`)
+	cfg, err := PeekConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := cfg.Poll.ParsedTimeout(); err != nil || d != 5*time.Minute {
+		t.Errorf("Poll.ParsedTimeout() = %v, %v; want 5m", d, err)
+	}
+}
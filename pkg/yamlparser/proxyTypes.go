@@ -0,0 +1,169 @@
+package yamlparser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/xaaha/hulak/pkg/httpclient"
+)
+
+// ProxySecret is the vault key holding an environment's default proxy: a
+// proxy URL, or a JSON object with the keys of the proxy section. Other
+// value types under this key are ignored.
+const ProxySecret = "proxy"
+
+// ProxyConfig represents the optional proxy section of a request file. It
+// may also be written as just the proxy URL:
+//
+//	proxy: http://proxy.corp:3128
+//
+// Without a URL here or in the environment, the HTTP_PROXY and HTTPS_PROXY
+// environment variables are used, and NoProxy adds to NO_PROXY.
+type ProxyConfig struct {
+	// URL is an http, https, socks5, or socks5h proxy URL.
+	URL string `json:"url,omitempty"      yaml:"url"`
+	// Username and Password authenticate with the proxy, e.g. from the
+	// vault through {{.proxyUser}}. They replace any in URL.
+	Username string `json:"username,omitempty" yaml:"username"`
+	Password string `json:"password,omitempty" yaml:"password"`
+	// NoProxy lists hosts reached directly; see httpclient.Proxy.
+	NoProxy HostList `json:"no_proxy,omitempty" yaml:"no_proxy"`
+}
+
+// UnmarshalYAML accepts the proxy URL alone or the full section.
+func (p *ProxyConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	var proxyURL string
+	if err := unmarshal(&proxyURL); err == nil {
+		*p = ProxyConfig{URL: proxyURL}
+		return nil
+	}
+	type section ProxyConfig
+	return unmarshal((*section)(p))
+}
+
+// HostList is a list of hosts, written as a YAML list or, like NO_PROXY,
+// as one comma-separated string.
+type HostList []string
+
+// UnmarshalYAML accepts a list or a comma-separated string.
+func (h *HostList) UnmarshalYAML(unmarshal func(any) error) error {
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		var joined string
+		if err := unmarshal(&joined); err != nil {
+			return errors.New("no_proxy must be a list of hosts or a comma-separated string")
+		}
+		list = strings.Split(joined, ",")
+	}
+	hosts := make([]string, 0, len(list))
+	for _, host := range list {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	*h = hosts
+	return nil
+}
+
+// IsValid checks the proxy URL and that credentials come with one. A nil
+// section is valid.
+func (p *ProxyConfig) IsValid() (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+	if p.URL == "" {
+		if p.Username != "" || p.Password != "" {
+			return false, errors.New("username and password need a proxy url")
+		}
+		return true, nil
+	}
+	if _, err := httpclient.ParseProxyURL(p.URL); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// over returns p with the settings it leaves unset taken from base. The URL
+// and its credentials are one setting: a file with a url replaces all three.
+func (p *ProxyConfig) over(base *ProxyConfig) *ProxyConfig {
+	switch {
+	case p == nil:
+		return base
+	case base == nil:
+		return p
+	}
+	merged := *p
+	if merged.URL == "" {
+		merged.URL, merged.Username, merged.Password = base.URL, base.Username, base.Password
+	}
+	if len(merged.NoProxy) == 0 {
+		merged.NoProxy = base.NoProxy
+	}
+	return &merged
+}
+
+// envProxy returns the environment's proxy from secretsMap, or nil when it
+// has none.
+func envProxy(secretsMap map[string]any) (*ProxyConfig, error) {
+	var cfg ProxyConfig
+	switch raw := secretsMap[ProxySecret].(type) {
+	case string:
+		cfg.URL = raw
+	case map[string]any:
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s secret: %w", ProxySecret, err)
+		}
+		if err := yaml.Unmarshal(b, &cfg); err != nil {
+			return nil, fmt.Errorf("invalid %s secret: %w", ProxySecret, err)
+		}
+	default:
+		return nil, nil
+	}
+	if valid, err := cfg.IsValid(); !valid {
+		return nil, fmt.Errorf("invalid %s secret: %w", ProxySecret, err)
+	}
+	return &cfg, nil
+}
+
+// proxies caches built proxies by their settings, so requests with the same
+// settings share one *httpclient.Proxy, and with it one HTTP client and its
+// connections.
+var proxies sync.Map
+
+// resolveProxy builds the proxy of a request file: its proxy section over
+// the environment's (see ProxySecret), setting by setting. It returns nil
+// when neither has one.
+func resolveProxy(file *ProxyConfig, secretsMap map[string]any) (*httpclient.Proxy, error) {
+	env, err := envProxy(secretsMap)
+	if err != nil {
+		return nil, err
+	}
+	p := file.over(env)
+	if p == nil {
+		return nil, nil
+	}
+
+	proxy := &httpclient.Proxy{NoProxy: p.NoProxy}
+	if p.URL != "" {
+		if proxy.URL, err = httpclient.ParseProxyURL(p.URL); err != nil {
+			return nil, err
+		}
+		if p.Username != "" || p.Password != "" {
+			proxy.URL.User = url.UserPassword(p.Username, p.Password)
+		}
+	}
+
+	var key string
+	if proxy.URL != nil {
+		key = proxy.URL.String()
+	}
+	key += "\n" + strings.Join(p.NoProxy, ",")
+	actual, _ := proxies.LoadOrStore(key, proxy)
+	return actual.(*httpclient.Proxy), nil
+}
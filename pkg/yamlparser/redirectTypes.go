@@ -0,0 +1,96 @@
+package yamlparser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xaaha/hulak/pkg/httpclient"
+)
+
+// Shorthands of the `redirects:` setting.
+const (
+	RedirectsFollow = "follow"
+	RedirectsNone   = "none"
+)
+
+// Redirects represents the optional `redirects:` setting of a request
+// file. It may be written as a shorthand:
+//
+//	redirects: follow  # up to 10 redirects
+//	redirects: none    # the first redirect is the response
+//	redirects: 3       # up to 3 redirects
+//
+// Without it, a request follows up to 10 redirects and fails on more, and
+// never follows one from HTTPS to HTTP.
+type Redirects struct {
+	// Follow is false to stop at the first redirect. Defaults to true.
+	Follow *bool `json:"follow,omitempty"          yaml:"follow"`
+	// Max is how many redirects to follow; the next one is returned as the
+	// response. Defaults to 10.
+	Max *int `json:"max,omitempty"             yaml:"max"`
+	// AllowDowngrade follows redirects from HTTPS to HTTP.
+	AllowDowngrade bool `json:"allow_downgrade,omitempty" yaml:"allow_downgrade"`
+}
+
+// UnmarshalYAML accepts a shorthand or the full section.
+func (r *Redirects) UnmarshalYAML(unmarshal func(any) error) error {
+	var shorthand string
+	if err := unmarshal(&shorthand); err == nil {
+		follow := true
+		switch shorthand = strings.ToLower(strings.TrimSpace(shorthand)); shorthand {
+		case RedirectsFollow:
+		case RedirectsNone:
+			follow = false
+		default:
+			n, err := strconv.Atoi(shorthand)
+			if err != nil {
+				return fmt.Errorf(
+					"redirects must be %s, %s, a number, or a section, got %q",
+					RedirectsFollow, RedirectsNone, shorthand,
+				)
+			}
+			*r = Redirects{Max: &n}
+			return nil
+		}
+		*r = Redirects{Follow: &follow}
+		return nil
+	}
+	type section Redirects
+	return unmarshal((*section)(r))
+}
+
+// IsValid checks that max is not negative and isn't set with follow:
+// false. A nil section is valid.
+func (r *Redirects) IsValid() (bool, error) {
+	if r == nil {
+		return true, nil
+	}
+	if r.Max != nil && *r.Max < 0 {
+		return false, fmt.Errorf("max must not be negative, got %d", *r.Max)
+	}
+	if r.Follow != nil && !*r.Follow && r.Max != nil && *r.Max > 0 {
+		return false, errors.New("max has no effect with follow: false")
+	}
+	return true, nil
+}
+
+// Policy returns the redirect policy of the section, or nil for the
+// default one.
+func (r *Redirects) Policy() *httpclient.RedirectPolicy {
+	if r == nil {
+		return nil
+	}
+	policy := &httpclient.RedirectPolicy{
+		Max:            httpclient.DefaultMaxRedirects,
+		AllowDowngrade: r.AllowDowngrade,
+	}
+	switch {
+	case r.Follow != nil && !*r.Follow:
+		policy.Max = 0
+	case r.Max != nil:
+		policy.Max = *r.Max
+	}
+	return policy
+}
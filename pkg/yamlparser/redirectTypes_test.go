@@ -0,0 +1,72 @@
+package yamlparser
+
+import (
+	"strings"
+	"testing"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/xaaha/hulak/pkg/httpclient"
+)
+
+func TestRedirects(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    *httpclient.RedirectPolicy
+		wantErr string
+	}{
+		{name: "no setting", yaml: "method: GET\n"},
+		{
+			name: "follow",
+			yaml: "redirects: follow\n",
+			want: &httpclient.RedirectPolicy{Max: httpclient.DefaultMaxRedirects},
+		},
+		{name: "none", yaml: "redirects: None\n", want: &httpclient.RedirectPolicy{}},
+		{name: "a number", yaml: "redirects: 3\n", want: &httpclient.RedirectPolicy{Max: 3}},
+		{name: "a number from a template", yaml: "redirects: '2'\n", want: &httpclient.RedirectPolicy{Max: 2}},
+		{
+			name: "section",
+			yaml: "redirects:\n  max: 5\n  allow_downgrade: true\n",
+			want: &httpclient.RedirectPolicy{Max: 5, AllowDowngrade: true},
+		},
+		{
+			name: "downgrades only",
+			yaml: "redirects:\n  allow_downgrade: true\n",
+			want: &httpclient.RedirectPolicy{Max: httpclient.DefaultMaxRedirects, AllowDowngrade: true},
+		},
+		{
+			name: "follow false",
+			yaml: "redirects:\n  follow: false\n",
+			want: &httpclient.RedirectPolicy{},
+		},
+		{name: "unknown shorthand", yaml: "redirects: always\n", wantErr: "redirects must be follow, none"},
+		{name: "negative max", yaml: "redirects: -1\n", wantErr: "max must not be negative"},
+		{
+			name:    "max without following",
+			yaml:    "redirects:\n  follow: false\n  max: 2\n",
+			wantErr: "max has no effect with follow: false",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var file APICallFile
+			err := yaml.Unmarshal([]byte(tc.yaml), &file)
+			if err == nil {
+				_, err = file.Redirects.IsValid()
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := file.Redirects.Policy()
+			if (got == nil) != (tc.want == nil) || (got != nil && *got != *tc.want) {
+				t.Errorf("Policy() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
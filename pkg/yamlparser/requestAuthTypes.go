@@ -0,0 +1,294 @@
+package yamlparser
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/xaaha/hulak/pkg/actions"
+)
+
+// Values of `auth.type` on a request. SigV4, HMAC, and Digest sign each
+// HTTP request as it is sent, so they don't apply to WebSocket or gRPC.
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthAPIKey = "apikey"
+	AuthSigV4  = "sigv4"
+	AuthHMAC   = "hmac"
+	AuthDigest = "digest"
+)
+
+// Where an apikey credential goes, `auth.in`.
+const (
+	APIKeyInHeader = "header"
+	APIKeyInQuery  = "query"
+)
+
+// DefaultAPIKeyName is the header an apikey is sent in when `auth.name`
+// is unset.
+const DefaultAPIKeyName = "X-API-Key"
+
+// DefaultSignatureName is the header an hmac signature is sent in when
+// `auth.name` is unset.
+const DefaultSignatureName = "X-Signature"
+
+// Encodings of an hmac signature, `auth.encoding`.
+const (
+	SignatureHex    = "hex"
+	SignatureBase64 = "base64"
+)
+
+// authorizationHeader is the header set by use, bearer, and basic.
+const authorizationHeader = "Authorization"
+
+// RequestAuth represents the optional `auth:` section of an API or GraphQL
+// request: the credentials hulak adds to it, so the file doesn't hand-write
+// an Authorization header. Set either Use or Type.
+type RequestAuth struct {
+	// Use names an Auth-kind file, as getValueOf does: login for
+	// login.hk.yaml, or a path. Its access_token is sent as a bearer
+	// token; in a vault project, a cached token is renewed once expired.
+	Use string `json:"use,omitempty"      yaml:"use"`
+	// Type is "bearer", "basic", or "apikey".
+	Type string `json:"type,omitempty"     yaml:"type"`
+	// Token is the bearer token.
+	Token string `json:"token,omitempty"    yaml:"token"`
+	// Username and Password are the basic credentials.
+	Username string `json:"username,omitempty" yaml:"username"`
+	Password string `json:"password,omitempty" yaml:"password"`
+	// Key is the API key, sent in the header or query parameter Name, or
+	// the hmac secret.
+	Key  string `json:"key,omitempty"      yaml:"key"`
+	Name string `json:"name,omitempty"     yaml:"name"`
+	// In is "header" (default) or "query".
+	In string `json:"in,omitempty"       yaml:"in"`
+
+	// AccessKey, SecretKey, and the optional SessionToken are the AWS
+	// credentials sigv4 signs with, for Service in Region.
+	AccessKey    string `json:"access_key,omitempty"       yaml:"access_key"`
+	SecretKey    string `json:"secret_key,omitempty"       yaml:"secret_key"`
+	SessionToken string `json:"session_token,omitempty"    yaml:"session_token"`
+	Region       string `json:"region,omitempty"           yaml:"region"`
+	Service      string `json:"service,omitempty"          yaml:"service"`
+
+	// StringToSign is the hmac canonical string, with placeholders such as
+	// {method} and {body_sha256} filled in per request. Key is the secret,
+	// and the signature goes in the header Name.
+	StringToSign string `json:"string_to_sign,omitempty"   yaml:"string_to_sign"`
+	// Encoding is "hex" (default) or "base64".
+	Encoding string `json:"encoding,omitempty"         yaml:"encoding"`
+	// Prefix goes before the signature in the header, e.g. "HMAC ".
+	Prefix string `json:"prefix,omitempty"           yaml:"prefix"`
+	// TimestampHeader and NonceHeader, when set, send the {timestamp} and
+	// {nonce} the signature covers, so the server can check it.
+	TimestampHeader string `json:"timestamp_header,omitempty" yaml:"timestamp_header"`
+	NonceHeader     string `json:"nonce_header,omitempty"     yaml:"nonce_header"`
+}
+
+// IsValid checks that the section names one complete credential. A nil
+// RequestAuth is valid.
+func (a *RequestAuth) IsValid() (bool, error) {
+	if a == nil {
+		return true, nil
+	}
+	switch {
+	case a.Use != "" && a.Type != "":
+		return false, errors.New("use and type can't both be set")
+	case a.Use != "":
+		return true, nil
+	}
+
+	switch strings.ToLower(a.Type) {
+	case "":
+		return false, errors.New("set use to an Auth file, or type to bearer, basic, apikey, sigv4, hmac, or digest")
+	case AuthBearer:
+		if a.Token == "" {
+			return false, errors.New("bearer needs a token")
+		}
+	case AuthBasic, AuthDigest:
+		if a.Username == "" {
+			return false, fmt.Errorf("%s needs a username", strings.ToLower(a.Type))
+		}
+	case AuthAPIKey:
+		if a.Key == "" {
+			return false, errors.New("apikey needs a key")
+		}
+		if in := strings.ToLower(a.In); in != "" && in != APIKeyInHeader && in != APIKeyInQuery {
+			return false, fmt.Errorf("invalid in %q; use header or query", a.In)
+		}
+	case AuthSigV4:
+		if a.AccessKey == "" || a.SecretKey == "" {
+			return false, errors.New("sigv4 needs access_key and secret_key")
+		}
+		if a.Region == "" || a.Service == "" {
+			return false, errors.New("sigv4 needs region and service")
+		}
+	case AuthHMAC:
+		if a.Key == "" || a.StringToSign == "" {
+			return false, errors.New("hmac needs a key and string_to_sign")
+		}
+		if enc := strings.ToLower(a.Encoding); enc != "" && enc != SignatureHex && enc != SignatureBase64 {
+			return false, fmt.Errorf("invalid encoding %q; use hex or base64", a.Encoding)
+		}
+	default:
+		return false, fmt.Errorf(
+			"unsupported type %q; use bearer, basic, apikey, sigv4, hmac, or digest", a.Type,
+		)
+	}
+	return true, nil
+}
+
+// Signs reports whether the credential is computed for each HTTP request
+// as it is sent (sigv4, hmac, digest) rather than added to the file's
+// headers up front.
+func (a *RequestAuth) Signs() bool {
+	if a == nil || a.Use != "" {
+		return false
+	}
+	switch strings.ToLower(a.Type) {
+	case AuthSigV4, AuthHMAC, AuthDigest:
+		return true
+	}
+	return false
+}
+
+// SignatureName returns the header an hmac signature is sent in.
+func (a *RequestAuth) SignatureName() string {
+	if a.Name == "" {
+		return DefaultSignatureName
+	}
+	return a.Name
+}
+
+// target returns where the credential goes: the header or query parameter
+// name, and whether it is a query parameter.
+func (a *RequestAuth) target() (string, bool) {
+	if a.Use != "" || strings.ToLower(a.Type) != AuthAPIKey {
+		return authorizationHeader, false
+	}
+	name := a.Name
+	if name == "" {
+		name = DefaultAPIKeyName
+	}
+	return name, strings.ToLower(a.In) == APIKeyInQuery
+}
+
+// BearerToken is the credential of an `auth: use:` section, the
+// access_token of an Auth-kind file. It is read when the request is sent,
+// not when the file is built, so building a request, e.g. for a dry run,
+// never reads or renews a token. Until then the Authorization header
+// holds a placeholder naming the file.
+type BearerToken struct {
+	// From names the Auth-kind file, as RequestAuth.Use does.
+	From string
+	// getValueOf reads the token, with the run's actions.ResponseSource.
+	getValueOf func(key, fileName string) any
+}
+
+// placeholder is the Authorization header until the token is read.
+func (b *BearerToken) placeholder() string {
+	return fmt.Sprintf("Bearer <access_token from %s>", b.From)
+}
+
+// Apply returns a copy of headers with the token in the Authorization
+// header. A nil BearerToken returns headers as is.
+func (b *BearerToken) Apply(headers map[string]string) (map[string]string, error) {
+	if b == nil {
+		return headers, nil
+	}
+	token, _ := b.getValueOf("access_token", b.From).(string)
+	if token == "" {
+		return nil, fmt.Errorf("no access_token from %q; run it first", b.From)
+	}
+	withToken := maps.Clone(headers)
+	if withToken == nil {
+		withToken = make(map[string]string)
+	}
+	withToken[authorizationHeader] = "Bearer " + token
+	return withToken, nil
+}
+
+// bearer returns the BearerToken of a use section, reading the token with
+// the getValueOf of secretsMap, or nil for other sections.
+func (a *RequestAuth) bearer(secretsMap map[string]any) *BearerToken {
+	if a == nil || a.Use == "" {
+		return nil
+	}
+	return &BearerToken{From: a.Use, getValueOf: actions.GetValueOfWith(secretsMap)}
+}
+
+// credential returns the header or query parameter value. A use section
+// gets its placeholder; see BearerToken.
+func (a *RequestAuth) credential() (string, error) {
+	switch {
+	case a.Use != "":
+		return (&BearerToken{From: a.Use}).placeholder(), nil
+	case strings.ToLower(a.Type) == AuthBearer:
+		return "Bearer " + a.Token, nil
+	case strings.ToLower(a.Type) == AuthBasic:
+		return actions.BasicAuth(a.Username, a.Password), nil
+	default:
+		return a.Key, nil
+	}
+}
+
+// apply adds the credential to headers, or to params for an apikey in the
+// query. A hand-written header or parameter of the same name is an error,
+// since only one of them can be sent. params is nil for a kind without
+// query parameters.
+func (a *RequestAuth) apply(headers, params *map[string]string) error {
+	if a == nil || a.Signs() {
+		return nil
+	}
+	name, inQuery := a.target()
+	switch {
+	case inQuery && params == nil:
+		return errors.New("in: query isn't supported by this kind; use header")
+	case inQuery:
+		if _, ok := (*params)[name]; ok {
+			return fmt.Errorf("urlparams and auth both set %s", name)
+		}
+	case hasHeader(*headers, name):
+		return fmt.Errorf("headers and auth both set %s", name)
+	}
+
+	value, err := a.credential()
+	if err != nil {
+		return err
+	}
+	target := headers
+	if inQuery {
+		target = params
+	}
+	if *target == nil {
+		*target = make(map[string]string)
+	}
+	(*target)[name] = value
+	return nil
+}
+
+// applyAuth adds the auth section's credential to the request.
+func (user *APICallFile) applyAuth(secretsMap map[string]any) error {
+	user.bearer = user.Auth.bearer(secretsMap)
+	return user.Auth.apply(&user.Headers, &user.URLParams)
+}
+
+// applyAuth adds the auth section's credential to the opening handshake.
+func (ws *WebSocketFile) applyAuth(secretsMap map[string]any) error {
+	if ws.Auth.Signs() {
+		return fmt.Errorf("type %s only applies to HTTP requests", ws.Auth.Type)
+	}
+	ws.bearer = ws.Auth.bearer(secretsMap)
+	return ws.Auth.apply(&ws.Headers, &ws.URLParams)
+}
+
+// applyAuth adds the auth section's credential to the request metadata.
+func (g *GRPCFile) applyAuth(secretsMap map[string]any) error {
+	if g.Auth.Signs() {
+		return fmt.Errorf("type %s only applies to HTTP requests", g.Auth.Type)
+	}
+	g.bearer = g.Auth.bearer(secretsMap)
+	return g.Auth.apply(&g.Headers, nil)
+}
@@ -0,0 +1,224 @@
+package yamlparser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xaaha/hulak/pkg/actions"
+)
+
+func TestRequestAuth_IsValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    *RequestAuth
+		wantErr string
+	}{
+		{name: "nil", auth: nil},
+		{name: "use", auth: &RequestAuth{Use: "login"}},
+		{name: "bearer", auth: &RequestAuth{Type: "Bearer", Token: "t"}},
+		{name: "basic without password", auth: &RequestAuth{Type: "basic", Username: "ada"}},
+		{name: "apikey in query", auth: &RequestAuth{Type: "apikey", Key: "k", In: "query"}},
+		{name: "use and type", auth: &RequestAuth{Use: "login", Type: "bearer"}, wantErr: "use and type can't both be set"},
+		{name: "empty", auth: &RequestAuth{}, wantErr: "set use to an Auth file"},
+		{name: "bearer without token", auth: &RequestAuth{Type: "bearer"}, wantErr: "bearer needs a token"},
+		{name: "basic without username", auth: &RequestAuth{Type: "basic", Password: "p"}, wantErr: "basic needs a username"},
+		{name: "apikey without key", auth: &RequestAuth{Type: "apikey"}, wantErr: "apikey needs a key"},
+		{name: "apikey in body", auth: &RequestAuth{Type: "apikey", Key: "k", In: "body"}, wantErr: `invalid in "body"`},
+		{name: "sigv4", auth: &RequestAuth{Type: "sigv4", AccessKey: "a", SecretKey: "s", Region: "us-east-1", Service: "s3"}},
+		{name: "sigv4 without region", auth: &RequestAuth{Type: "sigv4", AccessKey: "a", SecretKey: "s", Service: "s3"}, wantErr: "sigv4 needs region and service"},
+		{name: "sigv4 without secret", auth: &RequestAuth{Type: "sigv4", AccessKey: "a", Region: "r", Service: "s"}, wantErr: "sigv4 needs access_key and secret_key"},
+		{name: "hmac", auth: &RequestAuth{Type: "hmac", Key: "k", StringToSign: "{method}", Encoding: "base64"}},
+		{name: "hmac without string_to_sign", auth: &RequestAuth{Type: "hmac", Key: "k"}, wantErr: "hmac needs a key and string_to_sign"},
+		{name: "hmac with bad encoding", auth: &RequestAuth{Type: "hmac", Key: "k", StringToSign: "x", Encoding: "base32"}, wantErr: `invalid encoding "base32"`},
+		{name: "digest", auth: &RequestAuth{Type: "digest", Username: "ada", Password: "pw"}},
+		{name: "digest without username", auth: &RequestAuth{Type: "digest"}, wantErr: "digest needs a username"},
+		{name: "unknown type", auth: &RequestAuth{Type: "ntlm"}, wantErr: `unsupported type "ntlm"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.auth.IsValid()
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Errorf("IsValid() = %v, %v; want valid", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("IsValid() = %v, %v; want error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPICallFile_applyAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        APICallFile
+		wantHeaders map[string]string
+		wantParams  map[string]string
+		wantErr     string
+	}{
+		{
+			name:        "use holds a placeholder until the request is sent",
+			file:        APICallFile{Auth: &RequestAuth{Use: "login.hk.yaml"}},
+			wantHeaders: map[string]string{"Authorization": "Bearer <access_token from login.hk.yaml>"},
+		},
+		{
+			name:    "use with a hand-written authorization header",
+			file:    APICallFile{Headers: map[string]string{"Authorization": "Bearer old"}, Auth: &RequestAuth{Use: "login.hk.yaml"}},
+			wantErr: "headers and auth both set Authorization",
+		},
+		{
+			name:        "bearer",
+			file:        APICallFile{Headers: map[string]string{"Accept": "application/json"}, Auth: &RequestAuth{Type: "bearer", Token: "t"}},
+			wantHeaders: map[string]string{"Accept": "application/json", "Authorization": "Bearer t"},
+		},
+		{
+			name:        "basic",
+			file:        APICallFile{Auth: &RequestAuth{Type: "basic", Username: "ada", Password: "pw"}},
+			wantHeaders: map[string]string{"Authorization": "Basic YWRhOnB3"},
+		},
+		{
+			name:        "apikey in the default header",
+			file:        APICallFile{Auth: &RequestAuth{Type: "apikey", Key: "k-1"}},
+			wantHeaders: map[string]string{"X-API-Key": "k-1"},
+		},
+		{
+			name:       "apikey in a query parameter",
+			file:       APICallFile{URLParams: map[string]string{"q": "x"}, Auth: &RequestAuth{Type: "apikey", Key: "k-1", Name: "api_key", In: "query"}},
+			wantParams: map[string]string{"q": "x", "api_key": "k-1"},
+		},
+		{
+			name:    "hand-written authorization header",
+			file:    APICallFile{Headers: map[string]string{"authorization": "Bearer old"}, Auth: &RequestAuth{Type: "bearer", Token: "t"}},
+			wantErr: "headers and auth both set Authorization",
+		},
+		{
+			name:    "query parameter set twice",
+			file:    APICallFile{URLParams: map[string]string{"api_key": "old"}, Auth: &RequestAuth{Type: "apikey", Key: "k", Name: "api_key", In: "query"}},
+			wantErr: "urlparams and auth both set api_key",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.file.applyAuth(nil)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("applyAuth() = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyAuth() = %v", err)
+			}
+			if len(tc.file.Headers) != len(tc.wantHeaders) {
+				t.Errorf("headers = %v, want %v", tc.file.Headers, tc.wantHeaders)
+			}
+			for k, v := range tc.wantHeaders {
+				if tc.file.Headers[k] != v {
+					t.Errorf("header %s = %q, want %q", k, tc.file.Headers[k], v)
+				}
+			}
+			for k, v := range tc.wantParams {
+				if tc.file.URLParams[k] != v {
+					t.Errorf("urlparam %s = %q, want %q", k, tc.file.URLParams[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestBearerToken_Apply(t *testing.T) {
+	dir := t.TempDir()
+	login := filepath.Join(dir, "login.hk.yaml")
+	if err := os.WriteFile(login, []byte("kind: Auth\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	response := `{"access_token": "tok-1", "token_type": "bearer"}`
+	if err := os.WriteFile(filepath.Join(dir, "login.hk_response.json"), []byte(response), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	source := actions.ResponseSource(func(string) (any, time.Time, bool) {
+		calls++
+		return nil, time.Time{}, false
+	})
+	secrets := actions.WithResponseSource(map[string]any{}, source)
+
+	file := APICallFile{Headers: map[string]string{"Accept": "application/json"}, Auth: &RequestAuth{Use: login}}
+	if err := file.applyAuth(secrets); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Errorf("applyAuth() asked the token source %d times, want none before the request is sent", calls)
+	}
+
+	headers, err := file.bearer.Apply(file.Headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["Authorization"] != "Bearer tok-1" || headers["Accept"] != "application/json" {
+		t.Errorf("Apply() = %v, want the token and the file's headers", headers)
+	}
+	if file.Headers["Authorization"] != "Bearer <access_token from "+login+">" {
+		t.Errorf("Apply() changed the file's headers to %v", file.Headers)
+	}
+
+	missing := &BearerToken{From: filepath.Join(dir, "missing.hk.yaml"), getValueOf: actions.GetValueOfWith(nil)}
+	if _, err := missing.Apply(nil); err == nil || !strings.Contains(err.Error(), "no access_token from") {
+		t.Errorf("Apply() before the auth file ran = %v, want an error", err)
+	}
+
+	var none *BearerToken
+	if got, err := none.Apply(file.Headers); err != nil || got["Authorization"] != file.Headers["Authorization"] {
+		t.Errorf("nil Apply() = %v, %v; want the headers as is", got, err)
+	}
+}
+
+func TestRequestAuth_OtherKinds(t *testing.T) {
+	ws := WebSocketFile{Auth: &RequestAuth{Type: "apikey", Key: "k", Name: "token", In: "query"}}
+	if err := ws.applyAuth(nil); err != nil || ws.URLParams["token"] != "k" {
+		t.Errorf("websocket applyAuth() = %v, urlparams %v", err, ws.URLParams)
+	}
+
+	g := GRPCFile{Auth: &RequestAuth{Type: "bearer", Token: "t"}}
+	if err := g.applyAuth(nil); err != nil || g.Headers["Authorization"] != "Bearer t" {
+		t.Errorf("grpc applyAuth() = %v, headers %v", err, g.Headers)
+	}
+	g = GRPCFile{Auth: &RequestAuth{Type: "apikey", Key: "k", In: "query"}}
+	if err := g.applyAuth(nil); err == nil {
+		t.Error("grpc applyAuth() with in: query = nil, want an error")
+	}
+
+	ws = WebSocketFile{Auth: &RequestAuth{Type: "digest", Username: "u"}}
+	if err := ws.applyAuth(nil); err == nil || !strings.Contains(err.Error(), "only applies to HTTP requests") {
+		t.Errorf("websocket applyAuth() with digest = %v, want an error", err)
+	}
+}
+
+func TestAPICallFile_Signing(t *testing.T) {
+	auth := &RequestAuth{Type: "HMAC", Key: "k", StringToSign: "{method}"}
+	file := APICallFile{Method: "GET", URL: "https://api.example.com", Auth: auth}
+	if err := file.applyAuth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Headers) != 0 {
+		t.Errorf("headers = %v, want none until the request is signed", file.Headers)
+	}
+	info, err := file.PrepareStruct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Signing != auth {
+		t.Errorf("Signing = %v, want the auth section", info.Signing)
+	}
+
+	file.Auth = &RequestAuth{Type: "bearer", Token: "t"}
+	if info, _ := file.PrepareStruct(); info.Signing != nil {
+		t.Errorf("Signing = %v for bearer, want nil", info.Signing)
+	}
+}
@@ -0,0 +1,190 @@
+package yamlparser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Backoff strategies for the `retry:` section.
+const (
+	BackoffExponential = "exponential"
+	BackoffConstant    = "constant"
+)
+
+// Transport error classes that `retry.errors` can list.
+const (
+	RetryOnTimeout    = "timeout"
+	RetryOnConnection = "connection"
+)
+
+// Retry defaults, used for any field the section leaves out and for files
+// retried through `hulak run --retries`.
+const (
+	DefaultRetryAttempts = 3
+	DefaultRetryDelay    = 500 * time.Millisecond
+	DefaultRetryMaxDelay = 10 * time.Second
+)
+
+// DefaultRetryStatus are the status codes retried when `retry.status` is
+// unset: rate limiting and the gateway errors a flaky upstream produces.
+var DefaultRetryStatus = []int{429, 502, 503, 504}
+
+// Retry represents the optional `retry:` section of a request file. A file
+// without one is sent once.
+type Retry struct {
+	// Attempts is the total number of tries, including the first.
+	Attempts int `json:"attempts,omitempty"  yaml:"attempts"`
+	// Backoff is "exponential" (default; the delay doubles after each try)
+	// or "constant".
+	Backoff string `json:"backoff,omitempty"   yaml:"backoff"`
+	// Delay is the wait before the first retry, as a Go duration.
+	Delay string `json:"delay,omitempty"     yaml:"delay"`
+	// MaxDelay caps any single wait, including a server's Retry-After.
+	MaxDelay string `json:"max_delay,omitempty" yaml:"max_delay"`
+	// AttemptTimeout bounds each attempt, as a Go duration, so a server
+	// that hangs is retried as a timeout. Unset, an attempt can take the
+	// file's whole timeout.
+	AttemptTimeout string `json:"attempt_timeout,omitempty" yaml:"attempt_timeout"`
+	// Jitter randomizes each wait to between half and all of its value so
+	// parallel runs don't retry in lockstep.
+	Jitter bool `json:"jitter,omitempty"    yaml:"jitter"`
+	// Status lists the response codes that trigger a retry.
+	Status StatusCodes `json:"status,omitempty"    yaml:"status"`
+	// Errors lists the transport error classes that trigger a retry:
+	// "timeout" and "connection". Unset means both; an empty list means
+	// none.
+	Errors []string `json:"errors,omitempty"    yaml:"errors"`
+}
+
+// RetryPolicy is a Retry with defaults applied and durations parsed.
+type RetryPolicy struct {
+	Attempts       int
+	Constant       bool
+	Delay          time.Duration
+	MaxDelay       time.Duration
+	AttemptTimeout time.Duration
+	Jitter         bool
+	Status         []int
+	OnTimeout      bool
+	OnConnection   bool
+}
+
+// IsValid checks that the section resolves to a usable policy. A nil Retry
+// is valid.
+func (r *Retry) IsValid() (bool, error) {
+	if _, err := r.Policy(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Policy applies defaults and parses durations. A nil Retry yields a policy
+// of a single attempt.
+func (r *Retry) Policy() (RetryPolicy, error) {
+	if r == nil {
+		return RetryPolicy{Attempts: 1}, nil
+	}
+
+	p := RetryPolicy{
+		Attempts:     DefaultRetryAttempts,
+		Delay:        DefaultRetryDelay,
+		MaxDelay:     DefaultRetryMaxDelay,
+		Jitter:       r.Jitter,
+		Status:       DefaultRetryStatus,
+		OnTimeout:    true,
+		OnConnection: true,
+	}
+
+	switch {
+	case r.Attempts < 0:
+		return RetryPolicy{}, fmt.Errorf("retry.attempts must be at least 1, got %d", r.Attempts)
+	case r.Attempts > 0:
+		p.Attempts = r.Attempts
+	}
+
+	switch strings.ToLower(r.Backoff) {
+	case "", BackoffExponential:
+	case BackoffConstant:
+		p.Constant = true
+	default:
+		return RetryPolicy{}, fmt.Errorf(
+			"retry.backoff must be %q or %q, got %q", BackoffExponential, BackoffConstant, r.Backoff,
+		)
+	}
+
+	var err error
+	if p.Delay, err = parseRetryDuration("delay", r.Delay, p.Delay); err != nil {
+		return RetryPolicy{}, err
+	}
+	if p.MaxDelay, err = parseRetryDuration("max_delay", r.MaxDelay, p.MaxDelay); err != nil {
+		return RetryPolicy{}, err
+	}
+	if p.AttemptTimeout, err = parseRetryDuration("attempt_timeout", r.AttemptTimeout, 0); err != nil {
+		return RetryPolicy{}, err
+	}
+	if p.MaxDelay < p.Delay {
+		return RetryPolicy{}, fmt.Errorf(
+			"retry.max_delay (%s) is shorter than retry.delay (%s)", p.MaxDelay, p.Delay,
+		)
+	}
+
+	if len(r.Status) > 0 {
+		p.Status = r.Status
+	}
+
+	if r.Errors != nil {
+		p.OnTimeout = false
+		p.OnConnection = false
+		for _, class := range r.Errors {
+			switch strings.ToLower(strings.TrimSpace(class)) {
+			case RetryOnTimeout:
+				p.OnTimeout = true
+			case RetryOnConnection:
+				p.OnConnection = true
+			default:
+				return RetryPolicy{}, fmt.Errorf(
+					"retry.errors: unknown class %q (use %q or %q)",
+					class, RetryOnTimeout, RetryOnConnection,
+				)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// RetriesStatus reports whether a response with this status code should be
+// retried.
+func (p *RetryPolicy) RetriesStatus(code int) bool {
+	return slices.Contains(p.Status, code)
+}
+
+// Backoff returns the wait before retry number n (1 for the first retry),
+// before jitter and capped at MaxDelay.
+func (p *RetryPolicy) Backoff(n int) time.Duration {
+	d := p.Delay
+	if !p.Constant {
+		for i := 1; i < n && d < p.MaxDelay; i++ {
+			d *= 2
+		}
+	}
+	return min(d, p.MaxDelay)
+}
+
+// parseRetryDuration parses a non-negative retry duration, returning def
+// when value is empty.
+func parseRetryDuration(field, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("retry.%s %q: %w", field, value, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("retry.%s must not be negative, got %q", field, value)
+	}
+	return d, nil
+}
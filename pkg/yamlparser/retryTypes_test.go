@@ -0,0 +1,155 @@
+package yamlparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetry_Policy(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   *Retry
+		want    RetryPolicy
+		wantErr string
+	}{
+		{"nil retry sends once", nil, RetryPolicy{Attempts: 1}, ""},
+		{
+			"empty section uses defaults",
+			&Retry{},
+			RetryPolicy{
+				Attempts:     DefaultRetryAttempts,
+				Delay:        DefaultRetryDelay,
+				MaxDelay:     DefaultRetryMaxDelay,
+				Status:       DefaultRetryStatus,
+				OnTimeout:    true,
+				OnConnection: true,
+			},
+			"",
+		},
+		{
+			"every field set",
+			&Retry{
+				Attempts:       5,
+				Backoff:        "Constant",
+				Delay:          "1s",
+				MaxDelay:       "2s",
+				AttemptTimeout: "3s",
+				Jitter:         true,
+				Status:         StatusCodes{503},
+				Errors:         []string{"timeout"},
+			},
+			RetryPolicy{
+				Attempts:       5,
+				Constant:       true,
+				Delay:          time.Second,
+				MaxDelay:       2 * time.Second,
+				AttemptTimeout: 3 * time.Second,
+				Jitter:         true,
+				Status:         []int{503},
+				OnTimeout:      true,
+			},
+			"",
+		},
+		{
+			"empty errors list disables transport retries",
+			&Retry{Errors: []string{}},
+			RetryPolicy{
+				Attempts: DefaultRetryAttempts,
+				Delay:    DefaultRetryDelay,
+				MaxDelay: DefaultRetryMaxDelay,
+				Status:   DefaultRetryStatus,
+			},
+			"",
+		},
+		{"negative attempts", &Retry{Attempts: -1}, RetryPolicy{}, "retry.attempts"},
+		{"unknown backoff", &Retry{Backoff: "linear"}, RetryPolicy{}, "retry.backoff"},
+		{"bad delay", &Retry{Delay: "10"}, RetryPolicy{}, "retry.delay"},
+		{"negative attempt timeout", &Retry{AttemptTimeout: "-1s"}, RetryPolicy{}, "retry.attempt_timeout"},
+		{"max below delay", &Retry{Delay: "5s", MaxDelay: "1s"}, RetryPolicy{}, "shorter than"},
+		{"unknown error class", &Retry{Errors: []string{"dns"}}, RetryPolicy{}, `unknown class "dns"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.retry.Policy()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tc.wantErr)
+				}
+				if valid, _ := tc.retry.IsValid(); valid {
+					t.Error("IsValid() = true for an invalid section")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Policy() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	exp := RetryPolicy{Delay: 100 * time.Millisecond, MaxDelay: time.Second}
+	constant := exp
+	constant.Constant = true
+
+	tests := []struct {
+		policy RetryPolicy
+		n      int
+		want   time.Duration
+	}{
+		{exp, 1, 100 * time.Millisecond},
+		{exp, 2, 200 * time.Millisecond},
+		{exp, 4, 800 * time.Millisecond},
+		{exp, 5, time.Second},
+		{exp, 50, time.Second},
+		{constant, 1, 100 * time.Millisecond},
+		{constant, 4, 100 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		if got := tc.policy.Backoff(tc.n); got != tc.want {
+			t.Errorf("Backoff(%d) constant=%v = %v, want %v", tc.n, tc.policy.Constant, got, tc.want)
+		}
+	}
+}
+
+func TestFinalStructForAPI_RetrySection(t *testing.T) {
+	content := `
+method: GET
+url: https://example.com
+retry:
+  attempts: 4
+  backoff: constant
+  delay: 100ms
+  status: [502, 503]
+  errors: []
+`
+	path := createTempYAMLFile(t, content)
+	file, valid, err := FinalStructForAPI(path, map[string]any{})
+	if err != nil || !valid {
+		t.Fatalf("FinalStructForAPI() = %v, %v", valid, err)
+	}
+	policy, err := file.Retry.Policy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Attempts != 4 || !policy.Constant || policy.Delay != 100*time.Millisecond {
+		t.Errorf("policy = %+v", policy)
+	}
+	if !reflect.DeepEqual(policy.Status, []int{502, 503}) {
+		t.Errorf("Status = %v", policy.Status)
+	}
+	if policy.OnTimeout || policy.OnConnection {
+		t.Errorf("errors: [] should disable transport retries, got %+v", policy)
+	}
+
+	bad := createTempYAMLFile(t, "method: GET\nurl: https://example.com\nretry:\n  backoff: linear\n")
+	if _, valid, err := FinalStructForAPI(bad, map[string]any{}); valid || err == nil {
+		t.Errorf("expected invalid retry section to fail validation, got %v, %v", valid, err)
+	}
+}
@@ -0,0 +1,260 @@
+package yamlparser
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+	"sync"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// TLSSecret is the vault key holding an environment's default tls section,
+// as a JSON object with the same keys, e.g.
+//
+//	hulak secrets keys set tls --type json --stdin --env prod
+//
+// Other value types under this key are ignored.
+const TLSSecret = "tls"
+
+// tlsVersions maps min_version values to crypto/tls versions. An unquoted
+// 1.0 decodes as the number 1.
+var tlsVersions = map[string]uint16{
+	"1":   tls.VersionTLS10,
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// pemMarker starts every PEM block; a value containing it is inline PEM
+// rather than a path.
+const pemMarker = "-----BEGIN"
+
+// TLSConfig represents the optional tls section of a request file: a client
+// certificate for mutual TLS, CA bundles to trust besides the system's, the
+// server name to verify, and the minimum TLS version.
+//
+// Cert, Key, and each CA are inline PEM, e.g. a vault secret through
+// {{.clientKey}}, or a file resolved like getFile: project-root relative,
+// or "*.pem" for the file of the same name next to the request file.
+type TLSConfig struct {
+	Cert string `json:"cert,omitempty"        yaml:"cert"`
+	Key  string `json:"key,omitempty"         yaml:"key"`
+	// CA is one bundle or a list of them.
+	CA CABundles `json:"ca,omitempty"          yaml:"ca"`
+	// ServerName replaces the URL's host when verifying the server's
+	// certificate, and is sent as SNI.
+	ServerName string `json:"server_name,omitempty" yaml:"server_name"`
+	// MinVersion is "1.0" to "1.3". Default "1.2".
+	MinVersion string `json:"min_version,omitempty" yaml:"min_version"`
+}
+
+// CABundles is a list of CA bundles, each inline PEM or a file.
+type CABundles []string
+
+// UnmarshalYAML accepts a single bundle or a list of bundles.
+func (c *CABundles) UnmarshalYAML(unmarshal func(any) error) error {
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		*c = list
+		return nil
+	}
+	var one string
+	if err := unmarshal(&one); err != nil {
+		return errors.New("ca must be a bundle or a list of bundles")
+	}
+	*c = CABundles{one}
+	return nil
+}
+
+// IsValid checks that cert and key are set together and that min_version
+// is known. A nil section is valid.
+func (t *TLSConfig) IsValid() (bool, error) {
+	if t == nil {
+		return true, nil
+	}
+	if (t.Cert == "") != (t.Key == "") {
+		return false, errors.New("cert and key must be set together")
+	}
+	if _, err := t.version(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// version returns MinVersion as a crypto/tls version.
+func (t *TLSConfig) version() (uint16, error) {
+	if t.MinVersion == "" {
+		return tls.VersionTLS12, nil
+	}
+	v, ok := tlsVersions[t.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("unknown min_version %q; use 1.0, 1.1, 1.2, or 1.3", t.MinVersion)
+	}
+	return v, nil
+}
+
+// over returns t with the settings it leaves unset taken from base. Cert
+// and key are one setting: a file with either replaces both.
+func (t *TLSConfig) over(base *TLSConfig) *TLSConfig {
+	switch {
+	case t == nil:
+		return base
+	case base == nil:
+		return t
+	}
+	merged := *t
+	if merged.Cert == "" && merged.Key == "" {
+		merged.Cert, merged.Key = base.Cert, base.Key
+	}
+	if len(merged.CA) == 0 {
+		merged.CA = base.CA
+	}
+	if merged.ServerName == "" {
+		merged.ServerName = base.ServerName
+	}
+	if merged.MinVersion == "" {
+		merged.MinVersion = base.MinVersion
+	}
+	return &merged
+}
+
+// envTLS returns the environment's tls section from secretsMap, or nil
+// when it has none.
+func envTLS(secretsMap map[string]any) (*TLSConfig, error) {
+	raw, ok := secretsMap[TLSSecret].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s secret: %w", TLSSecret, err)
+	}
+	var cfg TLSConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s secret: %w", TLSSecret, err)
+	}
+	if valid, err := cfg.IsValid(); !valid {
+		return nil, fmt.Errorf("invalid %s secret: %w", TLSSecret, err)
+	}
+	return &cfg, nil
+}
+
+// tlsConfigs caches built configs by their settings and the contents of
+// the files they name. Files with the same settings share one *tls.Config,
+// and with it one HTTP client and its connections.
+var tlsConfigs sync.Map
+
+// resolveTLS builds the TLS settings of the request file at filePath: its
+// tls section over the environment's (see TLSSecret), setting by setting.
+// It returns nil when neither has one.
+func resolveTLS(file *TLSConfig, secretsMap map[string]any, filePath string) (*tls.Config, error) {
+	env, err := envTLS(secretsMap)
+	if err != nil {
+		return nil, err
+	}
+	t := file.over(env)
+	if t == nil {
+		return nil, nil
+	}
+	return t.build(filePath)
+}
+
+// build reads the section's certificates and returns its *tls.Config.
+func (t *TLSConfig) build(filePath string) (*tls.Config, error) {
+	version, err := t.version()
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.New()
+	writePart(fingerprint, []byte(t.ServerName))
+	writePart(fingerprint, []byte(t.MinVersion))
+
+	var certPEM, keyPEM []byte
+	if t.Cert != "" {
+		if certPEM, err = readPEM(t.Cert, filePath); err != nil {
+			return nil, fmt.Errorf("cert: %w", err)
+		}
+		if keyPEM, err = readPEM(t.Key, filePath); err != nil {
+			return nil, fmt.Errorf("key: %w", err)
+		}
+	}
+	writePart(fingerprint, certPEM)
+	writePart(fingerprint, keyPEM)
+
+	bundles := make([][]byte, len(t.CA))
+	for i, ca := range t.CA {
+		if bundles[i], err = readPEM(ca, filePath); err != nil {
+			return nil, fmt.Errorf("ca: %w", err)
+		}
+		writePart(fingerprint, bundles[i])
+	}
+
+	key := string(fingerprint.Sum(nil))
+	if cfg, ok := tlsConfigs.Load(key); ok {
+		return cfg.(*tls.Config), nil
+	}
+
+	cfg := &tls.Config{MinVersion: version, ServerName: t.ServerName} //nolint:gosec // G402: min_version is the user's choice
+	if certPEM != nil {
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	if len(bundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for i, bundle := range bundles {
+			if !pool.AppendCertsFromPEM(bundle) {
+				return nil, fmt.Errorf("ca %s: no certificates found", pemSource(t.CA[i]))
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	actual, _ := tlsConfigs.LoadOrStore(key, cfg)
+	return actual.(*tls.Config), nil
+}
+
+// writePart adds b to h with its length, so parts can't run together.
+func writePart(h hash.Hash, b []byte) {
+	fmt.Fprintf(h, "%d:", len(b))
+	h.Write(b)
+}
+
+// readPEM returns value itself when it is inline PEM, otherwise the
+// contents of the file it names, resolved from the request file.
+func readPEM(value, filePath string) ([]byte, error) {
+	if strings.Contains(value, pemMarker) {
+		return []byte(value), nil
+	}
+	path, err := utils.ResolveRequestFile(filePath, value)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// pemSource names a cert, key, or CA value in errors without printing
+// inline PEM.
+func pemSource(value string) string {
+	if strings.Contains(value, pemMarker) {
+		return "(inline PEM)"
+	}
+	return value
+}
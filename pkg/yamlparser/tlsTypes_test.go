@@ -0,0 +1,227 @@
+package yamlparser
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// selfSigned returns a self-signed certificate and its key, as PEM.
+func selfSigned(t *testing.T, name string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestTLSConfig_IsValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     *TLSConfig
+		wantErr string
+	}{
+		{name: "nil section"},
+		{name: "cert and key", tls: &TLSConfig{Cert: "c.pem", Key: "k.pem", MinVersion: "1.3"}},
+		{name: "ca only", tls: &TLSConfig{CA: CABundles{"ca.pem"}}},
+		{name: "cert without key", tls: &TLSConfig{Cert: "c.pem"}, wantErr: "cert and key must be set together"},
+		{name: "key without cert", tls: &TLSConfig{Key: "k.pem"}, wantErr: "cert and key must be set together"},
+		{name: "unknown version", tls: &TLSConfig{MinVersion: "1.4"}, wantErr: `unknown min_version "1.4"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.tls.IsValid()
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v, want error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCABundles_UnmarshalYAML(t *testing.T) {
+	for name, tc := range map[string]struct {
+		yaml string
+		want int
+	}{
+		"one bundle": {"ca: certs/ca.pem\n", 1},
+		"a list":     {"ca: [certs/a.pem, certs/b.pem]\n", 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var cfg TLSConfig
+			if err := yaml.Unmarshal([]byte(tc.yaml), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.CA) != tc.want {
+				t.Errorf("ca = %q, want %d bundles", cfg.CA, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveTLS(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, utils.EnvironmentFolder), utils.DirPer); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	request := filepath.Join(root, "mtls", "orders.hk.yaml")
+	certPEM, keyPEM := selfSigned(t, "client")
+	caPEM, _ := selfSigned(t, "Test CA")
+	for name, content := range map[string]string{
+		"client.pem":     certPEM,
+		"client-key.pem": keyPEM,
+		"orders.pem":     caPEM,
+		"not-a-cert.pem": "hello",
+		"orders.hk.yaml": "url: https://x\n",
+	} {
+		path := filepath.Join(root, "mtls", name)
+		if err := os.MkdirAll(filepath.Dir(path), utils.DirPer); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	envSecret := map[string]any{
+		TLSSecret: map[string]any{"cert": certPEM, "key": keyPEM, "min_version": "1.3"},
+	}
+
+	tests := []struct {
+		name        string
+		file        *TLSConfig
+		secrets     map[string]any
+		wantNil     bool
+		wantCert    bool
+		wantCA      bool
+		wantVersion uint16
+		wantServer  string
+		wantErr     string
+	}{
+		{name: "neither file nor environment", wantNil: true},
+		{name: "non-object tls secret is ignored", secrets: map[string]any{TLSSecret: "on"}, wantNil: true},
+		{
+			name:        "files, with the sibling shorthand",
+			file:        &TLSConfig{Cert: "mtls/client.pem", Key: "mtls/client-key.pem", CA: CABundles{"*.pem"}, ServerName: "api.internal"},
+			wantCert:    true,
+			wantCA:      true,
+			wantVersion: tls.VersionTLS12,
+			wantServer:  "api.internal",
+		},
+		{
+			name:        "environment only",
+			secrets:     envSecret,
+			wantCert:    true,
+			wantVersion: tls.VersionTLS13,
+		},
+		{
+			name:        "file settings override the environment's one by one",
+			file:        &TLSConfig{CA: CABundles{caPEM}, MinVersion: "1.2"},
+			secrets:     envSecret,
+			wantCert:    true,
+			wantCA:      true,
+			wantVersion: tls.VersionTLS12,
+		},
+		{name: "missing file", file: &TLSConfig{CA: CABundles{"mtls/missing.pem"}}, wantErr: "file does not exist"},
+		{name: "bundle without certificates", file: &TLSConfig{CA: CABundles{"mtls/not-a-cert.pem"}}, wantErr: "ca mtls/not-a-cert.pem: no certificates found"},
+		{name: "mismatched key", file: &TLSConfig{Cert: certPEM, Key: caPEM}, wantErr: "client certificate"},
+		{
+			name:    "invalid environment secret",
+			secrets: map[string]any{TLSSecret: map[string]any{"cert": certPEM}},
+			wantErr: "invalid tls secret: cert and key must be set together",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := resolveTLS(tc.file, tc.secrets, request)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("resolveTLS() = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantNil {
+				if cfg != nil {
+					t.Fatalf("resolveTLS() = %+v, want nil", cfg)
+				}
+				return
+			}
+			if got := len(cfg.Certificates) == 1; got != tc.wantCert {
+				t.Errorf("client certificate set = %v, want %v", got, tc.wantCert)
+			}
+			if got := cfg.RootCAs != nil; got != tc.wantCA {
+				t.Errorf("RootCAs set = %v, want %v", got, tc.wantCA)
+			}
+			if cfg.MinVersion != tc.wantVersion {
+				t.Errorf("MinVersion = %x, want %x", cfg.MinVersion, tc.wantVersion)
+			}
+			if cfg.ServerName != tc.wantServer {
+				t.Errorf("ServerName = %q, want %q", cfg.ServerName, tc.wantServer)
+			}
+		})
+	}
+
+	t.Run("same settings share a config", func(t *testing.T) {
+		a, err := resolveTLS(&TLSConfig{CA: CABundles{"mtls/orders.pem"}}, nil, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := resolveTLS(&TLSConfig{CA: CABundles{caPEM}}, nil, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := resolveTLS(&TLSConfig{CA: CABundles{caPEM}, MinVersion: "1.3"}, nil, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != b {
+			t.Error("a file and the same inline PEM built two configs")
+		}
+		if a == c {
+			t.Error("different settings shared a config")
+		}
+	})
+}
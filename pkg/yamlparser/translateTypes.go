@@ -257,6 +257,13 @@ func translateType(
 		}
 
 		secretVal, exists := secretsMap[dotStringActionObj.KeyName]
+		if !exists {
+			// {{.captured.id}} lives one level down in a namespace map
+			ns, name, found := strings.Cut(dotStringActionObj.KeyName, ".")
+			if nsMap, isMap := secretsMap[ns].(map[string]any); found && isMap {
+				secretVal, exists = nsMap[name]
+			}
+		}
 		if !exists {
 			continue
 		}
@@ -0,0 +1,213 @@
+package yamlparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// FormFilePrefix marks a formdata value as a file to upload rather than a
+// text field, e.g. `avatar: "@file:images/avatar.png"`.
+const FormFilePrefix = "@file:"
+
+// uploadBody is a request body assembled from in-memory chunks and open
+// files, read in order. Its size is known up front so the request is sent
+// with a Content-Length instead of chunked. Closing it closes the files;
+// the HTTP client does that once the request is written.
+type uploadBody struct {
+	io.Reader
+	size  int64
+	files []*os.File
+}
+
+// Size is the total body length in bytes.
+func (u *uploadBody) Size() int64 {
+	return u.size
+}
+
+// Close closes every file the body reads from.
+func (u *uploadBody) Close() error {
+	var errs []error
+	for _, f := range u.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ResolveFiles resolves the files the body uploads (binary, and formdata
+// values starting with FormFilePrefix) to absolute paths. Paths resolve like
+// getFile: "*.png" is the file next to requestPath, anything else is
+// relative to the project root. A nil body has nothing to resolve.
+func (b *Body) ResolveFiles(requestPath string) error {
+	if b == nil {
+		return nil
+	}
+	if b.Binary != "" {
+		path, err := utils.ResolveRequestFile(requestPath, b.Binary)
+		if err != nil {
+			return fmt.Errorf("binary: %w", err)
+		}
+		b.Binary = path
+	}
+	for key, val := range b.FormData {
+		ref, isFile := strings.CutPrefix(val, FormFilePrefix)
+		if !isFile {
+			continue
+		}
+		path, err := utils.ResolveRequestFile(requestPath, strings.TrimSpace(ref))
+		if err != nil {
+			return fmt.Errorf("formdata %q: %w", key, err)
+		}
+		b.FormData[key] = FormFilePrefix + path
+	}
+	return nil
+}
+
+// hasFormFiles reports whether any formdata value is a file reference.
+func hasFormFiles(keyValue map[string]string) bool {
+	for _, val := range keyValue {
+		if strings.HasPrefix(val, FormFilePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeBinaryBody streams the file at path as the request body. The
+// content type comes from the file extension, or from sniffing the first
+// bytes when the extension is unknown.
+func encodeBinaryBody(path string) (io.Reader, string, error) {
+	f, info, err := openUpload(path)
+	if err != nil {
+		return nil, "", err
+	}
+	ct, err := detectContentType(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, "", err
+	}
+	return &uploadBody{Reader: f, size: info.Size(), files: []*os.File{f}}, ct, nil
+}
+
+// encodeMultipartFiles encodes formdata that includes file references. Text
+// fields are written as usual; each file becomes a file part with its base
+// name and detected content type, streamed from disk when the body is read.
+// Fields are written in key order.
+func encodeMultipartFiles(keyValue map[string]string) (io.Reader, string, error) {
+	var (
+		chunk  bytes.Buffer
+		parts  []io.Reader
+		body   = &uploadBody{}
+		writer = multipart.NewWriter(&chunk)
+	)
+	// cut moves the bytes written so far into their own part, so a file can
+	// be read between them.
+	cut := func() {
+		written := bytes.Clone(chunk.Bytes())
+		chunk.Reset()
+		parts = append(parts, bytes.NewReader(written))
+		body.size += int64(len(written))
+	}
+	fail := func(err error) (io.Reader, string, error) {
+		_ = body.Close()
+		return nil, "", err
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(keyValue)) {
+		val := keyValue[key]
+		if key == "" || val == "" {
+			continue
+		}
+		path, isFile := strings.CutPrefix(val, FormFilePrefix)
+		if !isFile {
+			if err := writer.WriteField(key, val); err != nil {
+				return fail(err)
+			}
+			continue
+		}
+
+		f, info, err := openUpload(path)
+		if err != nil {
+			return fail(fmt.Errorf("formdata %q: %w", key, err))
+		}
+		body.files = append(body.files, f)
+		ct, err := detectContentType(f)
+		if err != nil {
+			return fail(err)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(
+			`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(key), quoteEscaper.Replace(filepath.Base(path)),
+		))
+		header.Set("Content-Type", ct)
+		if _, err := writer.CreatePart(header); err != nil {
+			return fail(err)
+		}
+		cut()
+		parts = append(parts, f)
+		body.size += info.Size()
+	}
+	if err := writer.Close(); err != nil {
+		return fail(err)
+	}
+	cut()
+
+	body.Reader = io.MultiReader(parts...)
+	return body, writer.FormDataContentType(), nil
+}
+
+// quoteEscaper escapes a Content-Disposition parameter, as mime/multipart
+// does for CreateFormFile.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// openUpload opens a regular file for upload.
+func openUpload(path string) (*os.File, os.FileInfo, error) {
+	f, err := os.Open(path) //nolint:gosec // G304: the path comes from the user's own request file
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	return f, info, nil
+}
+
+// detectContentType picks a file's content type from its extension, then
+// by sniffing its first 512 bytes, then falls back to
+// application/octet-stream. The file is rewound afterwards.
+func detectContentType(f *os.File) (string, error) {
+	if ct := mime.TypeByExtension(filepath.Ext(f.Name())); ct != "" {
+		return ct, nil
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	// DetectContentType never returns "", and falls back to
+	// application/octet-stream for unrecognized bytes.
+	return http.DetectContentType(head[:n]), nil
+}
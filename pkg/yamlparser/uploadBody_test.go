@@ -0,0 +1,179 @@
+package yamlparser
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xaaha/hulak/pkg/utils"
+)
+
+// pngHeader is enough of a PNG for http.DetectContentType to recognize.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func writeUpload(t *testing.T, path string, content []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), utils.DirPer); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeBody_Binary(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		wantCT  string
+	}{
+		{"type from extension", "report.pdf", []byte("%PDF-1.7 ..."), "application/pdf"},
+		{"type sniffed without extension", "avatar", pngHeader, "image/png"},
+		{"unknown bytes", "blob", []byte{0x00, 0x01, 0x02}, "application/octet-stream"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.file)
+			writeUpload(t, path, tc.content)
+
+			body, ct, err := (&Body{Binary: path}).EncodeBody()
+			if err != nil {
+				t.Fatalf("EncodeBody: %v", err)
+			}
+			if ct != tc.wantCT {
+				t.Errorf("content type = %q, want %q", ct, tc.wantCT)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tc.content) {
+				t.Errorf("body = %q, want %q", got, tc.content)
+			}
+			if size := body.(*uploadBody).Size(); size != int64(len(tc.content)) {
+				t.Errorf("Size() = %d, want %d", size, len(tc.content))
+			}
+			if err := body.(io.Closer).Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+
+	if _, _, err := (&Body{Binary: filepath.Join(dir, "missing.bin")}).EncodeBody(); err == nil {
+		t.Error("expected an error for a missing file, got nil")
+	}
+}
+
+func TestEncodeFormData_Files(t *testing.T) {
+	dir := t.TempDir()
+	avatar := filepath.Join(dir, "avatar.png")
+	notes := filepath.Join(dir, "notes.txt")
+	writeUpload(t, avatar, pngHeader)
+	writeUpload(t, notes, []byte("hello"))
+
+	body, ct, err := EncodeFormData(map[string]string{
+		"name":   "Ada",
+		"avatar": FormFilePrefix + avatar,
+		"notes":  FormFilePrefix + notes,
+	})
+	if err != nil {
+		t.Fatalf("EncodeFormData: %v", err)
+	}
+	defer body.(io.Closer).Close()
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := body.(*uploadBody).Size(); size != int64(len(raw)) {
+		t.Errorf("Size() = %d, body is %d bytes", size, len(raw))
+	}
+
+	media, params, err := mime.ParseMediaType(ct)
+	if err != nil || media != "multipart/form-data" {
+		t.Fatalf("content type = %q (%v), want multipart/form-data", ct, err)
+	}
+
+	type part struct{ file, ct, content string }
+	got := map[string]part{}
+	reader := multipart.NewReader(bytes.NewReader(raw), params["boundary"])
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		content, _ := io.ReadAll(p)
+		got[p.FormName()] = part{p.FileName(), p.Header.Get("Content-Type"), string(content)}
+	}
+
+	want := map[string]part{
+		"avatar": {"avatar.png", "image/png", string(pngHeader)},
+		"name":   {"", "", "Ada"},
+		"notes":  {"notes.txt", "text/plain; charset=utf-8", "hello"},
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("part %q = %+v, want %+v", name, got[name], w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d parts, want %d", len(got), len(want))
+	}
+}
+
+func TestBodyResolveFiles(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, utils.EnvironmentFolder), utils.DirPer); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	request := filepath.Join(root, "uploads", "avatar.hk.yaml")
+	writeUpload(t, filepath.Join(root, "uploads", "avatar.png"), pngHeader)
+	writeUpload(t, filepath.Join(root, "files", "report.pdf"), []byte("%PDF"))
+
+	body := &Body{FormData: map[string]string{
+		"avatar": "@file:*.png",
+		"report": "@file: files/report.pdf",
+		"name":   "Ada",
+	}}
+	if err := body.ResolveFiles(request); err != nil {
+		t.Fatalf("ResolveFiles: %v", err)
+	}
+	if want := FormFilePrefix + filepath.Join(root, "uploads", "avatar.png"); body.FormData["avatar"] != want {
+		t.Errorf("avatar = %q, want %q", body.FormData["avatar"], want)
+	}
+	if want := FormFilePrefix + filepath.Join(root, "files", "report.pdf"); body.FormData["report"] != want {
+		t.Errorf("report = %q, want %q", body.FormData["report"], want)
+	}
+	if body.FormData["name"] != "Ada" {
+		t.Errorf("text field changed to %q", body.FormData["name"])
+	}
+
+	binary := &Body{Binary: "files/report.pdf"}
+	if err := binary.ResolveFiles(request); err != nil {
+		t.Fatalf("ResolveFiles binary: %v", err)
+	}
+	if want := filepath.Join(root, "files", "report.pdf"); binary.Binary != want {
+		t.Errorf("binary = %q, want %q", binary.Binary, want)
+	}
+
+	missing := &Body{Binary: "files/nope.bin"}
+	if err := missing.ResolveFiles(request); err == nil || !strings.Contains(err.Error(), "binary") {
+		t.Errorf("err = %v, want a binary resolution error", err)
+	}
+}
@@ -0,0 +1,200 @@
+package yamlparser
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/xaaha/hulak/pkg/httpclient"
+)
+
+// DefaultExpectTimeout is how long an `expect` step waits for a matching
+// message when its `timeout` is unset.
+const DefaultExpectTimeout = 10 * time.Second
+
+// WebSocketFile represents a request file of kind WebSocket: a connection
+// to URL and a script of messages to send and expect, in order. After the
+// script the connection is closed, or with an Events section kept open to
+// read more messages until one of its limits is reached.
+type WebSocketFile struct {
+	URL       URL               `json:"url,omitempty"          yaml:"url"`
+	URLParams map[string]string `json:"urlparams,omitempty"    yaml:"urlparams"`
+	// Headers are sent with the opening handshake, e.g. Authorization.
+	Headers map[string]string `json:"headers,omitempty"      yaml:"headers"`
+	// Auth adds credentials to the headers; see RequestAuth.
+	Auth *RequestAuth `json:"auth,omitempty"          yaml:"auth"`
+	// Subprotocols are offered in Sec-WebSocket-Protocol.
+	Subprotocols []string `json:"subprotocols,omitempty" yaml:"subprotocols"`
+	// Messages is the script. See WebSocketStep.
+	Messages []WebSocketStep `json:"messages,omitempty"     yaml:"messages"`
+	// Events keeps reading after the script. Its limits count messages
+	// received after the last step; see Events.
+	Events *Events `json:"events,omitempty"       yaml:"events"`
+	// TLS applies to wss:// connections; see TLSConfig.
+	TLS *TLSConfig `json:"tls,omitempty"          yaml:"tls"`
+	// Proxy tunnels the connection through a proxy; see ProxyConfig.
+	Proxy *ProxyConfig `json:"proxy,omitempty"        yaml:"proxy"`
+
+	// clientTLS and clientProxy are TLS and Proxy built over the
+	// environment's settings, and bearer the token of a use section.
+	clientTLS   *tls.Config
+	clientProxy *httpclient.Proxy
+	bearer      *BearerToken
+}
+
+// Bearer returns the token of an `auth: use:` section, read as the
+// connection opens, or nil. It is set by FinalStructForWebSocket.
+func (ws *WebSocketFile) Bearer() *BearerToken {
+	return ws.bearer
+}
+
+// ClientTLS returns the connection's TLS settings, or nil for the
+// defaults. It is set by FinalStructForWebSocket.
+func (ws *WebSocketFile) ClientTLS() *tls.Config {
+	return ws.clientTLS
+}
+
+// ClientProxy returns the proxy to connect through, or nil for the one in
+// the environment variables. It is set by FinalStructForWebSocket.
+func (ws *WebSocketFile) ClientProxy() *httpclient.Proxy {
+	return ws.clientProxy
+}
+
+// WebSocketStep is one step of a WebSocket script. Exactly one of Send,
+// JSON, or Expect is set.
+type WebSocketStep struct {
+	// Send is sent as a text message.
+	Send *string `json:"send,omitempty"    yaml:"send"`
+	// JSON is encoded and sent as a text message, for payloads easier to
+	// write as YAML.
+	JSON any `json:"json,omitempty"    yaml:"json"`
+	// Expect waits for a received message meeting its json and body
+	// checks. Messages that don't match are kept in the transcript and
+	// skipped; the step fails when Timeout passes or the connection closes
+	// first.
+	Expect *Assert `json:"expect,omitempty"  yaml:"expect"`
+	// Timeout bounds an expect step, as a Go duration. Default
+	// DefaultExpectTimeout.
+	Timeout string `json:"timeout,omitempty" yaml:"timeout"`
+}
+
+// IsValid checks the connection URL, the script, and the events limits.
+func (ws *WebSocketFile) IsValid(filePath string) (bool, error) {
+	if ws == nil {
+		return false, errors.New("requested websocket file is not valid")
+	}
+	u, err := url.Parse(string(ws.URL))
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return false, fmt.Errorf("missing or invalid URL: %s in file %s; use ws:// or wss://", ws.URL, filePath)
+	}
+	for i := range ws.Messages {
+		if err := ws.Messages[i].validate(); err != nil {
+			return false, fmt.Errorf("invalid messages[%d] in '%s': %w", i, filePath, err)
+		}
+	}
+	if valid, err := ws.Auth.IsValid(); !valid {
+		return false, fmt.Errorf("invalid auth section in '%s': %w", filePath, err)
+	}
+	if valid, err := ws.Events.IsValid(); !valid {
+		return false, fmt.Errorf("invalid events section in '%s': %w", filePath, err)
+	}
+	if valid, err := ws.TLS.IsValid(); !valid {
+		return false, fmt.Errorf("invalid tls section in '%s': %w", filePath, err)
+	}
+	if valid, err := ws.Proxy.IsValid(); !valid {
+		return false, fmt.Errorf("invalid proxy section in '%s': %w", filePath, err)
+	}
+	return true, nil
+}
+
+// validate checks that the step does exactly one thing.
+func (s *WebSocketStep) validate() error {
+	set := 0
+	for _, ok := range []bool{s.Send != nil, s.JSON != nil, s.Expect != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("needs exactly one of send, json, or expect")
+	}
+	if s.Expect == nil {
+		if s.Timeout != "" {
+			return errors.New("timeout only applies to expect")
+		}
+		return nil
+	}
+	e := s.Expect
+	if len(e.Status) > 0 || len(e.Headers) > 0 || e.MaxDuration != "" {
+		return errors.New("expect only supports json and body checks")
+	}
+	if len(e.JSON) == 0 && len(e.Body) == 0 {
+		return errors.New("expect needs at least one of json or body")
+	}
+	if valid, err := e.IsValid(); !valid {
+		return fmt.Errorf("expect: %w", err)
+	}
+	_, err := s.ParsedTimeout()
+	return err
+}
+
+// ParsedTimeout returns the expect timeout, or DefaultExpectTimeout when
+// unset.
+func (s *WebSocketStep) ParsedTimeout() (time.Duration, error) {
+	if s.Timeout == "" {
+		return DefaultExpectTimeout, nil
+	}
+	d, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("timeout %q: %w", s.Timeout, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("timeout must be positive, got %q", s.Timeout)
+	}
+	return d, nil
+}
+
+// Payload is the text a send or json step sends.
+func (s *WebSocketStep) Payload() (string, error) {
+	if s.Send != nil {
+		return *s.Send, nil
+	}
+	b, err := json.Marshal(s.JSON)
+	if err != nil {
+		return "", fmt.Errorf("encoding json message: %w", err)
+	}
+	return string(b), nil
+}
+
+// FinalStructForWebSocket builds and validates a WebSocket request file,
+// resolving templates with secretsMap like the other kinds.
+func FinalStructForWebSocket(filePath string, secretsMap map[string]any) (WebSocketFile, error) {
+	buf, err := checkYamlFile(filePath, secretsMap)
+	if err != nil {
+		return WebSocketFile{}, err
+	}
+
+	var file WebSocketFile
+	dec := yaml.NewDecoder(buf)
+	if err := dec.Decode(&file); err != nil {
+		return WebSocketFile{}, fmt.Errorf("decoding %s: %w", filePath, err)
+	}
+
+	if valid, err := file.IsValid(filePath); !valid {
+		return WebSocketFile{}, err
+	}
+	if err := file.applyAuth(secretsMap); err != nil {
+		return WebSocketFile{}, fmt.Errorf("auth in '%s': %w", filePath, err)
+	}
+	if file.clientTLS, err = resolveTLS(file.TLS, secretsMap, filePath); err != nil {
+		return WebSocketFile{}, fmt.Errorf("tls in '%s': %w", filePath, err)
+	}
+	if file.clientProxy, err = resolveProxy(file.Proxy, secretsMap); err != nil {
+		return WebSocketFile{}, fmt.Errorf("proxy in '%s': %w", filePath, err)
+	}
+	return file, nil
+}
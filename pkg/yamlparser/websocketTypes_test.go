@@ -0,0 +1,119 @@
+package yamlparser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSocketFile_IsValid(t *testing.T) {
+	hello := "hello"
+	bodyCheck := &Assert{Body: []string{"^hello$"}}
+
+	tests := []struct {
+		name    string
+		file    WebSocketFile
+		wantErr string
+	}{
+		{"url only", WebSocketFile{URL: "ws://localhost:8080/echo"}, ""},
+		{
+			"script",
+			WebSocketFile{URL: "wss://example.com/live", Messages: []WebSocketStep{
+				{Send: &hello},
+				{JSON: map[string]any{"op": "subscribe"}},
+				{Expect: bodyCheck, Timeout: "2s"},
+			}},
+			"",
+		},
+		{"http url", WebSocketFile{URL: "https://example.com"}, "use ws:// or wss://"},
+		{"missing url", WebSocketFile{}, "missing or invalid URL"},
+		{
+			"empty step",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{}}},
+			"messages[0] in 'ws.hk.yaml': needs exactly one of send, json, or expect",
+		},
+		{
+			"two actions in one step",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{Send: &hello, Expect: bodyCheck}}},
+			"needs exactly one of",
+		},
+		{
+			"timeout on a send",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{Send: &hello, Timeout: "1s"}}},
+			"timeout only applies to expect",
+		},
+		{
+			"status check",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{Expect: &Assert{Status: StatusCodes{200}}}}},
+			"expect only supports json and body checks",
+		},
+		{
+			"empty expect",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{Expect: &Assert{}}}},
+			"expect needs at least one of json or body",
+		},
+		{
+			"bad regex",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{Expect: &Assert{Body: []string{"("}}}}},
+			"expect: assert.body[0]",
+		},
+		{
+			"bad timeout",
+			WebSocketFile{URL: "ws://x", Messages: []WebSocketStep{{Expect: bodyCheck, Timeout: "0s"}}},
+			"timeout must be positive",
+		},
+		{
+			"bad events",
+			WebSocketFile{URL: "ws://x", Events: &Events{MaxEvents: -1}},
+			"invalid events section",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			valid, err := tc.file.IsValid("ws.hk.yaml")
+			if tc.wantErr == "" {
+				if !valid || err != nil {
+					t.Fatalf("IsValid() = %v, %v; want true, nil", valid, err)
+				}
+				return
+			}
+			if valid || err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("IsValid() = %v, %v; want false and error containing %q", valid, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFinalStructForWebSocket(t *testing.T) {
+	path := createTempYAMLFile(t, `kind: WebSocket
+url: "{{.wsUrl}}/feed"
+headers:
+  Authorization: Bearer {{.token}}
+messages:
+  - json:
+      op: subscribe
+      Symbols: [ABC]
+  - expect:
+      json:
+        - path: op
+          equals: subscribed
+`)
+	file, err := FinalStructForWebSocket(path, map[string]any{"wsUrl": "ws://localhost:9000", "token": "t0k"})
+	if err != nil {
+		t.Fatalf("FinalStructForWebSocket: %v", err)
+	}
+	if file.URL != "ws://localhost:9000/feed" || file.Headers["authorization"] != "Bearer t0k" {
+		t.Errorf("url %q, headers %v; want templates resolved", file.URL, file.Headers)
+	}
+	payload, err := file.Messages[0].Payload()
+	if err != nil || payload != `{"Symbols":["ABC"],"op":"subscribe"}` {
+		t.Errorf("Payload() = %q, %v; want the JSON with its key case kept", payload, err)
+	}
+	if d, _ := file.Messages[1].ParsedTimeout(); d != DefaultExpectTimeout {
+		t.Errorf("ParsedTimeout() = %v, want the %v default", d, DefaultExpectTimeout)
+	}
+	if d, _ := (&WebSocketStep{Timeout: "1m"}).ParsedTimeout(); d != time.Minute {
+		t.Errorf("ParsedTimeout() = %v, want 1m", d)
+	}
+}
@@ -121,7 +121,7 @@ func checkYamlFile(filepath string, secretsMap map[string]any) (*bytes.Buffer, e
 	}
 
 	// translate the types, if acceptable
-	parsedMap, err = translateType(data, parsedMap, secretsMap, actions.GetValueOf)
+	parsedMap, err = translateType(data, parsedMap, secretsMap, actions.GetValueOfWith(secretsMap))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", utils.ErrYAMLPostProcessing, err)
 	}
@@ -155,6 +155,22 @@ func FinalStructForAPI(filePath string, secretsMap map[string]any) (APICallFile,
 		return APICallFile{}, false, err
 	}
 
+	if err := file.Body.ResolveFiles(filePath); err != nil {
+		return APICallFile{}, false, fmt.Errorf("invalid body in '%s': %w", filePath, err)
+	}
+
+	if err := file.applyAuth(secretsMap); err != nil {
+		return APICallFile{}, false, fmt.Errorf("auth in '%s': %w", filePath, err)
+	}
+
+	if file.clientTLS, err = resolveTLS(file.TLS, secretsMap, filePath); err != nil {
+		return APICallFile{}, false, fmt.Errorf("tls in '%s': %w", filePath, err)
+	}
+
+	if file.clientProxy, err = resolveProxy(file.Proxy, secretsMap); err != nil {
+		return APICallFile{}, false, fmt.Errorf("proxy in '%s': %w", filePath, err)
+	}
+
 	return file, true, nil
 }
 
@@ -178,6 +194,14 @@ func FinalStructForOAuth2(
 	if valid, err := auth2Config.IsValid(); !valid {
 		return AuthRequestFile{}, fmt.Errorf("error on auth2 request body: %w", err)
 	}
+
+	if auth2Config.clientTLS, err = resolveTLS(auth2Config.TLS, secretsMap, filePath); err != nil {
+		return AuthRequestFile{}, fmt.Errorf("tls in '%s': %w", filePath, err)
+	}
+
+	if auth2Config.clientProxy, err = resolveProxy(auth2Config.Proxy, secretsMap); err != nil {
+		return AuthRequestFile{}, fmt.Errorf("proxy in '%s': %w", filePath, err)
+	}
 	return auth2Config, nil
 }
 
@@ -201,5 +225,17 @@ func FinalStructForGraphQL(filePath string, secretsMap map[string]any) (APICallF
 		return APICallFile{}, false, err
 	}
 
+	if err := file.applyAuth(secretsMap); err != nil {
+		return APICallFile{}, false, fmt.Errorf("auth in '%s': %w", filePath, err)
+	}
+
+	if file.clientTLS, err = resolveTLS(file.TLS, secretsMap, filePath); err != nil {
+		return APICallFile{}, false, fmt.Errorf("tls in '%s': %w", filePath, err)
+	}
+
+	if file.clientProxy, err = resolveProxy(file.Proxy, secretsMap); err != nil {
+		return APICallFile{}, false, fmt.Errorf("proxy in '%s': %w", filePath, err)
+	}
+
 	return file, true, nil
 }